@@ -0,0 +1,238 @@
+package aarch64codegen
+
+import (
+	"bytes"
+	"fmt"
+	"math/bits"
+	"strings"
+
+	"alon.kr/x/list"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+)
+
+// AssemblyInstruction is an AArch64 instruction that can be printed in the
+// GNU assembler syntax.
+type AssemblyInstruction interface {
+	gen.InstructionDefinition
+
+	// Returns the textual representation of the instruction, in the GNU
+	// assembler syntax, without indentation.
+	Assembly(ctx *InstructionCodegenContext) (string, core.ResultList)
+}
+
+// Returns the name of the symbol that represents the function in the
+// generated object or assembly file.
+func SymbolName(function *gen.FunctionInfo) string {
+	return strings.TrimPrefix(function.Name, "@")
+}
+
+// Returns the name of the symbol that represents the data global in the
+// generated object or assembly file.
+func DataSymbolName(global *gen.DataGlobalInfo) string {
+	return strings.TrimPrefix(global.Name(), "@")
+}
+
+// Returns the name of the local assembly label that represents the basic block
+// of the provided label.
+//
+// Labels are local to a function in usm, but are global to the whole file in
+// the GNU assembler syntax, so the index of the function is included in the
+// label. The function name can't be used instead, since both names may contain
+// underscores, and then different labels may map to the same name (for
+// example, ".b_c" in "@a" and ".c" in "@a_b").
+// Labels that start with ".L" are not included in the symbol table.
+func (ctx *FunctionCodegenContext) LabelName(label *gen.LabelInfo) string {
+	index := ctx.FunctionIndices[ctx.FunctionInfo]
+	return fmt.Sprintf(".L%d_%s", index, strings.TrimPrefix(label.Name, "."))
+}
+
+// Returns the textual representation of an instruction operand, in the GNU
+// assembler syntax.
+func (ctx *InstructionCodegenContext) OperandAssembly(
+	argument gen.ArgumentInfo,
+) string {
+	switch typedArgument := argument.(type) {
+	case *gen.RegisterArgumentInfo:
+		return ctx.RegisterAssembly(typedArgument.Register)
+	case *gen.ImmediateInfo:
+		return "#" + typedArgument.Value.String()
+	case *gen.LabelArgumentInfo:
+		return ctx.LabelName(typedArgument.Label)
+	case *gen.GlobalArgumentInfo:
+		return strings.TrimPrefix(typedArgument.Name(), "@")
+	default:
+		return argument.String()
+	}
+}
+
+func (ctx *InstructionCodegenContext) RegisterAssembly(
+	register *gen.RegisterInfo,
+) string {
+	return strings.TrimPrefix(register.Name, "%")
+}
+
+// Returns the textual representation of an instruction whose operands are its
+// targets, followed by its arguments, in order. This is the form of most
+// instructions, for example "add x0, x1, #5".
+func (ctx *InstructionCodegenContext) DefaultAssembly() string {
+	operands := []string{}
+	for _, target := range ctx.InstructionInfo.Targets {
+		operands = append(operands, ctx.RegisterAssembly(target.Register))
+	}
+
+	for _, argument := range ctx.InstructionInfo.Arguments {
+		operands = append(operands, ctx.OperandAssembly(argument))
+	}
+
+	return FormatAssembly(ctx.InstructionInfo.Definition.Operator(ctx.InstructionInfo), operands...)
+}
+
+// Formats an instruction with the provided mnemonic and operands.
+func FormatAssembly(mnemonic string, operands ...string) string {
+	if len(operands) == 0 {
+		return mnemonic
+	}
+
+	return mnemonic + "\t" + strings.Join(operands, ", ")
+}
+
+// MARK: Generation
+
+func (ctx *InstructionCodegenContext) Assembly(
+	buffer *bytes.Buffer,
+) core.ResultList {
+	instruction, ok := ctx.InstructionInfo.Definition.(AssemblyInstruction)
+	if !ok {
+		return list.FromSingle(core.Result{
+			{
+				Type:     core.InternalErrorResult,
+				Message:  "Instruction can't be printed as AArch64 assembly",
+				Location: ctx.Declaration,
+			},
+		})
+	}
+
+	text, results := instruction.Assembly(ctx)
+	if !results.IsEmpty() {
+		return results
+	}
+
+	buffer.WriteString("\t" + text + "\n")
+	return core.ResultList{}
+}
+
+func (ctx *FunctionCodegenContext) Assembly(
+	buffer *bytes.Buffer,
+) core.ResultList {
+	name := SymbolName(ctx.FunctionInfo)
+	fmt.Fprintf(buffer, "\t.globl\t%s\n", name)
+	fmt.Fprintf(buffer, "\t.p2align\t2\n")
+	fmt.Fprintf(buffer, "\t.type\t%s, %%function\n", name)
+	fmt.Fprintf(buffer, "%s:\n", name)
+
+	offset := uint64(0)
+	for _, block := range ctx.CollectBasicBlocks() {
+		if block.Label != nil {
+			fmt.Fprintf(buffer, "%s:\n", ctx.LabelName(block.Label))
+		}
+
+		for _, instruction := range block.Instructions {
+			instCtx := ctx.newInstructionCodegenContext(instruction, offset)
+			results := instCtx.Assembly(buffer)
+			if !results.IsEmpty() {
+				return results
+			}
+
+			offset += 4
+		}
+	}
+
+	fmt.Fprintf(buffer, "\t.size\t%s, .-%s\n", name, name)
+	return core.ResultList{}
+}
+
+// The maximal number of bytes in a single ".byte" directive.
+const assemblyBytesPerLine = 8
+
+// Writes the provided data globals to a section that starts with the provided
+// directive. Nothing is written if there are no globals.
+//
+// Each global is aligned with its own ".p2align" directive, which results in
+// the same layout as NewDataSection, so the initial values are taken from it.
+func dataSectionAssembly(
+	buffer *bytes.Buffer,
+	directive string,
+	globals []*gen.DataGlobalInfo,
+) core.ResultList {
+	if len(globals) == 0 {
+		return core.ResultList{}
+	}
+
+	section, results := NewDataSection(globals)
+	if !results.IsEmpty() {
+		return results
+	}
+
+	buffer.WriteString("\n\t" + directive + "\n")
+	for _, global := range section.Globals {
+		size, alignment, results := TypeLayout(global.Type)
+		if !results.IsEmpty() {
+			return results
+		}
+
+		name := DataSymbolName(global)
+		fmt.Fprintf(buffer, "\n\t.globl\t%s\n", name)
+		fmt.Fprintf(buffer, "\t.p2align\t%d\n", bits.TrailingZeros64(alignment))
+		fmt.Fprintf(buffer, "\t.type\t%s, %%object\n", name)
+		fmt.Fprintf(buffer, "\t.size\t%s, %d\n", name, size)
+		fmt.Fprintf(buffer, "%s:\n", name)
+
+		offset := section.Offsets[global]
+		data := section.Data[offset : offset+size]
+		for start := 0; start < len(data); start += assemblyBytesPerLine {
+			end := min(start+assemblyBytesPerLine, len(data))
+
+			values := []string{}
+			for _, value := range data[start:end] {
+				values = append(values, fmt.Sprintf("0x%02x", value))
+			}
+
+			fmt.Fprintf(buffer, "\t.byte\t%s\n", strings.Join(values, ", "))
+		}
+	}
+
+	return core.ResultList{}
+}
+
+// Writes the file in the GNU assembler syntax to the provided buffer.
+// Only defined functions are written: references to functions that are only
+// declared are resolved by the linker.
+//
+// Variables are written to the ".data" section, and constants to the
+// ".rodata" section, after all functions.
+func (ctx *FileCodegenContext) Assembly(
+	buffer *bytes.Buffer,
+) core.ResultList {
+	buffer.WriteString("\t.text\n")
+
+	for _, function := range ctx.OrderedFunctions {
+		if !function.IsDefined() {
+			continue
+		}
+
+		buffer.WriteString("\n")
+		funcCtx := ctx.newFunctionCodegenContext(function)
+		results := funcCtx.Assembly(buffer)
+		if !results.IsEmpty() {
+			return results
+		}
+	}
+
+	results := dataSectionAssembly(buffer, ".data", ctx.OrderedVariables)
+	if !results.IsEmpty() {
+		return results
+	}
+
+	return dataSectionAssembly(buffer, ".section\t.rodata", ctx.OrderedConstants)
+}
@@ -0,0 +1,177 @@
+package aarch64codegen
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+
+	"alon.kr/x/list"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+)
+
+// The size (in bits) of a pointer in AArch64.
+var pointerSize = big.NewInt(64)
+
+// DataSection contains the memory representation of a list of data globals
+// (variables or constants), which are placed in the same section of the
+// object file.
+type DataSection struct {
+	// The globals in the section, ordered by their offsets.
+	Globals []*gen.DataGlobalInfo
+
+	// The offset of each global in the section.
+	Offsets map[*gen.DataGlobalInfo]uint64
+
+	// The contents of the section, with the initial values of all globals.
+	Data []byte
+
+	// The alignment (in bytes) of the section: the maximal alignment of the
+	// globals in the section, and at least 1.
+	Alignment uint64
+}
+
+// Returns the data globals ordered by the location of their declaration in
+// the source, and then by their names.
+func orderDataGlobals(globals []*gen.DataGlobalInfo) []*gen.DataGlobalInfo {
+	slices.SortFunc(globals, func(a, b *gen.DataGlobalInfo) int {
+		if a.Declaration() != nil && b.Declaration() != nil {
+			if c := cmp.Compare(a.Declaration().Start, b.Declaration().Start); c != 0 {
+				return c
+			}
+		}
+
+		return cmp.Compare(a.Name(), b.Name())
+	})
+
+	return globals
+}
+
+func orderVariables(file *gen.FileInfo) []*gen.DataGlobalInfo {
+	globals := make([]*gen.DataGlobalInfo, 0, len(file.Variables))
+	for _, variable := range file.Variables {
+		globals = append(globals, &variable.DataGlobalInfo)
+	}
+
+	return orderDataGlobals(globals)
+}
+
+func orderConstants(file *gen.FileInfo) []*gen.DataGlobalInfo {
+	globals := make([]*gen.DataGlobalInfo, 0, len(file.Constants))
+	for _, constant := range file.Constants {
+		globals = append(globals, &constant.DataGlobalInfo)
+	}
+
+	return orderDataGlobals(globals)
+}
+
+// Returns the size and the alignment (both in bytes) of a value of the
+// provided type in memory, according to the type layout (see
+// gen.ReferencedTypeLayout).
+func TypeLayout(
+	typ gen.ReferencedTypeInfo,
+) (size uint64, alignment uint64, results core.ResultList) {
+	sizeBits, alignmentBits := gen.ReferencedTypeLayout(typ, pointerSize)
+
+	if sizeBits == nil || !sizeBits.IsUint64() || sizeBits.Uint64() > math.MaxUint64-7 {
+		return 0, 0, list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  fmt.Sprintf("Type \"%s\" can't be placed in memory", typ),
+				Location: typ.Declaration,
+			},
+		})
+	}
+
+	size = (sizeBits.Uint64() + 7) / 8
+	alignment = alignmentBits.Uint64() / 8
+	return size, alignment, core.ResultList{}
+}
+
+// Returns the offset (in bytes) of each element of the provided composite
+// initializer, relative to the start of the initializer.
+func elementOffsets(initializer *gen.InitializerInfo) []uint64 {
+	offsets := make([]uint64, len(initializer.Elements))
+
+	if initializer.IsStruct() {
+		for i := range offsets {
+			offsets[i] = initializer.Type.Base.Fields[i].Offset.Uint64() / 8
+		}
+		return offsets
+	}
+
+	// The types of composite initializers are placed in memory as a whole,
+	// so the layouts of their elements are valid.
+	size, alignment, _ := TypeLayout(initializer.Type.RepeatedElement())
+	stride := (size + alignment - 1) / alignment * alignment
+	for i := range offsets {
+		offsets[i] = uint64(i) * stride
+	}
+
+	return offsets
+}
+
+// Writes the memory representation of the provided scalar value, in little
+// endian two's complement form, to the provided buffer. The value is truncated
+// to the size of the buffer.
+func writeScalar(buffer []byte, value *big.Int) {
+	modulo := new(big.Int).Lsh(big.NewInt(1), uint(len(buffer))*8)
+	unsigned := new(big.Int).Mod(value, modulo)
+	unsigned.FillBytes(buffer)
+	slices.Reverse(buffer)
+}
+
+// Writes the memory representation of the provided initializer to the
+// provided buffer, which should be exactly of the size of the initializer
+// type. The buffer is expected to be zeroed.
+func writeInitializer(buffer []byte, initializer *gen.InitializerInfo) {
+	if initializer.IsScalar() {
+		writeScalar(buffer, initializer.Value)
+		return
+	}
+
+	offsets := elementOffsets(initializer)
+	for i, element := range initializer.Elements {
+		if element == nil {
+			continue
+		}
+
+		size, _, _ := TypeLayout(element.Type)
+		writeInitializer(buffer[offsets[i]:offsets[i]+size], element)
+	}
+}
+
+// Generates the memory representation of the provided globals, which are
+// placed one after the other, each aligned to the alignment of its type.
+func NewDataSection(
+	globals []*gen.DataGlobalInfo,
+) (*DataSection, core.ResultList) {
+	section := &DataSection{
+		Globals:   globals,
+		Offsets:   make(map[*gen.DataGlobalInfo]uint64, len(globals)),
+		Data:      []byte{},
+		Alignment: 1,
+	}
+
+	for _, global := range globals {
+		size, alignment, results := TypeLayout(global.Type)
+		if !results.IsEmpty() {
+			return nil, results
+		}
+
+		section.Alignment = max(section.Alignment, alignment)
+		offset := (uint64(len(section.Data)) + alignment - 1) / alignment * alignment
+		section.Offsets[global] = offset
+
+		padding := offset - uint64(len(section.Data))
+		section.Data = append(section.Data, make([]byte, padding+size)...)
+
+		if global.Initializer != nil {
+			writeInitializer(section.Data[offset:offset+size], global.Initializer)
+		}
+	}
+
+	return section, core.ResultList{}
+}
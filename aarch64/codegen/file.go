@@ -2,8 +2,9 @@ package aarch64codegen
 
 import (
 	"bytes"
+	"cmp"
+	"slices"
 
-	"alon.kr/x/macho/load/section64"
 	"alon.kr/x/usm/core"
 	"alon.kr/x/usm/gen"
 )
@@ -13,6 +14,16 @@ import (
 type FileCodegenContext struct {
 	*gen.FileInfo
 
+	// The functions of the file, in the order in which they are defined in the
+	// source. Code is generated in this order, and the function indices
+	// correspond to it.
+	OrderedFunctions []*gen.FunctionInfo
+
+	// The global variables and constants of the file, in the order in which
+	// they are defined in the source.
+	OrderedVariables []*gen.DataGlobalInfo
+	OrderedConstants []*gen.DataGlobalInfo
+
 	// The offset of each function in the file (object file), relative to the
 	// base object offset. It stores offsets of only defined functions.
 	FunctionOffsets map[*gen.FunctionInfo]uint64
@@ -23,19 +34,47 @@ type FileCodegenContext struct {
 
 	// The list of static relocations needed to be applied to the produced
 	// object file before it is linked to an executable.
-	Relocations []section64.RelocationBuilder
+	Relocations []Relocation
+}
+
+// Returns the functions of the file, ordered by the location of their
+// declaration in the source. Functions without a declaration are ordered
+// after all other functions, by their name.
+func orderFunctions(file *gen.FileInfo) []*gen.FunctionInfo {
+	functions := make([]*gen.FunctionInfo, 0, len(file.Functions))
+	for _, function := range file.Functions {
+		functions = append(functions, function)
+	}
+
+	slices.SortFunc(functions, func(a, b *gen.FunctionInfo) int {
+		if a.Declaration != nil && b.Declaration != nil {
+			if c := cmp.Compare(a.Declaration.Start, b.Declaration.Start); c != 0 {
+				return c
+			}
+		} else if a.Declaration != nil {
+			return -1
+		} else if b.Declaration != nil {
+			return 1
+		}
+
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	return functions
 }
 
 func NewFileCodegenContext(file *gen.FileInfo) *FileCodegenContext {
+	functions := orderFunctions(file)
 	functionOffsets := make(map[*gen.FunctionInfo]uint64)
-	functionIndices := make(map[*gen.FunctionInfo]uint32, len(file.Functions))
+	functionIndices := make(map[*gen.FunctionInfo]uint32, len(functions))
 
 	offset := uint64(0)
 	idx := uint32(0)
-	for _, function := range file.Functions {
+	for _, function := range functions {
+		functionIndices[function] = idx
+
 		if function.IsDefined() {
 			functionOffsets[function] = offset
-			functionIndices[function] = idx
 
 			functionSize := uint64(function.Size()) * 4 // TODO: handle overflow?
 			offset += functionSize
@@ -45,10 +84,13 @@ func NewFileCodegenContext(file *gen.FileInfo) *FileCodegenContext {
 	}
 
 	return &FileCodegenContext{
-		FileInfo:        file,
-		FunctionOffsets: functionOffsets,
-		FunctionIndices: functionIndices,
-		Relocations:     []section64.RelocationBuilder{},
+		FileInfo:         file,
+		OrderedFunctions: functions,
+		OrderedVariables: orderVariables(file),
+		OrderedConstants: orderConstants(file),
+		FunctionOffsets:  functionOffsets,
+		FunctionIndices:  functionIndices,
+		Relocations:      []Relocation{},
 	}
 }
 
@@ -77,7 +119,7 @@ func (ctx *FileCodegenContext) newFunctionCodegenContext(
 func (ctx *FileCodegenContext) Codegen(
 	buffer *bytes.Buffer,
 ) core.ResultList {
-	for _, function := range ctx.Functions {
+	for _, function := range ctx.OrderedFunctions {
 		funcCtx := ctx.newFunctionCodegenContext(function)
 		results := funcCtx.Codegen(buffer)
 		if !results.IsEmpty() {
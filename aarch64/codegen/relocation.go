@@ -0,0 +1,44 @@
+package aarch64codegen
+
+import "alon.kr/x/usm/gen"
+
+type RelocationType uint8
+
+const (
+	// A 26 bit, 4 byte aligned, PC relative offset of a "bl" instruction.
+	CallRelocation RelocationType = iota
+
+	// A 26 bit, 4 byte aligned, PC relative offset of a "b" instruction.
+	JumpRelocation
+
+	// A 21 bit, PC relative offset of the 4KB page of the symbol, of an "adrp"
+	// instruction.
+	PageRelocation
+
+	// The low 12 bits of the address of the symbol (its offset in its 4KB
+	// page), in the immediate of an "add" instruction.
+	PageOffsetRelocation
+)
+
+// Relocation is a reference from the generated code to a function or a data
+// global symbol, which is not resolved while generating the code, and should
+// be resolved by the linker.
+//
+// Relocations are collected independently of the object file format, and are
+// converted to the format specific representation when the object file is
+// written.
+type Relocation struct {
+	// The offset of the instruction that should be patched, relative to the
+	// start of the generated code.
+	Offset uint64
+
+	// The function whose address is referenced by the instruction, or nil if
+	// the instruction references a data global.
+	Function *gen.FunctionInfo
+
+	// The data global (variable or constant) whose address is referenced by
+	// the instruction, or nil if the instruction references a function.
+	Data *gen.DataGlobalInfo
+
+	Type RelocationType
+}
@@ -45,41 +45,107 @@ func (add Add) codegenImmediateVariant(
 	return inst, core.ResultList{}
 }
 
-func (add Add) Codegen(
-	ctx *aarch64codegen.InstructionCodegenContext,
-) (instructions.Instruction, core.ResultList) {
+// Adds the offset of a function or of a data global in its 4KB page to the
+// provided register, which should hold the address of the page (see Adrp).
+// The offset is filled by the linker, so a page offset relocation is returned.
+func (add Add) codegenPageOffsetVariant(
+	info *gen.InstructionInfo,
+) (instructions.Instruction, *aarch64codegen.Relocation, core.ResultList) {
+	Xd, results := aarch64translation.TargetToAarch64GPorSPRegister(info.Targets[0])
+
+	Xn, curResults := aarch64translation.ArgumentToAarch64GPorSPRegister(info.Arguments[0])
+	results.Extend(&curResults)
+
+	function, global, curResults := aarch64translation.ArgumentToAddressableGlobal(info.Arguments[1])
+	results.Extend(&curResults)
+
+	if !results.IsEmpty() {
+		return nil, nil, results
+	}
+
+	relocation := &aarch64codegen.Relocation{
+		Function: function,
+		Data:     global,
+		Type:     aarch64codegen.PageOffsetRelocation,
+	}
+
+	return instructions.NewAddImmediate(Xd, Xn, 0), relocation, core.ResultList{}
+}
+
+// Returns the binary representation of the instruction, and the relocation
+// of the page offset that is added by it, if there is one. The offset of the
+// returned relocation is not set.
+func (add Add) codegen(
+	info *gen.InstructionInfo,
+) (instructions.Instruction, *aarch64codegen.Relocation, core.ResultList) {
 	// TODO: this implementation is very similar to the one in adds.go, and possibly
 	// other binary arithmetic instructions. Consider refactoring this.
 
-	info := ctx.InstructionInfo
 	results := aarch64translation.ValidateBinaryInstruction(info)
 	if !results.IsEmpty() {
-		return nil, results
+		return nil, nil, results
 	}
 
 	switch info.Arguments[1].(type) {
 	case *gen.RegisterArgumentInfo:
-		return add.codegenRegisterVariant(info)
+		inst, results := add.codegenRegisterVariant(info)
+		return inst, nil, results
 	case *gen.ImmediateInfo:
-		return add.codegenImmediateVariant(info)
+		inst, results := add.codegenImmediateVariant(info)
+		return inst, nil, results
+	case *gen.GlobalArgumentInfo:
+		return add.codegenPageOffsetVariant(info)
 	default:
-		return nil, list.FromSingle(core.Result{
+		return nil, nil, list.FromSingle(core.Result{
 			{
 				Type:     core.ErrorResult,
-				Message:  "Second \"add\" argument must be a register or immediate",
+				Message:  "Second \"add\" argument must be a register, immediate or global",
 				Location: info.Arguments[1].Declaration(),
 			},
 		})
 	}
 }
 
+func (add Add) Codegen(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (instructions.Instruction, core.ResultList) {
+	inst, relocation, results := add.codegen(ctx.InstructionInfo)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	if relocation != nil {
+		relocation.Offset = ctx.InstructionOffsetInFile()
+		ctx.Relocations = append(ctx.Relocations, *relocation)
+	}
+
+	return inst, core.ResultList{}
+}
+
 func (add Add) Validate(
 	info *gen.InstructionInfo,
 ) core.ResultList {
-	// TODO: this is a pretty hacky way to validate the instruction: we create
-	// a "mock" generation context, and then try to generate the binary
-	// representation of the instruction.
-	ctx := aarch64codegen.InstructionCodegenContext{InstructionInfo: info}
-	_, results := add.Codegen(&ctx)
+	_, _, results := add.codegen(info)
 	return results
 }
+
+func (add Add) Assembly(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (string, core.ResultList) {
+	_, relocation, results := add.codegen(ctx.InstructionInfo)
+	if !results.IsEmpty() {
+		return "", results
+	}
+
+	if relocation != nil {
+		info := ctx.InstructionInfo
+		return aarch64codegen.FormatAssembly(
+			add.Operator(info),
+			ctx.RegisterAssembly(info.Targets[0].Register),
+			ctx.OperandAssembly(info.Arguments[0]),
+			":lo12:"+ctx.OperandAssembly(info.Arguments[1]),
+		), core.ResultList{}
+	}
+
+	return ctx.DefaultAssembly(), core.ResultList{}
+}
@@ -67,6 +67,44 @@ func assertExpectedCodegen(
 	assert.Equal(t, expected.Binary(), code.Binary())
 }
 
+// assertExpectedBinary is similar to assertExpectedCodegen, but compares the
+// generated code to a raw binary instruction. This is used for instructions
+// that are encoded directly in the aarch64isa package.
+func assertExpectedBinary(
+	t *testing.T,
+	def gen.InstructionDefinition,
+	expected uint32,
+	src string,
+) {
+	info, inst := buildInstructionFromSource(t, def, src)
+
+	generationContext := &aarch64codegen.InstructionCodegenContext{
+		InstructionInfo: info,
+	}
+
+	code, results := inst.Codegen(generationContext)
+	assert.True(t, results.IsEmpty())
+
+	assert.Equal(t, expected, code.Binary())
+}
+
+type binaryTestCase struct {
+	src      string
+	expected uint32
+}
+
+func runExpectedBinaryTests(
+	t *testing.T,
+	def gen.InstructionDefinition,
+	testCases []binaryTestCase,
+) {
+	for idx, testCase := range testCases {
+		t.Run(fmt.Sprint(idx), func(t *testing.T) {
+			assertExpectedBinary(t, def, testCase.expected, testCase.src)
+		})
+	}
+}
+
 func TestAddExpectedCodegen(t *testing.T) {
 	def := aarch64isa.NewAdd()
 
@@ -83,3 +83,14 @@ func (adds Adds) Validate(
 	_, results := adds.Codegen(&ctx)
 	return results
 }
+
+func (adds Adds) Assembly(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (string, core.ResultList) {
+	_, results := adds.Codegen(ctx)
+	if !results.IsEmpty() {
+		return "", results
+	}
+
+	return ctx.DefaultAssembly(), core.ResultList{}
+}
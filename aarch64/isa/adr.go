@@ -0,0 +1,134 @@
+package aarch64isa
+
+import (
+	"alon.kr/x/aarch64codegen/instructions"
+	"alon.kr/x/aarch64codegen/registers"
+	"alon.kr/x/list"
+	aarch64codegen "alon.kr/x/usm/aarch64/codegen"
+	aarch64translation "alon.kr/x/usm/aarch64/translation"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+)
+
+// The maximal absolute byte offset that can be encoded in an ADR instruction.
+const maxAdrOffset = 1 << 20
+
+// Computes the address of a label, or of a function that is defined in the
+// same file, relative to the program counter.
+//
+// For example: "%x17 = adr .table" sets x17 to the address of ".table", and
+// "%x0 = adr @f" sets x0 to the address of the function "@f".
+type Adr struct {
+	gen.NonBranchingInstruction
+}
+
+func NewAdr() gen.InstructionDefinition {
+	return Adr{}
+}
+
+func (Adr) Operator(*gen.InstructionInfo) string {
+	return "adr"
+}
+
+// Returns the destination register of the instruction. The argument is
+// returned as a function if it is a global argument, and as a label otherwise.
+func (Adr) Operands(
+	info *gen.InstructionInfo,
+) (
+	Xd registers.GPRegister,
+	label *gen.LabelInfo,
+	function *gen.FunctionInfo,
+	results core.ResultList,
+) {
+	results = gen.AssertTargetsExactly(info, 1)
+
+	curResults := gen.AssertArgumentsExactly(info, 1)
+	results.Extend(&curResults)
+
+	if !results.IsEmpty() {
+		return
+	}
+
+	Xd, curResults = aarch64translation.TargetToAarch64GPRegister(info.Targets[0])
+	results.Extend(&curResults)
+
+	if _, ok := info.Arguments[0].(*gen.GlobalArgumentInfo); ok {
+		function, curResults = aarch64translation.ArgumentToFunctionInfo(info.Arguments[0])
+	} else {
+		label, curResults = aarch64translation.ArgumentToLabelInfo(info.Arguments[0])
+	}
+
+	results.Extend(&curResults)
+	return
+}
+
+// Returns the offset of the argument of the instruction from the instruction
+// itself, in bytes.
+func (Adr) offset(
+	ctx *aarch64codegen.InstructionCodegenContext,
+	label *gen.LabelInfo,
+	function *gen.FunctionInfo,
+) (int64, core.ResultList) {
+	if function == nil {
+		targetOffset := ctx.BasicBlockOffsets[label.BasicBlock]
+		return int64(targetOffset) - int64(ctx.InstructionOffsetInFunction), core.ResultList{}
+	}
+
+	targetOffset, ok := ctx.FunctionOffsets[function]
+	if !ok {
+		return 0, list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Address of a function that is not defined in the file is not supported",
+				Location: ctx.Declaration,
+			},
+		})
+	}
+
+	return int64(targetOffset) - int64(ctx.InstructionOffsetInFile()), core.ResultList{}
+}
+
+func (i Adr) Codegen(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (instructions.Instruction, core.ResultList) {
+	Xd, label, function, results := i.Operands(ctx.InstructionInfo)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	offset, results := i.offset(ctx, label, function)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	if offset < -maxAdrOffset || offset >= maxAdrOffset {
+		return nil, list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Address offset too large",
+				Location: ctx.Declaration,
+			},
+		})
+	}
+
+	imm := uint32(offset) & 0x1FFFFF
+	return rawInstruction(
+		0x10000000 | (imm&3)<<29 | (imm>>2)<<5 | uint32(Xd),
+	), core.ResultList{}
+}
+
+func (i Adr) Validate(info *gen.InstructionInfo) core.ResultList {
+	_, _, _, results := i.Operands(info)
+	return results
+}
+
+func (i Adr) Assembly(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (string, core.ResultList) {
+	results := i.Validate(ctx.InstructionInfo)
+	if !results.IsEmpty() {
+		return "", results
+	}
+
+	return ctx.DefaultAssembly(), core.ResultList{}
+}
@@ -0,0 +1,94 @@
+package aarch64isa
+
+import (
+	"alon.kr/x/aarch64codegen/instructions"
+	"alon.kr/x/aarch64codegen/registers"
+	aarch64codegen "alon.kr/x/usm/aarch64/codegen"
+	aarch64translation "alon.kr/x/usm/aarch64/translation"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+)
+
+// Computes the address of the 4KB page of a function, or of a global variable
+// or constant, relative to the program counter. The page offset of the global
+// should be added to the result, with an "add" instruction.
+//
+// For example: "%x0 = adrp @counter" followed by "%x0 = add %x0 @counter"
+// sets x0 to the address of "@counter".
+//
+// The address is always resolved by the linker, using a relocation, so the
+// global may be defined in another file, and may be placed anywhere in a range
+// of 4GB from the instruction.
+type Adrp struct {
+	gen.NonBranchingInstruction
+}
+
+func NewAdrp() gen.InstructionDefinition {
+	return Adrp{}
+}
+
+func (Adrp) Operator(*gen.InstructionInfo) string {
+	return "adrp"
+}
+
+// Returns the destination register of the instruction, and either the
+// function or the data global whose page address is computed.
+func (Adrp) Operands(
+	info *gen.InstructionInfo,
+) (
+	Xd registers.GPRegister,
+	function *gen.FunctionInfo,
+	global *gen.DataGlobalInfo,
+	results core.ResultList,
+) {
+	results = gen.AssertTargetsExactly(info, 1)
+
+	curResults := gen.AssertArgumentsExactly(info, 1)
+	results.Extend(&curResults)
+
+	if !results.IsEmpty() {
+		return
+	}
+
+	Xd, curResults = aarch64translation.TargetToAarch64GPRegister(info.Targets[0])
+	results.Extend(&curResults)
+
+	function, global, curResults = aarch64translation.ArgumentToAddressableGlobal(info.Arguments[0])
+	results.Extend(&curResults)
+	return
+}
+
+func (i Adrp) Codegen(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (instructions.Instruction, core.ResultList) {
+	Xd, function, global, results := i.Operands(ctx.InstructionInfo)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	ctx.Relocations = append(ctx.Relocations, aarch64codegen.Relocation{
+		Offset:   ctx.InstructionOffsetInFile(),
+		Function: function,
+		Data:     global,
+		Type:     aarch64codegen.PageRelocation,
+	})
+
+	// The page offset is filled by the linker.
+	return rawInstruction(0x90000000 | uint32(Xd)), core.ResultList{}
+}
+
+func (i Adrp) Validate(info *gen.InstructionInfo) core.ResultList {
+	_, _, _, results := i.Operands(info)
+	return results
+}
+
+func (i Adrp) Assembly(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (string, core.ResultList) {
+	results := i.Validate(ctx.InstructionInfo)
+	if !results.IsEmpty() {
+		return "", results
+	}
+
+	return ctx.DefaultAssembly(), core.ResultList{}
+}
@@ -0,0 +1,18 @@
+package aarch64isa
+
+import (
+	"alon.kr/x/usm/gen"
+)
+
+// Bitwise AND of two registers (Xd = Xn & Xm).
+type And struct {
+	binaryRegisterInstruction
+}
+
+func NewAnd() gen.InstructionDefinition {
+	return And{binaryRegisterInstruction{opcode: 0x8A000000}}
+}
+
+func (And) Operator(*gen.InstructionInfo) string {
+	return "and"
+}
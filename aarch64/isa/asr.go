@@ -0,0 +1,27 @@
+package aarch64isa
+
+import (
+	"alon.kr/x/aarch64codegen/instructions"
+	"alon.kr/x/aarch64codegen/registers"
+	"alon.kr/x/usm/gen"
+)
+
+// Arithmetic shift right by an immediate amount (Xd = Xn >> #shift), which
+// fills the upper bits with copies of the sign bit.
+//
+// This is an alias of "sbfm Xd, Xn, #shift, #63".
+type Asr struct {
+	shiftImmediateInstruction
+}
+
+func NewAsr() gen.InstructionDefinition {
+	return Asr{shiftImmediateInstruction{encode: encodeAsr}}
+}
+
+func encodeAsr(Xd, Xn registers.GPRegister, shift uint8) instructions.Instruction {
+	return encodeSbfm(Xd, Xn, shift, 63)
+}
+
+func (Asr) Operator(*gen.InstructionInfo) string {
+	return "asr"
+}
@@ -35,9 +35,32 @@ func (i Branch) Target(
 	return label, core.ResultList{}
 }
 
+// Returns the function that the branch jumps to, and true, if the branch is a
+// tail call to a function (and not a jump to a label in the current function).
+func (i Branch) FunctionTarget(
+	info *gen.InstructionInfo,
+) (*gen.FunctionInfo, bool) {
+	if len(info.Arguments) != 1 {
+		return nil, false
+	}
+
+	if _, ok := info.Arguments[0].(*gen.GlobalArgumentInfo); !ok {
+		return nil, false
+	}
+
+	function, results := aarch64translation.ArgumentToFunctionInfo(info.Arguments[0])
+	return function, results.IsEmpty()
+}
+
 func (i Branch) PossibleNextSteps(
 	info *gen.InstructionInfo,
 ) (gen.StepInfo, core.ResultList) {
+	if _, ok := i.FunctionTarget(info); ok {
+		// Execution continues in the target function, which returns to the
+		// caller of the current function.
+		return gen.StepInfo{PossibleReturn: true}, core.ResultList{}
+	}
+
 	target, results := i.Target(info)
 	return gen.StepInfo{
 		PossibleBranches: []*gen.LabelInfo{target},
@@ -70,11 +93,52 @@ func (i Branch) internalValidate(
 	return artifacts, core.ResultList{}
 }
 
+func (i Branch) functionCodegen(
+	ctx *aarch64codegen.InstructionCodegenContext,
+	target *gen.FunctionInfo,
+) (instructions.Instruction, core.ResultList) {
+	targetOffset, ok := ctx.FunctionOffsets[target]
+	if !ok {
+		// Target function is not defined: we add a relocation to the symbol
+		// and let the linker resolve it.
+		ctx.Relocations = append(ctx.Relocations, aarch64codegen.Relocation{
+			Offset:   ctx.InstructionOffsetInFile(),
+			Function: target,
+			Type:     aarch64codegen.JumpRelocation,
+		})
+
+		return instructions.NewBranch(0), core.ResultList{}
+	}
+
+	currentOffset := ctx.InstructionOffsetInFile()
+	offset, err := aarch64translation.Uint64DiffToOffset26Align4(targetOffset, currentOffset)
+
+	if err != nil {
+		return nil, list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Branch offset too large",
+				Location: ctx.Declaration,
+			},
+			{
+				Type:    core.DebugResult,
+				Message: err.Error(),
+			},
+		})
+	}
+
+	return instructions.NewBranch(offset), core.ResultList{}
+}
+
 func (i Branch) Codegen(
 	ctx *aarch64codegen.InstructionCodegenContext,
 ) (instructions.Instruction, core.ResultList) {
 	info := ctx.InstructionInfo
 
+	if function, ok := i.FunctionTarget(info); ok {
+		return i.functionCodegen(ctx, function)
+	}
+
 	artifacts, results := i.internalValidate(info)
 	if !results.IsEmpty() {
 		return nil, results
@@ -107,6 +171,21 @@ func (i Branch) Codegen(
 func (i Branch) Validate(
 	info *gen.InstructionInfo,
 ) core.ResultList {
+	if _, ok := i.FunctionTarget(info); ok {
+		return gen.AssertTargetsExactly(info, 0)
+	}
+
 	_, results := i.internalValidate(info)
 	return results
 }
+
+func (i Branch) Assembly(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (string, core.ResultList) {
+	results := i.Validate(ctx.InstructionInfo)
+	if !results.IsEmpty() {
+		return "", results
+	}
+
+	return ctx.DefaultAssembly(), core.ResultList{}
+}
@@ -100,3 +100,14 @@ func (b Bcond) Validate(
 
 	return core.ResultList{}
 }
+
+func (b Bcond) Assembly(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (string, core.ResultList) {
+	results := b.Validate(ctx.InstructionInfo)
+	if !results.IsEmpty() {
+		return "", results
+	}
+
+	return ctx.DefaultAssembly(), core.ResultList{}
+}
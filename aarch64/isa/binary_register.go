@@ -0,0 +1,54 @@
+package aarch64isa
+
+import (
+	"alon.kr/x/aarch64codegen/instructions"
+	aarch64codegen "alon.kr/x/usm/aarch64/codegen"
+	aarch64translation "alon.kr/x/usm/aarch64/translation"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+)
+
+// binaryRegisterInstruction implements the shared functionality of
+// instructions that accept exactly two general purpose register arguments and
+// define a single general purpose register target (Xd = op Xn Xm).
+type binaryRegisterInstruction struct {
+	gen.NonBranchingInstruction
+
+	opcode uint32
+}
+
+func (i binaryRegisterInstruction) Codegen(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (instructions.Instruction, core.ResultList) {
+	info := ctx.InstructionInfo
+	results := aarch64translation.ValidateBinaryInstruction(info)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	Xd, Xn, Xm, results := aarch64translation.BinaryInstructionToAarch64(info)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return encodeThreeRegisters(i.opcode, Xd, Xn, Xm), core.ResultList{}
+}
+
+func (i binaryRegisterInstruction) Validate(
+	info *gen.InstructionInfo,
+) core.ResultList {
+	ctx := aarch64codegen.InstructionCodegenContext{InstructionInfo: info}
+	_, results := i.Codegen(&ctx)
+	return results
+}
+
+func (i binaryRegisterInstruction) Assembly(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (string, core.ResultList) {
+	_, results := i.Codegen(ctx)
+	if !results.IsEmpty() {
+		return "", results
+	}
+
+	return ctx.DefaultAssembly(), core.ResultList{}
+}
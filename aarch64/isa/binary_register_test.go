@@ -0,0 +1,34 @@
+package aarch64isa_test
+
+import (
+	"testing"
+
+	aarch64isa "alon.kr/x/usm/aarch64/isa"
+)
+
+func TestMulExpectedCodegen(t *testing.T) {
+	runExpectedBinaryTests(t, aarch64isa.NewMul(), []binaryTestCase{
+		{"%x0 = mul %x1 %x2\n", 0x9b027c20},
+		{"%xzr = mul %xzr %xzr\n", 0x9b1f7fff},
+	})
+}
+
+func TestBitwiseExpectedCodegen(t *testing.T) {
+	t.Run("and", func(t *testing.T) {
+		runExpectedBinaryTests(t, aarch64isa.NewAnd(), []binaryTestCase{
+			{"%x0 = and %x1 %x2\n", 0x8a020020},
+		})
+	})
+
+	t.Run("orr", func(t *testing.T) {
+		runExpectedBinaryTests(t, aarch64isa.NewOrr(), []binaryTestCase{
+			{"%x3 = orr %x4 %x5\n", 0xaa050083},
+		})
+	})
+
+	t.Run("eor", func(t *testing.T) {
+		runExpectedBinaryTests(t, aarch64isa.NewEor(), []binaryTestCase{
+			{"%x30 = eor %x29 %x28\n", 0xca1c03be},
+		})
+	})
+}
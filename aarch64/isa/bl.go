@@ -3,7 +3,6 @@ package aarch64isa
 import (
 	"alon.kr/x/aarch64codegen/instructions"
 	"alon.kr/x/list"
-	"alon.kr/x/macho/load/section64"
 	aarch64codegen "alon.kr/x/usm/aarch64/codegen"
 	aarch64translation "alon.kr/x/usm/aarch64/translation"
 	"alon.kr/x/usm/core"
@@ -62,13 +61,10 @@ func (b Bl) registerRelocation(
 		return results
 	}
 
-	relocation := section64.RelocationBuilder{
-		Address:                uint32(ctx.InstructionOffsetInFile()),
-		SymbolIndex:            ctx.FunctionIndices[target],
-		IsRelocationPcRelative: true,
-		Length:                 section64.RelocationLengthLong,
-		IsRelocationExtern:     true,
-		Type:                   section64.RelocationTypeArm64Branch26,
+	relocation := aarch64codegen.Relocation{
+		Offset:   ctx.InstructionOffsetInFile(),
+		Function: target,
+		Type:     aarch64codegen.CallRelocation,
 	}
 
 	ctx.Relocations = append(ctx.Relocations, relocation)
@@ -114,3 +110,14 @@ func (b Bl) Codegen(
 
 	return instructions.NewBl(offset), core.ResultList{}
 }
+
+func (b Bl) Assembly(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (string, core.ResultList) {
+	results := b.Validate(ctx.InstructionInfo)
+	if !results.IsEmpty() {
+		return "", results
+	}
+
+	return ctx.DefaultAssembly(), core.ResultList{}
+}
@@ -0,0 +1,66 @@
+package aarch64isa
+
+import (
+	"alon.kr/x/aarch64codegen/instructions"
+	"alon.kr/x/aarch64codegen/registers"
+	aarch64codegen "alon.kr/x/usm/aarch64/codegen"
+	aarch64translation "alon.kr/x/usm/aarch64/translation"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+)
+
+// Call the function at the address in a register: branch to the address, and
+// set the link register to the address of the next instruction.
+//
+// For example: "blr %x16".
+type Blr struct {
+	gen.NonBranchingInstruction
+}
+
+func NewBlr() gen.InstructionDefinition {
+	return Blr{}
+}
+
+func (Blr) Operator(*gen.InstructionInfo) string {
+	return "blr"
+}
+
+func (Blr) Xn(info *gen.InstructionInfo) (registers.GPRegister, core.ResultList) {
+	results := gen.AssertTargetsExactly(info, 0)
+
+	curResults := gen.AssertArgumentsExactly(info, 1)
+	results.Extend(&curResults)
+
+	if !results.IsEmpty() {
+		return registers.GPRegister(0), results
+	}
+
+	return aarch64translation.ArgumentToAarch64GPRegister(info.Arguments[0])
+}
+
+func (i Blr) Codegen(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (instructions.Instruction, core.ResultList) {
+	Xn, results := i.Xn(ctx.InstructionInfo)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return rawInstruction(0xD63F0000 | uint32(Xn)<<5), core.ResultList{}
+}
+
+func (i Blr) Validate(info *gen.InstructionInfo) core.ResultList {
+	_, results := i.Xn(info)
+	return results
+}
+
+func (i Blr) Assembly(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (string, core.ResultList) {
+	results := i.Validate(ctx.InstructionInfo)
+	if !results.IsEmpty() {
+		return "", results
+	}
+
+	return ctx.DefaultAssembly(), core.ResultList{}
+}
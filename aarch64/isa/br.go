@@ -0,0 +1,83 @@
+package aarch64isa
+
+import (
+	"alon.kr/x/aarch64codegen/instructions"
+	"alon.kr/x/aarch64codegen/registers"
+	aarch64codegen "alon.kr/x/usm/aarch64/codegen"
+	aarch64translation "alon.kr/x/usm/aarch64/translation"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+)
+
+// Branch to the address in a register.
+//
+// The register may be followed by the labels that the branch may jump to,
+// which are not encoded in the instruction, but are used to keep the control
+// flow graph of the function complete. For example:
+//
+//	br %x17 .first .second
+type Br struct{}
+
+func NewBr() gen.InstructionDefinition {
+	return Br{}
+}
+
+func (Br) Operator(*gen.InstructionInfo) string {
+	return "br"
+}
+
+func (Br) PossibleNextSteps(info *gen.InstructionInfo) (gen.StepInfo, core.ResultList) {
+	return gen.StepInfo{
+		PossibleBranches: gen.ArgumentsToLabels(info.Arguments),
+	}, core.ResultList{}
+}
+
+func (Br) Xn(info *gen.InstructionInfo) (registers.GPRegister, core.ResultList) {
+	results := gen.AssertTargetsExactly(info, 0)
+
+	curResults := gen.AssertAtLeastArguments(info, 1)
+	results.Extend(&curResults)
+
+	if !results.IsEmpty() {
+		return registers.GPRegister(0), results
+	}
+
+	Xn, results := aarch64translation.ArgumentToAarch64GPRegister(info.Arguments[0])
+	for _, argument := range info.Arguments[1:] {
+		_, curResults := aarch64translation.ArgumentToLabelInfo(argument)
+		results.Extend(&curResults)
+	}
+
+	return Xn, results
+}
+
+func (i Br) Codegen(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (instructions.Instruction, core.ResultList) {
+	Xn, results := i.Xn(ctx.InstructionInfo)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return rawInstruction(0xD61F0000 | uint32(Xn)<<5), core.ResultList{}
+}
+
+func (i Br) Validate(info *gen.InstructionInfo) core.ResultList {
+	_, results := i.Xn(info)
+	return results
+}
+
+func (i Br) Assembly(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (string, core.ResultList) {
+	info := ctx.InstructionInfo
+	_, results := i.Xn(info)
+	if !results.IsEmpty() {
+		return "", results
+	}
+
+	return aarch64codegen.FormatAssembly(
+		i.Operator(info),
+		ctx.OperandAssembly(info.Arguments[0]),
+	), core.ResultList{}
+}
@@ -0,0 +1,21 @@
+package aarch64isa_test
+
+import (
+	"testing"
+
+	aarch64isa "alon.kr/x/usm/aarch64/isa"
+)
+
+func TestBrExpectedCodegen(t *testing.T) {
+	runExpectedBinaryTests(t, aarch64isa.NewBr(), []binaryTestCase{
+		{"br %x17\n", 0xd61f0220},
+		{"br %x0\n", 0xd61f0000},
+	})
+}
+
+func TestBlrExpectedCodegen(t *testing.T) {
+	runExpectedBinaryTests(t, aarch64isa.NewBlr(), []binaryTestCase{
+		{"blr %x16\n", 0xd63f0200},
+		{"blr %x8\n", 0xd63f0100},
+	})
+}
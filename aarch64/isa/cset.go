@@ -0,0 +1,79 @@
+package aarch64isa
+
+import (
+	"alon.kr/x/aarch64codegen/immediates"
+	"alon.kr/x/aarch64codegen/instructions"
+	"alon.kr/x/aarch64codegen/registers"
+	aarch64codegen "alon.kr/x/usm/aarch64/codegen"
+	aarch64translation "alon.kr/x/usm/aarch64/translation"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+)
+
+// Sets the target register to one if the condition holds, and to zero
+// otherwise.
+//
+// This is an alias of "csinc Xd, xzr, xzr, <inverted condition>".
+type Cset struct {
+	gen.NonBranchingInstruction
+
+	Condition immediates.Condition
+}
+
+func NewCset(condition immediates.Condition) gen.InstructionDefinition {
+	return Cset{
+		Condition: condition,
+	}
+}
+
+func (i Cset) Operator(*gen.InstructionInfo) string {
+	return "cset." + i.Condition.String()
+}
+
+func (Cset) Register(
+	info *gen.InstructionInfo,
+) (Xd registers.GPRegister, results core.ResultList) {
+	results = gen.AssertTargetsExactly(info, 1)
+
+	curResults := gen.AssertArgumentsExactly(info, 0)
+	results.Extend(&curResults)
+
+	if !results.IsEmpty() {
+		return
+	}
+
+	return aarch64translation.TargetToAarch64GPRegister(info.Targets[0])
+}
+
+func (i Cset) Codegen(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (instructions.Instruction, core.ResultList) {
+	Xd, results := i.Register(ctx.InstructionInfo)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	// Conditions are encoded in pairs, where the least significant bit
+	// inverts the condition.
+	inverted := uint32(i.Condition) ^ 1
+	opcode := 0x9A800400 | inverted<<12
+	xzr := registers.GPRegisterXZR
+	return encodeThreeRegisters(opcode, Xd, xzr, xzr), core.ResultList{}
+}
+
+func (i Cset) Validate(info *gen.InstructionInfo) core.ResultList {
+	_, results := i.Register(info)
+	return results
+}
+
+func (i Cset) Assembly(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (string, core.ResultList) {
+	_, results := i.Register(ctx.InstructionInfo)
+	if !results.IsEmpty() {
+		return "", results
+	}
+
+	target := ctx.RegisterAssembly(ctx.InstructionInfo.Targets[0].Register)
+	return aarch64codegen.FormatAssembly("cset", target, i.Condition.String()), core.ResultList{}
+}
@@ -0,0 +1,22 @@
+package aarch64isa_test
+
+import (
+	"testing"
+
+	"alon.kr/x/aarch64codegen/immediates"
+	aarch64isa "alon.kr/x/usm/aarch64/isa"
+)
+
+func TestCsetExpectedCodegen(t *testing.T) {
+	t.Run("eq", func(t *testing.T) {
+		runExpectedBinaryTests(t, aarch64isa.NewCset(immediates.ConditionEq), []binaryTestCase{
+			{"%x0 = cset.eq\n", 0x9a9f17e0},
+		})
+	})
+
+	t.Run("lt", func(t *testing.T) {
+		runExpectedBinaryTests(t, aarch64isa.NewCset(immediates.ConditionLt), []binaryTestCase{
+			{"%x5 = cset.lt\n", 0x9a9fa7e5},
+		})
+	})
+}
@@ -0,0 +1,33 @@
+package aarch64isa
+
+import (
+	"alon.kr/x/usm/gen"
+)
+
+// Unsigned division of two registers (Xd = Xn / Xm), rounding towards zero.
+// Division by zero results in zero.
+type Udiv struct {
+	binaryRegisterInstruction
+}
+
+func NewUdiv() gen.InstructionDefinition {
+	return Udiv{binaryRegisterInstruction{opcode: 0x9AC00800}}
+}
+
+func (Udiv) Operator(*gen.InstructionInfo) string {
+	return "udiv"
+}
+
+// Signed division of two registers (Xd = Xn / Xm), rounding towards zero.
+// Division by zero results in zero.
+type Sdiv struct {
+	binaryRegisterInstruction
+}
+
+func NewSdiv() gen.InstructionDefinition {
+	return Sdiv{binaryRegisterInstruction{opcode: 0x9AC00C00}}
+}
+
+func (Sdiv) Operator(*gen.InstructionInfo) string {
+	return "sdiv"
+}
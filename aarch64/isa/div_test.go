@@ -0,0 +1,28 @@
+package aarch64isa_test
+
+import (
+	"testing"
+
+	aarch64isa "alon.kr/x/usm/aarch64/isa"
+)
+
+func TestDivisionExpectedCodegen(t *testing.T) {
+	t.Run("udiv", func(t *testing.T) {
+		runExpectedBinaryTests(t, aarch64isa.NewUdiv(), []binaryTestCase{
+			{"%x0 = udiv %x1 %x2\n", 0x9ac20820},
+		})
+	})
+
+	t.Run("sdiv", func(t *testing.T) {
+		runExpectedBinaryTests(t, aarch64isa.NewSdiv(), []binaryTestCase{
+			{"%x0 = sdiv %x1 %x2\n", 0x9ac20c20},
+		})
+	})
+
+	t.Run("msub", func(t *testing.T) {
+		runExpectedBinaryTests(t, aarch64isa.NewMsub(), []binaryTestCase{
+			{"%x0 = msub %x1 %x2 %x3\n", 0x9b028c20},
+			{"%x16 = msub %x30 %x17 %x16\n", 0x9b11c3d0},
+		})
+	})
+}
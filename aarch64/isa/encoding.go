@@ -0,0 +1,73 @@
+package aarch64isa
+
+import (
+	"alon.kr/x/aarch64codegen/immediates"
+	"alon.kr/x/aarch64codegen/instructions"
+	"alon.kr/x/aarch64codegen/registers"
+)
+
+// rawInstruction is an AArch64 instruction that is encoded directly in this
+// package, for instructions that the aarch64codegen module does not provide a
+// constructor for (yet).
+type rawInstruction uint32
+
+func (i rawInstruction) Binary() uint32 {
+	return uint32(i)
+}
+
+// Encodes instructions of the "Xd, Xn, Xm" form (data processing, 3 registers
+// or shifted registers with zero shift amount).
+func encodeThreeRegisters(
+	opcode uint32,
+	Xd, Xn, Xm registers.GPRegister,
+) instructions.Instruction {
+	return rawInstruction(
+		opcode | uint32(Xm)<<16 | uint32(Xn)<<5 | uint32(Xd),
+	)
+}
+
+// Encodes a 64 bit "move wide" instruction (MOVZ, MOVK, MOVN).
+func encodeMoveWide(
+	opcode uint32,
+	Xd registers.GPRegister,
+	imm immediates.Immediate16,
+	shift instructions.MovShift,
+) instructions.Instruction {
+	return rawInstruction(
+		opcode | uint32(shift)<<21 | uint32(imm)<<5 | uint32(Xd),
+	)
+}
+
+// Encodes a 64 bit load or store instruction, with an unsigned offset
+// immediate. The offset is provided in bytes, and should already be validated
+// as a multiple of 8 that fits in the scaled 12 bit immediate.
+func encodeLoadStoreUnsignedOffset(
+	opcode uint32,
+	Xt registers.GPRegister,
+	Xn registers.GPorSPRegister,
+	offset uint64,
+) instructions.Instruction {
+	return rawInstruction(
+		opcode | uint32(offset/8)<<10 | uint32(Xn)<<5 | uint32(Xt),
+	)
+}
+
+// Encodes a 64 bit unsigned bitfield move (UBFM) instruction.
+func encodeUbfm(
+	Xd, Xn registers.GPRegister,
+	immr, imms uint8,
+) instructions.Instruction {
+	return rawInstruction(
+		0xD3400000 | uint32(immr)<<16 | uint32(imms)<<10 | uint32(Xn)<<5 | uint32(Xd),
+	)
+}
+
+// Encodes a 64 bit signed bitfield move (SBFM) instruction.
+func encodeSbfm(
+	Xd, Xn registers.GPRegister,
+	immr, imms uint8,
+) instructions.Instruction {
+	return rawInstruction(
+		0x93400000 | uint32(immr)<<16 | uint32(imms)<<10 | uint32(Xn)<<5 | uint32(Xd),
+	)
+}
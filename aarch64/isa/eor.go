@@ -0,0 +1,18 @@
+package aarch64isa
+
+import (
+	"alon.kr/x/usm/gen"
+)
+
+// Bitwise exclusive OR of two registers (Xd = Xn ^ Xm).
+type Eor struct {
+	binaryRegisterInstruction
+}
+
+func NewEor() gen.InstructionDefinition {
+	return Eor{binaryRegisterInstruction{opcode: 0xCA000000}}
+}
+
+func (Eor) Operator(*gen.InstructionInfo) string {
+	return "eor"
+}
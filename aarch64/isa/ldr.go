@@ -0,0 +1,83 @@
+package aarch64isa
+
+import (
+	"alon.kr/x/aarch64codegen/instructions"
+	"alon.kr/x/aarch64codegen/registers"
+	aarch64codegen "alon.kr/x/usm/aarch64/codegen"
+	aarch64translation "alon.kr/x/usm/aarch64/translation"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+)
+
+// Load a 64 bit value from memory, from the address in the base register plus
+// an unsigned immediate byte offset.
+//
+// For example: "%x0 = ldr %sp $64 #16" loads the value at [sp + 16] to x0.
+type Ldr struct {
+	gen.NonBranchingInstruction
+}
+
+func NewLdr() gen.InstructionDefinition {
+	return Ldr{}
+}
+
+func (Ldr) Operator(*gen.InstructionInfo) string {
+	return "ldr"
+}
+
+func (Ldr) Operands(
+	info *gen.InstructionInfo,
+) (
+	Xt registers.GPRegister,
+	Xn registers.GPorSPRegister,
+	offset uint64,
+	results core.ResultList,
+) {
+	results = aarch64translation.ValidateBinaryInstruction(info)
+	if !results.IsEmpty() {
+		return
+	}
+
+	Xt, curResults := aarch64translation.TargetToAarch64GPRegister(info.Targets[0])
+	results.Extend(&curResults)
+
+	Xn, curResults = aarch64translation.ArgumentToAarch64GPorSPRegister(info.Arguments[0])
+	results.Extend(&curResults)
+
+	offset, curResults = aarch64translation.ArgumentToAarch64ScaledOffset(info.Arguments[1], 8)
+	results.Extend(&curResults)
+
+	return
+}
+
+func (i Ldr) Codegen(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (instructions.Instruction, core.ResultList) {
+	Xt, Xn, offset, results := i.Operands(ctx.InstructionInfo)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return encodeLoadStoreUnsignedOffset(0xF9400000, Xt, Xn, offset), core.ResultList{}
+}
+
+func (i Ldr) Validate(info *gen.InstructionInfo) core.ResultList {
+	_, _, _, results := i.Operands(info)
+	return results
+}
+
+func (i Ldr) Assembly(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (string, core.ResultList) {
+	info := ctx.InstructionInfo
+	_, _, _, results := i.Operands(info)
+	if !results.IsEmpty() {
+		return "", results
+	}
+
+	return aarch64codegen.FormatAssembly(
+		i.Operator(info),
+		ctx.RegisterAssembly(info.Targets[0].Register),
+		"["+ctx.OperandAssembly(info.Arguments[0])+", "+ctx.OperandAssembly(info.Arguments[1])+"]",
+	), core.ResultList{}
+}
@@ -0,0 +1,78 @@
+package aarch64isa
+
+import (
+	"alon.kr/x/aarch64codegen/instructions"
+	"alon.kr/x/aarch64codegen/registers"
+	aarch64codegen "alon.kr/x/usm/aarch64/codegen"
+	aarch64translation "alon.kr/x/usm/aarch64/translation"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+)
+
+// Load a signed 32 bit value from memory and sign extend it to 64 bits. The
+// address is the base register plus the index register, scaled by 4.
+//
+// For example: "%x16 = ldrsw %x17 %x16" loads the word at [x17 + x16 * 4].
+type Ldrsw struct {
+	gen.NonBranchingInstruction
+}
+
+func NewLdrsw() gen.InstructionDefinition {
+	return Ldrsw{}
+}
+
+func (Ldrsw) Operator(*gen.InstructionInfo) string {
+	return "ldrsw"
+}
+
+func (Ldrsw) Registers(
+	info *gen.InstructionInfo,
+) (Xt, Xn, Xm registers.GPRegister, results core.ResultList) {
+	results = aarch64translation.ValidateBinaryInstruction(info)
+	if !results.IsEmpty() {
+		return
+	}
+
+	Xt, curResults := aarch64translation.TargetToAarch64GPRegister(info.Targets[0])
+	results.Extend(&curResults)
+
+	Xn, curResults = aarch64translation.ArgumentToAarch64GPRegister(info.Arguments[0])
+	results.Extend(&curResults)
+
+	Xm, curResults = aarch64translation.ArgumentToAarch64GPRegister(info.Arguments[1])
+	results.Extend(&curResults)
+
+	return
+}
+
+func (i Ldrsw) Codegen(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (instructions.Instruction, core.ResultList) {
+	Xt, Xn, Xm, results := i.Registers(ctx.InstructionInfo)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return encodeThreeRegisters(0xB8A07800, Xt, Xn, Xm), core.ResultList{}
+}
+
+func (i Ldrsw) Validate(info *gen.InstructionInfo) core.ResultList {
+	_, _, _, results := i.Registers(info)
+	return results
+}
+
+func (i Ldrsw) Assembly(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (string, core.ResultList) {
+	info := ctx.InstructionInfo
+	_, _, _, results := i.Registers(info)
+	if !results.IsEmpty() {
+		return "", results
+	}
+
+	return aarch64codegen.FormatAssembly(
+		i.Operator(info),
+		ctx.RegisterAssembly(info.Targets[0].Register),
+		"["+ctx.OperandAssembly(info.Arguments[0])+", "+ctx.OperandAssembly(info.Arguments[1])+", lsl #2]",
+	), core.ResultList{}
+}
@@ -0,0 +1,30 @@
+package aarch64isa_test
+
+import (
+	"testing"
+
+	aarch64isa "alon.kr/x/usm/aarch64/isa"
+)
+
+func TestLdrExpectedCodegen(t *testing.T) {
+	runExpectedBinaryTests(t, aarch64isa.NewLdr(), []binaryTestCase{
+		{"%x0 = ldr %sp $64 #0\n", 0xf94003e0},
+		{"%x1 = ldr %x2 $64 #32760\n", 0xf97ffc41},
+		{"%x29 = ldr %sp $64 #8\n", 0xf94007fd},
+	})
+}
+
+func TestLdrswExpectedCodegen(t *testing.T) {
+	runExpectedBinaryTests(t, aarch64isa.NewLdrsw(), []binaryTestCase{
+		{"%x16 = ldrsw %x17 %x16\n", 0xb8b07a30},
+		{"%x0 = ldrsw %x1 %x2\n", 0xb8a27820},
+	})
+}
+
+func TestStrExpectedCodegen(t *testing.T) {
+	runExpectedBinaryTests(t, aarch64isa.NewStr(), []binaryTestCase{
+		{"str %x0 %sp $64 #0\n", 0xf90003e0},
+		{"str %x30 %sp $64 #8\n", 0xf90007fe},
+		{"str %x1 %x2 $64 #32760\n", 0xf93ffc41},
+	})
+}
@@ -0,0 +1,28 @@
+package aarch64isa
+
+import (
+	"alon.kr/x/aarch64codegen/instructions"
+	"alon.kr/x/aarch64codegen/registers"
+	"alon.kr/x/usm/gen"
+)
+
+// Logical shift left by an immediate amount (Xd = Xn << #shift).
+//
+// This is an alias of "ubfm Xd, Xn, #(-shift mod 64), #(63 - shift)".
+type Lsl struct {
+	shiftImmediateInstruction
+}
+
+func NewLsl() gen.InstructionDefinition {
+	return Lsl{shiftImmediateInstruction{encode: encodeLsl}}
+}
+
+func encodeLsl(Xd, Xn registers.GPRegister, shift uint8) instructions.Instruction {
+	immr := (64 - shift) % 64
+	imms := 63 - shift
+	return encodeUbfm(Xd, Xn, immr, imms)
+}
+
+func (Lsl) Operator(*gen.InstructionInfo) string {
+	return "lsl"
+}
@@ -0,0 +1,51 @@
+package aarch64isa_test
+
+import (
+	"testing"
+
+	aarch64isa "alon.kr/x/usm/aarch64/isa"
+)
+
+func TestLslExpectedCodegen(t *testing.T) {
+	runExpectedBinaryTests(t, aarch64isa.NewLsl(), []binaryTestCase{
+		{"%x0 = lsl %x1 $6 #1\n", 0xd37ff820},
+		{"%x2 = lsl %x3 $6 #63\n", 0xd3410062},
+		{"%x4 = lsl %x5 $6 #0\n", 0xd340fca4},
+	})
+}
+
+func TestShiftRightExpectedCodegen(t *testing.T) {
+	t.Run("lsr", func(t *testing.T) {
+		runExpectedBinaryTests(t, aarch64isa.NewLsr(), []binaryTestCase{
+			{"%x0 = lsr %x1 $6 #1\n", 0xd341fc20},
+			{"%x2 = lsr %x3 $6 #32\n", 0xd360fc62},
+		})
+	})
+
+	t.Run("asr", func(t *testing.T) {
+		runExpectedBinaryTests(t, aarch64isa.NewAsr(), []binaryTestCase{
+			{"%x0 = asr %x1 $6 #1\n", 0x9341fc20},
+			{"%x2 = asr %x3 $6 #63\n", 0x937ffc62},
+		})
+	})
+}
+
+func TestShiftRegisterExpectedCodegen(t *testing.T) {
+	t.Run("lslv", func(t *testing.T) {
+		runExpectedBinaryTests(t, aarch64isa.NewLslv(), []binaryTestCase{
+			{"%x0 = lslv %x1 %x2\n", 0x9ac22020},
+		})
+	})
+
+	t.Run("lsrv", func(t *testing.T) {
+		runExpectedBinaryTests(t, aarch64isa.NewLsrv(), []binaryTestCase{
+			{"%x0 = lsrv %x1 %x2\n", 0x9ac22420},
+		})
+	})
+
+	t.Run("asrv", func(t *testing.T) {
+		runExpectedBinaryTests(t, aarch64isa.NewAsrv(), []binaryTestCase{
+			{"%x0 = asrv %x1 %x2\n", 0x9ac22820},
+		})
+	})
+}
@@ -0,0 +1,27 @@
+package aarch64isa
+
+import (
+	"alon.kr/x/aarch64codegen/instructions"
+	"alon.kr/x/aarch64codegen/registers"
+	"alon.kr/x/usm/gen"
+)
+
+// Logical shift right by an immediate amount (Xd = Xn >> #shift), which fills
+// the upper bits with zeros.
+//
+// This is an alias of "ubfm Xd, Xn, #shift, #63".
+type Lsr struct {
+	shiftImmediateInstruction
+}
+
+func NewLsr() gen.InstructionDefinition {
+	return Lsr{shiftImmediateInstruction{encode: encodeLsr}}
+}
+
+func encodeLsr(Xd, Xn registers.GPRegister, shift uint8) instructions.Instruction {
+	return encodeUbfm(Xd, Xn, shift, 63)
+}
+
+func (Lsr) Operator(*gen.InstructionInfo) string {
+	return "lsr"
+}
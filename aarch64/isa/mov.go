@@ -0,0 +1,75 @@
+package aarch64isa
+
+import (
+	"alon.kr/x/aarch64codegen/instructions"
+	"alon.kr/x/aarch64codegen/registers"
+	aarch64codegen "alon.kr/x/usm/aarch64/codegen"
+	aarch64translation "alon.kr/x/usm/aarch64/translation"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+)
+
+// Copies the value of a general purpose register into another one.
+//
+// This is an alias for "orr Xd, xzr, Xm". Notice that moving to or from the
+// stack pointer should be done with "add Xd, Xn, #0" instead.
+type Mov struct {
+	gen.NonBranchingInstruction
+}
+
+func NewMov() gen.InstructionDefinition {
+	return Mov{}
+}
+
+func (Mov) Operator(*gen.InstructionInfo) string {
+	return "mov"
+}
+
+func (Mov) Registers(
+	info *gen.InstructionInfo,
+) (Xd, Xm registers.GPRegister, results core.ResultList) {
+	results = gen.AssertTargetsExactly(info, 1)
+
+	curResults := gen.AssertArgumentsExactly(info, 1)
+	results.Extend(&curResults)
+
+	if !results.IsEmpty() {
+		return
+	}
+
+	Xd, curResults = aarch64translation.TargetToAarch64GPRegister(info.Targets[0])
+	results.Extend(&curResults)
+
+	Xm, curResults = aarch64translation.ArgumentToAarch64GPRegister(info.Arguments[0])
+	results.Extend(&curResults)
+
+	return
+}
+
+func (i Mov) Codegen(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (instructions.Instruction, core.ResultList) {
+	Xd, Xm, results := i.Registers(ctx.InstructionInfo)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	inst := encodeThreeRegisters(0xAA000000, Xd, registers.GPRegisterXZR, Xm)
+	return inst, core.ResultList{}
+}
+
+func (i Mov) Validate(info *gen.InstructionInfo) core.ResultList {
+	_, _, results := i.Registers(info)
+	return results
+}
+
+func (i Mov) Assembly(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (string, core.ResultList) {
+	_, _, results := i.Registers(ctx.InstructionInfo)
+	if !results.IsEmpty() {
+		return "", results
+	}
+
+	return ctx.DefaultAssembly(), core.ResultList{}
+}
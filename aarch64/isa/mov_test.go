@@ -0,0 +1,22 @@
+package aarch64isa_test
+
+import (
+	"testing"
+
+	aarch64isa "alon.kr/x/usm/aarch64/isa"
+)
+
+func TestMovExpectedCodegen(t *testing.T) {
+	runExpectedBinaryTests(t, aarch64isa.NewMov(), []binaryTestCase{
+		{"%x0 = mov %x1\n", 0xaa0103e0},
+		{"%x30 = mov %xzr\n", 0xaa1f03fe},
+	})
+}
+
+func TestMovkExpectedCodegen(t *testing.T) {
+	runExpectedBinaryTests(t, aarch64isa.NewMovk(), []binaryTestCase{
+		{"%x0 = movk $16 #0xffff\n", 0xf29fffe0},
+		{"%x2 = movk $16 #0x1234 $8 #16\n", 0xf2a24682},
+		{"%x3 = movk $16 #1 $8 #48\n", 0xf2e00023},
+	})
+}
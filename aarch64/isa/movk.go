@@ -0,0 +1,44 @@
+package aarch64isa
+
+import (
+	"alon.kr/x/aarch64codegen/instructions"
+	aarch64codegen "alon.kr/x/usm/aarch64/codegen"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+)
+
+// Move a 16 bit immediate into a (possibly shifted) position in the target
+// register, keeping the rest of the register bits unchanged.
+//
+// Accepts the same arguments as "movz", and is usually used after it to
+// construct wide immediates.
+type Movk struct {
+	Movz
+}
+
+func NewMovk() gen.InstructionDefinition {
+	return Movk{}
+}
+
+func (Movk) Operator(*gen.InstructionInfo) string {
+	return "movk"
+}
+
+func (i Movk) Codegen(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (instructions.Instruction, core.ResultList) {
+	info := ctx.InstructionInfo
+	results := core.ResultList{}
+
+	Xd, curResults := i.Xd(info)
+	results.Extend(&curResults)
+
+	imm, shift, curResults := i.Immediate(info)
+	results.Extend(&curResults)
+
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return encodeMoveWide(0xF2800000, Xd, imm, shift), core.ResultList{}
+}
@@ -98,3 +98,30 @@ func (i Movz) Codegen(
 
 	return instructions.MOVZ(Xd, imm, shift), core.ResultList{}
 }
+
+// Returns the instruction in the GNU assembler syntax, for example
+// "movz x0, #42, lsl #16". This is shared with the "movk" instruction.
+func (i Movz) Assembly(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (string, core.ResultList) {
+	info := ctx.InstructionInfo
+	results := i.Validate(info)
+	if !results.IsEmpty() {
+		return "", results
+	}
+
+	operands := []string{
+		ctx.RegisterAssembly(info.Targets[0].Register),
+		ctx.OperandAssembly(info.Arguments[0]),
+	}
+
+	if len(info.Arguments) > 1 {
+		shift := info.Arguments[1].(*gen.ImmediateInfo).Value
+		if shift.Sign() != 0 {
+			operands = append(operands, "lsl #"+shift.String())
+		}
+	}
+
+	mnemonic := info.Definition.Operator(info)
+	return aarch64codegen.FormatAssembly(mnemonic, operands...), core.ResultList{}
+}
@@ -0,0 +1,79 @@
+package aarch64isa
+
+import (
+	"alon.kr/x/aarch64codegen/instructions"
+	"alon.kr/x/aarch64codegen/registers"
+	aarch64codegen "alon.kr/x/usm/aarch64/codegen"
+	aarch64translation "alon.kr/x/usm/aarch64/translation"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+)
+
+// Multiply and subtract (Xd = Xa - Xn * Xm), where the arguments are
+// provided in the "Xn Xm Xa" order.
+type Msub struct {
+	gen.NonBranchingInstruction
+}
+
+func NewMsub() gen.InstructionDefinition {
+	return Msub{}
+}
+
+func (Msub) Operator(*gen.InstructionInfo) string {
+	return "msub"
+}
+
+func (Msub) Registers(
+	info *gen.InstructionInfo,
+) (Xd, Xn, Xm, Xa registers.GPRegister, results core.ResultList) {
+	results = gen.AssertTargetsExactly(info, 1)
+
+	curResults := gen.AssertArgumentsExactly(info, 3)
+	results.Extend(&curResults)
+
+	if !results.IsEmpty() {
+		return
+	}
+
+	Xd, curResults = aarch64translation.TargetToAarch64GPRegister(info.Targets[0])
+	results.Extend(&curResults)
+
+	Xn, curResults = aarch64translation.ArgumentToAarch64GPRegister(info.Arguments[0])
+	results.Extend(&curResults)
+
+	Xm, curResults = aarch64translation.ArgumentToAarch64GPRegister(info.Arguments[1])
+	results.Extend(&curResults)
+
+	Xa, curResults = aarch64translation.ArgumentToAarch64GPRegister(info.Arguments[2])
+	results.Extend(&curResults)
+
+	return
+}
+
+func (i Msub) Codegen(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (instructions.Instruction, core.ResultList) {
+	Xd, Xn, Xm, Xa, results := i.Registers(ctx.InstructionInfo)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	inst := encodeThreeRegisters(0x9B008000|uint32(Xa)<<10, Xd, Xn, Xm)
+	return inst, core.ResultList{}
+}
+
+func (i Msub) Validate(info *gen.InstructionInfo) core.ResultList {
+	_, _, _, _, results := i.Registers(info)
+	return results
+}
+
+func (i Msub) Assembly(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (string, core.ResultList) {
+	_, _, _, _, results := i.Registers(ctx.InstructionInfo)
+	if !results.IsEmpty() {
+		return "", results
+	}
+
+	return ctx.DefaultAssembly(), core.ResultList{}
+}
@@ -0,0 +1,18 @@
+package aarch64isa
+
+import (
+	"alon.kr/x/usm/gen"
+)
+
+// Multiplies two registers (Xd = Xn * Xm).
+type Mul struct {
+	binaryRegisterInstruction
+}
+
+func NewMul() gen.InstructionDefinition {
+	return Mul{binaryRegisterInstruction{opcode: 0x9B007C00}}
+}
+
+func (Mul) Operator(*gen.InstructionInfo) string {
+	return "mul"
+}
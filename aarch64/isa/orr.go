@@ -0,0 +1,18 @@
+package aarch64isa
+
+import (
+	"alon.kr/x/usm/gen"
+)
+
+// Bitwise OR of two registers (Xd = Xn | Xm).
+type Orr struct {
+	binaryRegisterInstruction
+}
+
+func NewOrr() gen.InstructionDefinition {
+	return Orr{binaryRegisterInstruction{opcode: 0xAA000000}}
+}
+
+func (Orr) Operator(*gen.InstructionInfo) string {
+	return "orr"
+}
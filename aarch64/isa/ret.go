@@ -67,3 +67,14 @@ func (i Ret) Validate(info *gen.InstructionInfo) core.ResultList {
 
 	return core.ResultList{}
 }
+
+func (i Ret) Assembly(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (string, core.ResultList) {
+	results := i.Validate(ctx.InstructionInfo)
+	if !results.IsEmpty() {
+		return "", results
+	}
+
+	return ctx.DefaultAssembly(), core.ResultList{}
+}
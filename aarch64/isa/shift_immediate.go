@@ -0,0 +1,72 @@
+package aarch64isa
+
+import (
+	"math/big"
+
+	"alon.kr/x/aarch64codegen/instructions"
+	"alon.kr/x/aarch64codegen/registers"
+	aarch64codegen "alon.kr/x/usm/aarch64/codegen"
+	aarch64translation "alon.kr/x/usm/aarch64/translation"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+)
+
+// shiftImmediateInstruction implements the shared functionality of shifts by
+// an immediate amount (Xd = Xn <op> #shift), which are all aliases of bitfield
+// move instructions.
+type shiftImmediateInstruction struct {
+	gen.NonBranchingInstruction
+
+	// Encodes the instruction, given its operands.
+	encode func(Xd, Xn registers.GPRegister, shift uint8) instructions.Instruction
+}
+
+func (shiftImmediateInstruction) Operands(
+	info *gen.InstructionInfo,
+) (Xd, Xn registers.GPRegister, shift uint8, results core.ResultList) {
+	results = aarch64translation.ValidateBinaryInstruction(info)
+	if !results.IsEmpty() {
+		return
+	}
+
+	Xd, curResults := aarch64translation.TargetToAarch64GPRegister(info.Targets[0])
+	results.Extend(&curResults)
+
+	Xn, curResults = aarch64translation.ArgumentToAarch64GPRegister(info.Arguments[0])
+	results.Extend(&curResults)
+
+	shift, curResults = aarch64translation.ArgumentToAarch64ShiftAmount(
+		info.Arguments[1],
+		big.NewInt(64),
+	)
+	results.Extend(&curResults)
+
+	return
+}
+
+func (i shiftImmediateInstruction) Codegen(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (instructions.Instruction, core.ResultList) {
+	Xd, Xn, shift, results := i.Operands(ctx.InstructionInfo)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return i.encode(Xd, Xn, shift), core.ResultList{}
+}
+
+func (i shiftImmediateInstruction) Validate(info *gen.InstructionInfo) core.ResultList {
+	_, _, _, results := i.Operands(info)
+	return results
+}
+
+func (i shiftImmediateInstruction) Assembly(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (string, core.ResultList) {
+	_, _, _, results := i.Operands(ctx.InstructionInfo)
+	if !results.IsEmpty() {
+		return "", results
+	}
+
+	return ctx.DefaultAssembly(), core.ResultList{}
+}
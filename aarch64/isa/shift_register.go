@@ -0,0 +1,44 @@
+package aarch64isa
+
+import (
+	"alon.kr/x/usm/gen"
+)
+
+// Logical shift left by a variable amount (Xd = Xn << (Xm mod 64)).
+type Lslv struct {
+	binaryRegisterInstruction
+}
+
+func NewLslv() gen.InstructionDefinition {
+	return Lslv{binaryRegisterInstruction{opcode: 0x9AC02000}}
+}
+
+func (Lslv) Operator(*gen.InstructionInfo) string {
+	return "lslv"
+}
+
+// Logical shift right by a variable amount (Xd = Xn >> (Xm mod 64)).
+type Lsrv struct {
+	binaryRegisterInstruction
+}
+
+func NewLsrv() gen.InstructionDefinition {
+	return Lsrv{binaryRegisterInstruction{opcode: 0x9AC02400}}
+}
+
+func (Lsrv) Operator(*gen.InstructionInfo) string {
+	return "lsrv"
+}
+
+// Arithmetic shift right by a variable amount (Xd = Xn >> (Xm mod 64)).
+type Asrv struct {
+	binaryRegisterInstruction
+}
+
+func NewAsrv() gen.InstructionDefinition {
+	return Asrv{binaryRegisterInstruction{opcode: 0x9AC02800}}
+}
+
+func (Asrv) Operator(*gen.InstructionInfo) string {
+	return "asrv"
+}
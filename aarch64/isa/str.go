@@ -0,0 +1,87 @@
+package aarch64isa
+
+import (
+	"alon.kr/x/aarch64codegen/instructions"
+	"alon.kr/x/aarch64codegen/registers"
+	aarch64codegen "alon.kr/x/usm/aarch64/codegen"
+	aarch64translation "alon.kr/x/usm/aarch64/translation"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+)
+
+// Store a 64 bit register value to memory, at the address in the base register
+// plus an unsigned immediate byte offset.
+//
+// For example: "str %x0 %sp $64 #16" stores the value of x0 to [sp + 16].
+type Str struct {
+	gen.NonBranchingInstruction
+}
+
+func NewStr() gen.InstructionDefinition {
+	return Str{}
+}
+
+func (Str) Operator(*gen.InstructionInfo) string {
+	return "str"
+}
+
+func (Str) Operands(
+	info *gen.InstructionInfo,
+) (
+	Xt registers.GPRegister,
+	Xn registers.GPorSPRegister,
+	offset uint64,
+	results core.ResultList,
+) {
+	results = gen.AssertTargetsExactly(info, 0)
+
+	curResults := gen.AssertArgumentsExactly(info, 3)
+	results.Extend(&curResults)
+
+	if !results.IsEmpty() {
+		return
+	}
+
+	Xt, curResults = aarch64translation.ArgumentToAarch64GPRegister(info.Arguments[0])
+	results.Extend(&curResults)
+
+	Xn, curResults = aarch64translation.ArgumentToAarch64GPorSPRegister(info.Arguments[1])
+	results.Extend(&curResults)
+
+	offset, curResults = aarch64translation.ArgumentToAarch64ScaledOffset(info.Arguments[2], 8)
+	results.Extend(&curResults)
+
+	return
+}
+
+func (i Str) Codegen(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (instructions.Instruction, core.ResultList) {
+	Xt, Xn, offset, results := i.Operands(ctx.InstructionInfo)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return encodeLoadStoreUnsignedOffset(0xF9000000, Xt, Xn, offset), core.ResultList{}
+}
+
+func (i Str) Validate(info *gen.InstructionInfo) core.ResultList {
+	_, _, _, results := i.Operands(info)
+	return results
+}
+
+func (i Str) Assembly(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (string, core.ResultList) {
+	info := ctx.InstructionInfo
+	_, _, _, results := i.Operands(info)
+	if !results.IsEmpty() {
+		return "", results
+	}
+
+	return aarch64codegen.FormatAssembly(
+		i.Operator(info),
+		ctx.OperandAssembly(info.Arguments[0]),
+		"["+ctx.OperandAssembly(info.Arguments[1])+", "+ctx.OperandAssembly(info.Arguments[2])+"]",
+	), core.ResultList{}
+}
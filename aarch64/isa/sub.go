@@ -80,3 +80,14 @@ func (i Sub) Validate(
 	_, results := i.Codegen(&ctx)
 	return results
 }
+
+func (i Sub) Assembly(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (string, core.ResultList) {
+	_, results := i.Codegen(ctx)
+	if !results.IsEmpty() {
+		return "", results
+	}
+
+	return ctx.DefaultAssembly(), core.ResultList{}
+}
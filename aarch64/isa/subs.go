@@ -83,3 +83,14 @@ func (i Subs) Validate(
 	_, results := i.Codegen(&ctx)
 	return results
 }
+
+func (i Subs) Assembly(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (string, core.ResultList) {
+	_, results := i.Codegen(ctx)
+	if !results.IsEmpty() {
+		return "", results
+	}
+
+	return ctx.DefaultAssembly(), core.ResultList{}
+}
@@ -0,0 +1,105 @@
+package aarch64isa
+
+import (
+	"alon.kr/x/aarch64codegen/instructions"
+	"alon.kr/x/list"
+	aarch64codegen "alon.kr/x/usm/aarch64/codegen"
+	aarch64translation "alon.kr/x/usm/aarch64/translation"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+)
+
+// A jump table entry: a 32 bit word that holds the byte offset of the first
+// label, relative to the second label (which is the start of the table).
+//
+// For example: "word .case .table" is assembled to ".word .case - .table".
+//
+// The word is data that is embedded in the code, and is never executed.
+type Word struct{}
+
+func NewWord() gen.InstructionDefinition {
+	return Word{}
+}
+
+func (Word) Operator(*gen.InstructionInfo) string {
+	return "word"
+}
+
+func (Word) Labels(
+	info *gen.InstructionInfo,
+) (target, base *gen.LabelInfo, results core.ResultList) {
+	results = gen.AssertTargetsExactly(info, 0)
+
+	curResults := gen.AssertArgumentsExactly(info, 2)
+	results.Extend(&curResults)
+
+	if !results.IsEmpty() {
+		return
+	}
+
+	target, curResults = aarch64translation.ArgumentToLabelInfo(info.Arguments[0])
+	results.Extend(&curResults)
+
+	base, curResults = aarch64translation.ArgumentToLabelInfo(info.Arguments[1])
+	results.Extend(&curResults)
+
+	return
+}
+
+// The word is never executed, but it references the label that the matching
+// indirect branch may jump to.
+func (i Word) PossibleNextSteps(info *gen.InstructionInfo) (gen.StepInfo, core.ResultList) {
+	target, _, results := i.Labels(info)
+	if !results.IsEmpty() {
+		return gen.StepInfo{}, results
+	}
+
+	return gen.StepInfo{
+		PossibleBranches: []*gen.LabelInfo{target},
+	}, core.ResultList{}
+}
+
+func (i Word) Codegen(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (instructions.Instruction, core.ResultList) {
+	target, base, results := i.Labels(ctx.InstructionInfo)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	offset := int64(ctx.BasicBlockOffsets[target.BasicBlock]) -
+		int64(ctx.BasicBlockOffsets[base.BasicBlock])
+
+	offset32 := int32(offset)
+	if int64(offset32) != offset {
+		return nil, list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Jump table offset too large",
+				Location: ctx.Declaration,
+			},
+		})
+	}
+
+	return rawInstruction(uint32(offset32)), core.ResultList{}
+}
+
+func (i Word) Validate(info *gen.InstructionInfo) core.ResultList {
+	_, _, results := i.Labels(info)
+	return results
+}
+
+func (i Word) Assembly(
+	ctx *aarch64codegen.InstructionCodegenContext,
+) (string, core.ResultList) {
+	info := ctx.InstructionInfo
+	_, _, results := i.Labels(info)
+	if !results.IsEmpty() {
+		return "", results
+	}
+
+	return aarch64codegen.FormatAssembly(
+		".word",
+		ctx.OperandAssembly(info.Arguments[0])+" - "+ctx.OperandAssembly(info.Arguments[1]),
+	), core.ResultList{}
+}
@@ -12,18 +12,47 @@ func NewInstructionManager() gen.InstructionManager {
 		[]faststringmap.MapEntry[gen.InstructionDefinition]{
 			// Move
 			{Key: "movz", Value: aarch64isa.NewMovz()},
+			{Key: "movk", Value: aarch64isa.NewMovk()},
+			{Key: "mov", Value: aarch64isa.NewMov()},
 
 			// Arithmetic
 			{Key: "add", Value: aarch64isa.NewAdd()},
 			{Key: "adds", Value: aarch64isa.NewAdds()},
 			{Key: "sub", Value: aarch64isa.NewSub()},
 			{Key: "subs", Value: aarch64isa.NewSubs()},
+			{Key: "mul", Value: aarch64isa.NewMul()},
+			{Key: "msub", Value: aarch64isa.NewMsub()},
+			{Key: "udiv", Value: aarch64isa.NewUdiv()},
+			{Key: "sdiv", Value: aarch64isa.NewSdiv()},
+
+			// Bitwise Operations
+			{Key: "and", Value: aarch64isa.NewAnd()},
+			{Key: "orr", Value: aarch64isa.NewOrr()},
+			{Key: "eor", Value: aarch64isa.NewEor()},
+			{Key: "lsl", Value: aarch64isa.NewLsl()},
+			{Key: "lsr", Value: aarch64isa.NewLsr()},
+			{Key: "asr", Value: aarch64isa.NewAsr()},
+			{Key: "lslv", Value: aarch64isa.NewLslv()},
+			{Key: "lsrv", Value: aarch64isa.NewLsrv()},
+			{Key: "asrv", Value: aarch64isa.NewAsrv()},
+
+			// Memory
+			{Key: "ldr", Value: aarch64isa.NewLdr()},
+			{Key: "ldrsw", Value: aarch64isa.NewLdrsw()},
+			{Key: "str", Value: aarch64isa.NewStr()},
+			{Key: "adr", Value: aarch64isa.NewAdr()},
+			{Key: "adrp", Value: aarch64isa.NewAdrp()},
 
 			// Control flow
 			{Key: "b", Value: aarch64isa.NewBranch()},
 			{Key: "bl", Value: aarch64isa.NewBl()},
+			{Key: "blr", Value: aarch64isa.NewBlr()},
+			{Key: "br", Value: aarch64isa.NewBr()},
 			{Key: "ret", Value: aarch64isa.NewRet()},
 
+			// Jump tables
+			{Key: "word", Value: aarch64isa.NewWord()},
+
 			// Conditional branches
 			{Key: "b.eq", Value: aarch64isa.NewBcond(immediates.ConditionEq)},
 			{Key: "b.ne", Value: aarch64isa.NewBcond(immediates.ConditionNe)},
@@ -41,6 +70,22 @@ func NewInstructionManager() gen.InstructionManager {
 			{Key: "b.le", Value: aarch64isa.NewBcond(immediates.ConditionLe)},
 			{Key: "b.al", Value: aarch64isa.NewBcond(immediates.ConditionAl)},
 			{Key: "b.nv", Value: aarch64isa.NewBcond(immediates.ConditionNv)},
+
+			// Conditional sets
+			{Key: "cset.eq", Value: aarch64isa.NewCset(immediates.ConditionEq)},
+			{Key: "cset.ne", Value: aarch64isa.NewCset(immediates.ConditionNe)},
+			{Key: "cset.cs", Value: aarch64isa.NewCset(immediates.ConditionCs)},
+			{Key: "cset.cc", Value: aarch64isa.NewCset(immediates.ConditionCc)},
+			{Key: "cset.mi", Value: aarch64isa.NewCset(immediates.ConditionMi)},
+			{Key: "cset.pl", Value: aarch64isa.NewCset(immediates.ConditionPl)},
+			{Key: "cset.vs", Value: aarch64isa.NewCset(immediates.ConditionVs)},
+			{Key: "cset.vc", Value: aarch64isa.NewCset(immediates.ConditionVc)},
+			{Key: "cset.hi", Value: aarch64isa.NewCset(immediates.ConditionHi)},
+			{Key: "cset.ls", Value: aarch64isa.NewCset(immediates.ConditionLs)},
+			{Key: "cset.ge", Value: aarch64isa.NewCset(immediates.ConditionGe)},
+			{Key: "cset.lt", Value: aarch64isa.NewCset(immediates.ConditionLt)},
+			{Key: "cset.gt", Value: aarch64isa.NewCset(immediates.ConditionGt)},
+			{Key: "cset.le", Value: aarch64isa.NewCset(immediates.ConditionLe)},
 		},
 		false,
 	)
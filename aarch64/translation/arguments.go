@@ -1,6 +1,7 @@
 package aarch64translation
 
 import (
+	"fmt"
 	"math/big"
 
 	"alon.kr/x/aarch64codegen/immediates"
@@ -223,3 +224,118 @@ func ArgumentToFunctionInfo(
 
 	return info.FunctionInfo, core.ResultList{}
 }
+
+// ArgumentToDataGlobalInfo converts a global argument that references a
+// global variable or constant to its data global info.
+func ArgumentToDataGlobalInfo(
+	argument gen.ArgumentInfo,
+) (*gen.DataGlobalInfo, core.ResultList) {
+	globalArg, ok := argument.(*gen.GlobalArgumentInfo)
+	if !ok {
+		return nil, list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Expected global argument",
+				Location: argument.Declaration(),
+			},
+		})
+	}
+
+	switch info := globalArg.GlobalInfo.(type) {
+	case *gen.VariableGlobalInfo:
+		return &info.DataGlobalInfo, core.ResultList{}
+	case *gen.ConstantGlobalInfo:
+		return &info.DataGlobalInfo, core.ResultList{}
+	default:
+		return nil, list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Expected variable or constant global argument",
+				Location: argument.Declaration(),
+			},
+		})
+	}
+}
+
+// ArgumentToAddressableGlobal converts a global argument that references a
+// function, a global variable or a global constant to its function info or to
+// its data global info. Exactly one of the returned infos is not nil if no
+// errors are returned.
+func ArgumentToAddressableGlobal(
+	argument gen.ArgumentInfo,
+) (*gen.FunctionInfo, *gen.DataGlobalInfo, core.ResultList) {
+	globalArg, ok := argument.(*gen.GlobalArgumentInfo)
+	if ok {
+		if _, ok := globalArg.GlobalInfo.(*gen.FunctionGlobalInfo); ok {
+			function, results := ArgumentToFunctionInfo(argument)
+			return function, nil, results
+		}
+	}
+
+	global, results := ArgumentToDataGlobalInfo(argument)
+	return nil, global, results
+}
+
+// ArgumentToAarch64ShiftAmount converts an immediate argument to a shift
+// amount, which is an unsigned integer strictly smaller than the provided
+// register size (in bits).
+func ArgumentToAarch64ShiftAmount(
+	argument gen.ArgumentInfo,
+	registerSize *big.Int,
+) (uint8, core.ResultList) {
+	info, results := ArgumentToImmediateInfo(argument)
+	if !results.IsEmpty() {
+		return 0, results
+	}
+
+	if info.Value.Sign() < 0 || info.Value.Cmp(registerSize) >= 0 {
+		return 0, list.FromSingle(core.Result{
+			{
+				Type: core.ErrorResult,
+				Message: fmt.Sprintf(
+					"Expected shift amount between 0 and %s",
+					new(big.Int).Sub(registerSize, big.NewInt(1)),
+				),
+				Location: argument.Declaration(),
+			},
+		})
+	}
+
+	return uint8(info.Value.Uint64()), core.ResultList{}
+}
+
+// ArgumentToAarch64ScaledOffset converts an immediate argument to an unsigned
+// byte offset, which is encoded as a 12 bit immediate scaled by the provided
+// access size (in bytes), as in the unsigned offset load and store
+// instructions.
+func ArgumentToAarch64ScaledOffset(
+	argument gen.ArgumentInfo,
+	scale uint64,
+) (uint64, core.ResultList) {
+	info, results := ArgumentToImmediateInfo(argument)
+	if !results.IsEmpty() {
+		return 0, results
+	}
+
+	limit := new(big.Int).SetUint64(4096 * scale)
+	remainder := new(big.Int).Mod(info.Value, new(big.Int).SetUint64(scale))
+	isInvalid := info.Value.Sign() < 0 ||
+		info.Value.Cmp(limit) >= 0 ||
+		remainder.Sign() != 0
+
+	if isInvalid {
+		return 0, list.FromSingle(core.Result{
+			{
+				Type: core.ErrorResult,
+				Message: fmt.Sprintf(
+					"Expected an offset which is a multiple of %d, between 0 and %d",
+					scale,
+					4095*scale,
+				),
+				Location: argument.Declaration(),
+			},
+		})
+	}
+
+	return info.Value.Uint64(), core.ResultList{}
+}
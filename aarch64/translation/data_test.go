@@ -0,0 +1,71 @@
+package aarch64translation_test
+
+import (
+	"bytes"
+	"testing"
+
+	aarch64codegen "alon.kr/x/usm/aarch64/codegen"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataSection(t *testing.T) {
+	src := `var @byte $8 #-1
+var @table $16 ^3 {
+	#1
+	#0x1234
+}
+var @pointer $8 * #8
+var @zero $32
+`
+
+	file := generateFileInfo(t, src)
+	ctx := aarch64codegen.NewFileCodegenContext(file)
+	require.Len(t, ctx.OrderedVariables, 4)
+
+	section, results := aarch64codegen.NewDataSection(ctx.OrderedVariables)
+	require.True(t, results.IsEmpty())
+
+	assert.Equal(t, []byte{
+		0xff,                               // @byte
+		0x00,                               // (padding)
+		0x01, 0x00, 0x34, 0x12, 0x00, 0x00, // @table
+		0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // @pointer
+		0x00, 0x00, 0x00, 0x00, // @zero
+	}, section.Data)
+
+	offsets := []uint64{}
+	for _, global := range section.Globals {
+		offsets = append(offsets, section.Offsets[global])
+	}
+	assert.Equal(t, []uint64{0, 2, 8, 16}, offsets)
+}
+
+func TestDataGlobalAddressRelocations(t *testing.T) {
+	src := `var @counter $64 #7
+
+func @f {
+	$64 %x0 = adrp @counter
+	$64 %x0 = add %x0 @counter
+	ret
+}`
+
+	file := generateFileInfo(t, src)
+	ctx := aarch64codegen.NewFileCodegenContext(file)
+
+	buffer := bytes.Buffer{}
+	results := ctx.Codegen(&buffer)
+	require.True(t, results.IsEmpty())
+
+	assert.Equal(t, []byte{
+		0x00, 0x00, 0x00, 0x90, // adrp x0, counter
+		0x00, 0x00, 0x00, 0x91, // add x0, x0, :lo12:counter
+		0xc0, 0x03, 0x5f, 0xd6, // ret
+	}, buffer.Bytes())
+
+	global := ctx.OrderedVariables[0]
+	assert.Equal(t, []aarch64codegen.Relocation{
+		{Offset: 0, Data: global, Type: aarch64codegen.PageRelocation},
+		{Offset: 4, Data: global, Type: aarch64codegen.PageOffsetRelocation},
+	}, ctx.Relocations)
+}
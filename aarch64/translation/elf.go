@@ -0,0 +1,344 @@
+package aarch64translation
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+
+	"alon.kr/x/list"
+	aarch64codegen "alon.kr/x/usm/aarch64/codegen"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/transform"
+)
+
+// The indices of the sections in the generated ELF object file.
+const (
+	elfNullSectionIndex = iota
+	elfTextSectionIndex
+	elfDataSectionIndex
+	elfRodataSectionIndex
+	elfRelaTextSectionIndex
+	elfSymtabSectionIndex
+	elfStrtabSectionIndex
+	elfShstrtabSectionIndex
+	elfSectionCount
+)
+
+const (
+	elfHeaderSize        = 64
+	elfSectionHeaderSize = 64
+	elfSymbolSize        = 24
+	elfRelaSize          = 24
+)
+
+// elfStringTable builds the contents of an ELF string table section.
+type elfStringTable struct {
+	bytes.Buffer
+}
+
+func newElfStringTable() *elfStringTable {
+	table := &elfStringTable{}
+
+	// The first byte of a string table is always the empty string.
+	table.WriteByte(0)
+	return table
+}
+
+// Appends the string to the table, and returns its offset in the table.
+func (t *elfStringTable) Add(s string) uint32 {
+	offset := uint32(t.Len())
+	t.WriteString(s)
+	t.WriteByte(0)
+	return offset
+}
+
+func elfRelocationType(typ aarch64codegen.RelocationType) elf.R_AARCH64 {
+	switch typ {
+	case aarch64codegen.CallRelocation:
+		return elf.R_AARCH64_CALL26
+	case aarch64codegen.JumpRelocation:
+		return elf.R_AARCH64_JUMP26
+	case aarch64codegen.PageRelocation:
+		return elf.R_AARCH64_ADR_PREL_PG_HI21
+	case aarch64codegen.PageOffsetRelocation:
+		return elf.R_AARCH64_ADD_ABS_LO12_NC
+	default:
+		panic("unreachable")
+	}
+}
+
+// Returns the offset that should be used after the provided offset, such that
+// it is aligned to the provided alignment.
+func alignOffset(offset uint64, alignment uint64) uint64 {
+	return (offset + alignment - 1) / alignment * alignment
+}
+
+// Returns the global object symbols of the globals in the provided data
+// section, which is placed in the ELF section with the provided index.
+func elfDataSymbols(
+	strtab *elfStringTable,
+	section *aarch64codegen.DataSection,
+	sectionIndex uint16,
+) ([]elf.Sym64, core.ResultList) {
+	symbols := make([]elf.Sym64, 0, len(section.Globals))
+	for _, global := range section.Globals {
+		size, _, results := aarch64codegen.TypeLayout(global.Type)
+		if !results.IsEmpty() {
+			return nil, results
+		}
+
+		symbols = append(symbols, elf.Sym64{
+			Name:  strtab.Add(aarch64codegen.DataSymbolName(global)),
+			Info:  elf.ST_INFO(elf.STB_GLOBAL, elf.STT_OBJECT),
+			Shndx: sectionIndex,
+			Value: section.Offsets[global],
+			Size:  size,
+		})
+	}
+
+	return symbols, core.ResultList{}
+}
+
+func ToElfObject(
+	data *transform.TargetData,
+) (*transform.TargetData, core.ResultList) {
+	file := data.Code
+	fileCtx := aarch64codegen.NewFileCodegenContext(file)
+
+	codeBuffer := bytes.Buffer{}
+	results := fileCtx.Codegen(&codeBuffer)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	// Variables are placed in the writable ".data" section, and constants in
+	// the read only ".rodata" section. Both sections are always emitted, even
+	// if they are empty, so the section indices are fixed.
+	dataSection, results := aarch64codegen.NewDataSection(fileCtx.OrderedVariables)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	rodataSection, results := aarch64codegen.NewDataSection(fileCtx.OrderedConstants)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	// MARK: Symbols
+
+	strtab := newElfStringTable()
+
+	// The first symbol is always the undefined symbol, and is followed by
+	// the local symbol of the text section. All function and data symbols are
+	// global, and thus must appear after all local symbols.
+	symbols := []elf.Sym64{
+		{},
+		{
+			Info:  elf.ST_INFO(elf.STB_LOCAL, elf.STT_SECTION),
+			Shndx: elfTextSectionIndex,
+		},
+	}
+	firstGlobalSymbolIndex := uint32(len(symbols))
+
+	symbolIndices := make(map[string]uint32, len(fileCtx.OrderedFunctions))
+	for _, function := range fileCtx.OrderedFunctions {
+		symbol := elf.Sym64{
+			Name: strtab.Add(aarch64codegen.SymbolName(function)),
+			Info: elf.ST_INFO(elf.STB_GLOBAL, elf.STT_NOTYPE),
+		}
+
+		if function.IsDefined() {
+			symbol.Info = elf.ST_INFO(elf.STB_GLOBAL, elf.STT_FUNC)
+			symbol.Shndx = elfTextSectionIndex
+			symbol.Value = fileCtx.FunctionOffsets[function]
+			symbol.Size = uint64(function.Size()) * 4
+		}
+
+		symbolIndices[function.Name] = uint32(len(symbols))
+		symbols = append(symbols, symbol)
+	}
+
+	dataSymbolIndices := make(map[*gen.DataGlobalInfo]uint32)
+	for _, section := range []struct {
+		*aarch64codegen.DataSection
+		index uint16
+	}{
+		{dataSection, elfDataSectionIndex},
+		{rodataSection, elfRodataSectionIndex},
+	} {
+		sectionSymbols, results := elfDataSymbols(strtab, section.DataSection, section.index)
+		if !results.IsEmpty() {
+			return nil, results
+		}
+
+		for i, global := range section.Globals {
+			dataSymbolIndices[global] = uint32(len(symbols) + i)
+		}
+		symbols = append(symbols, sectionSymbols...)
+	}
+
+	relocations := make([]elf.Rela64, 0, len(fileCtx.Relocations))
+	for _, relocation := range fileCtx.Relocations {
+		var symbolIndex uint32
+		if relocation.Data != nil {
+			symbolIndex = dataSymbolIndices[relocation.Data]
+		} else {
+			symbolIndex = symbolIndices[relocation.Function.Name]
+		}
+
+		relocationType := uint32(elfRelocationType(relocation.Type))
+		relocations = append(relocations, elf.Rela64{
+			Off:  relocation.Offset,
+			Info: elf.R_INFO(symbolIndex, relocationType),
+		})
+	}
+
+	// MARK: Sections
+
+	shstrtab := newElfStringTable()
+
+	text := codeBuffer.Bytes()
+	textOffset := uint64(elfHeaderSize)
+
+	dataOffset := alignOffset(textOffset+uint64(len(text)), dataSection.Alignment)
+	rodataOffset := alignOffset(dataOffset+uint64(len(dataSection.Data)), rodataSection.Alignment)
+
+	relaTextOffset := alignOffset(rodataOffset+uint64(len(rodataSection.Data)), 8)
+	relaTextSize := uint64(len(relocations)) * elfRelaSize
+
+	symtabOffset := alignOffset(relaTextOffset+relaTextSize, 8)
+	symtabSize := uint64(len(symbols)) * elfSymbolSize
+
+	strtabOffset := symtabOffset + symtabSize
+	strtabSize := uint64(strtab.Len())
+
+	sections := make([]elf.Section64, elfSectionCount)
+	sections[elfTextSectionIndex] = elf.Section64{
+		Name:      shstrtab.Add(".text"),
+		Type:      uint32(elf.SHT_PROGBITS),
+		Flags:     uint64(elf.SHF_ALLOC | elf.SHF_EXECINSTR),
+		Off:       textOffset,
+		Size:      uint64(len(text)),
+		Addralign: 4,
+	}
+	sections[elfDataSectionIndex] = elf.Section64{
+		Name:      shstrtab.Add(".data"),
+		Type:      uint32(elf.SHT_PROGBITS),
+		Flags:     uint64(elf.SHF_ALLOC | elf.SHF_WRITE),
+		Off:       dataOffset,
+		Size:      uint64(len(dataSection.Data)),
+		Addralign: dataSection.Alignment,
+	}
+	sections[elfRodataSectionIndex] = elf.Section64{
+		Name:      shstrtab.Add(".rodata"),
+		Type:      uint32(elf.SHT_PROGBITS),
+		Flags:     uint64(elf.SHF_ALLOC),
+		Off:       rodataOffset,
+		Size:      uint64(len(rodataSection.Data)),
+		Addralign: rodataSection.Alignment,
+	}
+	sections[elfRelaTextSectionIndex] = elf.Section64{
+		Name:      shstrtab.Add(".rela.text"),
+		Type:      uint32(elf.SHT_RELA),
+		Flags:     uint64(elf.SHF_INFO_LINK),
+		Off:       relaTextOffset,
+		Size:      relaTextSize,
+		Link:      elfSymtabSectionIndex,
+		Info:      elfTextSectionIndex,
+		Addralign: 8,
+		Entsize:   elfRelaSize,
+	}
+	sections[elfSymtabSectionIndex] = elf.Section64{
+		Name:      shstrtab.Add(".symtab"),
+		Type:      uint32(elf.SHT_SYMTAB),
+		Off:       symtabOffset,
+		Size:      symtabSize,
+		Link:      elfStrtabSectionIndex,
+		Info:      firstGlobalSymbolIndex,
+		Addralign: 8,
+		Entsize:   elfSymbolSize,
+	}
+	sections[elfStrtabSectionIndex] = elf.Section64{
+		Name:      shstrtab.Add(".strtab"),
+		Type:      uint32(elf.SHT_STRTAB),
+		Off:       strtabOffset,
+		Size:      strtabSize,
+		Addralign: 1,
+	}
+
+	shstrtabName := shstrtab.Add(".shstrtab")
+	shstrtabOffset := strtabOffset + strtabSize
+	sections[elfShstrtabSectionIndex] = elf.Section64{
+		Name:      shstrtabName,
+		Type:      uint32(elf.SHT_STRTAB),
+		Off:       shstrtabOffset,
+		Size:      uint64(shstrtab.Len()),
+		Addralign: 1,
+	}
+
+	sectionHeadersOffset := alignOffset(shstrtabOffset+uint64(shstrtab.Len()), 8)
+
+	// MARK: Header
+
+	header := elf.Header64{
+		Type:      uint16(elf.ET_REL),
+		Machine:   uint16(elf.EM_AARCH64),
+		Version:   uint32(elf.EV_CURRENT),
+		Shoff:     sectionHeadersOffset,
+		Ehsize:    elfHeaderSize,
+		Shentsize: elfSectionHeaderSize,
+		Shnum:     elfSectionCount,
+		Shstrndx:  elfShstrtabSectionIndex,
+	}
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	header.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	header.Ident[elf.EI_OSABI] = byte(elf.ELFOSABI_NONE)
+
+	// MARK: Write
+
+	elfBuffer := new(bytes.Buffer)
+	pad := func(offset uint64) {
+		for uint64(elfBuffer.Len()) < offset {
+			elfBuffer.WriteByte(0)
+		}
+	}
+
+	// Writes to a bytes.Buffer never fail, and fixed size structures are
+	// always encoded successfully.
+	binary.Write(elfBuffer, binary.LittleEndian, header)
+	pad(textOffset)
+	elfBuffer.Write(text)
+	pad(dataOffset)
+	elfBuffer.Write(dataSection.Data)
+	pad(rodataOffset)
+	elfBuffer.Write(rodataSection.Data)
+	pad(relaTextOffset)
+	binary.Write(elfBuffer, binary.LittleEndian, relocations)
+	pad(symtabOffset)
+	binary.Write(elfBuffer, binary.LittleEndian, symbols)
+	pad(strtabOffset)
+	elfBuffer.Write(strtab.Bytes())
+	pad(shstrtabOffset)
+	elfBuffer.Write(shstrtab.Bytes())
+	pad(sectionHeadersOffset)
+	binary.Write(elfBuffer, binary.LittleEndian, sections)
+
+	if uint64(elfBuffer.Len()) != sectionHeadersOffset+elfSectionCount*elfSectionHeaderSize {
+		return nil, list.FromSingle(core.Result{
+			{
+				Type: core.InternalErrorResult,
+				Message: fmt.Sprintf(
+					"Generated ELF object file has unexpected size of %d bytes",
+					elfBuffer.Len(),
+				),
+			},
+		})
+	}
+
+	data.Artifact = elfBuffer
+	return data, core.ResultList{}
+}
@@ -0,0 +1,210 @@
+package aarch64translation_test
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"testing"
+
+	aarch64managers "alon.kr/x/usm/aarch64/managers"
+	aarch64translation "alon.kr/x/usm/aarch64/translation"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/internal/testutil"
+	"alon.kr/x/usm/transform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateFileInfo(t *testing.T, source string) *gen.FileInfo {
+	t.Helper()
+	return testutil.MustGenerateFile(t, aarch64managers.NewGenerationContext(), source)
+}
+
+func TestElfObject(t *testing.T) {
+	src := `func @external
+
+func @local {
+	ret
+}
+
+func @f {
+	bl @local
+	bl @external
+	b @external
+}`
+
+	file := generateFileInfo(t, src)
+	data := transform.NewTargetData(nil, file)
+	data, results := aarch64translation.ToElfObject(data)
+	require.True(t, results.IsEmpty())
+
+	object, err := elf.NewFile(bytes.NewReader(data.Artifact.Bytes()))
+	require.NoError(t, err)
+
+	assert.Equal(t, elf.ELFCLASS64, object.Class)
+	assert.Equal(t, elf.ET_REL, object.Type)
+	assert.Equal(t, elf.EM_AARCH64, object.Machine)
+
+	text := object.Section(".text")
+	require.NotNil(t, text)
+	code, err := text.Data()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		0xc0, 0x03, 0x5f, 0xd6, // ret
+		0xff, 0xff, 0xff, 0x97, // bl @local
+		0x00, 0x00, 0x00, 0x94, // bl @external
+		0x00, 0x00, 0x00, 0x14, // b @external
+	}, code)
+
+	symbols, err := object.Symbols()
+	require.NoError(t, err)
+
+	// The returned symbols omit the null symbol, which has the index 0.
+	symbolIndices := map[string]int{}
+	for i, symbol := range symbols {
+		symbolIndices[symbol.Name] = i + 1
+	}
+
+	external := symbols[symbolIndices["external"]-1]
+	assert.Equal(t, elf.SHN_UNDEF, external.Section)
+	assert.Equal(t, elf.STB_GLOBAL, elf.ST_BIND(external.Info))
+
+	f := symbols[symbolIndices["f"]-1]
+	assert.Equal(t, elf.STT_FUNC, elf.ST_TYPE(f.Info))
+	assert.Equal(t, uint64(4), f.Value)
+	assert.Equal(t, uint64(12), f.Size)
+
+	relaText := object.Section(".rela.text")
+	require.NotNil(t, relaText)
+
+	relaData, err := relaText.Data()
+	require.NoError(t, err)
+
+	relocations := make([]elf.Rela64, len(relaData)/24)
+	err = binary.Read(bytes.NewReader(relaData), binary.LittleEndian, relocations)
+	require.NoError(t, err)
+
+	externalIndex := uint32(symbolIndices["external"])
+	assert.Equal(t, []elf.Rela64{
+		{Off: 8, Info: elf.R_INFO(externalIndex, uint32(elf.R_AARCH64_CALL26))},
+		{Off: 12, Info: elf.R_INFO(externalIndex, uint32(elf.R_AARCH64_JUMP26))},
+	}, relocations)
+}
+
+func TestElfObjectDataGlobals(t *testing.T) {
+	src := `var @flag $8 #1
+var @counter $64 #7
+const @limit $32 #3
+
+func @f {
+	$64 %x0 = adrp @counter
+	$64 %x0 = add %x0 @counter
+	$64 %x1 = adrp @limit
+	$64 %x1 = add %x1 @limit
+	ret
+}`
+
+	file := generateFileInfo(t, src)
+	data := transform.NewTargetData(nil, file)
+	data, results := aarch64translation.ToElfObject(data)
+	require.True(t, results.IsEmpty())
+
+	object, err := elf.NewFile(bytes.NewReader(data.Artifact.Bytes()))
+	require.NoError(t, err)
+
+	dataSection := object.Section(".data")
+	require.NotNil(t, dataSection)
+	assert.Equal(t, elf.SHF_ALLOC|elf.SHF_WRITE, dataSection.Flags)
+	assert.Equal(t, uint64(8), dataSection.Addralign)
+	content, err := dataSection.Data()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x01,                                     // @flag
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // (padding)
+		0x07, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // @counter
+	}, content)
+
+	rodataSection := object.Section(".rodata")
+	require.NotNil(t, rodataSection)
+	assert.Equal(t, elf.SHF_ALLOC, rodataSection.Flags)
+	content, err = rodataSection.Data()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x03, 0x00, 0x00, 0x00}, content) // @limit
+
+	symbols, err := object.Symbols()
+	require.NoError(t, err)
+
+	symbolIndices := map[string]int{}
+	for i, symbol := range symbols {
+		symbolIndices[symbol.Name] = i + 1
+	}
+
+	counter := symbols[symbolIndices["counter"]-1]
+	assert.Equal(t, elf.STT_OBJECT, elf.ST_TYPE(counter.Info))
+	assert.Equal(t, elf.STB_GLOBAL, elf.ST_BIND(counter.Info))
+	assert.Equal(t, ".data", object.Sections[counter.Section].Name)
+	assert.Equal(t, uint64(8), counter.Value)
+	assert.Equal(t, uint64(8), counter.Size)
+
+	limit := symbols[symbolIndices["limit"]-1]
+	assert.Equal(t, ".rodata", object.Sections[limit.Section].Name)
+	assert.Equal(t, uint64(0), limit.Value)
+	assert.Equal(t, uint64(4), limit.Size)
+
+	relaData, err := object.Section(".rela.text").Data()
+	require.NoError(t, err)
+
+	relocations := make([]elf.Rela64, len(relaData)/24)
+	err = binary.Read(bytes.NewReader(relaData), binary.LittleEndian, relocations)
+	require.NoError(t, err)
+
+	counterIndex := uint32(symbolIndices["counter"])
+	limitIndex := uint32(symbolIndices["limit"])
+	assert.Equal(t, []elf.Rela64{
+		{Off: 0, Info: elf.R_INFO(counterIndex, uint32(elf.R_AARCH64_ADR_PREL_PG_HI21))},
+		{Off: 4, Info: elf.R_INFO(counterIndex, uint32(elf.R_AARCH64_ADD_ABS_LO12_NC))},
+		{Off: 8, Info: elf.R_INFO(limitIndex, uint32(elf.R_AARCH64_ADR_PREL_PG_HI21))},
+		{Off: 12, Info: elf.R_INFO(limitIndex, uint32(elf.R_AARCH64_ADD_ABS_LO12_NC))},
+	}, relocations)
+}
+
+func TestElfObjectFunctionAddress(t *testing.T) {
+	src := `func @external
+
+func @f {
+	$64 %x0 = adrp @external
+	$64 %x0 = add %x0 @external
+	ret
+}`
+
+	file := generateFileInfo(t, src)
+	data := transform.NewTargetData(nil, file)
+	data, results := aarch64translation.ToElfObject(data)
+	require.True(t, results.IsEmpty())
+
+	object, err := elf.NewFile(bytes.NewReader(data.Artifact.Bytes()))
+	require.NoError(t, err)
+
+	symbols, err := object.Symbols()
+	require.NoError(t, err)
+
+	externalIndex := uint32(0)
+	for i, symbol := range symbols {
+		if symbol.Name == "external" {
+			externalIndex = uint32(i + 1)
+		}
+	}
+	require.NotZero(t, externalIndex)
+
+	relaData, err := object.Section(".rela.text").Data()
+	require.NoError(t, err)
+
+	relocations := make([]elf.Rela64, len(relaData)/24)
+	err = binary.Read(bytes.NewReader(relaData), binary.LittleEndian, relocations)
+	require.NoError(t, err)
+
+	assert.Equal(t, []elf.Rela64{
+		{Off: 0, Info: elf.R_INFO(externalIndex, uint32(elf.R_AARCH64_ADR_PREL_PG_HI21))},
+		{Off: 4, Info: elf.R_INFO(externalIndex, uint32(elf.R_AARCH64_ADD_ABS_LO12_NC))},
+	}, relocations)
+}
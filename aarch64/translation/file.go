@@ -3,6 +3,7 @@ package aarch64translation
 import (
 	"bytes"
 	"fmt"
+	"math/bits"
 
 	"alon.kr/x/list"
 	"alon.kr/x/macho/builder"
@@ -16,9 +17,128 @@ import (
 	"alon.kr/x/macho/load/symtab/symbol"
 	aarch64codegen "alon.kr/x/usm/aarch64/codegen"
 	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
 	"alon.kr/x/usm/transform"
 )
 
+// The Mach-O AArch64 relocation types of "adrp" and "add" instructions that
+// reference a symbol (ARM64_RELOC_PAGE21 and ARM64_RELOC_PAGEOFF12).
+const (
+	machoRelocationTypeArm64Page21    = 3
+	machoRelocationTypeArm64Pageoff12 = 4
+)
+
+// Returns the Mach-O symbol index of each data global. Data symbols are
+// appended after all function symbols: first the variables, and then the
+// constants, in order (see appendMachoDataSection).
+func machoDataSymbolIndices(
+	fileCtx *aarch64codegen.FileCodegenContext,
+) map[*gen.DataGlobalInfo]uint32 {
+	indices := make(map[*gen.DataGlobalInfo]uint32)
+	index := uint32(len(fileCtx.OrderedFunctions))
+	for _, globals := range [][]*gen.DataGlobalInfo{
+		fileCtx.OrderedVariables,
+		fileCtx.OrderedConstants,
+	} {
+		for _, global := range globals {
+			indices[global] = index
+			index++
+		}
+	}
+
+	return indices
+}
+
+// Converts the format independent relocations of the code generation context
+// to Mach-O relocations. Function symbols are referenced by their function
+// index, and data symbols follow them. Both functions and data globals may be
+// referenced by page relocations.
+func machoRelocations(
+	fileCtx *aarch64codegen.FileCodegenContext,
+) []section64.RelocationBuilder {
+	dataSymbolIndices := machoDataSymbolIndices(fileCtx)
+
+	relocations := make([]section64.RelocationBuilder, 0, len(fileCtx.Relocations))
+	for _, relocation := range fileCtx.Relocations {
+		builder := section64.RelocationBuilder{
+			Address:            uint32(relocation.Offset),
+			Length:             section64.RelocationLengthLong,
+			IsRelocationExtern: true,
+		}
+
+		if relocation.Data != nil {
+			builder.SymbolIndex = dataSymbolIndices[relocation.Data]
+		} else {
+			builder.SymbolIndex = fileCtx.FunctionIndices[relocation.Function]
+		}
+
+		switch relocation.Type {
+		case aarch64codegen.PageRelocation:
+			builder.IsRelocationPcRelative = true
+			builder.Type = machoRelocationTypeArm64Page21
+		case aarch64codegen.PageOffsetRelocation:
+			builder.Type = machoRelocationTypeArm64Pageoff12
+		default:
+			// Both "b" and "bl" instructions share the same 26 bit branch
+			// relocation type in Mach-O.
+			builder.IsRelocationPcRelative = true
+			builder.Type = section64.RelocationTypeArm64Branch26
+		}
+
+		relocations = append(relocations, builder)
+	}
+
+	return relocations
+}
+
+// Returns a fixed size, zero padded Mach-O section or segment name.
+func machoName(name string) (result [16]byte) {
+	copy(result[:], name)
+	return result
+}
+
+// Appends a Mach-O section that holds the provided data globals to the
+// provided sections, and a symbol for each of the globals. Nothing is appended
+// if there are no globals.
+func appendMachoDataSection(
+	sections []section64.Section64Builder,
+	symbols []symbol.SymbolBuilder,
+	segmentName string,
+	sectionName string,
+	globals []*gen.DataGlobalInfo,
+) ([]section64.Section64Builder, []symbol.SymbolBuilder, core.ResultList) {
+	if len(globals) == 0 {
+		return sections, symbols, core.ResultList{}
+	}
+
+	data, results := aarch64codegen.NewDataSection(globals)
+	if !results.IsEmpty() {
+		return nil, nil, results
+	}
+
+	// The alignment of Mach-O sections is stored as a power of two.
+	sections = append(sections, section64.Section64Builder{
+		SectionName: machoName(sectionName),
+		SegmentName: machoName(segmentName),
+		Data:        data.Data,
+		Align:       uint32(bits.TrailingZeros64(data.Alignment)),
+	})
+
+	// Section ordinals in the symbol table start from 1.
+	sectionIndex := uint8(len(sections))
+	for _, global := range data.Globals {
+		symbols = append(symbols, nlist64_builders.SectionNlist64Builder{
+			Name:        "_" + global.Name()[1:],
+			Type:        nlist64.ExternalSymbol | nlist64.SectionSymbolType,
+			Section:     sectionIndex,
+			Description: nlist64.ReferenceFlagUndefinedNonLazy,
+			Offset:      data.Offsets[global],
+		})
+	}
+
+	return sections, symbols, core.ResultList{}
+}
+
 func ToMachoObject(
 	data *transform.TargetData,
 ) (*transform.TargetData, core.ResultList) {
@@ -32,7 +152,7 @@ func ToMachoObject(
 	}
 
 	symbols := []symbol.SymbolBuilder{}
-	for _, function := range file.Functions {
+	for _, function := range fileCtx.OrderedFunctions {
 		symbol := nlist64_builders.SectionNlist64Builder{
 			Name:        "_" + function.Name[1:],
 			Type:        nlist64.ExternalSymbol,
@@ -53,12 +173,29 @@ func ToMachoObject(
 		SegmentName: [16]byte{'_', '_', 'T', 'E', 'X', 'T', 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
 		Data:        codeBuffer.Bytes(),
 		Flags:       section64.AttrPureInstructions | section64.AttrSomeInstructions,
-		Relocations: fileCtx.Relocations,
+		Relocations: machoRelocations(fileCtx),
+	}
+
+	// Data symbols are appended after all function symbols, so the symbol
+	// indices of functions, which are used in relocations, are unchanged.
+	sections := []section64.Section64Builder{sectionBuilder}
+	sections, symbols, results = appendMachoDataSection(
+		sections, symbols, "__DATA", "__data", fileCtx.OrderedVariables,
+	)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	sections, symbols, results = appendMachoDataSection(
+		sections, symbols, "__TEXT", "__const", fileCtx.OrderedConstants,
+	)
+	if !results.IsEmpty() {
+		return nil, results
 	}
 
 	segmentBuilder := segment64.Segment64Builder{
 		SegmentName:        [16]byte{'_', '_', 'T', 'E', 'X', 'T', 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-		Sections:           []section64.Section64Builder{sectionBuilder},
+		Sections:           sections,
 		VirtualMemorySize:  16,
 		MaxProtections:     segment64.AllowAllProtection,
 		InitialProtections: segment64.AllowAllProtection,
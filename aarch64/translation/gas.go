@@ -0,0 +1,26 @@
+package aarch64translation
+
+import (
+	"bytes"
+
+	aarch64codegen "alon.kr/x/usm/aarch64/codegen"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/transform"
+)
+
+// Converts the AArch64 code into a textual assembly file, in the GNU
+// assembler syntax, which can be assembled by "as" or "clang".
+func ToGnuAssembly(
+	data *transform.TargetData,
+) (*transform.TargetData, core.ResultList) {
+	fileCtx := aarch64codegen.NewFileCodegenContext(data.Code)
+
+	buffer := new(bytes.Buffer)
+	results := fileCtx.Assembly(buffer)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	data.Artifact = buffer
+	return data, core.ResultList{}
+}
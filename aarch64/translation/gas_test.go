@@ -0,0 +1,136 @@
+package aarch64translation_test
+
+import (
+	"testing"
+
+	aarch64translation "alon.kr/x/usm/aarch64/translation"
+	"alon.kr/x/usm/transform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGnuAssembly(t *testing.T) {
+	src := `func @external
+
+func @f {
+.entry
+	%x0 = movz $16 #42 $6 #16
+	%x0 = movk $16 #7
+	%x1 = add %x0 $12 #5
+	%x1 = mul %x1 %x0
+	str %x1 %sp $64 #8
+	%x2 = ldr %sp $64 #8
+	%xzr = subs %x2 $12 #0
+	b.eq .done
+.call
+	bl @external
+.done
+	b @external
+}`
+
+	expected := `	.text
+
+	.globl	f
+	.p2align	2
+	.type	f, %function
+f:
+.L1_entry:
+	movz	x0, #42, lsl #16
+	movk	x0, #7
+	add	x1, x0, #5
+	mul	x1, x1, x0
+	str	x1, [sp, #8]
+	ldr	x2, [sp, #8]
+	subs	xzr, x2, #0
+	b.eq	.L1_done
+.L1_call:
+	bl	external
+.L1_done:
+	b	external
+	.size	f, .-f
+`
+
+	file := generateFileInfo(t, src)
+	data := transform.NewTargetData(nil, file)
+	data, results := aarch64translation.ToGnuAssembly(data)
+	require.True(t, results.IsEmpty())
+	assert.Equal(t, expected, data.Artifact.String())
+}
+
+func TestGnuAssemblyDataGlobals(t *testing.T) {
+	src := `var @flag $8 #1
+var @counter $64 #7
+const @limit $32 #3
+
+func @f {
+.entry
+	$64 %x0 = adrp @counter
+	$64 %x0 = add %x0 @counter
+	ret
+}`
+
+	expected := `	.text
+
+	.globl	f
+	.p2align	2
+	.type	f, %function
+f:
+.L0_entry:
+	adrp	x0, counter
+	add	x0, x0, :lo12:counter
+	ret
+	.size	f, .-f
+
+	.data
+
+	.globl	flag
+	.p2align	0
+	.type	flag, %object
+	.size	flag, 1
+flag:
+	.byte	0x01
+
+	.globl	counter
+	.p2align	3
+	.type	counter, %object
+	.size	counter, 8
+counter:
+	.byte	0x07, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00
+
+	.section	.rodata
+
+	.globl	limit
+	.p2align	2
+	.type	limit, %object
+	.size	limit, 4
+limit:
+	.byte	0x03, 0x00, 0x00, 0x00
+`
+
+	file := generateFileInfo(t, src)
+	data := transform.NewTargetData(nil, file)
+	data, results := aarch64translation.ToGnuAssembly(data)
+	require.True(t, results.IsEmpty())
+	assert.Equal(t, expected, data.Artifact.String())
+}
+
+func TestGnuAssemblyLabelsAreUnique(t *testing.T) {
+	src := `func @a_b {
+.c
+	ret
+}
+
+func @a {
+.b_c
+	ret
+}`
+
+	file := generateFileInfo(t, src)
+	data := transform.NewTargetData(nil, file)
+	data, results := aarch64translation.ToGnuAssembly(data)
+	require.True(t, results.IsEmpty())
+
+	assembly := data.Artifact.String()
+	assert.Contains(t, assembly, "\n.L0_c:\n")
+	assert.Contains(t, assembly, "\n.L1_b_c:\n")
+}
@@ -0,0 +1,252 @@
+// Package compiler wires the lexer, parser, generator and transformations of
+// USM together, so the compilation pipeline can be embedded in other tools.
+package compiler
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"alon.kr/x/list"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/lex"
+	"alon.kr/x/usm/parse"
+	"alon.kr/x/usm/transform"
+)
+
+// The filepath that is used for a source that is compiled from memory.
+const SourceFilepath = "<source>"
+
+// Options of a compilation. The zero value is valid, and uses the default
+// targets.
+type Options struct {
+	// The targets and transformations that are available to the compilation.
+	// If nil, a collection of the default targets is used.
+	Targets *transform.TargetCollection
+
+	// The name of the requested output target. If not empty, a shortest chain
+	// of transformations to this target is appended to the provided chain.
+	OutputTarget string
+
+	// An optional observer, which is called after each transformation in the
+	// chain is applied.
+	Observer transform.TransformationObserver
+}
+
+// The collection of default targets that is used if no targets are provided.
+// Targets are compared by identity, so the same collection is shared by all
+// compilations.
+var defaultTargets = sync.OnceValue(NewDefaultTargets)
+
+func (o Options) targets() *transform.TargetCollection {
+	if o.Targets == nil {
+		return defaultTargets()
+	}
+	return o.Targets
+}
+
+// Compile generates the provided source with the default options, and
+// applies the provided chain of transformations to it.
+//
+// See Options.Compile for more details.
+func Compile(
+	source []byte,
+	inputTarget string,
+	chain []string,
+) (*transform.TargetData, core.ResultList) {
+	return Options{}.Compile(source, inputTarget, chain)
+}
+
+// Compile generates the provided source as the input target (which is
+// provided by name), and applies the provided chain of transformations to
+// it.
+//
+// The source is treated as a single file, with the SourceFilepath filepath.
+// To locate the returned results, use the Sources that NewSources returns
+// for the same source.
+func (o Options) Compile(
+	source []byte,
+	inputTarget string,
+	chain []string,
+) (*transform.TargetData, core.ResultList) {
+	return o.CompileSources(NewSources(source), inputTarget, chain)
+}
+
+// NewSources returns the sources that Compile uses for the provided source.
+func NewSources(source []byte) core.Sources {
+	return core.NewSources([]string{SourceFilepath}, []string{string(source)})
+}
+
+// CompileSources generates and links the provided source files as the input
+// target (which is provided by name), and applies the provided chain of
+// transformations to the result.
+func (o Options) CompileSources(
+	sources core.Sources,
+	inputTarget string,
+	chain []string,
+) (*transform.TargetData, core.ResultList) {
+	target, results := o.LookupTarget(inputTarget)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	_, chain, results = o.ResolveChain(target, chain)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	info, results := o.Generate(sources, target)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return o.Transform(transform.NewTargetData(target, info), chain)
+}
+
+// Returns the target with the provided name, or an error if there is no such
+// target.
+func (o Options) LookupTarget(name string) (*transform.Target, core.ResultList) {
+	target, ok := o.targets().NameToTarget.LookupString(name)
+	if !ok {
+		return nil, list.FromSingle(core.Result{
+			{
+				Type:    core.ErrorResult,
+				Message: fmt.Sprintf("Target \"%s\" does not exist", name),
+			},
+		})
+	}
+
+	return target, core.ResultList{}
+}
+
+// Returns the transformations of the provided target that revert the
+// transformations of the provided chain which were not reverted in it yet,
+// in the order in which the reverted transformations were applied.
+func pendingReverts(
+	target *transform.Target,
+	transformations []*transform.Transformation,
+) []*transform.Transformation {
+	pending := []*transform.Transformation{}
+	for _, transformation := range transformations {
+		pending = slices.DeleteFunc(pending, func(revert *transform.Transformation) bool {
+			return revert == transformation
+		})
+
+		if transformation.Revert == "" {
+			continue
+		}
+
+		revert, ok := target.Transformations.NameToTransformation.LookupString(transformation.Revert)
+		if ok && !slices.Contains(pending, revert) {
+			pending = append(pending, revert)
+		}
+	}
+
+	return pending
+}
+
+// Checks that the provided chain of transformation names is valid from the
+// start target, and appends to it a shortest chain to the output target, if
+// one is requested.
+//
+// If the chain to the output target changes the target, transformations that
+// should be reverted before it (see transform.Transformation.Revert) are
+// reverted first. For example, "ssa" is followed by "out-of-ssa" before the
+// code is lowered to a machine specific target.
+//
+// Returns the transformations of the resolved chain, and their names.
+func (o Options) ResolveChain(
+	start *transform.Target,
+	chain []string,
+) ([]*transform.Transformation, []string, core.ResultList) {
+	transformations, end, results := o.targets().Traverse(start, chain)
+	if !results.IsEmpty() {
+		return nil, nil, results
+	}
+
+	if o.OutputTarget == "" {
+		return transformations, chain, core.ResultList{}
+	}
+
+	outputTarget, results := o.LookupTarget(o.OutputTarget)
+	if !results.IsEmpty() {
+		return nil, nil, results
+	}
+
+	route, results := o.targets().Route(end, outputTarget)
+	if !results.IsEmpty() {
+		return nil, nil, results
+	}
+
+	if len(route) > 0 {
+		route = append(pendingReverts(end, transformations), route...)
+	}
+
+	// The provided chain is copied, so the caller's slice is not modified.
+	chain = append([]string{}, chain...)
+	for _, transformation := range route {
+		chain = append(chain, transformation.Names[0])
+	}
+
+	return append(transformations, route...), chain, core.ResultList{}
+}
+
+// Tokenizes and parses each of the source files, and generates their linked
+// internal representation as the provided target.
+func (o Options) Generate(
+	sources core.Sources,
+	target *transform.Target,
+) (*gen.FileInfo, core.ResultList) {
+	ctx := target.GenerationContext
+	if ctx == nil {
+		return nil, list.FromSingle(core.Result{
+			{
+				Type: core.ErrorResult,
+				Message: fmt.Sprintf(
+					"Target type isn't supported as input: %v",
+					target.Names[0],
+				),
+			},
+		})
+	}
+
+	nodes := make([]parse.FileNode, len(sources.Files))
+	for i, file := range sources.Files {
+		tokens, err := lex.NewTokenizer().Tokenize(sources.FileView(file))
+		if err != nil {
+			return nil, list.FromSingle(core.Result{
+				{
+					Type:    core.ErrorResult,
+					Message: fmt.Sprintf("Can't tokenize %v: %v", file.Filepath, err),
+				},
+			})
+		}
+
+		tknView := parse.NewTokenView(tokens)
+		node, result := parse.NewFileParser().Parse(&tknView)
+		if result != nil {
+			file.ResolveEof(result)
+			return nil, list.FromSingle(result)
+		}
+
+		nodes[i] = node
+	}
+
+	generator := gen.NewFileGenerator()
+	node := parse.MergeFileNodes(nodes...)
+	info, results := generator.Generate(ctx, sources.Context, node)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return info, core.ResultList{}
+}
+
+// Applies the provided chain of transformations to the target data.
+func (o Options) Transform(
+	data *transform.TargetData,
+	chain []string,
+) (*transform.TargetData, core.ResultList) {
+	return o.targets().TransformWithObserver(data, chain, o.Observer)
+}
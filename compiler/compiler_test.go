@@ -0,0 +1,99 @@
+package compiler_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"alon.kr/x/usm/compiler"
+	"alon.kr/x/usm/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const source = `func $64 @main $64 %a {
+	$64 %b = add %a $64 #1
+	ret %b
+}
+`
+
+func TestCompileEmptyChain(t *testing.T) {
+	data, results := compiler.Compile([]byte(source), "usm", nil)
+	require.True(t, results.IsEmpty())
+	assert.Equal(t, "usm", data.Target.Names[0])
+
+	buffer := bytes.Buffer{}
+	_, err := data.WriteTo(&buffer)
+	require.NoError(t, err)
+	assert.Contains(t, buffer.String(), "$64 %b = add %a $64 #1")
+}
+
+func TestCompileChain(t *testing.T) {
+	data, results := compiler.Compile([]byte(source), "usm", []string{"aarch64", "gas"})
+	require.True(t, results.IsEmpty())
+	assert.Equal(t, "aarch64-gas", data.Target.Names[0])
+
+	buffer := bytes.Buffer{}
+	_, err := data.WriteTo(&buffer)
+	require.NoError(t, err)
+	assert.Contains(t, buffer.String(), "main:")
+}
+
+func TestCompileOutputTarget(t *testing.T) {
+	options := compiler.Options{OutputTarget: "aarch64-elf"}
+	data, results := options.Compile([]byte(source), "usm", []string{"ssa"})
+	require.True(t, results.IsEmpty())
+	assert.Equal(t, "aarch64-elf-object", data.Target.Names[0])
+	assert.True(t, bytes.HasPrefix(data.Artifact.Bytes(), []byte("\x7fELF")))
+}
+
+func TestCompileOutputTargetLeavesSsaForm(t *testing.T) {
+	src := `func $64 @main $64 %a {
+.entry
+	jz %a .end
+.then
+	$64 %a = add %a $64 #1
+.end
+	ret %a
+}
+`
+
+	options := compiler.Options{OutputTarget: "aarch64-elf"}
+	target, results := options.LookupTarget("usm")
+	require.True(t, results.IsEmpty())
+
+	_, chain, results := options.ResolveChain(target, []string{"ssa"})
+	require.True(t, results.IsEmpty())
+	assert.Equal(t, []string{
+		"ssa",
+		"out-of-static-single-assignment",
+		"aarch64",
+		"elf",
+	}, chain)
+
+	data, results := options.Compile([]byte(src), "usm", []string{"ssa"})
+	require.True(t, results.IsEmpty())
+	assert.Equal(t, "aarch64-elf-object", data.Target.Names[0])
+}
+
+func TestCompileUnknownTarget(t *testing.T) {
+	_, results := compiler.Compile([]byte(source), "nope", nil)
+	require.False(t, results.IsEmpty())
+	assert.Equal(t, "Target \"nope\" does not exist", results.Head.Value[0].Message)
+}
+
+func TestCompileInvalidChain(t *testing.T) {
+	_, results := compiler.Compile([]byte(source), "usm", []string{"gas"})
+	require.False(t, results.IsEmpty())
+	assert.Equal(t, core.ErrorResult, results.Head.Value[0].Type)
+}
+
+func TestCompileResultLocation(t *testing.T) {
+	invalid := []byte("func @main {\n\tret %undefined\n}\n")
+	_, results := compiler.Compile(invalid, "usm", nil)
+	require.False(t, results.IsEmpty())
+
+	stringer := core.NewResultStringer(compiler.NewSources(invalid))
+	text := stringer.StringResult(results.Head.Value)
+	assert.True(t, strings.HasPrefix(text, compiler.SourceFilepath+":2:"), text)
+}
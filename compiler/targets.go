@@ -0,0 +1,128 @@
+package compiler
+
+import (
+	aarch64managers "alon.kr/x/usm/aarch64/managers"
+	aarch64translation "alon.kr/x/usm/aarch64/translation"
+	"alon.kr/x/usm/opt"
+	"alon.kr/x/usm/transform"
+	usmaarch64 "alon.kr/x/usm/usm/aarch64"
+	usmmanagers "alon.kr/x/usm/usm/managers"
+	usmopt "alon.kr/x/usm/usm/opt"
+	usmssa "alon.kr/x/usm/usm/ssa"
+)
+
+// NewDefaultTargets returns a new collection of all the targets that USM
+// supports, and the transformations between them.
+//
+// A new collection is returned on each call, so callers can't affect each
+// other by modifying it.
+func NewDefaultTargets() *transform.TargetCollection {
+	return transform.NewTargetCollection(
+		&transform.Target{
+			Names:             []string{"usm"},
+			Extensions:        []string{".usm"},
+			Description:       "A universal assembly language",
+			GenerationContext: usmmanagers.NewGenerationContext(),
+			Transformations: *transform.NewTransformationCollection(
+				&transform.Transformation{
+					Names:       []string{"dead-code-elimination", "dce"},
+					Description: "An optimization pass that eliminates unnecessary instructions",
+					TargetName:  "usm",
+					Transform:   opt.TransformFileToDeadCodeElimination,
+				},
+				&transform.Transformation{
+					Names:       []string{"sparse-conditional-constant-propagation", "sccp", "const-prop"},
+					Description: "An optimization pass that propagates constant values, and removes code that is never executed",
+					TargetName:  "usm",
+					Transform:   usmopt.TransformFileToConstantPropagation,
+				},
+				&transform.Transformation{
+					Names:       []string{"static-single-assignment", "ssa"},
+					Description: "An optimization pass that converts the assembly to static single assignment form",
+					TargetName:  "usm",
+					Revert:      "out-of-ssa",
+					Transform:   usmssa.TransformFileToSsaForm,
+				},
+				&transform.Transformation{
+					Names:       []string{"out-of-static-single-assignment", "out-of-ssa"},
+					Description: "Converts the assembly out of static single assignment form, by replacing phi instructions with moves",
+					TargetName:  "usm",
+					Transform:   usmssa.TransformFileOutOfSsaForm,
+				},
+				&transform.Transformation{
+					Names:       []string{"verify"},
+					Description: "Checks the internal invariants of the assembly representation, for debugging passes",
+					TargetName:  "usm",
+					Transform:   opt.TransformFileVerify,
+				},
+				&transform.Transformation{
+					Names:       []string{"verify-ssa"},
+					Description: "Checks the internal invariants of the assembly representation, and that it is in static single assignment form",
+					TargetName:  "usm",
+					Transform:   opt.TransformFileVerifySsa,
+				},
+				&transform.Transformation{
+					Names:       []string{"aarch64", "arm64"},
+					Description: "Converts the universal assembly to matching machine specific AArch64 assembly",
+					TargetName:  "aarch64",
+					Transform:   usmaarch64.TransformFileToAarch64,
+				},
+			),
+		},
+
+		&transform.Target{
+			Names:             []string{"aarch64", "arm64"},
+			Extensions:        []string{".aarch64.usm", ".arm64.usm"},
+			Description:       "AArch64 (ARM64, ARMv8) assembly",
+			GenerationContext: aarch64managers.NewGenerationContext(),
+			Transformations: *transform.NewTransformationCollection(
+				&transform.Transformation{
+					Names:      []string{"macho", "macho-obj", "macho-object"},
+					TargetName: "aarch64-macho-object",
+					Transform:  aarch64translation.ToMachoObject,
+				},
+				&transform.Transformation{
+					Names:      []string{"elf", "elf-obj", "elf-object"},
+					TargetName: "aarch64-elf-object",
+					Transform:  aarch64translation.ToElfObject,
+				},
+				&transform.Transformation{
+					Names:      []string{"gas", "gnu-as", "gnu-assembly"},
+					TargetName: "aarch64-gas",
+					Transform:  aarch64translation.ToGnuAssembly,
+				},
+			),
+		},
+
+		&transform.Target{
+			Names: []string{
+				"aarch64-macho-object",
+				"aarch64-macho-obj",
+				"aarch64-macho",
+			},
+			Extensions:  []string{".o"},
+			Description: "Mach-O object file containing aarch64 assembly",
+			Binary:      true,
+		},
+
+		&transform.Target{
+			Names: []string{
+				"aarch64-elf-object",
+				"aarch64-elf-obj",
+				"aarch64-elf",
+			},
+			Extensions:  []string{".o"},
+			Description: "ELF relocatable object file containing aarch64 assembly",
+			Binary:      true,
+		},
+
+		&transform.Target{
+			Names: []string{
+				"aarch64-gas",
+				"aarch64-gnu-assembly",
+			},
+			Extensions:  []string{".s", ".S"},
+			Description: "AArch64 assembly in the GNU assembler syntax",
+		},
+	)
+}
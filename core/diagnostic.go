@@ -0,0 +1,223 @@
+package core
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+)
+
+// MARK: Diagnostic
+
+// The location of a diagnostic, in a form that can be serialized.
+type DiagnosticLocation struct {
+	File  string         `json:"file"`
+	Start SourcePosition `json:"start"`
+	End   SourcePosition `json:"end"`
+}
+
+// Diagnostic is a machine readable representation of a result.
+//
+// The first details of the result form the diagnostic itself, and the rest of
+// them (usually hints) are attached to it as notes.
+type Diagnostic struct {
+	Type     string              `json:"type"`
+	Message  string              `json:"message"`
+	Location *DiagnosticLocation `json:"location,omitempty"`
+	Notes    []Diagnostic        `json:"notes,omitempty"`
+}
+
+func (l *SourceLocator) diagnosticFromDetails(details ResultDetails) Diagnostic {
+	diagnostic := Diagnostic{
+		Type:    details.Type.String(),
+		Message: details.Message,
+	}
+
+	if details.Location != nil {
+		location := l.Locate(*details.Location)
+		if location != nil {
+			diagnostic.Location = &DiagnosticLocation{
+				File:  location.File.Filepath,
+				Start: location.Start,
+				End:   location.End,
+			}
+		}
+	}
+
+	return diagnostic
+}
+
+// Converts the provided results to diagnostics. Empty results are skipped.
+func (l *SourceLocator) Diagnostics(results ResultList) []Diagnostic {
+	diagnostics := []Diagnostic{}
+	for result := range results.Range() {
+		if len(result) == 0 {
+			continue
+		}
+
+		diagnostic := l.diagnosticFromDetails(result[0])
+		for _, details := range result[1:] {
+			note := l.diagnosticFromDetails(details)
+			diagnostic.Notes = append(diagnostic.Notes, note)
+		}
+
+		diagnostics = append(diagnostics, diagnostic)
+	}
+
+	return diagnostics
+}
+
+// MARK: JSON
+
+type diagnosticsJson struct {
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// Writes the provided diagnostics as a single JSON document, of the form
+// {"diagnostics": [...]}.
+func WriteDiagnosticsJson(writer io.Writer, diagnostics []Diagnostic) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(diagnosticsJson{Diagnostics: diagnostics})
+}
+
+// MARK: SARIF
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name string `json:"name"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations,omitempty"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+}
+
+type sarifLocation struct {
+	Message          *sarifMessage          `json:"message,omitempty"`
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	Uri string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   UsmUint `json:"startLine"`
+	StartColumn UsmUint `json:"startColumn"`
+	EndLine     UsmUint `json:"endLine"`
+	EndColumn   UsmUint `json:"endColumn"`
+}
+
+// Returns the SARIF level that matches the diagnostic type.
+func sarifLevel(diagnostic Diagnostic) string {
+	switch diagnostic.Type {
+	case ErrorResult.String(), InternalErrorResult.String():
+		return "error"
+	case WarningResult.String():
+		return "warning"
+	case HintResult.String():
+		return "note"
+	default:
+		return "none"
+	}
+}
+
+func sarifPhysicalLocationFromDiagnostic(
+	location *DiagnosticLocation,
+) *sarifPhysicalLocation {
+	if location == nil {
+		return nil
+	}
+
+	return &sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{
+			Uri: filepath.ToSlash(location.File),
+		},
+		Region: sarifRegion{
+			StartLine:   location.Start.Line,
+			StartColumn: location.Start.Column,
+			EndLine:     location.End.Line,
+			EndColumn:   location.End.Column,
+		},
+	}
+}
+
+func sarifResultFromDiagnostic(diagnostic Diagnostic) sarifResult {
+	result := sarifResult{
+		Level:   sarifLevel(diagnostic),
+		Message: sarifMessage{Text: diagnostic.Message},
+	}
+
+	if diagnostic.Location != nil {
+		result.Locations = []sarifLocation{{
+			PhysicalLocation: sarifPhysicalLocationFromDiagnostic(diagnostic.Location),
+		}}
+	}
+
+	// SARIF results do not have nested results, so notes are reported as
+	// related locations, which can hold a message without a location.
+	for _, note := range diagnostic.Notes {
+		result.RelatedLocations = append(result.RelatedLocations, sarifLocation{
+			Message:          &sarifMessage{Text: note.Message},
+			PhysicalLocation: sarifPhysicalLocationFromDiagnostic(note.Location),
+		})
+	}
+
+	return result
+}
+
+// Writes the provided diagnostics as a SARIF (version 2.1.0) log, with a
+// single run of the provided tool.
+func WriteDiagnosticsSarif(
+	writer io.Writer,
+	toolName string,
+	diagnostics []Diagnostic,
+) error {
+	results := make([]sarifResult, len(diagnostics))
+	for i, diagnostic := range diagnostics {
+		results[i] = sarifResultFromDiagnostic(diagnostic)
+	}
+
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: sarifDriver{Name: toolName}},
+			Results: results,
+		}},
+	}
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(log)
+}
@@ -0,0 +1,109 @@
+package core_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"alon.kr/x/list"
+	"alon.kr/x/usm/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDiagnostics(t *testing.T) []core.Diagnostic {
+	t.Helper()
+
+	sources := core.NewSources(
+		[]string{"a.usm", "b.usm"},
+		[]string{"one\ntwo\n", "three\n  four\n"},
+	)
+
+	a, b := sources.Files[0], sources.Files[1]
+	errorView := core.UnmanagedSourceView{Start: b.View.Start + 8, End: b.View.Start + 12}
+	hintView := core.UnmanagedSourceView{Start: a.View.Start + 4, End: a.View.Start + 7}
+
+	results := list.FromSingle(core.Result{
+		{Type: core.ErrorResult, Message: "error", Location: &errorView},
+		{Type: core.HintResult, Message: "hint", Location: &hintView},
+		{Type: core.HintResult, Message: "note"},
+	})
+
+	locator := core.NewSourceLocator(sources)
+	return locator.Diagnostics(results)
+}
+
+func TestDiagnosticsFromResults(t *testing.T) {
+	diagnostics := newTestDiagnostics(t)
+	require.Len(t, diagnostics, 1)
+
+	diagnostic := diagnostics[0]
+	assert.Equal(t, "error", diagnostic.Type)
+	assert.Equal(t, "error", diagnostic.Message)
+	assert.Equal(t, &core.DiagnosticLocation{
+		File:  "b.usm",
+		Start: core.SourcePosition{Line: 2, Column: 3},
+		End:   core.SourcePosition{Line: 2, Column: 7},
+	}, diagnostic.Location)
+
+	require.Len(t, diagnostic.Notes, 2)
+	assert.Equal(t, "hint", diagnostic.Notes[0].Type)
+	assert.Equal(t, "a.usm", diagnostic.Notes[0].Location.File)
+	assert.Equal(t, core.SourcePosition{Line: 2, Column: 1}, diagnostic.Notes[0].Location.Start)
+	assert.Nil(t, diagnostic.Notes[1].Location)
+}
+
+func TestWriteDiagnosticsJson(t *testing.T) {
+	buffer := bytes.Buffer{}
+	err := core.WriteDiagnosticsJson(&buffer, newTestDiagnostics(t))
+	require.NoError(t, err)
+
+	var document struct {
+		Diagnostics []core.Diagnostic `json:"diagnostics"`
+	}
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &document))
+	assert.Equal(t, newTestDiagnostics(t), document.Diagnostics)
+}
+
+func TestWriteEmptyDiagnosticsJson(t *testing.T) {
+	buffer := bytes.Buffer{}
+	err := core.WriteDiagnosticsJson(&buffer, []core.Diagnostic{})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"diagnostics": []}`, buffer.String())
+}
+
+func TestWriteDiagnosticsSarif(t *testing.T) {
+	buffer := bytes.Buffer{}
+	err := core.WriteDiagnosticsSarif(&buffer, "usm", newTestDiagnostics(t))
+	require.NoError(t, err)
+
+	expected := `{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": [{
+			"tool": {"driver": {"name": "usm"}},
+			"results": [{
+				"level": "error",
+				"message": {"text": "error"},
+				"locations": [{
+					"physicalLocation": {
+						"artifactLocation": {"uri": "b.usm"},
+						"region": {"startLine": 2, "startColumn": 3, "endLine": 2, "endColumn": 7}
+					}
+				}],
+				"relatedLocations": [
+					{
+						"message": {"text": "hint"},
+						"physicalLocation": {
+							"artifactLocation": {"uri": "a.usm"},
+							"region": {"startLine": 2, "startColumn": 1, "endLine": 2, "endColumn": 4}
+						}
+					},
+					{"message": {"text": "note"}}
+				]
+			}]
+		}]
+	}`
+
+	assert.JSONEq(t, expected, buffer.String())
+}
@@ -0,0 +1,211 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The number of unchanged lines that are printed around each change in a
+// unified diff.
+const diffContextLines = 3
+
+type diffOperation byte
+
+const (
+	diffEqual  diffOperation = ' '
+	diffDelete diffOperation = '-'
+	diffInsert diffOperation = '+'
+)
+
+type diffEdit struct {
+	Operation diffOperation
+	Line      string
+}
+
+// Splits the text into lines. Each line keeps its newline character, except
+// possibly the last one.
+func diffLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Computes a shortest edit script that transforms the old lines into the new
+// lines, using the Myers diff algorithm.
+func myersDiff(old []string, new []string) []diffEdit {
+	n, m := len(old), len(new)
+	limit := n + m
+	offset := limit + 1
+	v := make([]int, 2*limit+2)
+	trace := [][]int{}
+
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && old[x] == new[y] {
+				x++
+				y++
+			}
+
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrackDiff(old, new, trace, offset)
+			}
+		}
+	}
+
+	return nil // unreachable: the loop always finds a path.
+}
+
+// Reconstructs the edit script from the trace of the Myers algorithm.
+func backtrackDiff(old []string, new []string, trace [][]int, offset int) []diffEdit {
+	edits := []diffEdit{}
+	x, y := len(old), len(new)
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, diffEdit{diffEqual, old[x]})
+		}
+
+		if d > 0 {
+			if x == prevX {
+				y--
+				edits = append(edits, diffEdit{diffInsert, new[y]})
+			} else {
+				x--
+				edits = append(edits, diffEdit{diffDelete, old[x]})
+			}
+		}
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+
+	return edits
+}
+
+// Formats the start and length of a hunk range, as in GNU diff: the length
+// is omitted if it is one, and an empty range starts at the line before it.
+func hunkRange(start int, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	} else if length == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
+
+func writeHunk(builder *strings.Builder, edits []diffEdit, oldStart int, newStart int) {
+	oldLength, newLength := 0, 0
+	for _, edit := range edits {
+		if edit.Operation != diffInsert {
+			oldLength++
+		}
+		if edit.Operation != diffDelete {
+			newLength++
+		}
+	}
+
+	fmt.Fprintf(
+		builder,
+		"@@ -%s +%s @@\n",
+		hunkRange(oldStart, oldLength),
+		hunkRange(newStart, newLength),
+	)
+
+	for _, edit := range edits {
+		builder.WriteByte(byte(edit.Operation))
+		builder.WriteString(edit.Line)
+		if !strings.HasSuffix(edit.Line, "\n") {
+			builder.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// UnifiedDiff returns the line based difference between the old and new
+// texts, in the unified diff format, with the provided file names in its
+// header. Returns an empty string if the texts are equal.
+func UnifiedDiff(oldName string, newName string, old string, new string) string {
+	if old == new {
+		return ""
+	}
+
+	edits := myersDiff(diffLines(old), diffLines(new))
+
+	builder := strings.Builder{}
+	fmt.Fprintf(&builder, "--- %s\n+++ %s\n", oldName, newName)
+
+	// The indices of the old and new lines at the start of each edit.
+	oldIndices := make([]int, len(edits)+1)
+	newIndices := make([]int, len(edits)+1)
+	for i, edit := range edits {
+		oldIndices[i+1], newIndices[i+1] = oldIndices[i], newIndices[i]
+		if edit.Operation != diffInsert {
+			oldIndices[i+1]++
+		}
+		if edit.Operation != diffDelete {
+			newIndices[i+1]++
+		}
+	}
+
+	i := 0
+	for i < len(edits) {
+		if edits[i].Operation == diffEqual {
+			i++
+			continue
+		}
+
+		// A change is found. Extend the hunk until there is a run of equal
+		// lines that is long enough to separate it from the next change.
+		start := max(0, i-diffContextLines)
+		end := i
+		for end < len(edits) {
+			if edits[end].Operation != diffEqual {
+				end++
+				continue
+			}
+
+			run := end
+			for run < len(edits) && edits[run].Operation == diffEqual {
+				run++
+			}
+
+			if run == len(edits) || run-end > 2*diffContextLines {
+				end = min(run, end+diffContextLines)
+				break
+			}
+			end = run
+		}
+
+		writeHunk(&builder, edits[start:end], oldIndices[start], newIndices[start])
+		i = end
+	}
+
+	return builder.String()
+}
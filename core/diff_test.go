@@ -0,0 +1,51 @@
+package core_test
+
+import (
+	"testing"
+
+	"alon.kr/x/usm/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnifiedDiffEqual(t *testing.T) {
+	assert.Equal(t, "", core.UnifiedDiff("a", "b", "x\ny\n", "x\ny\n"))
+}
+
+func TestUnifiedDiffSingleChange(t *testing.T) {
+	old := "1\n2\n3\n4\n5\n6\n7\n8\n9\n"
+	new := "1\n2\n3\n4\nfive\n6\n7\n8\n9\n"
+	expected := "--- a\n+++ b\n" +
+		"@@ -2,7 +2,7 @@\n" +
+		" 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n"
+
+	assert.Equal(t, expected, core.UnifiedDiff("a", "b", old, new))
+}
+
+func TestUnifiedDiffSeparateHunks(t *testing.T) {
+	old := "a\n1\n2\n3\n4\n5\n6\n7\nb\n"
+	new := "A\n1\n2\n3\n4\n5\n6\n7\nB\n"
+	expected := "--- x\n+++ y\n" +
+		"@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n" +
+		"@@ -6,4 +6,4 @@\n 5\n 6\n 7\n-b\n+B\n"
+
+	assert.Equal(t, expected, core.UnifiedDiff("x", "y", old, new))
+}
+
+func TestUnifiedDiffMergedHunks(t *testing.T) {
+	old := "a\n1\n2\n3\n4\n5\n6\nb\n"
+	new := "A\n1\n2\n3\n4\n5\n6\nB\n"
+	expected := "--- x\n+++ y\n" +
+		"@@ -1,8 +1,8 @@\n-a\n+A\n 1\n 2\n 3\n 4\n 5\n 6\n-b\n+B\n"
+
+	assert.Equal(t, expected, core.UnifiedDiff("x", "y", old, new))
+}
+
+func TestUnifiedDiffInsertIntoEmpty(t *testing.T) {
+	expected := "--- x\n+++ y\n@@ -0,0 +1,2 @@\n+a\n+b\n"
+	assert.Equal(t, expected, core.UnifiedDiff("x", "y", "", "a\nb\n"))
+}
+
+func TestUnifiedDiffNoNewlineAtEndOfFile(t *testing.T) {
+	expected := "--- x\n+++ y\n@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+a\n"
+	assert.Equal(t, expected, core.UnifiedDiff("x", "y", "a", "a\n"))
+}
@@ -0,0 +1,91 @@
+package core
+
+import "sort"
+
+// A position in a source file. Both the line and the column are one based,
+// and the column is counted in characters (runes).
+type SourcePosition struct {
+	Line   UsmUint `json:"line"`
+	Column UsmUint `json:"column"`
+}
+
+// The location of a view in a source file.
+// The end position points to the character after the end of the view.
+type SourceLocation struct {
+	File  *SourceFile
+	Start SourcePosition
+	End   SourcePosition
+}
+
+// SourceLocator converts views of the shared source context into locations,
+// which are expressed with files, lines and columns.
+type SourceLocator struct {
+	Sources
+	LineStarts []UsmUint
+}
+
+func calculateLineStartsFromSource(ctx SourceContext) []UsmUint {
+	starts := []UsmUint{0}
+	for i, c := range ctx {
+		if c == '\n' {
+			starts = append(starts, UsmUint(i+1))
+		}
+	}
+	return starts
+}
+
+func NewSourceLocator(sources Sources) SourceLocator {
+	return SourceLocator{
+		Sources:    sources,
+		LineStarts: calculateLineStartsFromSource(sources.Context),
+	}
+}
+
+// Returns the (zero based) row in the shared source context that contains
+// the provided offset.
+func (l *SourceLocator) offsetToRow(offset SourceViewOffset) UsmUint {
+	return UsmUint(sort.Search(len(l.LineStarts), func(i int) bool {
+		return offset < l.LineStarts[i]
+	}) - 1)
+}
+
+func (l *SourceLocator) offsetToPosition(
+	file *SourceFile,
+	offset SourceViewOffset,
+) SourcePosition {
+	offset = min(offset, file.View.End)
+	row := l.offsetToRow(offset)
+	return SourcePosition{
+		Line:   row - l.offsetToRow(file.View.Start) + 1,
+		Column: offset - l.LineStarts[row] + 1,
+	}
+}
+
+// Returns the location of the provided view, or nil if the view does not
+// point into any of the files (for example, unresolved end of file locations).
+func (l *SourceLocator) Locate(view UnmanagedSourceView) *SourceLocation {
+	file := l.FileOf(view)
+	if file == nil {
+		return nil
+	}
+
+	return &SourceLocation{
+		File:  file,
+		Start: l.offsetToPosition(file, view.Start),
+		End:   l.offsetToPosition(file, max(view.Start, view.End)),
+	}
+}
+
+// Returns the content of the provided (one based) line of the file, without
+// the trailing newline.
+func (l *SourceLocator) Line(file *SourceFile, line UsmUint) string {
+	row := l.offsetToRow(file.View.Start) + line - 1
+	lineStart := l.LineStarts[row]
+	var lineEnd UsmUint
+	if row >= UsmUint(len(l.LineStarts)-1) {
+		lineEnd = UsmUint(len(l.Context))
+	} else {
+		lineEnd = l.LineStarts[row+1] - 1
+	}
+	return string(l.Context[lineStart:lineEnd])
+}
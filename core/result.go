@@ -2,7 +2,6 @@ package core
 
 import (
 	"fmt"
-	"sort"
 	"strings"
 
 	"alon.kr/x/list"
@@ -25,6 +24,24 @@ const (
 	DebugResult
 )
 
+// Returns the name of the result type, as used in machine readable output.
+func (t ResultType) String() string {
+	switch t {
+	case ErrorResult:
+		return "error"
+	case InternalErrorResult:
+		return "internal-error"
+	case WarningResult:
+		return "warning"
+	case HintResult:
+		return "hint"
+	case DebugResult:
+		return "debug"
+	default:
+		return "unknown"
+	}
+}
+
 type Result []ResultDetails
 
 type ResultDetails struct {
@@ -36,26 +53,14 @@ type ResultDetails struct {
 type ResultList = list.List[Result]
 
 type ResultStringer struct {
-	SourceContext
+	SourceLocator
 	Titles             map[ResultType]string
 	SourceErrorPointer string
-	Filepath           string
-	LineStarts         []UsmUint
-}
-
-func calculateLineStartsFromSource(ctx SourceContext) []UsmUint {
-	starts := []UsmUint{0}
-	for i, c := range ctx {
-		if c == '\n' {
-			starts = append(starts, UsmUint(i+1))
-		}
-	}
-	return starts
 }
 
-func NewResultStringer(ctx SourceContext, Filepath string) ResultStringer {
+func NewResultStringer(sources Sources) ResultStringer {
 	return ResultStringer{
-		SourceContext: ctx,
+		SourceLocator: NewSourceLocator(sources),
 		Titles: map[ResultType]string{
 			InternalErrorResult: color.New(color.Bold, color.BgRed, color.FgWhite).Sprint(" panic "),
 			ErrorResult:         color.New(color.Bold, color.FgRed).Sprint("error:"),
@@ -63,40 +68,16 @@ func NewResultStringer(ctx SourceContext, Filepath string) ResultStringer {
 			HintResult:          color.New(color.Bold, color.FgCyan).Sprint("note:"),
 		},
 		SourceErrorPointer: color.New(color.Bold, color.FgMagenta).Sprint("^"),
-		LineStarts:         calculateLineStartsFromSource(ctx),
-		Filepath:           Filepath,
 	}
 }
 
-func (w *ResultStringer) viewToLocation(
-	view UnmanagedSourceView,
-) (line UsmUint, col UsmUint) {
-	start := view.Start
-	row := sort.Search(len(w.LineStarts), func(i int) bool {
-		return start < w.LineStarts[i]
-	}) - 1
-	col = start - w.LineStarts[row]
-	return UsmUint(row), UsmUint(col)
-}
-
-func (w *ResultStringer) getLine(row UsmUint) string {
-	lineStart := w.LineStarts[row]
-	var lineEnd UsmUint
-	if row >= UsmUint(len(w.LineStarts)-1) {
-		lineEnd = UsmUint(len(w.SourceContext))
-	} else {
-		lineEnd = w.LineStarts[row+1] - 1
-	}
-	return string(w.SourceContext[lineStart:lineEnd])
-}
-
-func (w *ResultStringer) stringLineContext(row, col UsmUint) string {
-	firstLinePad := fmt.Sprintf("%*d", 5, row+1)
+func (w *ResultStringer) stringLineContext(location *SourceLocation) string {
+	firstLinePad := fmt.Sprintf("%*d", 5, location.Start.Line)
 	border := " | "
-	line := w.getLine(row)
+	line := w.Line(location.File, location.Start.Line)
 
 	secondLinePad := strings.Repeat(" ", len(firstLinePad))
-	pointerLine := strings.Repeat(" ", int(col)) + w.SourceErrorPointer
+	pointerLine := strings.Repeat(" ", int(location.Start.Column-1)) + w.SourceErrorPointer
 
 	firstLine := firstLinePad + border + line
 	secondLine := secondLinePad + border + pointerLine
@@ -107,9 +88,18 @@ func (w *ResultStringer) StringResultDetails(details ResultDetails) string {
 	locationPrefix := ""
 	locationSuffix := ""
 	if details.Location != nil {
-		row, col := w.viewToLocation(*details.Location)
-		locationPrefix = fmt.Sprintf("%s:%d:%d: ", w.Filepath, row+1, col+1)
-		locationSuffix = w.stringLineContext(row, col)
+		// Locations that do not point into any file (for example, unresolved
+		// end of file locations) are printed without a location.
+		location := w.Locate(*details.Location)
+		if location != nil {
+			locationPrefix = fmt.Sprintf(
+				"%s:%d:%d: ",
+				location.File.Filepath,
+				location.Start.Line,
+				location.Start.Column,
+			)
+			locationSuffix = w.stringLineContext(location)
+		}
 	}
 
 	title := w.Titles[details.Type]
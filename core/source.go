@@ -0,0 +1,112 @@
+package core
+
+import (
+	"io"
+	"os"
+
+	"alon.kr/x/view"
+)
+
+// A single file that takes part in a compilation.
+type SourceFile struct {
+	Filepath string
+
+	// The part of the shared source context (see Sources) that holds the
+	// content of the file.
+	View UnmanagedSourceView
+}
+
+// Sources holds the source code of all files in a compilation.
+//
+// The files are laid out one after the other in a single source context that
+// is shared between all of them, so every location in the context belongs to
+// exactly one file. This allows nodes, infos and results that originate from
+// different files to be used together, and the file of a location to be
+// recovered from the location itself.
+type Sources struct {
+	Context SourceContext
+	Files   []SourceFile
+}
+
+// Creates the sources of a compilation from the contents of its files.
+// The provided filepaths and contents are matched by their indices.
+func NewSources(filepaths []string, contents []string) Sources {
+	context := SourceContext{}
+	files := make([]SourceFile, len(filepaths))
+
+	for i, filepath := range filepaths {
+		start := SourceViewOffset(len(context))
+		context = append(context, []rune(contents[i])...)
+		end := SourceViewOffset(len(context))
+
+		// Files are separated by a newline, so each file starts on a new
+		// line, and locations at the end of a file are not shared with the
+		// start of the next file.
+		context = append(context, '\n')
+
+		files[i] = SourceFile{
+			Filepath: filepath,
+			View:     UnmanagedSourceView{Start: start, End: end},
+		}
+	}
+
+	return Sources{
+		Context: context,
+		Files:   files,
+	}
+}
+
+// Reads the content of the provided files, and creates their sources.
+func ReadSources(filepaths []string) (Sources, error) {
+	contents := make([]string, len(filepaths))
+	for i, filepath := range filepaths {
+		file, err := os.Open(filepath)
+		if err != nil {
+			return Sources{}, err
+		}
+
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return Sources{}, err
+		}
+
+		contents[i] = string(data)
+	}
+
+	return NewSources(filepaths, contents), nil
+}
+
+// Returns a view of the content of the provided file, in the shared context.
+func (s Sources) FileView(file SourceFile) SourceView {
+	return view.NewView[rune, SourceViewOffset](s.Context).Subview(
+		file.View.Start,
+		file.View.End,
+	)
+}
+
+// Returns the file that the provided location points into, or nil if the
+// location does not point into any of the files.
+func (s Sources) FileOf(location UnmanagedSourceView) *SourceFile {
+	for i := range s.Files {
+		file := &s.Files[i]
+		if file.View.Start <= location.Start && location.Start <= file.View.End {
+			return file
+		}
+	}
+
+	return nil
+}
+
+// Replaces end of file locations (see NewEofUnmanagedSourceView) in the
+// provided result with locations that point to the end of the file.
+func (f SourceFile) ResolveEof(result Result) {
+	eof := NewEofUnmanagedSourceView()
+	end := UnmanagedSourceView{Start: f.View.End, End: f.View.End}
+
+	for i := range result {
+		if result[i].Location != nil && *result[i].Location == eof {
+			result[i].Location = &end
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package core_test
+
+import (
+	"strings"
+	"testing"
+
+	"alon.kr/x/usm/core"
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourcesFileViews(t *testing.T) {
+	sources := core.NewSources(
+		[]string{"a.usm", "b.usm"},
+		[]string{"first\n", "second"},
+	)
+
+	require.Len(t, sources.Files, 2)
+	a, b := sources.Files[0], sources.Files[1]
+	assert.Equal(t, "first\n", string(sources.FileView(a).Raw()))
+	assert.Equal(t, "second", string(sources.FileView(b).Raw()))
+	assert.Less(t, a.View.End, b.View.Start)
+
+	assert.Equal(t, &sources.Files[0], sources.FileOf(a.View))
+	assert.Equal(t, &sources.Files[1], sources.FileOf(core.UnmanagedSourceView{
+		Start: b.View.Start + 1,
+		End:   b.View.Start + 2,
+	}))
+	assert.Nil(t, sources.FileOf(core.NewEofUnmanagedSourceView()))
+}
+
+func TestSourceFileResolveEof(t *testing.T) {
+	sources := core.NewSources([]string{"a.usm"}, []string{"func"})
+	file := sources.Files[0]
+
+	eof := core.NewEofUnmanagedSourceView()
+	result := core.Result{{Type: core.ErrorResult, Location: &eof}}
+	file.ResolveEof(result)
+
+	assert.Equal(t, file.View.End, result[0].Location.Start)
+	assert.Equal(t, &sources.Files[0], sources.FileOf(*result[0].Location))
+}
+
+func TestResultStringerMultipleFiles(t *testing.T) {
+	color.NoColor = true
+
+	sources := core.NewSources(
+		[]string{"a.usm", "b.usm"},
+		[]string{"one\ntwo", "three\n  four\n"},
+	)
+
+	b := sources.Files[1]
+	location := core.UnmanagedSourceView{Start: b.View.Start + 8, End: b.View.Start + 12}
+	stringer := core.NewResultStringer(sources)
+	s := stringer.StringResult(core.Result{{
+		Type:     core.ErrorResult,
+		Message:  "message",
+		Location: &location,
+	}})
+
+	lines := strings.Split(s, "\n")
+	require.GreaterOrEqual(t, len(lines), 3)
+	assert.Equal(t, "b.usm:2:3: error: message", lines[0])
+	assert.Equal(t, "    2 |   four", lines[1])
+	assert.Equal(t, "      |   ^", lines[2])
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"alon.kr/x/list"
+	"alon.kr/x/usm/core"
+)
+
+// Exit codes of the usm command line tool.
+const (
+	// The command completed successfully.
+	ExitSuccess = 0
+
+	// The input contains errors, which are reported as diagnostics.
+	ExitInputError = 1
+
+	// The command line is invalid: unknown flags, missing arguments,
+	// unrecognized input file types or an unsupported transformation chain.
+	ExitUsageError = 2
+
+	// An input file could not be read, or an output file could not be written.
+	ExitIOError = 3
+
+	// The compiler encountered an internal error. This is always a bug.
+	ExitInternalError = 4
+)
+
+const exitCodesHelp = `Exit codes:
+  0  success
+  1  the input contains errors
+  2  invalid command line usage
+  3  reading the input or writing the output failed
+  4  internal compiler error`
+
+// Supported values of the --diagnostics-format flag.
+const (
+	textDiagnosticsFormat  = "text"
+	jsonDiagnosticsFormat  = "json"
+	sarifDiagnosticsFormat = "sarif"
+)
+
+var diagnosticsFormat string
+
+func validateDiagnosticsFormat() error {
+	switch diagnosticsFormat {
+	case textDiagnosticsFormat, jsonDiagnosticsFormat, sarifDiagnosticsFormat:
+		return nil
+	default:
+		return fmt.Errorf(
+			"invalid diagnostics format %q (expected %q, %q or %q)",
+			diagnosticsFormat,
+			textDiagnosticsFormat,
+			jsonDiagnosticsFormat,
+			sarifDiagnosticsFormat,
+		)
+	}
+}
+
+// Writes the provided results to stderr, in the requested diagnostics format.
+//
+// In the machine readable formats, a single document is always written, even
+// if there are no results.
+func printResults(sources core.Sources, results core.ResultList) {
+	var err error
+
+	switch diagnosticsFormat {
+	case jsonDiagnosticsFormat:
+		locator := core.NewSourceLocator(sources)
+		err = core.WriteDiagnosticsJson(os.Stderr, locator.Diagnostics(results))
+	case sarifDiagnosticsFormat:
+		locator := core.NewSourceLocator(sources)
+		err = core.WriteDiagnosticsSarif(os.Stderr, "usm", locator.Diagnostics(results))
+	default:
+		stringer := core.NewResultStringer(sources)
+		for result := range results.Range() {
+			fmt.Fprint(os.Stderr, stringer.StringResult(result))
+		}
+	}
+
+	if err != nil {
+		os.Exit(ExitIOError)
+	}
+}
+
+// Reports the successful completion of a command. In the machine readable
+// diagnostics formats, this writes an empty diagnostics document.
+func printSuccess(sources core.Sources) {
+	if diagnosticsFormat != textDiagnosticsFormat {
+		printResults(sources, core.ResultList{})
+	}
+}
+
+func containsInternalError(results core.ResultList) bool {
+	for result := range results.Range() {
+		for _, details := range result {
+			if details.Type == core.InternalErrorResult {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Prints the provided results and exits with the provided exit code, or with
+// ExitInternalError if any of the results is an internal error.
+func printResultsAndExit(sources core.Sources, results core.ResultList, code int) {
+	printResults(sources, results)
+	if containsInternalError(results) {
+		code = ExitInternalError
+	}
+	os.Exit(code)
+}
+
+func printResultAndExit(sources core.Sources, result core.Result, code int) {
+	printResultsAndExit(sources, list.FromSingle(result), code)
+}
+
+// Reports an error that is not related to a location in the sources, and
+// exits with the provided exit code.
+func printErrorAndExit(code int, format string, args ...any) {
+	result := core.Result{{
+		Type:    core.ErrorResult,
+		Message: fmt.Sprintf(format, args...),
+	}}
+	printResultAndExit(core.Sources{}, result, code)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/transform"
+)
+
+// A special value of the --dump-after flag, which dumps the target data after
+// every transformation in the chain.
+const dumpAfterAll = "all"
+
+var dumpAfter []string
+var dumpDir string
+var dumpHex bool
+
+// Checks that each of the transformations that should be dumped is a part of
+// the transformation chain.
+func validateDumpAfter(transformations []*transform.Transformation) core.ResultList {
+	results := core.ResultList{}
+
+	for _, name := range dumpAfter {
+		if name == dumpAfterAll {
+			continue
+		}
+
+		found := slices.ContainsFunc(
+			transformations,
+			func(transformation *transform.Transformation) bool {
+				return slices.Contains(transformation.Names, name)
+			},
+		)
+
+		if !found {
+			results.Append(core.Result{
+				{
+					Type: core.ErrorResult,
+					Message: fmt.Sprintf(
+						"Can't dump after \"%s\": transformation is not in the chain",
+						name,
+					),
+				},
+			})
+		}
+	}
+
+	return results
+}
+
+func shouldDumpAfter(transformation *transform.Transformation) bool {
+	for _, name := range dumpAfter {
+		if name == dumpAfterAll || slices.Contains(transformation.Names, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returned by renderDump for binary artifacts, if hex dumps are not enabled.
+var errBinaryDumpSkipped = errors.New("binary artifact not dumped")
+
+// Renders the dump of the target data, and returns it with the extension of
+// the file it should be written to.
+//
+// Binary artifacts are dumped in hex, and only if hex is true. Otherwise,
+// errBinaryDumpSkipped is returned.
+func renderDump(data *transform.TargetData, hex bool) ([]byte, string, error) {
+	if data.Target.Binary && !hex {
+		return nil, "", errBinaryDumpSkipped
+	}
+
+	buffer := bytes.Buffer{}
+	extension := data.Target.Extensions[0]
+	var err error
+	if data.Target.Binary {
+		_, err = data.WriteHexTo(&buffer)
+		extension += ".hex"
+	} else {
+		_, err = data.WriteTo(&buffer)
+	}
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return buffer.Bytes(), extension, nil
+}
+
+// Returns a transformation observer that dumps the target data after each of
+// the requested transformations, and reports the time each of them took.
+//
+// Dumps are written to files in the dump directory, which are named after the
+// provided stem, the index of the transformation in the chain and its name.
+// If no dump directory is provided, dumps are written to stderr.
+func newDumpObserver(stem string) transform.TransformationObserver {
+	if len(dumpAfter) == 0 {
+		return nil
+	}
+
+	if dumpDir != "" {
+		if err := os.MkdirAll(dumpDir, 0o755); err != nil {
+			printErrorAndExit(ExitIOError, "Can't create dump directory: %v", err)
+		}
+	}
+
+	return func(
+		index int,
+		transformation *transform.Transformation,
+		data *transform.TargetData,
+		duration time.Duration,
+	) {
+		if !shouldDumpAfter(transformation) {
+			return
+		}
+
+		name := transformation.Names[0]
+		header := fmt.Sprintf(
+			"after \"%s\" (%v)",
+			name,
+			duration.Round(time.Microsecond),
+		)
+
+		dump, extension, err := renderDump(data, dumpHex)
+		if errors.Is(err, errBinaryDumpSkipped) {
+			fmt.Fprintf(os.Stderr, "%s: binary artifact not dumped, use --dump-hex\n", header)
+			return
+		} else if err != nil {
+			printErrorAndExit(ExitIOError, "Can't write dump: %v", err)
+		}
+
+		if dumpDir == "" {
+			fmt.Fprintf(os.Stderr, "%s:\n%s", header, dump)
+			return
+		}
+
+		filename := fmt.Sprintf("%s.%02d.%s%s", stem, index+1, name, extension)
+		path := filepath.Join(dumpDir, filename)
+		if err := os.WriteFile(path, dump, 0o644); err != nil {
+			printErrorAndExit(ExitIOError, "Can't write dump: %v", err)
+		}
+
+		fmt.Fprintf(os.Stderr, "%s: dumped to %s\n", header, path)
+	}
+}
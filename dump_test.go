@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"alon.kr/x/usm/transform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDumpTestData(binary bool, content string) *transform.TargetData {
+	extension := ".usm"
+	if binary {
+		extension = ".o"
+	}
+
+	return &transform.TargetData{
+		Target: &transform.Target{
+			Names:      []string{"test"},
+			Extensions: []string{extension},
+			Binary:     binary,
+		},
+		Artifact: bytes.NewBufferString(content),
+	}
+}
+
+func TestRenderDumpText(t *testing.T) {
+	data := newDumpTestData(false, "func @main {}\n")
+
+	dump, extension, err := renderDump(data, false)
+	require.NoError(t, err)
+	assert.Equal(t, "func @main {}\n", string(dump))
+	assert.Equal(t, ".usm", extension)
+
+	// Hex dumps only affect binary artifacts.
+	dump, extension, err = renderDump(data, true)
+	require.NoError(t, err)
+	assert.Equal(t, "func @main {}\n", string(dump))
+	assert.Equal(t, ".usm", extension)
+}
+
+func TestRenderDumpBinarySkipped(t *testing.T) {
+	data := newDumpTestData(true, "\x7fELF")
+
+	_, _, err := renderDump(data, false)
+	assert.ErrorIs(t, err, errBinaryDumpSkipped)
+}
+
+func TestRenderDumpBinaryHex(t *testing.T) {
+	data := newDumpTestData(true, "\x7fELF")
+
+	dump, extension, err := renderDump(data, true)
+	require.NoError(t, err)
+	assert.Equal(t, hex.Dump([]byte("\x7fELF")), string(dump))
+	assert.Equal(t, ".o.hex", extension)
+
+	// The artifact is not consumed by dumping it.
+	assert.Equal(t, "\x7fELF", data.Artifact.String())
+}
+
+func TestDumpObserverWritesFiles(t *testing.T) {
+	dir := t.TempDir()
+	dumpAfter, dumpDir, dumpHex = []string{"second"}, dir, false
+	t.Cleanup(func() { dumpAfter, dumpDir, dumpHex = nil, "", false })
+
+	observer := newDumpObserver("f")
+	require.NotNil(t, observer)
+
+	first := &transform.Transformation{Names: []string{"first"}}
+	second := &transform.Transformation{Names: []string{"second", "2nd"}}
+
+	observer(0, first, newDumpTestData(false, "first\n"), 0)
+	observer(1, second, newDumpTestData(false, "second\n"), 0)
+	observer(2, second, newDumpTestData(true, "\x00"), 0)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	// Only the text dump after "second" is written, since the binary
+	// artifact is skipped without --dump-hex.
+	require.Len(t, entries, 1)
+	assert.Equal(t, "f.02.second.usm", entries[0].Name())
+
+	content, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	require.NoError(t, err)
+	assert.Equal(t, "second\n", string(content))
+}
+
+func TestDumpObserverWritesHexFiles(t *testing.T) {
+	dir := t.TempDir()
+	dumpAfter, dumpDir, dumpHex = []string{dumpAfterAll}, dir, true
+	t.Cleanup(func() { dumpAfter, dumpDir, dumpHex = nil, "", false })
+
+	observer := newDumpObserver("f")
+	require.NotNil(t, observer)
+
+	transformation := &transform.Transformation{Names: []string{"elf"}}
+	observer(0, transformation, newDumpTestData(true, "\x00"), 0)
+
+	content, err := os.ReadFile(filepath.Join(dir, "f.01.elf.o.hex"))
+	require.NoError(t, err)
+	assert.Equal(t, hex.Dump([]byte("\x00")), string(content))
+}
+
+func TestNoDumpObserverWithoutDumpAfter(t *testing.T) {
+	assert.Nil(t, newDumpObserver("f"))
+}
@@ -64,6 +64,13 @@ func (b *BasicBlockInfo) PrependInstruction(instruction *InstructionInfo) {
 	b.Instructions = append([]*InstructionInfo{instruction}, b.Instructions...)
 }
 
+// Inserts the provided instruction to the basic block, such that it appears
+// in the provided index of the instructions slice.
+func (b *BasicBlockInfo) InsertInstruction(index int, instruction *InstructionInfo) {
+	instruction.BasicBlockInfo = b
+	b.Instructions = slices.Insert(b.Instructions, index, instruction)
+}
+
 func (b *BasicBlockInfo) RemoveInstruction(
 	instruction *InstructionInfo,
 ) (ok bool) {
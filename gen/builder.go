@@ -0,0 +1,452 @@
+package gen
+
+import (
+	"fmt"
+
+	"alon.kr/x/list"
+	"alon.kr/x/usm/core"
+)
+
+// MARK: File
+
+// Builder builds the internal representation of a file programmatically,
+// without printing and parsing source code. It is intended for frontends that
+// target USM.
+//
+// Instruction definitions, types, registers, labels and globals are managed
+// by the generation context that the builder is created with, exactly as they
+// are when generating from source code.
+type Builder struct {
+	*FileGenerationContext
+
+	File *FileInfo
+
+	functions []*FunctionBuilder
+}
+
+func NewBuilder(ctx *GenerationContext) *Builder {
+	return &Builder{
+		FileGenerationContext: ctx.NewFileGenerationContext(core.SourceContext{}),
+		File:                  NewFileInfo(),
+	}
+}
+
+func builderError(format string, args ...any) core.ResultList {
+	return list.FromSingle(core.Result{
+		{
+			Type:    core.ErrorResult,
+			Message: fmt.Sprintf(format, args...),
+		},
+	})
+}
+
+// Returns the (pure) type with the provided name, for example "$32".
+func (b *Builder) Type(name string) (ReferencedTypeInfo, core.ResultList) {
+	typ := b.Types.GetType(name)
+	if typ == nil {
+		return ReferencedTypeInfo{}, builderError("Undefined type \"%s\"", name)
+	}
+
+	return ReferencedTypeInfo{Base: typ}, core.ResultList{}
+}
+
+// Creates a new function with the provided name and return types, and adds
+// it to the file. The parameters and body of the function are provided with
+// the returned function builder.
+//
+// A function that has no instructions when it is finished is a declaration
+// of a function that is defined elsewhere.
+func (b *Builder) NewFunction(
+	name string,
+	targets ...ReferencedTypeInfo,
+) (*FunctionBuilder, core.ResultList) {
+	if b.Globals.GetGlobal(name) != nil {
+		return nil, builderError("Global \"%s\" already defined", name)
+	}
+
+	function := &FunctionInfo{
+		Name:    name,
+		Targets: targets,
+	}
+
+	results := b.Globals.NewGlobal(NewFunctionGlobalInfo(function))
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	ctx := b.NewFunctionGenerationContext()
+	function.Registers = ctx.Registers
+	function.Labels = ctx.Labels
+	b.File.AppendFunction(function)
+
+	builder := &FunctionBuilder{
+		FunctionGenerationContext: ctx,
+		Function:                  function,
+		started:                   make(map[*BasicBlockInfo]bool),
+	}
+
+	b.functions = append(b.functions, builder)
+	return builder, core.ResultList{}
+}
+
+// Finishes all functions that were not finished yet (see
+// FunctionBuilder.Finish), and returns the built file.
+func (b *Builder) Finish() (*FileInfo, core.ResultList) {
+	results := core.ResultList{}
+	for _, function := range b.functions {
+		_, curResults := function.Finish()
+		results.Extend(&curResults)
+	}
+
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return b.File, core.ResultList{}
+}
+
+// MARK: Function
+
+// FunctionBuilder builds the body of a single function.
+//
+// Instructions are always appended to the last basic block of the function.
+// New basic blocks are created with NewBlock, which allows them to be used as
+// branch destinations before they are placed in the function with
+// StartBlock.
+//
+// The back-references of registers, labels, instructions and basic blocks are
+// kept consistent as the function is built. The control flow edges between
+// the basic blocks depend on their final order, so they are linked, and the
+// function is validated, by Finish.
+type FunctionBuilder struct {
+	*FunctionGenerationContext
+
+	Function *FunctionInfo
+
+	// The last basic block of the function, to which instructions are
+	// appended. Nil if no block was started yet.
+	block *BasicBlockInfo
+
+	// All basic blocks that were created, in creation order, and whether each
+	// one of them was placed in the function.
+	blocks  []*BasicBlockInfo
+	started map[*BasicBlockInfo]bool
+
+	finished      bool
+	finishResults core.ResultList
+}
+
+// Creates a new register in the function, with the provided name and type.
+func (b *FunctionBuilder) NewRegister(
+	name string,
+	typ ReferencedTypeInfo,
+) (*RegisterInfo, core.ResultList) {
+	if b.Registers.GetRegister(name) != nil {
+		return nil, builderError(
+			"Register \"%s\" already defined in function \"%s\"",
+			name,
+			b.Function.Name,
+		)
+	}
+
+	register := NewRegisterInfo(name, typ)
+	results := b.Registers.NewRegister(register)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return register, core.ResultList{}
+}
+
+// Creates a new register, and appends it to the parameters of the function.
+func (b *FunctionBuilder) NewParameter(
+	name string,
+	typ ReferencedTypeInfo,
+) (*RegisterInfo, core.ResultList) {
+	register, results := b.NewRegister(name, typ)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	b.Function.Parameters = append(b.Function.Parameters, register)
+	return register, core.ResultList{}
+}
+
+// Creates a new basic block with the provided label name, or with a generated
+// label name if the name is empty.
+//
+// The block is not placed in the function until StartBlock is called with it,
+// but it can already be used as a branch destination.
+func (b *FunctionBuilder) NewBlock(name string) (*BasicBlockInfo, core.ResultList) {
+	var label *LabelInfo
+	if name == "" {
+		label = b.Labels.GenerateLabel()
+	} else if b.Labels.GetLabel(name) != nil {
+		return nil, builderError(
+			"Label \"%s\" already defined in function \"%s\"",
+			name,
+			b.Function.Name,
+		)
+	} else {
+		label = &LabelInfo{Name: name}
+	}
+
+	results := b.Labels.NewLabel(label)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	block := NewEmptyBasicBlockInfo(b.Function)
+	block.SetLabel(label)
+	b.blocks = append(b.blocks, block)
+	return block, core.ResultList{}
+}
+
+// Places the provided block after the last block of the function. Following
+// instructions are appended to it.
+func (b *FunctionBuilder) StartBlock(block *BasicBlockInfo) core.ResultList {
+	if b.finished {
+		return builderError("Function \"%s\" already finished", b.Function.Name)
+	}
+
+	if block.FunctionInfo != b.Function {
+		return builderError(
+			"Basic block \"%s\" does not belong to function \"%s\"",
+			block.Label.Name,
+			b.Function.Name,
+		)
+	}
+
+	if b.started[block] {
+		return builderError("Basic block \"%s\" already started", block.Label.Name)
+	}
+
+	if b.block == nil {
+		b.Function.EntryBlock = block
+	} else {
+		b.block.AppendBasicBlock(block)
+	}
+
+	b.started[block] = true
+	b.block = block
+	return core.ResultList{}
+}
+
+// Returns the block to which the next instruction should be appended.
+//
+// As in code that is generated from source, a new basic block is started
+// after an instruction that may branch, and before the first instruction of
+// the function if no block was started explicitly.
+func (b *FunctionBuilder) insertionBlock() (*BasicBlockInfo, core.ResultList) {
+	if b.block != nil {
+		if len(b.block.Instructions) == 0 {
+			return b.block, core.ResultList{}
+		}
+
+		last := b.block.Instructions[len(b.block.Instructions)-1]
+		steps, results := last.Definition.PossibleNextSteps(last)
+		if !results.IsEmpty() {
+			return nil, results
+		}
+
+		if !steps.IsBranchPossible() {
+			return b.block, core.ResultList{}
+		}
+	}
+
+	block, results := b.NewBlock("")
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	results = b.StartBlock(block)
+	return block, results
+}
+
+// Checks that the provided registers and labels belong to the function.
+func (b *FunctionBuilder) checkOperands(
+	targets []*RegisterInfo,
+	arguments []ArgumentInfo,
+) core.ResultList {
+	results := core.ResultList{}
+
+	checkRegister := func(register *RegisterInfo) {
+		if register == nil || b.Registers.GetRegister(register.Name) != register {
+			name := "<nil>"
+			if register != nil {
+				name = register.Name
+			}
+
+			curResults := builderError(
+				"Register \"%s\" is not defined in function \"%s\"",
+				name,
+				b.Function.Name,
+			)
+			results.Extend(&curResults)
+		}
+	}
+
+	for _, target := range targets {
+		checkRegister(target)
+	}
+
+	for _, argument := range arguments {
+		switch typed := argument.(type) {
+		case *RegisterArgumentInfo:
+			checkRegister(typed.Register)
+		case *LabelArgumentInfo:
+			if typed.Label == nil || b.Labels.GetLabel(typed.Label.Name) != typed.Label {
+				curResults := builderError(
+					"Label is not defined in function \"%s\"",
+					b.Function.Name,
+				)
+				results.Extend(&curResults)
+			}
+		}
+	}
+
+	return results
+}
+
+// Appends a new instruction to the function. The instruction definition is
+// looked up by the provided operator in the instruction manager of the
+// generation context.
+//
+// The instruction is registered as a definition of its target registers, and
+// as a usage of its register arguments.
+func (b *FunctionBuilder) Emit(
+	operator string,
+	targets []*RegisterInfo,
+	arguments []ArgumentInfo,
+) (*InstructionInfo, core.ResultList) {
+	if b.finished {
+		return nil, builderError("Function \"%s\" already finished", b.Function.Name)
+	}
+
+	definition, results := b.Instructions.GetInstructionDefinition(operator, nil)
+	if !results.IsEmpty() {
+		return nil, builderError("Undefined instruction \"%s\"", operator)
+	}
+
+	results = b.checkOperands(targets, arguments)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	block, results := b.insertionBlock()
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	instruction := NewEmptyInstructionInfo(nil)
+	for _, register := range targets {
+		instruction.AppendTarget(&TargetInfo{Register: register})
+	}
+
+	instruction.AppendArgument(arguments...)
+	for _, argument := range arguments {
+		argument.OnAttach(instruction)
+	}
+
+	instruction.SetInstruction(definition)
+	block.AppendInstruction(instruction)
+	return instruction, core.ResultList{}
+}
+
+// Appends a new branching instruction to the function. The provided
+// destination blocks are appended to the arguments, as label arguments.
+func (b *FunctionBuilder) Branch(
+	operator string,
+	arguments []ArgumentInfo,
+	destinations ...*BasicBlockInfo,
+) (*InstructionInfo, core.ResultList) {
+	arguments = append([]ArgumentInfo{}, arguments...)
+	for _, destination := range destinations {
+		arguments = append(arguments, NewLabelArgumentInfo(destination.Label))
+	}
+
+	return b.Emit(operator, nil, arguments)
+}
+
+// Links the control flow edges between the basic blocks of the function,
+// according to the possible next steps of their last instructions.
+func (b *FunctionBuilder) linkBlocks() core.ResultList {
+	for block := b.Function.EntryBlock; block != nil; block = block.NextBlock {
+		if len(block.Instructions) == 0 {
+			return builderError("Basic block \"%s\" is empty", block.Label.Name)
+		}
+
+		last := block.Instructions[len(block.Instructions)-1]
+		steps, results := last.Definition.PossibleNextSteps(last)
+		if !results.IsEmpty() {
+			return results
+		}
+
+		if steps.PossibleContinue {
+			if block.NextBlock == nil {
+				return list.FromSingle(core.Result{
+					{
+						Type: core.ErrorResult,
+						Message: fmt.Sprintf(
+							"Unexpected instruction to end function \"%s\"",
+							b.Function.Name,
+						),
+					},
+					{
+						Type:    core.HintResult,
+						Message: "Perhaps you forgot a return instruction?",
+					},
+				})
+			}
+
+			block.AppendForwardEdge(block.NextBlock)
+		}
+
+		for _, label := range steps.PossibleBranches {
+			block.AppendForwardEdge(label.BasicBlock)
+		}
+	}
+
+	return core.ResultList{}
+}
+
+// Finishes building the function: links the control flow graph, and
+// validates all instructions. The function can't be modified afterwards.
+//
+// Calling Finish more than once returns the same function and results.
+func (b *FunctionBuilder) Finish() (*FunctionInfo, core.ResultList) {
+	if !b.finished {
+		b.finished = true
+		b.finishResults = b.finish()
+	}
+
+	if !b.finishResults.IsEmpty() {
+		return nil, b.finishResults
+	}
+
+	return b.Function, core.ResultList{}
+}
+
+func (b *FunctionBuilder) finish() core.ResultList {
+	results := core.ResultList{}
+	for _, block := range b.blocks {
+		if !b.started[block] {
+			curResults := builderError(
+				"Basic block \"%s\" was never started",
+				block.Label.Name,
+			)
+			results.Extend(&curResults)
+		}
+	}
+
+	if !results.IsEmpty() {
+		return results
+	}
+
+	results = b.linkBlocks()
+	if !results.IsEmpty() {
+		return results
+	}
+
+	return b.Function.Validate()
+}
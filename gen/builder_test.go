@@ -0,0 +1,151 @@
+package gen_test
+
+import (
+	"testing"
+
+	"alon.kr/x/usm/gen"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuilderLoop(t *testing.T) {
+	builder := gen.NewBuilder(&testGenerationContext)
+	i32, results := builder.Type("$32")
+	require.True(t, results.IsEmpty())
+
+	function, results := builder.NewFunction("@f", i32)
+	require.True(t, results.IsEmpty())
+
+	a, results := function.NewParameter("%a", i32)
+	require.True(t, results.IsEmpty())
+	b, results := function.NewRegister("%b", i32)
+	require.True(t, results.IsEmpty())
+
+	loop, results := function.NewBlock(".loop")
+	require.True(t, results.IsEmpty())
+	exit, results := function.NewBlock(".exit")
+	require.True(t, results.IsEmpty())
+
+	// The entry block is created implicitly by the first instruction.
+	first, results := function.Emit(
+		"add",
+		[]*gen.RegisterInfo{b},
+		[]gen.ArgumentInfo{gen.NewRegisterArgumentInfo(a), gen.NewRegisterArgumentInfo(a)},
+	)
+	require.True(t, results.IsEmpty())
+
+	_, results = function.Branch("jz", []gen.ArgumentInfo{gen.NewRegisterArgumentInfo(b)}, exit)
+	require.True(t, results.IsEmpty())
+
+	results = function.StartBlock(loop)
+	require.True(t, results.IsEmpty())
+	second, results := function.Emit(
+		"add",
+		[]*gen.RegisterInfo{b},
+		[]gen.ArgumentInfo{gen.NewRegisterArgumentInfo(b), gen.NewRegisterArgumentInfo(a)},
+	)
+	require.True(t, results.IsEmpty())
+	_, results = function.Branch("j", nil, loop)
+	require.True(t, results.IsEmpty())
+
+	results = function.StartBlock(exit)
+	require.True(t, results.IsEmpty())
+	_, results = function.Emit("ret", nil, nil)
+	require.True(t, results.IsEmpty())
+
+	file, results := builder.Finish()
+	require.True(t, results.IsEmpty())
+
+	info := file.GetFunction("@f")
+	require.NotNil(t, info)
+	assert.Equal(t, file, info.FileInfo)
+
+	blocks := info.CollectBasicBlocks()
+	require.Len(t, blocks, 3)
+	entry := blocks[0]
+	assert.Equal(t, []*gen.BasicBlockInfo{loop, exit}, entry.ForwardEdges)
+	assert.Equal(t, []*gen.BasicBlockInfo{loop}, loop.ForwardEdges)
+	assert.Equal(t, []*gen.BasicBlockInfo{entry, loop}, loop.BackwardEdges)
+	assert.Equal(t, []*gen.BasicBlockInfo{entry}, exit.BackwardEdges)
+	assert.Equal(t, entry, first.BasicBlockInfo)
+	assert.Equal(t, loop, loop.Label.BasicBlock)
+
+	assert.Equal(t, []*gen.InstructionInfo{first, second}, b.Definitions)
+	assert.Equal(t, []*gen.InstructionInfo{first, first, second}, a.Usages)
+	assert.Equal(t, []*gen.RegisterInfo{a}, info.Parameters)
+}
+
+func TestBuilderErrors(t *testing.T) {
+	builder := gen.NewBuilder(&testGenerationContext)
+	i32, _ := builder.Type("$32")
+
+	_, results := builder.Type("$undefined")
+	assert.False(t, results.IsEmpty())
+
+	f, _ := builder.NewFunction("@f")
+	g, _ := builder.NewFunction("@g")
+
+	_, results = builder.NewFunction("@f")
+	assert.False(t, results.IsEmpty())
+
+	_, results = f.Emit("undefined", nil, nil)
+	assert.False(t, results.IsEmpty())
+
+	// Registers of one function can't be used in another function.
+	other, _ := g.NewRegister("%x", i32)
+	_, results = f.Emit("add", []*gen.RegisterInfo{other}, nil)
+	assert.False(t, results.IsEmpty())
+
+	_, results = f.NewRegister("%x", i32)
+	assert.True(t, results.IsEmpty())
+	_, results = f.NewRegister("%x", i32)
+	assert.False(t, results.IsEmpty())
+}
+
+func TestBuilderMissingReturn(t *testing.T) {
+	builder := gen.NewBuilder(&testGenerationContext)
+	i32, _ := builder.Type("$32")
+
+	function, _ := builder.NewFunction("@f")
+	x, _ := function.NewRegister("%x", i32)
+	_, results := function.Emit("add", []*gen.RegisterInfo{x}, nil)
+	require.True(t, results.IsEmpty())
+
+	_, results = function.Finish()
+	assert.False(t, results.IsEmpty())
+
+	// Finishing again reports the same results, and the function can't be
+	// modified anymore.
+	_, again := function.Finish()
+	assert.Equal(t, results, again)
+
+	_, results = function.Emit("ret", nil, nil)
+	assert.False(t, results.IsEmpty())
+}
+
+func TestBuilderBlockNeverStarted(t *testing.T) {
+	builder := gen.NewBuilder(&testGenerationContext)
+	function, _ := builder.NewFunction("@f")
+
+	block, _ := function.NewBlock(".never")
+	_, results := function.Branch("j", nil, block)
+	require.True(t, results.IsEmpty())
+
+	_, results = builder.Finish()
+	require.False(t, results.IsEmpty())
+	assert.Equal(
+		t,
+		"Basic block \".never\" was never started",
+		results.Head.Value[0].Message,
+	)
+}
+
+func TestBuilderDeclaration(t *testing.T) {
+	builder := gen.NewBuilder(&testGenerationContext)
+	_, results := builder.NewFunction("@extern")
+	require.True(t, results.IsEmpty())
+
+	file, results := builder.Finish()
+	require.True(t, results.IsEmpty())
+	assert.False(t, file.GetFunction("@extern").IsDefined())
+}
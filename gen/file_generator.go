@@ -6,9 +6,11 @@ import (
 )
 
 type FileGenerator struct {
-	NamedTypeGenerator      FileContextGenerator[parse.TypeDeclarationNode, *NamedTypeInfo]
+	NamedTypeGenerator      TypeDeclarationGenerator
 	FunctionGenerator       FileContextGenerator[parse.FunctionNode, *FunctionInfo]
 	FunctionGlobalGenerator FileContextGenerator[parse.FunctionNode, GlobalInfo]
+	VariableGlobalGenerator FileContextGenerator[parse.VarDeclarationNode, GlobalInfo]
+	ConstantGlobalGenerator FileContextGenerator[parse.ConstDeclarationNode, GlobalInfo]
 }
 
 func NewFileGenerator() FileGenerator {
@@ -16,18 +18,44 @@ func NewFileGenerator() FileGenerator {
 		NamedTypeGenerator:      NewNamedTypeGenerator(),
 		FunctionGenerator:       NewFunctionGenerator(),
 		FunctionGlobalGenerator: NewFunctionGlobalGenerator(),
+		VariableGlobalGenerator: NewVariableGlobalGenerator(),
+		ConstantGlobalGenerator: NewConstantGlobalGenerator(),
 	}
 }
 
+// Generates all types declared in the file. All types are declared before
+// any of them is defined, so types can refer to any other type in the file,
+// regardless of the order of declarations.
 func (g *FileGenerator) generateTypesFromDeclarations(
 	ctx *FileGenerationContext,
 	nodes []parse.TypeDeclarationNode,
-) (results core.ResultList) {
-	for _, node := range nodes {
-		_, curResults := g.NamedTypeGenerator.Generate(ctx, node)
+) (types []*NamedTypeInfo, results core.ResultList) {
+	types = make([]*NamedTypeInfo, len(nodes))
+	for i, node := range nodes {
+		typeInfo, curResults := g.NamedTypeGenerator.Declare(ctx, node)
 		results.Extend(&curResults)
+		types[i] = typeInfo
 	}
-	return results
+
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	for i, node := range nodes {
+		curResults := g.NamedTypeGenerator.Define(ctx, node, types[i])
+		results.Extend(&curResults)
+	}
+
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	results = CalculateTypeLayouts(ctx, types)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return types, core.ResultList{}
 }
 
 func (g *FileGenerator) generateFunctionGlobals(
@@ -42,11 +70,60 @@ func (g *FileGenerator) generateFunctionGlobals(
 	return results
 }
 
+func (g *FileGenerator) generateVariableGlobals(
+	ctx *FileGenerationContext,
+	nodes []parse.VarDeclarationNode,
+) (variables []*VariableGlobalInfo, results core.ResultList) {
+	for _, node := range nodes {
+		global, curResults := g.VariableGlobalGenerator.Generate(ctx, node)
+		results.Extend(&curResults)
+
+		if variable, ok := global.(*VariableGlobalInfo); ok {
+			variables = append(variables, variable)
+		}
+	}
+
+	return variables, results
+}
+
+func (g *FileGenerator) generateConstantGlobals(
+	ctx *FileGenerationContext,
+	nodes []parse.ConstDeclarationNode,
+) (constants []*ConstantGlobalInfo, results core.ResultList) {
+	for _, node := range nodes {
+		global, curResults := g.ConstantGlobalGenerator.Generate(ctx, node)
+		results.Extend(&curResults)
+
+		if constant, ok := global.(*ConstantGlobalInfo); ok {
+			constants = append(constants, constant)
+		}
+	}
+
+	return constants, results
+}
+
+// Generates and registers all globals in the file, so they can be referenced
+// by functions before their bodies are generated.
+//
+// Variables and constants are returned, since they are complete after this
+// step. Functions are completed only after their bodies are generated.
 func (g *FileGenerator) generateGlobals(
 	ctx *FileGenerationContext,
 	node parse.FileNode,
-) (results core.ResultList) {
-	return g.generateFunctionGlobals(ctx, node.Functions)
+) (
+	variables []*VariableGlobalInfo,
+	constants []*ConstantGlobalInfo,
+	results core.ResultList,
+) {
+	results = g.generateFunctionGlobals(ctx, node.Functions)
+
+	constants, curResults := g.generateConstantGlobals(ctx, node.Constants)
+	results.Extend(&curResults)
+
+	variables, curResults = g.generateVariableGlobals(ctx, node.Variables)
+	results.Extend(&curResults)
+
+	return variables, constants, results
 }
 
 func (g *FileGenerator) generateFunctions(
@@ -62,6 +139,70 @@ func (g *FileGenerator) generateFunctions(
 	return functions, results
 }
 
+// Returns true if the provided function declarations have the same targets
+// and parameter types.
+func functionSignaturesEqual(a, b *FunctionInfo) bool {
+	if len(a.Targets) != len(b.Targets) || len(a.Parameters) != len(b.Parameters) {
+		return false
+	}
+
+	for i := range a.Targets {
+		if !a.Targets[i].Equal(b.Targets[i]) {
+			return false
+		}
+	}
+
+	for i := range a.Parameters {
+		if !a.Parameters[i].Type.Equal(b.Parameters[i].Type) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Links the generated functions with their function globals.
+//
+// A function can be declared multiple times (for example, once in every file
+// that calls it, and defined in another file), but only the function info
+// that the global refers to is included in the file. Other declarations are
+// verified to match it.
+func (g *FileGenerator) linkFunctions(
+	ctx *FileGenerationContext,
+	functions []*FunctionInfo,
+) (linked []*FunctionInfo, results core.ResultList) {
+	for _, function := range functions {
+		global := ctx.Globals.GetGlobal(function.Name).(*FunctionGlobalInfo)
+		if global.FunctionInfo == function {
+			linked = append(linked, function)
+			continue
+		}
+
+		if !functionSignaturesEqual(function, global.FunctionInfo) {
+			results.Append(core.Result{
+				{
+					Type:     core.ErrorResult,
+					Message:  "Conflicting declarations of function \"" + function.Name + "\"",
+					Location: function.Declaration,
+				},
+				{
+					Type:     core.HintResult,
+					Message:  "Other declaration here",
+					Location: global.Declaration(),
+				},
+			})
+		}
+	}
+
+	return linked, results
+}
+
+// Generates the information of a whole file.
+//
+// The node can hold the declarations of multiple files that share the same
+// source context (see parse.MergeFileNodes and core.Sources). In that case,
+// types and globals are resolved across all of the files, and a single linked
+// file info is generated.
 func (g *FileGenerator) Generate(
 	ctx *GenerationContext,
 	source core.SourceContext,
@@ -69,28 +210,43 @@ func (g *FileGenerator) Generate(
 ) (*FileInfo, core.ResultList) {
 	fileCtx := ctx.NewFileGenerationContext(source)
 
-	results := g.generateTypesFromDeclarations(fileCtx, node.Types)
+	types, results := g.generateTypesFromDeclarations(fileCtx, node.Types)
 	if !results.IsEmpty() {
 		return nil, results
 	}
 
-	results = g.generateGlobals(fileCtx, node)
+	variables, constants, results := g.generateGlobals(fileCtx, node)
 	if !results.IsEmpty() {
 		return nil, results
 	}
 
-	functions, functionResults := g.generateFunctions(fileCtx, node.Functions)
-	results.Extend(&functionResults)
+	functions, results := g.generateFunctions(fileCtx, node.Functions)
+	if !results.IsEmpty() {
+		return nil, results
+	}
 
+	functions, results = g.linkFunctions(fileCtx, functions)
 	if !results.IsEmpty() {
 		return nil, results
 	}
 
 	file := NewFileInfo()
+	for _, typ := range types {
+		file.AppendType(typ)
+	}
+
 	for _, function := range functions {
 		file.AppendFunction(function)
 	}
 
+	for _, variable := range variables {
+		file.AppendVariable(variable)
+	}
+
+	for _, constant := range constants {
+		file.AppendConstant(constant)
+	}
+
 	results = file.Validate()
 	if !results.IsEmpty() {
 		return nil, results
@@ -0,0 +1,96 @@
+package gen_test
+
+import (
+	"testing"
+
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/lex"
+	"alon.kr/x/usm/parse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateFileFromSources(
+	t *testing.T,
+	sources core.Sources,
+) (*gen.FileInfo, core.ResultList) {
+	t.Helper()
+
+	nodes := make([]parse.FileNode, len(sources.Files))
+	for i, file := range sources.Files {
+		lexResult, err := lex.NewTokenizer().Tokenize(sources.FileView(file))
+		require.NoError(t, err)
+
+		tknView := parse.NewTokenView(lexResult)
+		node, result := parse.NewFileParser().Parse(&tknView)
+		require.Nil(t, result)
+		nodes[i] = node
+	}
+
+	generator := gen.NewFileGenerator()
+	node := parse.MergeFileNodes(nodes...)
+	return generator.Generate(&testGenerationContext, sources.Context, node)
+}
+
+func TestMultipleFilesLinking(t *testing.T) {
+	sources := core.NewSources(
+		[]string{"a.usm", "b.usm"},
+		[]string{
+			"func $32 @f $32 %x\n\nfunc @g {\n\tret\n}\n",
+			"func $32 @f $32 %x {\n\tret %x\n}\n",
+		},
+	)
+
+	file, results := generateFileFromSources(t, sources)
+	require.True(t, results.IsEmpty())
+	require.Len(t, file.Functions, 2)
+
+	f := file.GetFunction("@f")
+	require.NotNil(t, f)
+	assert.True(t, f.IsDefined())
+	assert.Equal(t, "b.usm", sources.FileOf(*f.Declaration).Filepath)
+
+	g := file.GetFunction("@g")
+	require.NotNil(t, g)
+	assert.Equal(t, "a.usm", sources.FileOf(*g.Declaration).Filepath)
+}
+
+func TestMultipleFilesDuplicateDefinition(t *testing.T) {
+	sources := core.NewSources(
+		[]string{"a.usm", "b.usm"},
+		[]string{
+			"func @f {\n\tret\n}\n",
+			"var @x $32\n\nfunc @f {\n\tret\n}\n",
+		},
+	)
+
+	_, results := generateFileFromSources(t, sources)
+	require.False(t, results.IsEmpty())
+
+	details := results.Head.Value
+	require.Len(t, details, 2)
+	assert.Equal(t, "Global already defined", details[0].Message)
+	assert.Equal(t, "b.usm", sources.FileOf(*details[0].Location).Filepath)
+	assert.Equal(t, core.HintResult, details[1].Type)
+	assert.Equal(t, "a.usm", sources.FileOf(*details[1].Location).Filepath)
+}
+
+func TestMultipleFilesConflictingDeclarations(t *testing.T) {
+	sources := core.NewSources(
+		[]string{"a.usm", "b.usm"},
+		[]string{
+			"func $16 @f $32 %x\n",
+			"func $32 @f $32 %x {\n\tret %x\n}\n",
+		},
+	)
+
+	_, results := generateFileFromSources(t, sources)
+	require.False(t, results.IsEmpty())
+
+	details := results.Head.Value
+	require.Len(t, details, 2)
+	assert.Equal(t, `Conflicting declarations of function "@f"`, details[0].Message)
+	assert.Equal(t, "a.usm", sources.FileOf(*details[0].Location).Filepath)
+	assert.Equal(t, "b.usm", sources.FileOf(*details[1].Location).Filepath)
+}
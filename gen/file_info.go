@@ -1,24 +1,89 @@
 package gen
 
-import "alon.kr/x/usm/core"
+import (
+	"cmp"
+	"slices"
+
+	"alon.kr/x/usm/core"
+)
 
 type FileInfo struct {
+	// The types that are declared in the file. Builtin types are not included.
+	Types map[string]*NamedTypeInfo
+
 	Functions map[string]*FunctionInfo
+	Variables map[string]*VariableGlobalInfo
+	Constants map[string]*ConstantGlobalInfo
 }
 
 func NewFileInfo() *FileInfo {
 	return &FileInfo{
+		Types:     make(map[string]*NamedTypeInfo),
 		Functions: make(map[string]*FunctionInfo),
+		Variables: make(map[string]*VariableGlobalInfo),
+		Constants: make(map[string]*ConstantGlobalInfo),
 	}
 }
 
-func (i *FileInfo) String() string {
+// Returns the string representation of the declared types of the file,
+// sorted by their names, with an empty line after each type.
+func (i *FileInfo) stringTypes() string {
+	types := make([]*NamedTypeInfo, 0, len(i.Types))
+	for _, typ := range i.Types {
+		types = append(types, typ)
+	}
+
+	slices.SortFunc(types, func(a, b *NamedTypeInfo) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
 	s := ""
+	for _, typ := range types {
+		s += typ.DeclarationString() + "\n\n"
+	}
+
+	return s
+}
+
+// Returns the string representation of the constants and variables of the
+// file, sorted by their names, with a line for each global.
+func (i *FileInfo) stringDataGlobals() string {
+	type dataGlobal interface {
+		Name() string
+		String() string
+	}
+
+	globals := make([]dataGlobal, 0, len(i.Constants)+len(i.Variables))
+	for _, constant := range i.Constants {
+		globals = append(globals, constant)
+	}
+	for _, variable := range i.Variables {
+		globals = append(globals, variable)
+	}
+
+	slices.SortFunc(globals, func(a, b dataGlobal) int {
+		return cmp.Compare(a.Name(), b.Name())
+	})
+
+	s := ""
+	for _, global := range globals {
+		s += global.String() + "\n"
+	}
+
+	return s
+}
+
+func (i *FileInfo) String() string {
+	s := i.stringTypes() + i.stringDataGlobals()
 
 	if len(i.Functions) == 0 {
 		return s
 	}
 
+	if s != "" {
+		s += "\n"
+	}
+
 	functions := make([]*FunctionInfo, 0, len(i.Functions))
 	for _, function := range i.Functions {
 		functions = append(functions, function)
@@ -34,6 +99,16 @@ func (i *FileInfo) String() string {
 	return s
 }
 
+// GetType returns the declared type with the given name, or nil if it does
+// not exist.
+func (i *FileInfo) GetType(name string) *NamedTypeInfo {
+	return i.Types[name]
+}
+
+func (i *FileInfo) AppendType(typ *NamedTypeInfo) {
+	i.Types[typ.Name] = typ
+}
+
 // GetFunction returns the function with the given name, or nil if it does not
 // exist.
 func (i *FileInfo) GetFunction(name string) *FunctionInfo {
@@ -55,6 +130,26 @@ func (i *FileInfo) AppendFunction(function *FunctionInfo) {
 	i.Functions[function.Name] = function
 }
 
+// GetVariable returns the global variable with the given name, or nil if it
+// does not exist.
+func (i *FileInfo) GetVariable(name string) *VariableGlobalInfo {
+	return i.Variables[name]
+}
+
+func (i *FileInfo) AppendVariable(variable *VariableGlobalInfo) {
+	i.Variables[variable.Name()] = variable
+}
+
+// GetConstant returns the global constant with the given name, or nil if it
+// does not exist.
+func (i *FileInfo) GetConstant(name string) *ConstantGlobalInfo {
+	return i.Constants[name]
+}
+
+func (i *FileInfo) AppendConstant(constant *ConstantGlobalInfo) {
+	i.Constants[constant.Name()] = constant
+}
+
 func (i *FileInfo) Validate() core.ResultList {
 	results := core.ResultList{}
 
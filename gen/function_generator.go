@@ -348,6 +348,16 @@ func (g *FunctionGenerator) getFunctionInfo(
 	}
 
 	function := functionGlobal.FunctionInfo
+	if *function.Declaration != node.UnmanagedSourceView {
+		// The global belongs to another declaration or to the definition of
+		// the function, so this declaration gets a separate info, which is
+		// later matched against the global one (see FileGenerator).
+		function = &FunctionInfo{
+			Name:        name,
+			Declaration: &node.UnmanagedSourceView,
+		}
+	}
+
 	return function, core.ResultList{}
 }
 
@@ -22,6 +22,7 @@ func (f *FunctionGlobalGenerator) Generate(
 
 	global := &FunctionGlobalInfo{
 		FunctionInfo: info,
+		HasBody:      node.Instructions != nil,
 	}
 
 	results := ctx.Globals.NewGlobal(global)
@@ -0,0 +1,143 @@
+package gen
+
+import (
+	"alon.kr/x/list"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/parse"
+)
+
+// DataGlobalGenerator generates the information that is shared between
+// global variables and constants, from their declaration.
+type DataGlobalGenerator struct {
+	ReferencedTypeGenerator FileContextGenerator[parse.TypeNode, ReferencedTypeInfo]
+	InitializerGenerator    *InitializerGenerator
+}
+
+func NewDataGlobalGenerator() FileContextGenerator[parse.GlobalDeclarationNode, DataGlobalInfo] {
+	return FileContextGenerator[parse.GlobalDeclarationNode, DataGlobalInfo](
+		&DataGlobalGenerator{
+			ReferencedTypeGenerator: NewReferencedTypeGenerator(),
+			InitializerGenerator:    NewInitializerGenerator(),
+		},
+	)
+}
+
+func (g *DataGlobalGenerator) Generate(
+	ctx *FileGenerationContext,
+	node parse.GlobalDeclarationNode,
+) (DataGlobalInfo, core.ResultList) {
+	name := NodeToSourceString(ctx, node.Identifier)
+	declaration := node.View()
+
+	typ, results := g.ReferencedTypeGenerator.Generate(ctx, node.Type)
+	if !results.IsEmpty() {
+		return DataGlobalInfo{}, results
+	}
+
+	var initializer *InitializerInfo
+	if node.Immediate != nil {
+		initializer, results = g.InitializerGenerator.Generate(ctx, typ, *node.Immediate)
+		if !results.IsEmpty() {
+			return DataGlobalInfo{}, results
+		}
+	}
+
+	return NewDataGlobalInfo(name, typ, initializer, &declaration), core.ResultList{}
+}
+
+// Registers the provided global in the global manager of the file, or returns
+// an error if a global with the same name already exists.
+func newUniqueGlobal(ctx *FileGenerationContext, global GlobalInfo) core.ResultList {
+	previous := ctx.Globals.GetGlobal(global.Name())
+	if previous != nil {
+		return list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Global already defined",
+				Location: global.Declaration(),
+			},
+			{
+				Type:     core.HintResult,
+				Message:  "Previous definition here",
+				Location: previous.Declaration(),
+			},
+		})
+	}
+
+	return ctx.Globals.NewGlobal(global)
+}
+
+// MARK: Variable
+
+type VariableGlobalGenerator struct {
+	DataGlobalGenerator FileContextGenerator[parse.GlobalDeclarationNode, DataGlobalInfo]
+}
+
+func NewVariableGlobalGenerator() FileContextGenerator[parse.VarDeclarationNode, GlobalInfo] {
+	return FileContextGenerator[parse.VarDeclarationNode, GlobalInfo](
+		&VariableGlobalGenerator{
+			DataGlobalGenerator: NewDataGlobalGenerator(),
+		},
+	)
+}
+
+func (g *VariableGlobalGenerator) Generate(
+	ctx *FileGenerationContext,
+	node parse.VarDeclarationNode,
+) (GlobalInfo, core.ResultList) {
+	data, results := g.DataGlobalGenerator.Generate(ctx, node.Declaration)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	global := NewVariableGlobalInfo(data)
+	results = newUniqueGlobal(ctx, global)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return global, core.ResultList{}
+}
+
+// MARK: Constant
+
+type ConstantGlobalGenerator struct {
+	DataGlobalGenerator FileContextGenerator[parse.GlobalDeclarationNode, DataGlobalInfo]
+}
+
+func NewConstantGlobalGenerator() FileContextGenerator[parse.ConstDeclarationNode, GlobalInfo] {
+	return FileContextGenerator[parse.ConstDeclarationNode, GlobalInfo](
+		&ConstantGlobalGenerator{
+			DataGlobalGenerator: NewDataGlobalGenerator(),
+		},
+	)
+}
+
+func (g *ConstantGlobalGenerator) Generate(
+	ctx *FileGenerationContext,
+	node parse.ConstDeclarationNode,
+) (GlobalInfo, core.ResultList) {
+	if node.Declaration.Immediate == nil {
+		v := node.View()
+		return nil, list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Constant must be initialized with a value",
+				Location: &v,
+			},
+		})
+	}
+
+	data, results := g.DataGlobalGenerator.Generate(ctx, node.Declaration)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	global := NewConstantGlobalInfo(data)
+	results = newUniqueGlobal(ctx, global)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return global, core.ResultList{}
+}
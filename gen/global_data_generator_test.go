@@ -0,0 +1,142 @@
+package gen_test
+
+import (
+	"testing"
+
+	"alon.kr/x/usm/gen"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataGlobalGeneration(t *testing.T) {
+	src := `const @answer $32 #42
+var @table $32 ^2 ^3 {
+	{
+		#1
+		#-2
+	}
+	{
+		#0x10
+	}
+}
+var @counter $32
+`
+
+	file, results := generateFileFromSource(t, src)
+	require.True(t, results.IsEmpty())
+
+	answer := file.GetConstant("@answer")
+	require.NotNil(t, answer)
+	assert.True(t, answer.Initializer.IsScalar())
+	assert.EqualValues(t, 42, answer.Initializer.Value.Int64())
+
+	counter := file.GetVariable("@counter")
+	require.NotNil(t, counter)
+	assert.Nil(t, counter.Initializer)
+
+	table := file.GetVariable("@table")
+	require.NotNil(t, table)
+	assert.Equal(t, "$32 ^2 ^3", table.Type.String())
+
+	elements := table.Initializer.Elements
+	require.Len(t, elements, 2)
+	assert.Equal(t, "$32 ^2", elements[0].Type.String())
+	require.Len(t, elements[0].Elements, 2)
+	assert.EqualValues(t, -2, elements[0].Elements[1].Value.Int64())
+	require.Len(t, elements[1].Elements, 1)
+	assert.EqualValues(t, 16, elements[1].Elements[0].Value.Int64())
+
+	global := &gen.GlobalArgumentInfo{GlobalInfo: table}
+	typ, results := gen.ArgumentToType(global)
+	require.True(t, results.IsEmpty())
+	assert.Equal(t, "$32 ^2 ^3 *1", typ.String())
+}
+
+func TestDataGlobalValueOutOfRange(t *testing.T) {
+	_, results := generateFileFromSource(t, "var @x $32 #0x100000000\n")
+	assert.False(t, results.IsEmpty())
+
+	_, results = generateFileFromSource(t, "var @x $32 #0xffffffff\n")
+	assert.True(t, results.IsEmpty())
+}
+
+func TestDataGlobalTooManyValues(t *testing.T) {
+	src := `var @x $32 ^2 {
+	#1
+	#2
+	#3
+}
+`
+
+	_, results := generateFileFromSource(t, src)
+	assert.False(t, results.IsEmpty())
+}
+
+func TestDataGlobalUnexpectedBlock(t *testing.T) {
+	src := `var @x $32 {
+	#1
+}
+`
+
+	_, results := generateFileFromSource(t, src)
+	assert.False(t, results.IsEmpty())
+}
+
+func TestConstantWithoutValue(t *testing.T) {
+	_, results := generateFileFromSource(t, "const @x $32\n")
+	assert.False(t, results.IsEmpty())
+}
+
+func TestDataGlobalAlreadyDefined(t *testing.T) {
+	src := `var @x $32
+const @x $32 #1
+`
+
+	_, results := generateFileFromSource(t, src)
+	require.False(t, results.IsEmpty())
+
+	details := results.Head.Value
+	require.Len(t, details, 2)
+	assert.Equal(t, "Global already defined", details[0].Message)
+}
+
+func TestStructInitializer(t *testing.T) {
+	src := `type $pair {
+	.first $32
+	.second .alias $8
+	.third $16
+}
+
+const @pair $pair {
+	.second #2
+	#3
+}
+`
+
+	file, results := generateFileFromSource(t, src)
+	require.True(t, results.IsEmpty())
+
+	elements := file.GetConstant("@pair").Initializer.Elements
+	require.Len(t, elements, 3)
+	assert.Nil(t, elements[0])
+	assert.EqualValues(t, 2, elements[1].Value.Int64())
+	assert.EqualValues(t, 3, elements[2].Value.Int64())
+}
+
+func TestStructInitializerErrors(t *testing.T) {
+	types := `type $pair {
+	.first $32
+	.second $8
+}
+`
+
+	for _, src := range []string{
+		"const @x $pair {\n\t.third #1\n}\n",
+		"const @x $pair {\n\t.first #1\n\t.first #2\n}\n",
+		"const @x $pair {\n\t.second #1\n\t#2\n}\n",
+		"const @x $pair {\n\t.second #256\n}\n",
+	} {
+		_, results := generateFileFromSource(t, types+src)
+		assert.False(t, results.IsEmpty(), src)
+	}
+}
@@ -0,0 +1,103 @@
+package gen
+
+import "alon.kr/x/usm/core"
+
+// TypedGlobalInfo is a global that can be used as a typed argument of an
+// instruction. The value of such an argument is the address of the global.
+type TypedGlobalInfo interface {
+	GlobalInfo
+
+	// Returns the type of the pointer to the global.
+	PointerType() ReferencedTypeInfo
+}
+
+// DataGlobalInfo contains the information that is shared between global
+// variables and constants: globals that reside in memory, and hold a value of
+// some type.
+type DataGlobalInfo struct {
+	name string
+
+	// The type of the value that the global holds.
+	Type ReferencedTypeInfo
+
+	// The initial value of the global. Nil if an initial value is not
+	// provided, in which case the global is initialized to zero.
+	Initializer *InitializerInfo
+
+	declaration *core.UnmanagedSourceView
+}
+
+func NewDataGlobalInfo(
+	name string,
+	typ ReferencedTypeInfo,
+	initializer *InitializerInfo,
+	declaration *core.UnmanagedSourceView,
+) DataGlobalInfo {
+	return DataGlobalInfo{
+		name:        name,
+		Type:        typ,
+		Initializer: initializer,
+		declaration: declaration,
+	}
+}
+
+func (i *DataGlobalInfo) Name() string {
+	return i.name
+}
+
+func (i *DataGlobalInfo) Declaration() *core.UnmanagedSourceView {
+	return i.declaration
+}
+
+// Variables and constants are always defined with their (possibly implicit)
+// initial value.
+func (i *DataGlobalInfo) IsDefined() bool {
+	return true
+}
+
+func (i *DataGlobalInfo) PointerType() ReferencedTypeInfo {
+	typ := i.Type.PointerTo()
+	typ.Declaration = i.declaration
+	return typ
+}
+
+func (i *DataGlobalInfo) String() string {
+	s := i.name + " " + i.Type.String()
+	if i.Initializer != nil {
+		s += " " + i.Initializer.String()
+	}
+
+	return s
+}
+
+// MARK: Variable
+
+// VariableGlobalInfo is a global that holds a mutable value, declared by the
+// "var" keyword.
+type VariableGlobalInfo struct {
+	DataGlobalInfo
+}
+
+func NewVariableGlobalInfo(data DataGlobalInfo) *VariableGlobalInfo {
+	return &VariableGlobalInfo{DataGlobalInfo: data}
+}
+
+func (i *VariableGlobalInfo) String() string {
+	return "var " + i.DataGlobalInfo.String()
+}
+
+// MARK: Constant
+
+// ConstantGlobalInfo is a global that holds an immutable value, declared by
+// the "const" keyword.
+type ConstantGlobalInfo struct {
+	DataGlobalInfo
+}
+
+func NewConstantGlobalInfo(data DataGlobalInfo) *ConstantGlobalInfo {
+	return &ConstantGlobalInfo{DataGlobalInfo: data}
+}
+
+func (i *ConstantGlobalInfo) String() string {
+	return "const " + i.DataGlobalInfo.String()
+}
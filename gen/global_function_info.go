@@ -4,6 +4,11 @@ import "alon.kr/x/usm/core"
 
 type FunctionGlobalInfo struct {
 	*FunctionInfo
+
+	// True if the function is declared with a body in the source code.
+	// Unlike FunctionInfo.IsDefined, this is known before the body of the
+	// function is generated.
+	HasBody bool
 }
 
 func NewFunctionGlobalInfo(functionInfo *FunctionInfo) GlobalInfo {
@@ -19,3 +24,7 @@ func (f *FunctionGlobalInfo) Name() string {
 func (f *FunctionGlobalInfo) Declaration() *core.UnmanagedSourceView {
 	return f.FunctionInfo.Declaration
 }
+
+func (f *FunctionGlobalInfo) IsDefined() bool {
+	return f.HasBody || f.FunctionInfo.IsDefined()
+}
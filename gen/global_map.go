@@ -1,6 +1,7 @@
 package gen
 
 import (
+	"alon.kr/x/list"
 	"alon.kr/x/usm/core"
 )
 
@@ -14,8 +15,43 @@ func (m *GlobalMap) GetGlobal(name string) GlobalInfo {
 	return (*m)[name]
 }
 
+// Registers a new global.
+//
+// A function can be declared multiple times (possibly in different files), as
+// long as it is defined at most once. The definition of the function takes
+// precedence over its declarations, regardless of their order. Any other
+// global can be declared only once.
 func (m *GlobalMap) NewGlobal(global GlobalInfo) core.ResultList {
 	name := global.Name()
+	previous := (*m)[name]
+
+	if previous != nil {
+		_, previousIsFunction := previous.(*FunctionGlobalInfo)
+		_, isFunction := global.(*FunctionGlobalInfo)
+		bothDefined := previous.IsDefined() && global.IsDefined()
+
+		if !previousIsFunction || !isFunction || bothDefined {
+			return list.FromSingle(core.Result{
+				{
+					Type:     core.ErrorResult,
+					Message:  "Global already defined",
+					Location: global.Declaration(),
+				},
+				{
+					Type:     core.HintResult,
+					Message:  "Previous definition here",
+					Location: previous.Declaration(),
+				},
+			})
+		}
+
+		if !global.IsDefined() {
+			// A declaration does not replace a previous declaration or
+			// definition of the same function.
+			return core.ResultList{}
+		}
+	}
+
 	(*m)[name] = global
 	return core.ResultList{}
 }
@@ -20,3 +20,38 @@ func TestGlobalMap(t *testing.T) {
 	assert.Equal(t, global, gotGlobal)
 	assert.Equal(t, info.Name, gotGlobal.Name())
 }
+
+func TestGlobalMapDefinitionTakesPrecedence(t *testing.T) {
+	gm := NewGlobalMap(nil)
+
+	declaration := &FunctionGlobalInfo{FunctionInfo: &FunctionInfo{Name: "foo"}}
+	definition := &FunctionGlobalInfo{
+		FunctionInfo: &FunctionInfo{Name: "foo"},
+		HasBody:      true,
+	}
+
+	results := gm.NewGlobal(declaration)
+	assert.True(t, results.IsEmpty())
+
+	results = gm.NewGlobal(definition)
+	assert.True(t, results.IsEmpty())
+	assert.Equal(t, definition, gm.GetGlobal("foo"))
+
+	results = gm.NewGlobal(declaration)
+	assert.True(t, results.IsEmpty())
+	assert.Equal(t, definition, gm.GetGlobal("foo"))
+}
+
+func TestGlobalMapDuplicateDefinition(t *testing.T) {
+	gm := NewGlobalMap(nil)
+
+	first := &FunctionGlobalInfo{FunctionInfo: &FunctionInfo{Name: "foo"}, HasBody: true}
+	second := &FunctionGlobalInfo{FunctionInfo: &FunctionInfo{Name: "foo"}, HasBody: true}
+
+	results := gm.NewGlobal(first)
+	assert.True(t, results.IsEmpty())
+
+	results = gm.NewGlobal(second)
+	assert.False(t, results.IsEmpty())
+	assert.Equal(t, first, gm.GetGlobal("foo"))
+}
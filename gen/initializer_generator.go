@@ -0,0 +1,293 @@
+package gen
+
+import (
+	"fmt"
+	"math/big"
+
+	"alon.kr/x/list"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/parse"
+)
+
+// InitializerGenerator generates the initial value of a global from an
+// immediate value node, with respect to the declared type of the global.
+//
+// A type whose last descriptor is a repeat descriptor (for example, "$32 ^4")
+// is initialized by a block of element values, where missing elements at the
+// end of the block are initialized to zero. A declared type is initialized by
+// a block of field values, where each value either specifies the label of the
+// field it initializes, or initializes the field that follows the previously
+// initialized field. Fields that are not initialized are zero.
+//
+// All other types, including declared types when a single value is provided,
+// are initialized by a single value.
+type InitializerGenerator struct{}
+
+func NewInitializerGenerator() *InitializerGenerator {
+	return &InitializerGenerator{}
+}
+
+// Returns the size (in bits) of a scalar value of the provided type.
+func (g *InitializerGenerator) scalarSize(
+	ctx *FileGenerationContext,
+	typ ReferencedTypeInfo,
+) *big.Int {
+	if typ.IsPure() {
+		return typ.Base.Size
+	}
+
+	return ctx.PointerSize
+}
+
+// Returns true if the provided value can be represented by a value of the
+// provided size (in bits), either as a signed or as an unsigned integer.
+func (g *InitializerGenerator) valueFitsSize(value *big.Int, size *big.Int) bool {
+	if size == nil || !size.IsUint64() {
+		return true
+	}
+
+	bits := uint(size.Uint64())
+	upper := new(big.Int).Lsh(big.NewInt(1), bits)
+	lower := new(big.Int).Neg(new(big.Int).Rsh(upper, 1))
+	return value.Cmp(lower) >= 0 && value.Cmp(upper) < 0
+}
+
+func (g *InitializerGenerator) generateScalar(
+	ctx *FileGenerationContext,
+	typ ReferencedTypeInfo,
+	node parse.ImmediateValueNode,
+) (*InitializerInfo, core.ResultList) {
+	v := node.View()
+
+	immediate, ok := node.(parse.ImmediateFinalValueNode)
+	if !ok {
+		return nil, list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  fmt.Sprintf("Expected a single value to initialize type \"%s\"", typ),
+				Location: &v,
+			},
+		})
+	}
+
+	immediate.Start += 1 // to skip the '#' character
+	valueStr := NodeToSourceString(ctx, immediate)
+	value, ok := new(big.Int).SetString(valueStr, 0)
+	if !ok {
+		return nil, list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Invalid immediate value",
+				Location: &v,
+			},
+		})
+	}
+
+	size := g.scalarSize(ctx, typ)
+	if !g.valueFitsSize(value, size) {
+		return nil, list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  fmt.Sprintf("Value does not fit in type \"%s\"", typ),
+				Location: &v,
+			},
+			{
+				Type:    core.HintResult,
+				Message: fmt.Sprintf("Type \"%s\" is %s bits wide", typ, size),
+			},
+		})
+	}
+
+	info := &InitializerInfo{
+		Type:        typ,
+		Value:       value,
+		Declaration: &v,
+	}
+
+	return info, core.ResultList{}
+}
+
+func (g *InitializerGenerator) generateRepeated(
+	ctx *FileGenerationContext,
+	typ ReferencedTypeInfo,
+	node parse.ImmediateValueNode,
+) (*InitializerInfo, core.ResultList) {
+	v := node.View()
+
+	block, ok := node.(parse.ImmediateBlockNode)
+	if !ok {
+		return nil, list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  fmt.Sprintf("Expected a block of values to initialize type \"%s\"", typ),
+				Location: &v,
+			},
+		})
+	}
+
+	amount := typ.Descriptors[len(typ.Descriptors)-1].Amount
+	elementType := typ.RepeatedElement()
+
+	if big.NewInt(int64(len(block.Nodes))).Cmp(amount) > 0 {
+		extra := block.Nodes[amount.Int64()].View()
+		return nil, list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  fmt.Sprintf("Too many values to initialize type \"%s\"", typ),
+				Location: &extra,
+			},
+			{
+				Type:    core.HintResult,
+				Message: fmt.Sprintf("Type \"%s\" holds %s elements", typ, amount),
+			},
+		})
+	}
+
+	results := core.ResultList{}
+	elements := make([]*InitializerInfo, len(block.Nodes))
+
+	for i, field := range block.Nodes {
+		if field.Label != nil {
+			labelView := field.Label.View()
+			results.Append(core.Result{
+				{
+					Type:     core.ErrorResult,
+					Message:  "Unexpected field label in initializer of repeated type",
+					Location: &labelView,
+				},
+			})
+			continue
+		}
+
+		element, curResults := g.Generate(ctx, elementType, field.Value)
+		results.Extend(&curResults)
+		elements[i] = element
+	}
+
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	info := &InitializerInfo{
+		Type:        typ,
+		Elements:    elements,
+		Declaration: &v,
+	}
+
+	return info, core.ResultList{}
+}
+
+// Returns the index of the field that the provided field node initializes.
+func (g *InitializerGenerator) fieldIndex(
+	ctx *FileGenerationContext,
+	typ ReferencedTypeInfo,
+	node parse.ImmediateFieldNode,
+	next int,
+) (int, core.ResultList) {
+	if node.Label == nil {
+		if next >= len(typ.Base.Fields) {
+			v := node.View()
+			return 0, list.FromSingle(core.Result{
+				{
+					Type:     core.ErrorResult,
+					Message:  fmt.Sprintf("Too many values to initialize type \"%s\"", typ),
+					Location: &v,
+				},
+				{
+					Type:     core.HintResult,
+					Message:  fmt.Sprintf("Type \"%s\" has %d fields", typ, len(typ.Base.Fields)),
+					Location: typ.Base.Declaration,
+				},
+			})
+		}
+
+		return next, core.ResultList{}
+	}
+
+	label := NodeToSourceString(ctx, node.Label)
+	index := typ.Base.FieldIndex(label)
+	if index < 0 {
+		v := node.Label.View()
+		return 0, list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  fmt.Sprintf("Type \"%s\" has no field \"%s\"", typ, label),
+				Location: &v,
+			},
+			{
+				Type:     core.HintResult,
+				Message:  "Type declared here",
+				Location: typ.Base.Declaration,
+			},
+		})
+	}
+
+	return index, core.ResultList{}
+}
+
+func (g *InitializerGenerator) generateStruct(
+	ctx *FileGenerationContext,
+	typ ReferencedTypeInfo,
+	block parse.ImmediateBlockNode,
+) (*InitializerInfo, core.ResultList) {
+	elements := make([]*InitializerInfo, len(typ.Base.Fields))
+
+	next := 0
+	for _, field := range block.Nodes {
+		index, results := g.fieldIndex(ctx, typ, field, next)
+		if !results.IsEmpty() {
+			return nil, results
+		}
+
+		if previous := elements[index]; previous != nil {
+			v := field.View()
+			return nil, list.FromSingle(core.Result{
+				{
+					Type:     core.ErrorResult,
+					Message:  "Field is initialized more than once",
+					Location: &v,
+				},
+				{
+					Type:     core.HintResult,
+					Message:  "Previously initialized here",
+					Location: previous.Declaration,
+				},
+			})
+		}
+
+		fieldType := typ.Base.Fields[index].Type
+		element, results := g.Generate(ctx, fieldType, field.Value)
+		if !results.IsEmpty() {
+			return nil, results
+		}
+
+		elements[index] = element
+		next = index + 1
+	}
+
+	v := block.View()
+	info := &InitializerInfo{
+		Type:        typ,
+		Elements:    elements,
+		Declaration: &v,
+	}
+
+	return info, core.ResultList{}
+}
+
+func (g *InitializerGenerator) Generate(
+	ctx *FileGenerationContext,
+	typ ReferencedTypeInfo,
+	node parse.ImmediateValueNode,
+) (*InitializerInfo, core.ResultList) {
+	if typ.IsRepeated() {
+		return g.generateRepeated(ctx, typ, node)
+	}
+
+	block, isBlock := node.(parse.ImmediateBlockNode)
+	if isBlock && typ.IsPure() && typ.Base.Fields != nil {
+		return g.generateStruct(ctx, typ, block)
+	}
+
+	return g.generateScalar(ctx, typ, node)
+}
@@ -0,0 +1,98 @@
+package gen
+
+import (
+	"math/big"
+	"strings"
+
+	"alon.kr/x/usm/core"
+)
+
+// InitializerInfo describes the initial value of a global variable or
+// constant, as declared by an immediate value in the source.
+//
+// An initializer is either a scalar, which holds a single integer value, or
+// a composite, which holds an initializer for each of the elements of the
+// type: the repeated elements of a repeated type, or the fields of a declared
+// type.
+type InitializerInfo struct {
+	// The type of the initialized value.
+	Type ReferencedTypeInfo
+
+	// The value of a scalar initializer. Nil if the initializer is a composite.
+	Value *big.Int
+
+	// The initializers of the elements of a composite initializer, in order.
+	// An element which is nil, or is missing at the end of the slice, is
+	// initialized to zero.
+	Elements []*InitializerInfo
+
+	Declaration *core.UnmanagedSourceView
+}
+
+func (i *InitializerInfo) IsScalar() bool {
+	return i.Value != nil
+}
+
+// Returns true if the elements of the initializer are the fields of a
+// declared type, and false if they are the elements of a repeated type.
+func (i *InitializerInfo) IsStruct() bool {
+	return i.Type.IsPure()
+}
+
+// Returns the type of the element of a composite initializer at the provided
+// index.
+func (i *InitializerInfo) elementType(index int) ReferencedTypeInfo {
+	if i.IsStruct() {
+		return i.Type.Base.Fields[index].Type
+	}
+
+	return i.Type.RepeatedElement()
+}
+
+// Returns the textual representation of a zero initializer of the provided
+// type.
+func zeroInitializerString(typ ReferencedTypeInfo, indent int) string {
+	if typ.IsRepeated() || (typ.IsPure() && typ.Base.Fields != nil) {
+		return "{\n" + strings.Repeat("\t", indent) + "}"
+	}
+
+	return "#0"
+}
+
+func (i *InitializerInfo) stringIndented(indent int) string {
+	if i.IsScalar() {
+		return "#" + i.Value.String()
+	}
+
+	prefix := strings.Repeat("\t", indent+1)
+	s := "{\n"
+
+	// The index of the last element that was written, which allows writing
+	// the next element without a label.
+	last := -1
+	for index, element := range i.Elements {
+		if element == nil {
+			continue
+		}
+
+		label := ""
+		if i.IsStruct() && len(i.Type.Base.Fields[index].Labels) > 0 {
+			label = i.Type.Base.Fields[index].Labels[0] + " "
+		} else {
+			// Elements without a label are positional, so all elements
+			// before them must be written explicitly.
+			for gap := last + 1; gap < index; gap++ {
+				s += prefix + zeroInitializerString(i.elementType(gap), indent+1) + "\n"
+			}
+		}
+
+		s += prefix + label + element.stringIndented(indent+1) + "\n"
+		last = index
+	}
+
+	return s + strings.Repeat("\t", indent) + "}"
+}
+
+func (i *InitializerInfo) String() string {
+	return i.stringIndented(0)
+}
@@ -123,6 +123,14 @@ func (m *InstructionMap) GetInstructionDefinition(
 	return inst, core.ResultList{}
 }
 
+func (m *InstructionMap) InstructionNames() []string {
+	names := make([]string, 0, len(*m))
+	for name := range *m {
+		names = append(names, name)
+	}
+	return names
+}
+
 func PrepareTestForInstructionGeneration(
 	src core.SourceView,
 	t *testing.T,
@@ -14,4 +14,7 @@ type InstructionManager interface {
 		name string,
 		node *parse.InstructionNode,
 	) (InstructionDefinition, core.ResultList)
+
+	// Returns the names of all instructions that the manager defines.
+	InstructionNames() []string
 }
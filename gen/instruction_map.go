@@ -11,6 +11,7 @@ import (
 
 type InstructionMap struct {
 	faststringmap.Map[InstructionDefinition]
+	names         []string
 	caseSensitive bool
 }
 
@@ -39,6 +40,10 @@ func (m *InstructionMap) GetInstructionDefinition(
 	return def, core.ResultList{}
 }
 
+func (m *InstructionMap) InstructionNames() []string {
+	return m.names
+}
+
 func NewInstructionMap(
 	definitions []faststringmap.MapEntry[InstructionDefinition],
 	caseSensitive bool,
@@ -49,8 +54,14 @@ func NewInstructionMap(
 		}
 	}
 
+	names := make([]string, len(definitions))
+	for i, definition := range definitions {
+		names[i] = definition.Key
+	}
+
 	return &InstructionMap{
 		Map:           faststringmap.NewMap(definitions),
+		names:         names,
 		caseSensitive: caseSensitive,
 	}
 }
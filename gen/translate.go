@@ -40,15 +40,19 @@ func ArgumentToType(arg ArgumentInfo) (ReferencedTypeInfo, core.ResultList) {
 	case *ImmediateInfo:
 		return typedArg.Type, core.ResultList{}
 
-	default:
-		return ReferencedTypeInfo{}, list.FromSingle(core.Result{
-			{
-				Type:     core.ErrorResult,
-				Message:  "Expected a typed argument",
-				Location: arg.Declaration(),
-			},
-		})
+	case *GlobalArgumentInfo:
+		if global, ok := typedArg.GlobalInfo.(TypedGlobalInfo); ok {
+			return global.PointerType(), core.ResultList{}
+		}
 	}
+
+	return ReferencedTypeInfo{}, list.FromSingle(core.Result{
+		{
+			Type:     core.ErrorResult,
+			Message:  "Expected a typed argument",
+			Location: arg.Declaration(),
+		},
+	})
 }
 
 func ArgumentsToTypes(
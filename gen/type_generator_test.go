@@ -8,6 +8,7 @@ import (
 	"alon.kr/x/usm/gen"
 	"alon.kr/x/usm/parse"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestTypeAliasDeclaration(t *testing.T) {
@@ -236,3 +237,162 @@ func TestRepeatTypeTooLarge(t *testing.T) {
 	_, results := generator.Generate(ctx, node)
 	assert.False(t, results.IsEmpty())
 }
+
+func TestStructTypeLayout(t *testing.T) {
+	src := `type $struct {
+	.a $8
+	.b .alias $32
+	.c $16 ^3
+}
+
+type $array {
+	$struct ^2
+}
+`
+
+	file, results := generateFileFromSource(t, src)
+	require.True(t, results.IsEmpty())
+
+	structType := file.GetType("$struct")
+	require.NotNil(t, structType)
+	require.Len(t, structType.Fields, 3)
+
+	offsets := []int64{}
+	for _, field := range structType.Fields {
+		offsets = append(offsets, field.Offset.Int64())
+	}
+
+	assert.Equal(t, []int64{0, 32, 64}, offsets)
+	assert.EqualValues(t, 112, structType.Size.Int64())
+	assert.EqualValues(t, 32, structType.Alignment.Int64())
+	assert.Equal(t, 1, structType.FieldIndex(".alias"))
+
+	// Each element of the array is padded to the alignment of the struct.
+	arrayType := file.GetType("$array")
+	require.NotNil(t, arrayType)
+	assert.EqualValues(t, 256, arrayType.Size.Int64())
+}
+
+func TestRecursiveTypeThroughPointer(t *testing.T) {
+	src := `type $list {
+	.head $node *
+}
+
+type $node {
+	.value $8
+	.next $node *
+}
+`
+
+	file, results := generateFileFromSource(t, src)
+	require.True(t, results.IsEmpty())
+
+	pointerSize := testGenerationContext.PointerSize.Int64()
+	node := file.GetType("$node")
+	assert.EqualValues(t, pointerSize, node.Fields[1].Offset.Int64())
+	assert.EqualValues(t, 2*pointerSize, node.Size.Int64())
+	assert.EqualValues(t, pointerSize, file.GetType("$list").Size.Int64())
+}
+
+func TestInfiniteTypeSize(t *testing.T) {
+	src := `type $a {
+	.value $32
+	.b $b
+}
+
+type $b {
+	.a $a ^2
+}
+`
+
+	_, results := generateFileFromSource(t, src)
+	require.False(t, results.IsEmpty())
+
+	details := results.Head.Value
+	require.Len(t, details, 3)
+	assert.Contains(t, details[0].Message, "infinite size")
+	assert.Equal(t, ".b $b", string(details[0].Location.Raw(core.NewSourceView(src).Ctx())))
+	assert.Equal(t, ".a $a ^2", string(details[1].Location.Raw(core.NewSourceView(src).Ctx())))
+}
+
+func TestDuplicateFieldLabel(t *testing.T) {
+	src := `type $a {
+	.x $32
+	.x $32
+}
+`
+
+	_, results := generateFileFromSource(t, src)
+	assert.False(t, results.IsEmpty())
+}
+
+func TestFunctionTypeDeclaration(t *testing.T) {
+	src := `type $binop $32 = func $32 $binop *
+`
+
+	file, results := generateFileFromSource(t, src)
+	require.True(t, results.IsEmpty())
+
+	binop := file.GetType("$binop")
+	require.NotNil(t, binop.Signature)
+	assert.Nil(t, binop.Fields)
+	assert.Len(t, binop.Signature.Returns, 1)
+	assert.Len(t, binop.Signature.Parameters, 2)
+	assert.Equal(t, "type $binop $32 = func $32 $binop *1", binop.DeclarationString())
+
+	pointer := binop.Signature.Parameters[1]
+	assert.Same(t, binop.Signature, pointer.FunctionSignature())
+	assert.Nil(t, binop.Signature.Parameters[0].FunctionSignature())
+}
+
+func TestIntegerSize(t *testing.T) {
+	src := `type $struct {
+	.a $32
+}
+`
+
+	file, results := generateFileFromSource(t, src)
+	require.True(t, results.IsEmpty())
+
+	structType := gen.ReferencedTypeInfo{Base: file.GetType("$struct")}
+	assert.Nil(t, structType.IntegerSize())
+
+	builtin := structType.Base.Fields[0].Type
+	assert.EqualValues(t, 32, builtin.IntegerSize().Int64())
+
+	assert.Nil(t, builtin.PointerTo().IntegerSize())
+
+	repeated := gen.ReferencedTypeInfo{
+		Base: builtin.Base,
+		Descriptors: []gen.TypeDescriptorInfo{
+			{Type: gen.RepeatTypeDescriptor, Amount: big.NewInt(2)},
+		},
+	}
+	assert.Nil(t, repeated.IntegerSize())
+}
+
+func TestWrapInteger(t *testing.T) {
+	src := `type $struct {
+	.a $8
+}
+`
+
+	file, results := generateFileFromSource(t, src)
+	require.True(t, results.IsEmpty())
+
+	byteType := file.GetType("$struct").Fields[0].Type
+	for value, expected := range map[int64]int64{
+		0:    0,
+		127:  127,
+		128:  -128,
+		255:  -1,
+		256:  0,
+		-129: 127,
+	} {
+		assert.EqualValues(t, expected, byteType.WrapInteger(big.NewInt(value)).Int64(), value)
+	}
+
+	// Values of non integer types are not wrapped.
+	pointerType := byteType.PointerTo()
+	assert.EqualValues(t, 1000, pointerType.WrapInteger(big.NewInt(1000)).Int64())
+}
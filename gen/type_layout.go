@@ -0,0 +1,207 @@
+package gen
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+
+	"alon.kr/x/list"
+	"alon.kr/x/usm/core"
+)
+
+// The layout of a type describes how values of the type are placed in memory.
+// All sizes, alignments and offsets are measured in bits.
+//
+// Builtin types are aligned to the smallest power of two number of bytes that
+// can hold them, but not more than the size of a pointer. Pointers are aligned
+// to their size. Declared types are laid out field after field, where each
+// field is aligned to its own alignment, and the type is aligned to the
+// maximal alignment of its fields.
+//
+// The size of a type does not include trailing padding. When a type is
+// repeated, each element is placed at a multiple of the type size rounded up
+// to the type alignment.
+
+// The minimal alignment of any type, in bits.
+var minTypeAlignment = big.NewInt(8)
+
+// Rounds the value up to the nearest multiple of the alignment.
+func alignUp(value *big.Int, alignment *big.Int) *big.Int {
+	aligned := new(big.Int).Add(value, alignment)
+	aligned.Sub(aligned, big.NewInt(1))
+	aligned.Div(aligned, alignment)
+	return aligned.Mul(aligned, alignment)
+}
+
+func builtinTypeAlignment(size *big.Int, pointerSize *big.Int) *big.Int {
+	alignment := new(big.Int).Set(minTypeAlignment)
+	for alignment.Cmp(size) < 0 && alignment.Cmp(pointerSize) < 0 {
+		alignment.Lsh(alignment, 1)
+	}
+
+	return alignment
+}
+
+func namedTypeAlignment(typ *NamedTypeInfo, pointerSize *big.Int) *big.Int {
+	if typ.Alignment != nil {
+		return typ.Alignment
+	}
+
+	return builtinTypeAlignment(typ.Size, pointerSize)
+}
+
+// Returns the size and alignment (in bits) of the provided type.
+//
+// The layout of the base type should already be calculated, unless the type
+// is a pointer (or is composed of pointers), in which case the size of the
+// base type does not affect the layout.
+func ReferencedTypeLayout(
+	typ ReferencedTypeInfo,
+	pointerSize *big.Int,
+) (size *big.Int, alignment *big.Int) {
+	descriptors := typ.Descriptors
+	lastPointer := -1
+	for i, descriptor := range descriptors {
+		if descriptor.Type == PointerTypeDescriptor {
+			lastPointer = i
+		}
+	}
+
+	if lastPointer >= 0 {
+		size, alignment = pointerSize, pointerSize
+		descriptors = descriptors[lastPointer+1:]
+	} else {
+		size = typ.Base.Size
+		alignment = namedTypeAlignment(typ.Base, pointerSize)
+	}
+
+	for _, descriptor := range descriptors {
+		// All remaining descriptors are repeat descriptors.
+		size = new(big.Int).Mul(alignUp(size, alignment), descriptor.Amount)
+	}
+
+	return size, alignment
+}
+
+// Returns the size (in bits) of the provided type.
+func ReferencedTypeSize(typ ReferencedTypeInfo, pointerSize *big.Int) *big.Int {
+	size, _ := ReferencedTypeLayout(typ, pointerSize)
+	return size
+}
+
+// Returns true if the layout of the provided type depends on the layout of
+// its base type, which is the case if it is not a pointer.
+func (t ReferencedTypeInfo) dependsOnBase() bool {
+	for _, descriptor := range t.Descriptors {
+		if descriptor.Type == PointerTypeDescriptor {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MARK: Calculation
+
+// typeLayoutCalculator calculates the layout of declared types, which can
+// refer to each other (and to themselves) in any order.
+type typeLayoutCalculator struct {
+	PointerSize *big.Int
+
+	// The types whose layout is currently being calculated, where each type
+	// contains the next type (directly, not through a pointer) in the field
+	// of the same index in the fields slice.
+	types  []*NamedTypeInfo
+	fields []*TypeFieldInfo
+}
+
+func (c *typeLayoutCalculator) cycleResults(start int) core.ResultList {
+	cycle := c.types[start:]
+	fields := c.fields[start:]
+
+	result := core.Result{
+		{
+			Type: core.ErrorResult,
+			Message: fmt.Sprintf(
+				"Type \"%s\" has an infinite size, since it contains itself",
+				cycle[0],
+			),
+			Location: fields[0].Declaration,
+		},
+	}
+
+	for i := 1; i < len(cycle); i++ {
+		result = append(result, core.ResultDetails{
+			Type:     core.HintResult,
+			Message:  fmt.Sprintf("Type \"%s\" contains type \"%s\" here", cycle[i], fields[i].Type.Base),
+			Location: fields[i].Declaration,
+		})
+	}
+
+	result = append(result, core.ResultDetails{
+		Type:    core.HintResult,
+		Message: "Perhaps you meant to use a pointer (\"*\")?",
+	})
+
+	return list.FromSingle(result)
+}
+
+func (c *typeLayoutCalculator) calculate(typ *NamedTypeInfo) core.ResultList {
+	if typ.Size != nil {
+		return core.ResultList{}
+	}
+
+	if start := slices.Index(c.types, typ); start >= 0 {
+		return c.cycleResults(start)
+	}
+
+	c.types = append(c.types, typ)
+	defer func() { c.types = c.types[:len(c.types)-1] }()
+
+	offset := big.NewInt(0)
+	alignment := new(big.Int).Set(minTypeAlignment)
+
+	for _, field := range typ.Fields {
+		if field.Type.dependsOnBase() {
+			c.fields = append(c.fields, field)
+			results := c.calculate(field.Type.Base)
+			c.fields = c.fields[:len(c.fields)-1]
+
+			if !results.IsEmpty() {
+				return results
+			}
+		}
+
+		fieldSize, fieldAlignment := ReferencedTypeLayout(field.Type, c.PointerSize)
+		field.Offset = alignUp(offset, fieldAlignment)
+		offset = new(big.Int).Add(field.Offset, fieldSize)
+
+		if fieldAlignment.Cmp(alignment) > 0 {
+			alignment.Set(fieldAlignment)
+		}
+	}
+
+	typ.Size = offset
+	typ.Alignment = alignment
+	return core.ResultList{}
+}
+
+// Calculates the size, alignment and field offsets of the provided declared
+// types, whose fields are already generated. Types that are contained in
+// themselves (not through a pointer) have an infinite size, and an error is
+// returned for them.
+func CalculateTypeLayouts(
+	ctx *FileGenerationContext,
+	types []*NamedTypeInfo,
+) core.ResultList {
+	calculator := typeLayoutCalculator{PointerSize: ctx.PointerSize}
+
+	for _, typ := range types {
+		results := calculator.calculate(typ)
+		if !results.IsEmpty() {
+			return results
+		}
+	}
+
+	return core.ResultList{}
+}
@@ -1,52 +1,48 @@
 package gen
 
 import (
-	"math/big"
-
 	"alon.kr/x/list"
 	"alon.kr/x/usm/core"
 	"alon.kr/x/usm/parse"
 )
 
+// TypeDeclarationGenerator generates named types from their declarations.
+//
+// Besides generating a single type declaration, it can generate a type in
+// two separate steps: first declaring the type, and only then defining its
+// fields. This allows types to refer to types which are declared after them,
+// and to themselves.
+type TypeDeclarationGenerator interface {
+	FileContextGenerator[parse.TypeDeclarationNode, *NamedTypeInfo]
+
+	// Registers a new named type, without generating its fields.
+	Declare(
+		ctx *FileGenerationContext,
+		node parse.TypeDeclarationNode,
+	) (*NamedTypeInfo, core.ResultList)
+
+	// Generates the fields of a type that was previously declared.
+	// The layout of the type is not calculated.
+	Define(
+		ctx *FileGenerationContext,
+		node parse.TypeDeclarationNode,
+		typeInfo *NamedTypeInfo,
+	) core.ResultList
+}
+
 type NamedTypeGenerator struct {
 	ReferencedTypeGenerator FileContextGenerator[parse.TypeNode, ReferencedTypeInfo]
 }
 
-func NewNamedTypeGenerator() FileContextGenerator[parse.TypeDeclarationNode, *NamedTypeInfo] {
-	return FileContextGenerator[parse.TypeDeclarationNode, *NamedTypeInfo](
+func NewNamedTypeGenerator() TypeDeclarationGenerator {
+	return TypeDeclarationGenerator(
 		&NamedTypeGenerator{
 			ReferencedTypeGenerator: NewReferencedTypeGenerator(),
 		},
 	)
 }
 
-func (g *NamedTypeGenerator) calculateTypeSize(
-	ctx *FileGenerationContext,
-	node parse.TypeNode,
-	typeInfo ReferencedTypeInfo,
-) (*big.Int, core.ResultList) {
-	size := new(big.Int).Set(typeInfo.Base.Size)
-
-	for _, descriptor := range typeInfo.Descriptors {
-		switch descriptor.Type {
-		case PointerTypeDescriptor:
-			size.Set(ctx.PointerSize)
-		case RepeatTypeDescriptor:
-			size.Mul(size, descriptor.Amount)
-		default:
-			v := node.View()
-			return nil, list.FromSingle(core.Result{{
-				Type:     core.InternalErrorResult,
-				Message:  "Unknown type descriptor",
-				Location: &v,
-			}})
-		}
-	}
-
-	return size, core.ResultList{}
-}
-
-func (g *NamedTypeGenerator) Generate(
+func (g *NamedTypeGenerator) Declare(
 	ctx *FileGenerationContext,
 	node parse.TypeDeclarationNode,
 ) (*NamedTypeInfo, core.ResultList) {
@@ -69,36 +65,151 @@ func (g *NamedTypeGenerator) Generate(
 		})
 	}
 
-	if len(node.Fields.Nodes) != 1 {
-		return nil, list.FromSingle(core.Result{
-			{
-				Type:     core.ErrorResult,
-				Message:  "Type declaration currently only supports a single field",
-				Location: &declaration,
-			},
-		})
+	typeInfo = &NamedTypeInfo{
+		Name:        identifier,
+		Fields:      []*TypeFieldInfo{},
+		Declaration: &declaration,
 	}
 
-	referencedTypeNode := node.Fields.Nodes[0].Type
-	referencedTypeInfo, results := g.ReferencedTypeGenerator.Generate(ctx, referencedTypeNode)
+	result := ctx.Types.NewType(typeInfo)
+	if result != nil {
+		return nil, list.FromSingle(result)
+	}
+
+	return typeInfo, core.ResultList{}
+}
+
+func (g *NamedTypeGenerator) generateField(
+	ctx *FileGenerationContext,
+	node parse.TypeFieldNode,
+) (*TypeFieldInfo, core.ResultList) {
+	typ, results := g.ReferencedTypeGenerator.Generate(ctx, node.Type)
 	if !results.IsEmpty() {
 		return nil, results
 	}
 
-	size, results := g.calculateTypeSize(ctx, referencedTypeNode, referencedTypeInfo)
+	labels := make([]string, 0, len(node.Labels))
+	for _, label := range node.Labels {
+		labels = append(labels, NodeToSourceString(ctx, label))
+	}
+
+	v := node.View()
+	field := &TypeFieldInfo{
+		Labels:      labels,
+		Type:        typ,
+		Declaration: &v,
+	}
+
+	return field, core.ResultList{}
+}
+
+func (g *NamedTypeGenerator) generateTypes(
+	ctx *FileGenerationContext,
+	nodes []parse.TypeNode,
+) ([]ReferencedTypeInfo, core.ResultList) {
+	results := core.ResultList{}
+	types := make([]ReferencedTypeInfo, 0, len(nodes))
+
+	for _, node := range nodes {
+		typ, curResults := g.ReferencedTypeGenerator.Generate(ctx, node)
+		results.Extend(&curResults)
+		types = append(types, typ)
+	}
+
+	return types, results
+}
+
+func (g *NamedTypeGenerator) defineSignature(
+	ctx *FileGenerationContext,
+	node *parse.TypeSignatureNode,
+	typeInfo *NamedTypeInfo,
+) core.ResultList {
+	returns, results := g.generateTypes(ctx, node.Returns)
+
+	parameters, curResults := g.generateTypes(ctx, node.Parameters)
+	results.Extend(&curResults)
+
+	if !results.IsEmpty() {
+		return results
+	}
+
+	typeInfo.Fields = nil
+	typeInfo.Signature = &TypeSignatureInfo{
+		Returns:    returns,
+		Parameters: parameters,
+	}
+
+	return core.ResultList{}
+}
+
+func (g *NamedTypeGenerator) Define(
+	ctx *FileGenerationContext,
+	node parse.TypeDeclarationNode,
+	typeInfo *NamedTypeInfo,
+) core.ResultList {
+	if node.Signature != nil {
+		return g.defineSignature(ctx, node.Signature, typeInfo)
+	}
+
+	results := core.ResultList{}
+	fields := make([]*TypeFieldInfo, 0, len(node.Fields.Nodes))
+
+	// Maps each field label to the field node that declared it.
+	labels := make(map[string]parse.LabelNode)
+
+	for _, fieldNode := range node.Fields.Nodes {
+		for _, label := range fieldNode.Labels {
+			name := NodeToSourceString(ctx, label)
+			if previous, ok := labels[name]; ok {
+				v := label.View()
+				previousView := previous.View()
+				results.Append(core.Result{
+					{
+						Type:     core.ErrorResult,
+						Message:  "Field label already defined",
+						Location: &v,
+					},
+					{
+						Type:     core.HintResult,
+						Message:  "Previous definition here",
+						Location: &previousView,
+					},
+				})
+			}
+
+			labels[name] = label
+		}
+
+		field, curResults := g.generateField(ctx, fieldNode)
+		results.Extend(&curResults)
+		fields = append(fields, field)
+	}
+
+	if !results.IsEmpty() {
+		return results
+	}
+
+	typeInfo.Fields = fields
+	return core.ResultList{}
+}
+
+func (g *NamedTypeGenerator) Generate(
+	ctx *FileGenerationContext,
+	node parse.TypeDeclarationNode,
+) (*NamedTypeInfo, core.ResultList) {
+	typeInfo, results := g.Declare(ctx, node)
 	if !results.IsEmpty() {
 		return nil, results
 	}
 
-	typeInfo = &NamedTypeInfo{
-		Name:        identifier,
-		Size:        size,
-		Declaration: &declaration,
+	results = g.Define(ctx, node, typeInfo)
+	if !results.IsEmpty() {
+		return nil, results
 	}
 
-	result := ctx.Types.NewType(typeInfo)
-	if result != nil {
-		return nil, list.FromSingle(result)
+	results = CalculateTypeLayouts(ctx, []*NamedTypeInfo{typeInfo})
+	if !results.IsEmpty() {
+		return nil, results
 	}
 
 	return typeInfo, core.ResultList{}
@@ -6,6 +6,45 @@ import (
 	"alon.kr/x/usm/core"
 )
 
+// A field of a named type, declared in the body of a "type" declaration.
+type TypeFieldInfo struct {
+	// The labels that refer to the field, including the "." prefix.
+	// A field can have any number of labels, including zero.
+	Labels []string
+
+	// The type of the field.
+	Type ReferencedTypeInfo
+
+	// The offset of the field from the start of the type, in bits.
+	// Nil until the layout of the type is calculated.
+	Offset *big.Int
+
+	Declaration *core.UnmanagedSourceView
+}
+
+// The signature of a function type: the types of the values that a function
+// of the type receives and returns. Values of the function type itself can't
+// be used directly; a pointer to a function type holds the address of a
+// function with the signature.
+type TypeSignatureInfo struct {
+	Returns    []ReferencedTypeInfo
+	Parameters []ReferencedTypeInfo
+}
+
+func (s *TypeSignatureInfo) String() string {
+	str := ""
+	for _, ret := range s.Returns {
+		str += ret.String() + " "
+	}
+
+	str += "= func"
+	for _, param := range s.Parameters {
+		str += " " + param.String()
+	}
+
+	return str
+}
+
 // A named type is a type that can has a distinct name.
 // It either (1) a builtin type or (2) a type alias declared by the "type"
 // keyword.
@@ -16,6 +55,17 @@ type NamedTypeInfo struct {
 	// The size of the type in bits.
 	Size *big.Int
 
+	// The alignment of the type in bits. If nil, the type is a builtin type
+	// which is aligned by its size (see ReferencedTypeAlignment).
+	Alignment *big.Int
+
+	// The fields of a declared type, in the order of their declaration.
+	// Nil if the type is a builtin type, or a function type.
+	Fields []*TypeFieldInfo
+
+	// The signature of a function type. Nil if the type is not a function type.
+	Signature *TypeSignatureInfo
+
 	// The source view of the type declaration.
 	// Should be nil only if it is a builtin type.
 	Declaration *core.UnmanagedSourceView
@@ -36,3 +86,41 @@ func NewNamedTypeInfo(
 func (n NamedTypeInfo) String() string {
 	return n.Name
 }
+
+func (f *TypeFieldInfo) String() string {
+	s := ""
+	for _, label := range f.Labels {
+		s += label + " "
+	}
+
+	return s + f.Type.String()
+}
+
+// Returns the textual representation of the declaration of the type, in the
+// form of a "type" declaration.
+func (n *NamedTypeInfo) DeclarationString() string {
+	if n.Signature != nil {
+		return "type " + n.Name + " " + n.Signature.String()
+	}
+
+	s := "type " + n.Name + " {\n"
+	for _, field := range n.Fields {
+		s += "\t" + field.String() + "\n"
+	}
+
+	return s + "}"
+}
+
+// Returns the index of the field with the provided label, or -1 if the type
+// has no such field.
+func (n *NamedTypeInfo) FieldIndex(label string) int {
+	for i, field := range n.Fields {
+		for _, fieldLabel := range field.Labels {
+			if fieldLabel == label {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
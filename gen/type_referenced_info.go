@@ -2,6 +2,7 @@ package gen
 
 import (
 	"math/big"
+	"slices"
 
 	"alon.kr/x/usm/core"
 )
@@ -33,6 +34,10 @@ func (i TypeDescriptorInfo) String() string {
 	return i.Type.String() + i.Amount.String()
 }
 
+func (i TypeDescriptorInfo) Equal(other TypeDescriptorInfo) bool {
+	return i.Type == other.Type && i.Amount.Cmp(other.Amount) == 0
+}
+
 // A referenced type is a combination of a basic type with (possibly zero)
 // type decorators that wrap it.
 // For example, if `$32“ is a basic named type, then `$32 *`, which is a
@@ -59,6 +64,124 @@ func (t ReferencedTypeInfo) IsPure() bool {
 	return len(t.Descriptors) == 0
 }
 
+// Returns true if the outermost descriptor of the type is a pointer
+// descriptor.
+func (t ReferencedTypeInfo) IsPointer() bool {
+	return len(t.Descriptors) > 0 &&
+		t.Descriptors[len(t.Descriptors)-1].Type == PointerTypeDescriptor
+}
+
+// Returns the type of the value that a pointer of the provided type points
+// to. The type should be a pointer (see IsPointer).
+//
+// A pointer descriptor with an amount larger than one is equivalent to
+// multiple consecutive pointer descriptors, so only a single level of
+// indirection is removed from it.
+func (t ReferencedTypeInfo) Pointee() ReferencedTypeInfo {
+	last := len(t.Descriptors) - 1
+	descriptors := slices.Clone(t.Descriptors[:last])
+
+	amount := t.Descriptors[last].Amount
+	if amount.Cmp(big.NewInt(1)) > 0 {
+		descriptors = append(descriptors, TypeDescriptorInfo{
+			Type:   PointerTypeDescriptor,
+			Amount: new(big.Int).Sub(amount, big.NewInt(1)),
+		})
+	}
+
+	return ReferencedTypeInfo{
+		Base:        t.Base,
+		Descriptors: descriptors,
+		Declaration: t.Declaration,
+	}
+}
+
+// Returns the type of a pointer to a value of the provided type.
+func (t ReferencedTypeInfo) PointerTo() ReferencedTypeInfo {
+	descriptors := slices.Clone(t.Descriptors)
+	descriptors = append(descriptors, TypeDescriptorInfo{
+		Type:   PointerTypeDescriptor,
+		Amount: big.NewInt(1),
+	})
+
+	return ReferencedTypeInfo{
+		Base:        t.Base,
+		Descriptors: descriptors,
+		Declaration: t.Declaration,
+	}
+}
+
+// Returns the signature of the functions that values of the type point to, or
+// nil if the type is not a (single level) pointer to a function type.
+func (t ReferencedTypeInfo) FunctionSignature() *TypeSignatureInfo {
+	if t.Base == nil || len(t.Descriptors) != 1 || !t.IsPointer() {
+		return nil
+	}
+
+	if t.Descriptors[0].Amount.Cmp(big.NewInt(1)) != 0 {
+		return nil
+	}
+
+	return t.Base.Signature
+}
+
+// Returns true if the outermost descriptor of the type is a repeat
+// descriptor, i.e. the type is an array.
+func (t ReferencedTypeInfo) IsRepeated() bool {
+	return len(t.Descriptors) > 0 &&
+		t.Descriptors[len(t.Descriptors)-1].Type == RepeatTypeDescriptor
+}
+
+// Returns the type of a single element of a repeated type.
+// The type should be repeated (see IsRepeated).
+func (t ReferencedTypeInfo) RepeatedElement() ReferencedTypeInfo {
+	return ReferencedTypeInfo{
+		Base:        t.Base,
+		Descriptors: t.Descriptors[:len(t.Descriptors)-1],
+		Declaration: t.Declaration,
+	}
+}
+
+// Returns the bit width of values of the type, or nil if the type is not an
+// integer type with a fixed bit width: a builtin type without descriptors.
+// Declared types (with fields or a signature) are not integers.
+func (t ReferencedTypeInfo) IntegerSize() *big.Int {
+	if !t.IsPure() || t.Base == nil || t.Base.Fields != nil {
+		return nil
+	}
+
+	size := t.Base.Size
+	if size == nil || size.Sign() <= 0 || !size.IsUint64() {
+		return nil
+	}
+
+	return size
+}
+
+// Wraps the provided value around to the bit width of the type, in two's
+// complement representation. The returned value is in the range
+// [-2^(n-1), 2^(n-1)), where n is the bit width of the type.
+//
+// Values of types that are not integers with a fixed bit width (see
+// IntegerSize) are returned unchanged.
+func (t ReferencedTypeInfo) WrapInteger(value *big.Int) *big.Int {
+	size := t.IntegerSize()
+	if size == nil {
+		return new(big.Int).Set(value)
+	}
+
+	bits := uint(size.Uint64())
+	modulo := new(big.Int).Lsh(big.NewInt(1), bits)
+	half := new(big.Int).Rsh(modulo, 1)
+
+	wrapped := new(big.Int).Mod(value, modulo)
+	if wrapped.Cmp(half) >= 0 {
+		wrapped.Sub(wrapped, modulo)
+	}
+
+	return wrapped
+}
+
 func (info ReferencedTypeInfo) Equal(other ReferencedTypeInfo) bool {
 	if info.Base != other.Base {
 		return false
@@ -69,7 +192,7 @@ func (info ReferencedTypeInfo) Equal(other ReferencedTypeInfo) bool {
 	}
 
 	for i := range info.Descriptors {
-		if info.Descriptors[i] != other.Descriptors[i] {
+		if !info.Descriptors[i].Equal(other.Descriptors[i]) {
 			return false
 		}
 	}
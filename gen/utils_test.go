@@ -1,10 +1,13 @@
 package gen_test
 
 import (
+	"fmt"
 	"math/big"
+	"testing"
 
 	"alon.kr/x/usm/core"
 	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/internal/testutil"
 )
 
 // MARK: TypeMap
@@ -80,9 +83,10 @@ var testManagerCreators = gen.ManagerCreators{
 		return gen.RegisterManager(&RegisterMap{})
 	},
 	TypeManagerCreator: func(*gen.GenerationContext) gen.TypeManager {
-		manager := gen.TypeManager(&TypeMap{})
-		typ := gen.NewNamedTypeInfo("$32", big.NewInt(32), nil)
-		manager.NewType(typ)
+		manager := &TypeMap{}
+		for _, size := range []int64{8, 16, 32} {
+			manager.newBuiltinType(fmt.Sprintf("$%d", size), big.NewInt(size))
+		}
 		return manager
 	},
 	GlobalManagerCreator: gen.NewGlobalMap,
@@ -93,3 +97,13 @@ var testGenerationContext = gen.GenerationContext{
 	Instructions:    testInstructionSet,
 	PointerSize:     big.NewInt(314), // An arbitrary, unique value.
 }
+
+// Generates the internal representation of the provided source file, using
+// the test generation context.
+func generateFileFromSource(
+	t *testing.T,
+	source string,
+) (*gen.FileInfo, core.ResultList) {
+	t.Helper()
+	return testutil.GenerateFile(t, &testGenerationContext, source)
+}
@@ -0,0 +1,52 @@
+// Package testutil contains helpers that are shared by the tests of multiple
+// packages.
+package testutil
+
+import (
+	"testing"
+
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/lex"
+	"alon.kr/x/usm/parse"
+	"github.com/stretchr/testify/require"
+)
+
+// GenerateFile lexes, parses and generates the internal representation of the
+// provided source file, using the provided generation context.
+//
+// The test fails if the source can't be lexed or parsed. The results of the
+// generation are returned, so tests can assert on generation errors.
+func GenerateFile(
+	t *testing.T,
+	ctx *gen.GenerationContext,
+	source string,
+) (*gen.FileInfo, core.ResultList) {
+	t.Helper()
+
+	srcView := core.NewSourceView(source)
+
+	lexResult, err := lex.NewTokenizer().Tokenize(srcView)
+	require.NoError(t, err)
+
+	tknView := parse.NewTokenView(lexResult)
+	fileNode, result := parse.NewFileParser().Parse(&tknView)
+	require.Nil(t, result)
+
+	generator := gen.NewFileGenerator()
+	return generator.Generate(ctx, srcView.Ctx(), fileNode)
+}
+
+// MustGenerateFile is like GenerateFile, but also fails the test if the
+// generation returns any results.
+func MustGenerateFile(
+	t *testing.T,
+	ctx *gen.GenerationContext,
+	source string,
+) *gen.FileInfo {
+	t.Helper()
+
+	info, results := GenerateFile(t, ctx, source)
+	require.True(t, results.IsEmpty(), "Failed to generate file info")
+	return info
+}
@@ -0,0 +1,373 @@
+package interpreter
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+
+	"alon.kr/x/list"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+)
+
+// The interpreter executes functions of a file directly, from their internal
+// representation, without lowering them to a machine specific target first.
+//
+// Each register holds an arbitrary precision integer, which is wrapped around
+// to the bit width of the register type whenever it is assigned a value.
+// The semantics of each instruction are provided by the instruction set,
+// using the optional ExecutableInstruction interface.
+
+// ExecutableInstruction is an instruction that can be executed by the
+// interpreter.
+type ExecutableInstruction interface {
+	gen.InstructionDefinition
+
+	// Executes the instruction in the provided frame: reads the values of the
+	// instruction arguments from the frame, assigns the instruction targets,
+	// and returns the step that the execution should take next.
+	Execute(info *gen.InstructionInfo, frame *Frame) (Step, core.ResultList)
+}
+
+// Step describes how the execution proceeds after an instruction is executed.
+// The zero value continues the execution to the next instruction.
+type Step struct {
+	// If not nil, the execution continues at the basic block of the label.
+	Branch *gen.LabelInfo
+
+	// True if the execution of the function ends after the instruction.
+	Return bool
+
+	// The values returned from the function, if Return is true.
+	Values []*big.Int
+}
+
+func ContinueStep() Step {
+	return Step{}
+}
+
+func BranchStep(label *gen.LabelInfo) Step {
+	return Step{Branch: label}
+}
+
+func ReturnStep(values []*big.Int) Step {
+	return Step{Return: true, Values: values}
+}
+
+// The maximal depth of nested function calls, after which the execution is
+// aborted. This prevents unbounded recursion from crashing the interpreter.
+const DefaultMaxCallDepth = 1 << 12
+
+type Interpreter struct {
+	File *gen.FileInfo
+
+	MaxCallDepth int
+
+	depth int
+
+	// The functions whose addresses were taken, where the address of each
+	// function is its index in the slice plus one (so zero is never a valid
+	// function address).
+	functions []*gen.FunctionInfo
+}
+
+func NewInterpreter(file *gen.FileInfo) *Interpreter {
+	return &Interpreter{
+		File:         file,
+		MaxCallDepth: DefaultMaxCallDepth,
+	}
+}
+
+// MARK: Function Pointers
+
+// Returns the value that represents the address of the provided function.
+// The same function always has the same address.
+func (i *Interpreter) FunctionAddress(function *gen.FunctionInfo) *big.Int {
+	index := slices.Index(i.functions, function)
+	if index < 0 {
+		index = len(i.functions)
+		i.functions = append(i.functions, function)
+	}
+
+	return big.NewInt(int64(index) + 1)
+}
+
+// Returns the function at the provided address, or nil if the address is not
+// an address of a function (see FunctionAddress).
+func (i *Interpreter) FunctionAt(address *big.Int) *gen.FunctionInfo {
+	if !address.IsInt64() {
+		return nil
+	}
+
+	index := address.Int64() - 1
+	if index < 0 || index >= int64(len(i.functions)) {
+		return nil
+	}
+
+	return i.functions[index]
+}
+
+// MARK: Frame
+
+// Frame holds the state of a single function invocation.
+type Frame struct {
+	*Interpreter
+
+	Function *gen.FunctionInfo
+
+	// The basic block that is currently executed.
+	Block *gen.BasicBlockInfo
+
+	// The basic block from which the execution arrived to the current block,
+	// or nil if the current block is the first block that is executed.
+	PreviousBlock *gen.BasicBlockInfo
+
+	registers map[*gen.RegisterInfo]*big.Int
+
+	// The values of the registers that were assigned since the execution
+	// arrived to the current block, before they were assigned.
+	// A register which is not a key in the map was not assigned yet, and its
+	// value at the entry of the block is its current value.
+	entryRegisters map[*gen.RegisterInfo]*big.Int
+}
+
+func (f *Frame) enterBlock(block *gen.BasicBlockInfo) {
+	f.PreviousBlock = f.Block
+	f.Block = block
+	clear(f.entryRegisters)
+}
+
+// Assigns the provided value to the register, wrapped around to the bit width
+// of the register type.
+func (f *Frame) SetRegister(register *gen.RegisterInfo, value *big.Int) {
+	if _, ok := f.entryRegisters[register]; !ok {
+		f.entryRegisters[register] = f.registers[register]
+	}
+
+	f.registers[register] = register.Type.WrapInteger(value)
+}
+
+func (f *Frame) argumentValue(
+	argument gen.ArgumentInfo,
+	registers func(*gen.RegisterInfo) *big.Int,
+) (*big.Int, core.ResultList) {
+	switch typedArgument := argument.(type) {
+	case *gen.RegisterArgumentInfo:
+		value := registers(typedArgument.Register)
+		if value == nil {
+			return nil, list.FromSingle(core.Result{
+				{
+					Type: core.ErrorResult,
+					Message: fmt.Sprintf(
+						"Register \"%s\" is used before it is assigned a value",
+						typedArgument.Register,
+					),
+					Location: argument.Declaration(),
+				},
+			})
+		}
+		return value, core.ResultList{}
+
+	case *gen.ImmediateInfo:
+		return typedArgument.Type.WrapInteger(typedArgument.Value), core.ResultList{}
+
+	default:
+		return nil, list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Argument does not hold a value that can be interpreted",
+				Location: argument.Declaration(),
+			},
+		})
+	}
+}
+
+// Returns the current value of the provided argument, which should be a
+// register or an immediate value.
+func (f *Frame) Value(argument gen.ArgumentInfo) (*big.Int, core.ResultList) {
+	return f.argumentValue(argument, func(register *gen.RegisterInfo) *big.Int {
+		return f.registers[register]
+	})
+}
+
+// Returns the value that the provided argument held when the execution
+// arrived to the current basic block, ignoring assignments that were made
+// since then.
+//
+// This is used to evaluate phi instructions, which are executed in parallel
+// at the entry of a basic block.
+func (f *Frame) EntryValue(argument gen.ArgumentInfo) (*big.Int, core.ResultList) {
+	return f.argumentValue(argument, func(register *gen.RegisterInfo) *big.Int {
+		if value, ok := f.entryRegisters[register]; ok {
+			return value
+		}
+		return f.registers[register]
+	})
+}
+
+// Returns the values of the provided arguments, in order.
+func (f *Frame) Values(arguments []gen.ArgumentInfo) ([]*big.Int, core.ResultList) {
+	values := make([]*big.Int, len(arguments))
+	results := core.ResultList{}
+
+	for i, argument := range arguments {
+		value, curResults := f.Value(argument)
+		results.Extend(&curResults)
+		values[i] = value
+	}
+
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return values, core.ResultList{}
+}
+
+// Assigns the provided values to the provided targets, in order.
+func (f *Frame) SetTargets(targets []*gen.TargetInfo, values []*big.Int) {
+	for i, target := range targets {
+		f.SetRegister(target.Register, values[i])
+	}
+}
+
+// MARK: Execution
+
+func (i *Interpreter) executeInstruction(
+	instruction *gen.InstructionInfo,
+	frame *Frame,
+) (Step, core.ResultList) {
+	definition, ok := instruction.Definition.(ExecutableInstruction)
+	if !ok {
+		return Step{}, list.FromSingle(core.Result{
+			{
+				Type: core.ErrorResult,
+				Message: fmt.Sprintf(
+					"Instruction \"%s\" can't be interpreted",
+					instruction.Definition.Operator(instruction),
+				),
+				Location: instruction.Declaration,
+			},
+		})
+	}
+
+	return definition.Execute(instruction, frame)
+}
+
+// Executes the provided function with the provided arguments, and returns the
+// values returned by the function.
+//
+// The argument values are wrapped around to the bit width of the respective
+// parameter types.
+func (i *Interpreter) Call(
+	function *gen.FunctionInfo,
+	arguments []*big.Int,
+) ([]*big.Int, core.ResultList) {
+	if !function.IsDefined() {
+		return nil, list.FromSingle(core.Result{
+			{
+				Type: core.ErrorResult,
+				Message: fmt.Sprintf(
+					"Function \"%s\" is declared but not defined, and can't be interpreted",
+					function.Name,
+				),
+				Location: function.Declaration,
+			},
+		})
+	}
+
+	if len(arguments) != len(function.Parameters) {
+		return nil, list.FromSingle(core.Result{
+			{
+				Type: core.ErrorResult,
+				Message: fmt.Sprintf(
+					"Function \"%s\" expects %d arguments, but %d were provided",
+					function.Name,
+					len(function.Parameters),
+					len(arguments),
+				),
+				Location: function.Declaration,
+			},
+		})
+	}
+
+	if i.depth >= i.MaxCallDepth {
+		return nil, list.FromSingle(core.Result{
+			{
+				Type: core.ErrorResult,
+				Message: fmt.Sprintf(
+					"Maximal call depth of %d exceeded",
+					i.MaxCallDepth,
+				),
+				Location: function.Declaration,
+			},
+		})
+	}
+
+	i.depth++
+	defer func() { i.depth-- }()
+
+	frame := &Frame{
+		Interpreter:    i,
+		Function:       function,
+		registers:      make(map[*gen.RegisterInfo]*big.Int),
+		entryRegisters: make(map[*gen.RegisterInfo]*big.Int),
+	}
+
+	for index, parameter := range function.Parameters {
+		frame.SetRegister(parameter, arguments[index])
+	}
+
+	block := function.EntryBlock
+	for block != nil {
+		frame.enterBlock(block)
+		next := block.NextBlock
+
+		for _, instruction := range block.Instructions {
+			step, results := i.executeInstruction(instruction, frame)
+			if !results.IsEmpty() {
+				return nil, results
+			}
+
+			if step.Return {
+				return step.Values, core.ResultList{}
+			}
+
+			if step.Branch != nil {
+				next = step.Branch.BasicBlock
+				break
+			}
+		}
+
+		block = next
+	}
+
+	return nil, list.FromSingle(core.Result{
+		{
+			Type: core.ErrorResult,
+			Message: fmt.Sprintf(
+				"Execution of function \"%s\" reached its end without returning",
+				function.Name,
+			),
+			Location: function.Declaration,
+		},
+	})
+}
+
+// Executes the function with the provided name, and returns the values
+// returned by the function.
+func (i *Interpreter) Run(
+	functionName string,
+	arguments []*big.Int,
+) ([]*big.Int, core.ResultList) {
+	function := i.File.GetFunction(functionName)
+	if function == nil {
+		return nil, list.FromSingle(core.Result{
+			{
+				Type:    core.ErrorResult,
+				Message: fmt.Sprintf("Function \"%s\" does not exist", functionName),
+			},
+		})
+	}
+
+	return i.Call(function, arguments)
+}
@@ -0,0 +1,249 @@
+package interpreter_test
+
+import (
+	"math/big"
+	"testing"
+
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/internal/testutil"
+	"alon.kr/x/usm/interpreter"
+	usmmanagers "alon.kr/x/usm/usm/managers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateFileInfo(t *testing.T, source string) *gen.FileInfo {
+	t.Helper()
+	return testutil.MustGenerateFile(t, usmmanagers.NewGenerationContext(), source)
+}
+
+func run(
+	t *testing.T,
+	source string,
+	functionName string,
+	arguments ...int64,
+) ([]*big.Int, core.ResultList) {
+	t.Helper()
+
+	file := generateFileInfo(t, source)
+	values := make([]*big.Int, len(arguments))
+	for i, argument := range arguments {
+		values[i] = big.NewInt(argument)
+	}
+
+	return interpreter.NewInterpreter(file).Run(functionName, values)
+}
+
+func assertReturns(
+	t *testing.T,
+	expected []int64,
+	values []*big.Int,
+	results core.ResultList,
+) {
+	t.Helper()
+
+	require.True(t, results.IsEmpty())
+	require.Len(t, values, len(expected))
+	for i, value := range expected {
+		assert.Equal(t, big.NewInt(value).String(), values[i].String())
+	}
+}
+
+func TestInterpretLoop(t *testing.T) {
+	src := `func $32 @main $32 %n {
+	$32 %sum = $32 #0
+.loop
+	%sum = add %sum %n
+	%n = sub %n $32 #1
+	jnz %n .loop
+	ret %sum
+}`
+
+	values, results := run(t, src, "@main", 10)
+	assertReturns(t, []int64{55}, values, results)
+}
+
+func TestInterpretWraparound(t *testing.T) {
+	src := `func $8 $8 @main $8 %a {
+	$8 %b = add %a $8 #100
+	$8 %c = mul %a $8 #3
+	ret %b %c
+}`
+
+	values, results := run(t, src, "@main", 100)
+	assertReturns(t, []int64{-56, 44}, values, results)
+
+	values, results = run(t, src, "@main", 300)
+	assertReturns(t, []int64{-112, -124}, values, results)
+}
+
+func TestInterpretShifts(t *testing.T) {
+	src := `func $8 $8 $8 $16 @main $8 %a $8 %n {
+	$8 %l = shl %a %n
+	$8 %r = lshr %a %n
+	$8 %q = ashr %a %n
+	$16 %z = zext %a
+	ret %l %r %q %z
+}`
+
+	values, results := run(t, src, "@main", -128, 3)
+	assertReturns(t, []int64{0, 16, -16, 128}, values, results)
+
+	// The amount is interpreted as an unsigned integer, and shifting by the
+	// full width of the value shifts out all of its bits.
+	values, results = run(t, src, "@main", -1, -1)
+	assertReturns(t, []int64{0, 0, -1, 255}, values, results)
+}
+
+func TestInterpretSwitch(t *testing.T) {
+	src := `func $32 @main $8 %v {
+.entry
+	switch %v .default $8 #1 .one $8 #-1 .max
+.one
+	ret $32 #10
+.max
+	ret $32 #20
+.default
+	ret $32 #30
+}`
+
+	values, results := run(t, src, "@main", 1)
+	assertReturns(t, []int64{10}, values, results)
+
+	values, results = run(t, src, "@main", 255)
+	assertReturns(t, []int64{20}, values, results)
+
+	values, results = run(t, src, "@main", 2)
+	assertReturns(t, []int64{30}, values, results)
+}
+
+func TestInterpretIndirectCall(t *testing.T) {
+	src := `type $binop $32 = func $32 $32
+
+func $32 @add $32 %a $32 %b {
+	$32 %r = add %a %b
+	ret %r
+}
+
+func $32 @sub $32 %a $32 %b {
+	$32 %r = sub %a %b
+	ret %r
+}
+
+func $32 @main $32 %x {
+.entry
+	$binop * %f = fptr @add
+	jz %x .call
+.other
+	%f = fptr @sub
+.call
+	$32 %r = call %f $32 #10 $32 #3
+	ret %r
+}`
+
+	values, results := run(t, src, "@main", 0)
+	assertReturns(t, []int64{13}, values, results)
+
+	values, results = run(t, src, "@main", 1)
+	assertReturns(t, []int64{7}, values, results)
+}
+
+func TestInterpretRecursiveCall(t *testing.T) {
+	src := `func $64 @fact $64 %n {
+	jz %n .base
+	$64 %m = sub %n $64 #1
+	$64 %r = call @fact %m
+	%r = mul %r %n
+	ret %r
+.base
+	ret $64 #1
+}
+
+func $64 @main {
+	$64 %r = call @fact $64 #20
+	ret %r
+}`
+
+	values, results := run(t, src, "@main")
+	assertReturns(t, []int64{2432902008176640000}, values, results)
+}
+
+func TestInterpretPhiInParallel(t *testing.T) {
+	src := `func $32 @main $32 %n {
+.entry
+	$32 %a = $32 #1
+	$32 %b = $32 #2
+.loop
+	$32 %x = phi .entry %a .loop %y
+	$32 %y = phi .entry %b .loop %x
+	%n = sub %n $32 #1
+	jnz %n .loop
+	ret %x
+}`
+
+	for n, expected := range []int64{1, 2, 1, 2} {
+		values, results := run(t, src, "@main", int64(n+1))
+		assertReturns(t, []int64{expected}, values, results)
+	}
+}
+
+func TestInterpretDivision(t *testing.T) {
+	src := `func $32 $32 $1 @main $32 %a $32 %b {
+	$32 %q = sdiv %a %b
+	$32 %r = urem %a %b
+	$1 %c = sle %a %b
+	ret %q %r %c
+}`
+
+	values, results := run(t, src, "@main", -9, 4)
+	assertReturns(t, []int64{-2, 3, -1}, values, results)
+
+	_, results = run(t, src, "@main", 1, 0)
+	require.False(t, results.IsEmpty())
+	assert.Equal(t, "Division by zero", results.Head.Value[0].Message)
+}
+
+func TestInterpretUndefinedRegister(t *testing.T) {
+	src := `func $32 @main {
+	$32 %r = add %r $32 #1
+	ret %r
+}`
+
+	_, results := run(t, src, "@main")
+	assert.False(t, results.IsEmpty())
+}
+
+func TestInterpretDeclaredFunction(t *testing.T) {
+	src := `func $32 @ext $32 %x
+
+func $32 @main {
+	$32 %r = call @ext $32 #1
+	ret %r
+}`
+
+	_, results := run(t, src, "@main")
+	assert.False(t, results.IsEmpty())
+}
+
+func TestInterpretMaxCallDepth(t *testing.T) {
+	src := `func @main {
+	call @main
+	ret
+}`
+
+	_, results := run(t, src, "@main")
+	assert.False(t, results.IsEmpty())
+}
+
+func TestInterpretArgumentsMismatch(t *testing.T) {
+	src := `func $32 @main $32 %n {
+	ret %n
+}`
+
+	_, results := run(t, src, "@main", 1, 2)
+	assert.False(t, results.IsEmpty())
+
+	_, results = run(t, src, "@missing")
+	assert.False(t, results.IsEmpty())
+}
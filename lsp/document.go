@@ -0,0 +1,569 @@
+package lsp
+
+import (
+	"unicode/utf16"
+
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/lex"
+	"alon.kr/x/usm/parse"
+	"alon.kr/x/usm/transform"
+)
+
+// A text document that is open in the client, with the results of its
+// analysis.
+//
+// The document is analyzed from scratch on every change. Each of the fields
+// that hold the result of a compilation step is nil if the step, or one of
+// the steps before it, has failed.
+type document struct {
+	Uri     string
+	Sources core.Sources
+	Locator core.SourceLocator
+
+	// The target of the document, which is determined from its filename.
+	// Nil if the target can't be determined, or if it does not support
+	// generation from source.
+	Target *transform.Target
+
+	Tokens []lex.Token
+	Node   *parse.FileNode
+
+	// The generated file. If generation fails, symbols are still resolved
+	// from the explicit declarations in Node (see nodeDefinition).
+	Info *gen.FileInfo
+
+	// The results of all compilation steps that were performed.
+	Results core.ResultList
+}
+
+func newDocument(
+	targets *transform.TargetCollection,
+	uri string,
+	text string,
+) *document {
+	sources := core.NewSources([]string{uri}, []string{text})
+	doc := &document{
+		Uri:     uri,
+		Sources: sources,
+		Locator: core.NewSourceLocator(sources),
+	}
+
+	target, _ := targets.FilenameToTarget(uri)
+	if target != nil && target.GenerationContext != nil {
+		doc.Target = target
+	}
+
+	doc.analyze()
+	return doc
+}
+
+func (d *document) file() *core.SourceFile {
+	return &d.Sources.Files[0]
+}
+
+// Lexes, parses and generates the document, and stores the results.
+func (d *document) analyze() {
+	tokens, err := lex.NewTokenizer().Tokenize(d.Sources.FileView(*d.file()))
+	if err != nil {
+		// Tokenization errors have no location, so they are reported at the
+		// start of the file.
+		location := core.UnmanagedSourceView{Start: 0, End: 0}
+		d.Results.Append(core.Result{{
+			Type:     core.ErrorResult,
+			Message:  err.Error(),
+			Location: &location,
+		}})
+		return
+	}
+
+	d.Tokens = tokens
+	tknView := parse.NewTokenView(tokens)
+	node, result := parse.NewFileParser().Parse(&tknView)
+	if result != nil {
+		d.file().ResolveEof(result)
+		d.Results.Append(result)
+		return
+	}
+
+	d.Node = &node
+	if d.Target == nil {
+		return
+	}
+
+	generator := gen.NewFileGenerator()
+	info, results := generator.Generate(
+		d.Target.GenerationContext,
+		d.Sources.Context,
+		node,
+	)
+
+	d.Results.Extend(&results)
+	if results.IsEmpty() {
+		d.Info = info
+	}
+}
+
+// MARK: Positions
+
+// Returns the number of UTF-16 code units in the provided runes.
+func utf16Length(runes []rune) uint64 {
+	length := uint64(0)
+	for _, r := range runes {
+		length += uint64(utf16.RuneLen(r))
+	}
+	return length
+}
+
+// Converts a (one based, rune counted) position of the core package to a
+// (zero based, UTF-16 counted) LSP position.
+func (d *document) toPosition(position core.SourcePosition) Position {
+	line := []rune(d.Locator.Line(d.file(), position.Line))
+	column := min(int(position.Column-1), len(line))
+	return Position{
+		Line:      position.Line - 1,
+		Character: utf16Length(line[:column]),
+	}
+}
+
+func (d *document) toRange(location *core.SourceLocation) Range {
+	return Range{
+		Start: d.toPosition(location.Start),
+		End:   d.toPosition(location.End),
+	}
+}
+
+// Converts a view of the document to an LSP range.
+// Views that do not point into the document are converted to an empty range
+// at the start of the document.
+func (d *document) viewToRange(view core.UnmanagedSourceView) Range {
+	location := d.Locator.Locate(view)
+	if location == nil {
+		return Range{}
+	}
+	return d.toRange(location)
+}
+
+// Converts an LSP position to an offset in the document.
+// Positions beyond the end of a line are clamped to the end of the line.
+func (d *document) positionToOffset(position Position) core.SourceViewOffset {
+	lines := d.Locator.LineStarts
+	if position.Line >= uint64(len(lines)) {
+		return d.file().View.End
+	}
+
+	offset := lines[position.Line]
+	line := []rune(d.Locator.Line(d.file(), position.Line+1))
+	character := uint64(0)
+	for _, r := range line {
+		character += uint64(utf16.RuneLen(r))
+		if character > position.Character {
+			break
+		}
+		offset++
+	}
+
+	return offset
+}
+
+// MARK: Diagnostics
+
+func severity(typ core.ResultType) int {
+	switch typ {
+	case core.WarningResult:
+		return warningSeverity
+	case core.HintResult, core.DebugResult:
+		return hintSeverity
+	default:
+		return errorSeverity
+	}
+}
+
+// Converts the results of the document analysis to LSP diagnostics.
+//
+// The hints and notes of a result are attached to its diagnostic as related
+// information if they have a location, and appended to its message otherwise.
+func (d *document) Diagnostics() []Diagnostic {
+	diagnostics := []Diagnostic{}
+
+	for result := range d.Results.Range() {
+		if len(result) == 0 {
+			continue
+		}
+
+		diagnostic := Diagnostic{
+			Severity: severity(result[0].Type),
+			Source:   "usm",
+			Message:  result[0].Message,
+		}
+
+		if result[0].Location != nil {
+			diagnostic.Range = d.viewToRange(*result[0].Location)
+		}
+
+		for _, details := range result[1:] {
+			if details.Location == nil {
+				diagnostic.Message += "\n" + details.Message
+				continue
+			}
+
+			diagnostic.RelatedInformation = append(
+				diagnostic.RelatedInformation,
+				DiagnosticRelatedInformation{
+					Location: Location{
+						Uri:   d.Uri,
+						Range: d.viewToRange(*details.Location),
+					},
+					Message: details.Message,
+				},
+			)
+		}
+
+		diagnostics = append(diagnostics, diagnostic)
+	}
+
+	return diagnostics
+}
+
+// MARK: Symbols
+
+// Returns the register, label, global or type token at the provided offset,
+// or nil if there is no such token.
+//
+// A token also matches an offset that points right after it, so the symbol
+// under the cursor is found when the cursor is placed at the end of a word.
+func (d *document) symbolAt(offset core.SourceViewOffset) *lex.Token {
+	var match *lex.Token
+	for i := range d.Tokens {
+		token := &d.Tokens[i]
+		switch token.Type {
+		case lex.RegisterToken, lex.LabelToken, lex.GlobalToken, lex.TypeToken:
+		default:
+			continue
+		}
+
+		if token.View.Start <= offset && offset < token.View.End {
+			return token
+		}
+
+		if token.View.End == offset {
+			match = token
+		}
+	}
+
+	return match
+}
+
+func (d *document) viewName(view core.UnmanagedSourceView) string {
+	return string(view.Raw(d.Sources.Context))
+}
+
+func (d *document) symbolName(token *lex.Token) string {
+	return d.viewName(token.View)
+}
+
+// Returns the function whose declaration contains the provided offset, or nil
+// if there is no such function.
+func (d *document) functionAt(offset core.SourceViewOffset) *gen.FunctionInfo {
+	for _, function := range d.Info.Functions {
+		declaration := function.Declaration
+		if declaration != nil && declaration.Start <= offset && offset <= declaration.End {
+			return function
+		}
+	}
+
+	return nil
+}
+
+// Returns the register with the provided name, in the function that contains
+// the provided offset.
+func (d *document) registerAt(
+	offset core.SourceViewOffset,
+	name string,
+) *gen.RegisterInfo {
+	function := d.functionAt(offset)
+	if function == nil || function.Registers == nil {
+		return nil
+	}
+
+	return function.Registers.GetRegister(name)
+}
+
+// Returns the declaration of the global with the provided name.
+func (d *document) globalDeclaration(name string) *core.UnmanagedSourceView {
+	if function := d.Info.GetFunction(name); function != nil {
+		return function.Declaration
+	}
+
+	if variable := d.Info.GetVariable(name); variable != nil {
+		return variable.Declaration()
+	}
+
+	if constant := d.Info.GetConstant(name); constant != nil {
+		return constant.Declaration()
+	}
+
+	return nil
+}
+
+// Returns the function node that contains the provided offset, or nil if there
+// is no such function.
+func (d *document) functionNodeAt(offset core.SourceViewOffset) *parse.FunctionNode {
+	for i := range d.Node.Functions {
+		function := &d.Node.Functions[i]
+		if function.Start <= offset && offset <= function.End {
+			return function
+		}
+	}
+
+	return nil
+}
+
+// Returns the declaration of the register with the provided name in the
+// function node: its parameter, or its first target with an explicit type.
+func (d *document) registerNodeDeclaration(
+	function *parse.FunctionNode,
+	name string,
+) *core.UnmanagedSourceView {
+	for _, parameter := range function.Signature.Parameters {
+		if d.viewName(parameter.Register.View()) == name {
+			view := parameter.View()
+			return &view
+		}
+	}
+
+	if function.Instructions == nil {
+		return nil
+	}
+
+	for _, instruction := range function.Instructions.Nodes {
+		for _, target := range instruction.Targets {
+			if target.Type != nil && d.viewName(target.Register.View()) == name {
+				view := target.View()
+				return &view
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d *document) labelNodeDeclaration(
+	function *parse.FunctionNode,
+	name string,
+) *core.UnmanagedSourceView {
+	if function.Instructions == nil {
+		return nil
+	}
+
+	for _, instruction := range function.Instructions.Nodes {
+		for _, label := range instruction.Labels {
+			if d.viewName(label.View()) == name {
+				view := label.View()
+				return &view
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d *document) globalNodeDeclaration(name string) *core.UnmanagedSourceView {
+	for _, function := range d.Node.Functions {
+		if d.viewName(function.Signature.Identifier) == name {
+			view := function.View()
+			return &view
+		}
+	}
+
+	declarations := []parse.GlobalDeclarationNode{}
+	for _, variable := range d.Node.Variables {
+		declarations = append(declarations, variable.Declaration)
+	}
+
+	for _, constant := range d.Node.Constants {
+		declarations = append(declarations, constant.Declaration)
+	}
+
+	for _, declaration := range declarations {
+		if d.viewName(declaration.Identifier.View()) == name {
+			view := declaration.View()
+			return &view
+		}
+	}
+
+	return nil
+}
+
+func (d *document) typeNodeDeclaration(name string) *core.UnmanagedSourceView {
+	for _, typ := range d.Node.Types {
+		if d.viewName(typ.Identifier) == name {
+			view := typ.View()
+			return &view
+		}
+	}
+
+	return nil
+}
+
+// Returns the declaration of the provided symbol token, resolved from the
+// parsed file. This is used if the document can't be generated, so navigation
+// keeps working in the presence of unrelated errors. The returned declarations
+// match the declarations of the generated file.
+func (d *document) nodeDefinition(
+	offset core.SourceViewOffset,
+	token *lex.Token,
+) *core.UnmanagedSourceView {
+	name := d.symbolName(token)
+	switch token.Type {
+	case lex.RegisterToken:
+		if function := d.functionNodeAt(offset); function != nil {
+			return d.registerNodeDeclaration(function, name)
+		}
+
+	case lex.LabelToken:
+		if function := d.functionNodeAt(offset); function != nil {
+			return d.labelNodeDeclaration(function, name)
+		}
+
+	case lex.GlobalToken:
+		return d.globalNodeDeclaration(name)
+
+	case lex.TypeToken:
+		return d.typeNodeDeclaration(name)
+	}
+
+	return nil
+}
+
+// Returns the declaration of the symbol at the provided offset, or nil if
+// there is no symbol at the offset, or if it has no declaration in the source
+// (for example, builtin types).
+func (d *document) Definition(offset core.SourceViewOffset) *core.UnmanagedSourceView {
+	token := d.symbolAt(offset)
+	if token == nil || d.Node == nil {
+		return nil
+	}
+
+	if d.Info == nil {
+		return d.nodeDefinition(offset, token)
+	}
+
+	name := d.symbolName(token)
+	switch token.Type {
+	case lex.RegisterToken:
+		if register := d.registerAt(offset, name); register != nil {
+			return &register.Declaration
+		}
+
+	case lex.LabelToken:
+		function := d.functionAt(offset)
+		if function != nil && function.Labels != nil {
+			if label := function.Labels.GetLabel(name); label != nil {
+				return &label.Declaration
+			}
+		}
+
+	case lex.GlobalToken:
+		return d.globalDeclaration(name)
+
+	case lex.TypeToken:
+		if typ := d.Info.GetType(name); typ != nil {
+			return typ.Declaration
+		}
+	}
+
+	return nil
+}
+
+// Returns the signature of the function, without its body.
+func functionSignatureString(function *gen.FunctionInfo) string {
+	s := "func"
+	for _, target := range function.Targets {
+		s += " " + target.String()
+	}
+
+	s += " " + function.Name
+
+	for _, parameter := range function.Parameters {
+		s += " " + parameter.Type.String() + " " + parameter.Name
+	}
+
+	return s
+}
+
+// Returns a short USM snippet that describes the symbol at the provided
+// offset, and the view of the symbol. Returns an empty string if there is no
+// symbol at the offset, or if there is no information about it.
+func (d *document) Hover(offset core.SourceViewOffset) (string, core.UnmanagedSourceView) {
+	token := d.symbolAt(offset)
+	if token == nil || d.Info == nil {
+		return "", core.UnmanagedSourceView{}
+	}
+
+	name := d.symbolName(token)
+	switch token.Type {
+	case lex.RegisterToken:
+		if register := d.registerAt(offset, name); register != nil {
+			return register.Type.String() + " " + register.Name, token.View
+		}
+
+	case lex.GlobalToken:
+		if function := d.Info.GetFunction(name); function != nil {
+			return functionSignatureString(function), token.View
+		}
+
+		if variable := d.Info.GetVariable(name); variable != nil {
+			return variable.String(), token.View
+		}
+
+		if constant := d.Info.GetConstant(name); constant != nil {
+			return constant.String(), token.View
+		}
+
+	case lex.TypeToken:
+		if typ := d.Info.GetType(name); typ != nil {
+			return typ.DeclarationString(), token.View
+		}
+	}
+
+	return "", core.UnmanagedSourceView{}
+}
+
+// Returns the names of the instructions of the document target.
+func (d *document) InstructionNames() []string {
+	if d.Target == nil {
+		return nil
+	}
+
+	names := []string{}
+	for _, name := range d.Target.GenerationContext.Instructions.InstructionNames() {
+		// The move instruction has an empty name, and is written without an
+		// operator.
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// Returns the formatted document, or false if the document can't be
+// formatted (for example, if it can't be parsed, or if formatting it would
+// drop one of its comments).
+func (d *document) Format() (string, bool) {
+	if d.Node == nil {
+		return "", false
+	}
+
+	formatted, result := parse.Format(d.Sources.FileView(*d.file()))
+	return formatted, result == nil
+}
+
+// Returns the range that covers the whole document.
+func (d *document) FullRange() Range {
+	end := d.file().View.End
+	return Range{
+		End: d.viewToRange(core.UnmanagedSourceView{Start: end, End: end}).Start,
+	}
+}
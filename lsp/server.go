@@ -0,0 +1,320 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/transform"
+)
+
+// Returned by Serve if the client sent an exit notification without a
+// preceding shutdown request.
+var ErrExitWithoutShutdown = errors.New("exit notification received before shutdown")
+
+// Server is a language server for USM, which communicates with a single
+// client over a pair of streams (usually stdin and stdout).
+//
+// The server supports full document synchronization, publishes diagnostics
+// of the lex, parse and generation steps on every change, and provides
+// definitions, hovers, instruction completions and formatting.
+type Server struct {
+	// The targets that are used to determine the target of each document
+	// from its filename, and to generate it.
+	Targets *transform.TargetCollection
+
+	writer      io.Writer
+	documents   map[string]*document
+	initialized bool
+	shutdown    bool
+}
+
+func NewServer(targets *transform.TargetCollection) *Server {
+	return &Server{
+		Targets:   targets,
+		documents: make(map[string]*document),
+	}
+}
+
+// Serves the client until an exit notification is received, or until the
+// reader is exhausted.
+func (s *Server) Serve(reader io.Reader, writer io.Writer) error {
+	s.writer = writer
+	bufferedReader := bufio.NewReader(reader)
+
+	for {
+		body, err := readMessage(bufferedReader)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			// The id of the request can't be determined, so the error is
+			// reported with a null id, as the specification requires.
+			err = s.respondError(nil, &responseError{
+				Code:    parseErrorCode,
+				Message: err.Error(),
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return ErrExitWithoutShutdown
+			}
+			return nil
+		}
+
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// MARK: Messages
+
+func (s *Server) respond(id *json.RawMessage, result any) error {
+	return writeMessage(s.writer, response{JsonRpc: "2.0", Id: id, Result: result})
+}
+
+func (s *Server) respondError(id *json.RawMessage, err *responseError) error {
+	return writeMessage(s.writer, errorResponse{JsonRpc: "2.0", Id: id, Error: err})
+}
+
+func (s *Server) notify(method string, params any) error {
+	return writeMessage(s.writer, struct {
+		JsonRpc string `json:"jsonrpc"`
+		Method  string `json:"method"`
+		Params  any    `json:"params"`
+	}{JsonRpc: "2.0", Method: method, Params: params})
+}
+
+type requestHandler func(s *Server, params json.RawMessage) (any, *responseError)
+
+type notificationHandler func(s *Server, params json.RawMessage) error
+
+var requestHandlers = map[string]requestHandler{
+	"initialize":              (*Server).initialize,
+	"shutdown":                (*Server).handleShutdown,
+	"textDocument/definition": (*Server).definition,
+	"textDocument/hover":      (*Server).hover,
+	"textDocument/completion": (*Server).completion,
+	"textDocument/formatting": (*Server).formatting,
+}
+
+var notificationHandlers = map[string]notificationHandler{
+	"textDocument/didOpen":   (*Server).didOpen,
+	"textDocument/didChange": (*Server).didChange,
+	"textDocument/didClose":  (*Server).didClose,
+}
+
+// Dispatches a message to its handler. Returns an error only if the
+// communication with the client has failed.
+func (s *Server) handle(msg message) error {
+	if msg.Id == nil {
+		// Unknown notifications, and notifications that are received before
+		// the server is initialized, are ignored.
+		handler, ok := notificationHandlers[msg.Method]
+		if !ok || !s.initialized {
+			return nil
+		}
+		return handler(s, msg.Params)
+	}
+
+	handler, ok := requestHandlers[msg.Method]
+	if !ok {
+		return s.respondError(msg.Id, &responseError{
+			Code:    methodNotFoundErrorCode,
+			Message: "method not found: " + msg.Method,
+		})
+	}
+
+	if !s.initialized && msg.Method != "initialize" {
+		return s.respondError(msg.Id, &responseError{
+			Code:    serverNotInitializedCode,
+			Message: "server is not initialized",
+		})
+	}
+
+	result, err := handler(s, msg.Params)
+	if err != nil {
+		return s.respondError(msg.Id, err)
+	}
+
+	return s.respond(msg.Id, result)
+}
+
+func unmarshalParams(params json.RawMessage, value any) *responseError {
+	if err := json.Unmarshal(params, value); err != nil {
+		return &responseError{Code: invalidParamsErrorCode, Message: err.Error()}
+	}
+	return nil
+}
+
+// MARK: Lifecycle
+
+func (s *Server) initialize(json.RawMessage) (any, *responseError) {
+	s.initialized = true
+	return InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync:           fullTextDocumentSync,
+			DefinitionProvider:         true,
+			HoverProvider:              true,
+			CompletionProvider:         struct{}{},
+			DocumentFormattingProvider: true,
+		},
+		ServerInfo: ServerInfo{Name: "usm"},
+	}, nil
+}
+
+func (s *Server) handleShutdown(json.RawMessage) (any, *responseError) {
+	s.shutdown = true
+	return nil, nil
+}
+
+// MARK: Synchronization
+
+// Analyzes the new content of the document, and publishes its diagnostics.
+func (s *Server) update(uri string, text string) error {
+	doc := newDocument(s.Targets, uri, text)
+	s.documents[uri] = doc
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		Uri:         uri,
+		Diagnostics: doc.Diagnostics(),
+	})
+}
+
+func (s *Server) didOpen(params json.RawMessage) error {
+	var p DidOpenTextDocumentParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil
+	}
+
+	return s.update(p.TextDocument.Uri, p.TextDocument.Text)
+}
+
+func (s *Server) didChange(params json.RawMessage) error {
+	var p DidChangeTextDocumentParams
+	if err := json.Unmarshal(params, &p); err != nil || len(p.ContentChanges) == 0 {
+		return nil
+	}
+
+	// The server uses full document synchronization, so the last change
+	// holds the whole content of the document.
+	text := p.ContentChanges[len(p.ContentChanges)-1].Text
+	return s.update(p.TextDocument.Uri, text)
+}
+
+func (s *Server) didClose(params json.RawMessage) error {
+	var p DidCloseTextDocumentParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil
+	}
+
+	delete(s.documents, p.TextDocument.Uri)
+
+	// Clear the diagnostics of the closed document.
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		Uri:         p.TextDocument.Uri,
+		Diagnostics: []Diagnostic{},
+	})
+}
+
+// MARK: Language Features
+
+// Returns the document and the offset that the provided position params
+// point to. The document is nil if it is not open.
+func (s *Server) documentPosition(
+	params json.RawMessage,
+) (*document, core.SourceViewOffset, *responseError) {
+	var p TextDocumentPositionParams
+	if err := unmarshalParams(params, &p); err != nil {
+		return nil, 0, err
+	}
+
+	doc := s.documents[p.TextDocument.Uri]
+	if doc == nil {
+		return nil, 0, nil
+	}
+
+	return doc, doc.positionToOffset(p.Position), nil
+}
+
+func (s *Server) definition(params json.RawMessage) (any, *responseError) {
+	doc, offset, err := s.documentPosition(params)
+	if doc == nil {
+		return nil, err
+	}
+
+	declaration := doc.Definition(offset)
+	if declaration == nil {
+		return nil, nil
+	}
+
+	return Location{Uri: doc.Uri, Range: doc.viewToRange(*declaration)}, nil
+}
+
+func (s *Server) hover(params json.RawMessage) (any, *responseError) {
+	doc, offset, err := s.documentPosition(params)
+	if doc == nil {
+		return nil, err
+	}
+
+	text, view := doc.Hover(offset)
+	if text == "" {
+		return nil, nil
+	}
+
+	symbolRange := doc.viewToRange(view)
+	return Hover{
+		Contents: MarkupContent{
+			Kind:  "markdown",
+			Value: "```usm\n" + text + "\n```",
+		},
+		Range: &symbolRange,
+	}, nil
+}
+
+func (s *Server) completion(params json.RawMessage) (any, *responseError) {
+	doc, _, err := s.documentPosition(params)
+	if doc == nil {
+		return nil, err
+	}
+
+	items := []CompletionItem{}
+	for _, name := range doc.InstructionNames() {
+		items = append(items, CompletionItem{
+			Label: name,
+			Kind:  keywordCompletionItemKind,
+		})
+	}
+
+	return items, nil
+}
+
+func (s *Server) formatting(params json.RawMessage) (any, *responseError) {
+	var p DocumentFormattingParams
+	if err := unmarshalParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	doc := s.documents[p.TextDocument.Uri]
+	if doc == nil {
+		return nil, nil
+	}
+
+	formatted, ok := doc.Format()
+	if !ok {
+		return nil, nil
+	}
+
+	return []TextEdit{{Range: doc.FullRange(), NewText: formatted}}, nil
+}
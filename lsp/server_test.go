@@ -0,0 +1,357 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"alon.kr/x/usm/transform"
+	usmmanagers "alon.kr/x/usm/usm/managers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testUri = "file:///test.usm"
+
+const testSource = `func $32 @add $32 %a $32 %b {
+    $32 %c = add %a %b
+    ret %c
+}
+
+func $32 @main {
+    $32 %x = call @add $32 #1 $32 #2
+    ret %x
+}
+`
+
+var testTargets = transform.NewTargetCollection(
+	&transform.Target{
+		Names:             []string{"usm"},
+		Extensions:        []string{".usm"},
+		GenerationContext: usmmanagers.NewGenerationContext(),
+	},
+)
+
+// A client that records the messages it sends, and can then replay them to a
+// server, and collect the server responses and notifications.
+type testClient struct {
+	input  bytes.Buffer
+	nextId int
+}
+
+func (c *testClient) send(t *testing.T, value any) {
+	t.Helper()
+	require.NoError(t, writeMessage(&c.input, value))
+}
+
+func (c *testClient) request(t *testing.T, method string, params any) int {
+	t.Helper()
+	c.nextId++
+	c.send(t, map[string]any{
+		"jsonrpc": "2.0",
+		"id":      c.nextId,
+		"method":  method,
+		"params":  params,
+	})
+	return c.nextId
+}
+
+func (c *testClient) notify(t *testing.T, method string, params any) {
+	t.Helper()
+	c.send(t, map[string]any{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+	})
+}
+
+func (c *testClient) open(t *testing.T, text string) {
+	t.Helper()
+	c.request(t, "initialize", map[string]any{})
+	c.notify(t, "initialized", map[string]any{})
+	c.notify(t, "textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{Uri: testUri, LanguageId: "usm", Text: text},
+	})
+}
+
+type testMessage struct {
+	Id     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+// Serves the recorded messages, and returns the messages of the server.
+func (c *testClient) serve(t *testing.T) ([]testMessage, error) {
+	t.Helper()
+
+	output := bytes.Buffer{}
+	err := NewServer(testTargets).Serve(&c.input, &output)
+
+	messages := []testMessage{}
+	reader := bufio.NewReader(&output)
+	for reader.Buffered() > 0 || output.Len() > 0 {
+		body, readErr := readMessage(reader)
+		require.NoError(t, readErr)
+
+		var msg testMessage
+		require.NoError(t, json.Unmarshal(body, &msg))
+		messages = append(messages, msg)
+	}
+
+	return messages, err
+}
+
+func findResponse(t *testing.T, messages []testMessage, id int) testMessage {
+	t.Helper()
+	for _, msg := range messages {
+		if msg.Id != nil && *msg.Id == id {
+			return msg
+		}
+	}
+
+	require.FailNow(t, "response not found", "id: %d", id)
+	return testMessage{}
+}
+
+func findDiagnostics(t *testing.T, messages []testMessage) []PublishDiagnosticsParams {
+	t.Helper()
+	published := []PublishDiagnosticsParams{}
+	for _, msg := range messages {
+		if msg.Method == "textDocument/publishDiagnostics" {
+			var params PublishDiagnosticsParams
+			require.NoError(t, json.Unmarshal(msg.Params, &params))
+			published = append(published, params)
+		}
+	}
+	return published
+}
+
+func position(line, character uint64) TextDocumentPositionParams {
+	return TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{Uri: testUri},
+		Position:     Position{Line: line, Character: character},
+	}
+}
+
+func TestServerLifecycle(t *testing.T) {
+	client := testClient{}
+	id := client.request(t, "initialize", map[string]any{})
+	shutdownId := client.request(t, "shutdown", nil)
+	client.notify(t, "exit", nil)
+
+	messages, err := client.serve(t)
+	require.NoError(t, err)
+
+	var result InitializeResult
+	require.NoError(t, json.Unmarshal(findResponse(t, messages, id).Result, &result))
+	assert.True(t, result.Capabilities.DefinitionProvider)
+	assert.Equal(t, fullTextDocumentSync, result.Capabilities.TextDocumentSync)
+
+	shutdown := findResponse(t, messages, shutdownId)
+	assert.Nil(t, shutdown.Error)
+	assert.Equal(t, "null", string(shutdown.Result))
+}
+
+func TestServerExitWithoutShutdown(t *testing.T) {
+	client := testClient{}
+	client.request(t, "initialize", map[string]any{})
+	client.notify(t, "exit", nil)
+
+	_, err := client.serve(t)
+	assert.ErrorIs(t, err, ErrExitWithoutShutdown)
+}
+
+func TestServerRequestBeforeInitialize(t *testing.T) {
+	client := testClient{}
+	id := client.request(t, "textDocument/hover", position(0, 0))
+
+	messages, err := client.serve(t)
+	require.NoError(t, err)
+
+	response := findResponse(t, messages, id)
+	require.NotNil(t, response.Error)
+	assert.Equal(t, serverNotInitializedCode, response.Error.Code)
+}
+
+func TestServerUnknownMethod(t *testing.T) {
+	client := testClient{}
+	client.request(t, "initialize", map[string]any{})
+	id := client.request(t, "workspace/unknown", nil)
+
+	messages, err := client.serve(t)
+	require.NoError(t, err)
+
+	response := findResponse(t, messages, id)
+	require.NotNil(t, response.Error)
+	assert.Equal(t, methodNotFoundErrorCode, response.Error.Code)
+}
+
+func TestServerPublishesDiagnostics(t *testing.T) {
+	client := testClient{}
+	client.open(t, "func $32 @f {\n    ret %nope\n}\n")
+	client.notify(t, "textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{Uri: testUri},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: testSource}},
+	})
+
+	messages, err := client.serve(t)
+	require.NoError(t, err)
+
+	published := findDiagnostics(t, messages)
+	require.Len(t, published, 2)
+
+	require.Len(t, published[0].Diagnostics, 1)
+	diagnostic := published[0].Diagnostics[0]
+	assert.Equal(t, errorSeverity, diagnostic.Severity)
+	assert.Contains(t, diagnostic.Message, "Undefined or untyped register")
+	assert.Equal(t, Range{
+		Start: Position{Line: 1, Character: 8},
+		End:   Position{Line: 1, Character: 13},
+	}, diagnostic.Range)
+
+	assert.Empty(t, published[1].Diagnostics)
+}
+
+func TestServerParseErrorDiagnostic(t *testing.T) {
+	client := testClient{}
+	client.open(t, "func")
+
+	messages, err := client.serve(t)
+	require.NoError(t, err)
+
+	published := findDiagnostics(t, messages)
+	require.Len(t, published, 1)
+	require.Len(t, published[0].Diagnostics, 1)
+	assert.Equal(t, Position{Line: 0, Character: 4}, published[0].Diagnostics[0].Range.Start)
+}
+
+func TestServerDefinition(t *testing.T) {
+	client := testClient{}
+	client.open(t, testSource)
+	registerId := client.request(t, "textDocument/definition", position(1, 18))
+	globalId := client.request(t, "textDocument/definition", position(6, 20))
+	builtinId := client.request(t, "textDocument/definition", position(6, 5))
+
+	messages, err := client.serve(t)
+	require.NoError(t, err)
+
+	var register Location
+	require.NoError(t, json.Unmarshal(findResponse(t, messages, registerId).Result, &register))
+	assert.Equal(t, testUri, register.Uri)
+	// The declaration of a parameter register includes its type.
+	assert.Equal(t, Position{Line: 0, Character: 14}, register.Range.Start)
+
+	var global Location
+	require.NoError(t, json.Unmarshal(findResponse(t, messages, globalId).Result, &global))
+	assert.Equal(t, Position{Line: 0, Character: 0}, global.Range.Start)
+
+	assert.Equal(t, "null", string(findResponse(t, messages, builtinId).Result))
+}
+
+func TestServerHover(t *testing.T) {
+	client := testClient{}
+	client.open(t, testSource)
+	registerId := client.request(t, "textDocument/hover", position(2, 9))
+	globalId := client.request(t, "textDocument/hover", position(6, 19))
+
+	messages, err := client.serve(t)
+	require.NoError(t, err)
+
+	var register Hover
+	require.NoError(t, json.Unmarshal(findResponse(t, messages, registerId).Result, &register))
+	assert.Equal(t, "```usm\n$32 %c\n```", register.Contents.Value)
+	assert.Equal(t, &Range{
+		Start: Position{Line: 2, Character: 8},
+		End:   Position{Line: 2, Character: 10},
+	}, register.Range)
+
+	var global Hover
+	require.NoError(t, json.Unmarshal(findResponse(t, messages, globalId).Result, &global))
+	assert.Equal(t, "```usm\nfunc $32 @add $32 %a $32 %b\n```", global.Contents.Value)
+}
+
+func TestServerCompletion(t *testing.T) {
+	client := testClient{}
+	client.open(t, testSource)
+	id := client.request(t, "textDocument/completion", position(2, 4))
+
+	messages, err := client.serve(t)
+	require.NoError(t, err)
+
+	var items []CompletionItem
+	require.NoError(t, json.Unmarshal(findResponse(t, messages, id).Result, &items))
+
+	labels := []string{}
+	for _, item := range items {
+		labels = append(labels, item.Label)
+	}
+
+	assert.Contains(t, labels, "add")
+	assert.Contains(t, labels, "call")
+	assert.NotContains(t, labels, "")
+}
+
+func TestServerFormatting(t *testing.T) {
+	client := testClient{}
+	client.open(t, "func   $32 @f {\n  ret $32 #1\n}")
+	id := client.request(t, "textDocument/formatting", DocumentFormattingParams{
+		TextDocument: TextDocumentIdentifier{Uri: testUri},
+	})
+
+	messages, err := client.serve(t)
+	require.NoError(t, err)
+
+	var edits []TextEdit
+	require.NoError(t, json.Unmarshal(findResponse(t, messages, id).Result, &edits))
+	require.Len(t, edits, 1)
+	assert.Equal(t, "func $32 @f {\n\tret $32 #1\n}\n", edits[0].NewText)
+	assert.Equal(t, Range{End: Position{Line: 2, Character: 1}}, edits[0].Range)
+}
+
+func TestServerDefinitionWithGenerationErrors(t *testing.T) {
+	// The unknown instruction fails the generation of the whole file.
+	source := testSource + `
+type $pair {
+    .first $32
+}
+
+func @broken $pair * %p {
+.loop
+    $32 %y = unknown %p
+    j .loop
+}
+`
+
+	client := testClient{}
+	client.open(t, source)
+	registerId := client.request(t, "textDocument/definition", position(1, 18))
+	globalId := client.request(t, "textDocument/definition", position(6, 20))
+	typeId := client.request(t, "textDocument/definition", position(14, 15))
+	labelId := client.request(t, "textDocument/definition", position(17, 8))
+
+	messages, err := client.serve(t)
+	require.NoError(t, err)
+
+	published := findDiagnostics(t, messages)
+	require.Len(t, published, 1)
+	assert.NotEmpty(t, published[0].Diagnostics)
+
+	var register Location
+	require.NoError(t, json.Unmarshal(findResponse(t, messages, registerId).Result, &register))
+	assert.Equal(t, Position{Line: 0, Character: 14}, register.Range.Start)
+
+	var global Location
+	require.NoError(t, json.Unmarshal(findResponse(t, messages, globalId).Result, &global))
+	assert.Equal(t, Position{Line: 0, Character: 0}, global.Range.Start)
+
+	var typ Location
+	require.NoError(t, json.Unmarshal(findResponse(t, messages, typeId).Result, &typ))
+	assert.Equal(t, Position{Line: 10, Character: 0}, typ.Range.Start)
+
+	var label Location
+	require.NoError(t, json.Unmarshal(findResponse(t, messages, labelId).Result, &label))
+	assert.Equal(t, Position{Line: 15, Character: 0}, label.Range.Start)
+}
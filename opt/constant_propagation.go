@@ -0,0 +1,696 @@
+package opt
+
+import (
+	"math/big"
+	"slices"
+
+	"alon.kr/x/set"
+	"alon.kr/x/stack"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+)
+
+// Sparse conditional constant propagation (SCCP), as described by Wegman and
+// Zadeck in "Constant Propagation with Conditional Branches".
+//
+// Each register is assigned a value in a lattice of three levels: undefined
+// (no definition of the register was executed yet), a constant value, or
+// overdefined (the register may hold more than a single value).
+// Starting from the entry block, we evaluate only instructions in basic blocks
+// that are known to be executable, and a control flow edge is marked as
+// executable only if the branch that it represents may be taken, according
+// to the values of the registers known so far.
+//
+// The analysis is optimized for SSA form, but is still correct when a register
+// has multiple definitions, since the value of the register is the meet of
+// the values of all of its (executable) definitions.
+
+// ConstantFoldingInstruction is an instruction whose targets can be computed
+// at compile time, when the values of all of its arguments are known.
+type ConstantFoldingInstruction interface {
+	gen.InstructionDefinition
+
+	// Returns the values of the instruction targets, provided the values of
+	// the instruction arguments. The slice of values is parallel to the
+	// instruction arguments, and values of non-value arguments (labels, for
+	// example) are nil.
+	//
+	// The returned values do not need to fit in the target types: they are
+	// wrapped around to the bit width of each target type by the caller.
+	Fold(info *gen.InstructionInfo, arguments []*big.Int) ([]*big.Int, core.ResultList)
+}
+
+// ConstantBranchingInstruction is a branching instruction whose next step can
+// be determined at compile time, when the values of all of its arguments are
+// known.
+type ConstantBranchingInstruction interface {
+	gen.InstructionDefinition
+
+	// Returns the next step of the instruction, provided the values of the
+	// instruction arguments, in the same form as in the Fold method.
+	FoldBranch(info *gen.InstructionInfo, arguments []*big.Int) (gen.StepInfo, core.ResultList)
+}
+
+// ConstantPropagationPhiInstruction is a phi instruction, whose incoming
+// arguments can be removed when their predecessor blocks are unreachable.
+type ConstantPropagationPhiInstruction interface {
+	PhiInstruction
+
+	// Removes the argument that is forwarded by the phi instruction when
+	// arriving from the provided predecessor block, if there is one.
+	RemoveIncomingArgument(info *gen.InstructionInfo, predecessor *gen.BasicBlockInfo)
+}
+
+// ConstantSubstitutionInstruction is an instruction that restricts which of
+// its register arguments can be replaced with immediates. Register arguments
+// of other instructions are always replaced if their value is constant.
+type ConstantSubstitutionInstruction interface {
+	gen.InstructionDefinition
+
+	// Returns true if the argument at the provided index can be replaced with
+	// the provided immediate, without making the instruction invalid.
+	CanSubstituteArgument(info *gen.InstructionInfo, index int, immediate *gen.ImmediateInfo) bool
+}
+
+// ConstantPropagationScheme provides the ISA specific instructions that the
+// constant propagation uses to rewrite the function.
+type ConstantPropagationScheme interface {
+	// Returns the definition of an instruction that has a single target, and a
+	// single argument, which is copied into the target.
+	NewMoveDefinition() gen.InstructionDefinition
+
+	// Returns the definition of an instruction that has a single label argument,
+	// and unconditionally jumps to it.
+	NewJumpDefinition() gen.InstructionDefinition
+}
+
+// MARK: Lattice
+
+type latticeLevel uint8
+
+const (
+	undefinedLevel latticeLevel = iota
+	constantLevel
+	overdefinedLevel
+)
+
+type latticeValue struct {
+	level latticeLevel
+	value *big.Int
+}
+
+var (
+	undefinedValue   = latticeValue{level: undefinedLevel}
+	overdefinedValue = latticeValue{level: overdefinedLevel}
+)
+
+func constantValue(value *big.Int) latticeValue {
+	return latticeValue{level: constantLevel, value: value}
+}
+
+func (v latticeValue) equal(other latticeValue) bool {
+	if v.level != other.level {
+		return false
+	}
+
+	return v.level != constantLevel || v.value.Cmp(other.value) == 0
+}
+
+func (v latticeValue) meet(other latticeValue) latticeValue {
+	switch {
+	case v.level == undefinedLevel:
+		return other
+	case other.level == undefinedLevel:
+		return v
+	case v.equal(other):
+		return v
+	default:
+		return overdefinedValue
+	}
+}
+
+// Returns the constant value of the provided value, wrapped around to the bit
+// width of the provided type (see gen.ReferencedTypeInfo.WrapInteger), or an
+// overdefined value if the type is not an integer type that can be folded.
+func wrapToType(value *big.Int, typ gen.ReferencedTypeInfo) latticeValue {
+	if typ.IntegerSize() == nil {
+		return overdefinedValue
+	}
+
+	return constantValue(typ.WrapInteger(value))
+}
+
+// MARK: Analysis
+
+type controlFlowEdge struct {
+	from *gen.BasicBlockInfo
+	to   *gen.BasicBlockInfo
+}
+
+type constantPropagation struct {
+	*gen.FunctionInfo
+
+	Scheme ConstantPropagationScheme
+
+	values map[*gen.RegisterInfo]latticeValue
+
+	executableBlocks set.Set[*gen.BasicBlockInfo]
+	executableEdges  set.Set[controlFlowEdge]
+
+	// The next steps of basic blocks that end with a branching instruction
+	// whose arguments are all constant.
+	foldedSteps map[*gen.BasicBlockInfo]gen.StepInfo
+
+	// Basic blocks that end with a branching instruction whose arguments
+	// remained undefined after the analysis has converged, and are thus
+	// treated as overdefined.
+	undefinedBranches set.Set[*gen.BasicBlockInfo]
+
+	edgesWorklist        stack.Stack[controlFlowEdge]
+	instructionsWorklist stack.Stack[*gen.InstructionInfo]
+}
+
+func (p *constantPropagation) registerValue(register *gen.RegisterInfo) latticeValue {
+	if value, ok := p.values[register]; ok {
+		return value
+	}
+
+	return undefinedValue
+}
+
+func (p *constantPropagation) argumentValue(argument gen.ArgumentInfo) latticeValue {
+	switch typedArgument := argument.(type) {
+	case *gen.RegisterArgumentInfo:
+		return p.registerValue(typedArgument.Register)
+	case *gen.ImmediateInfo:
+		return wrapToType(typedArgument.Value, typedArgument.Type)
+	default:
+		return overdefinedValue
+	}
+}
+
+// Updates the value of the register to the meet of its current value and the
+// provided value, and schedules the usages of the register for evaluation if
+// the value has changed.
+func (p *constantPropagation) updateRegister(
+	register *gen.RegisterInfo,
+	value latticeValue,
+) {
+	current := p.registerValue(register)
+	updated := current.meet(value)
+	if updated.equal(current) {
+		return
+	}
+
+	p.values[register] = updated
+	for _, usage := range register.Usages {
+		p.instructionsWorklist.Push(usage)
+	}
+}
+
+func (p *constantPropagation) overdefineTargets(instruction *gen.InstructionInfo) {
+	for _, register := range gen.TargetsToRegisters(instruction.Targets) {
+		p.updateRegister(register, overdefinedValue)
+	}
+}
+
+// Returns the values of the instruction value arguments, and the lowest level
+// among them: if some argument is undefined the level is undefinedLevel, and
+// if all arguments are constant, the level is constantLevel.
+func (p *constantPropagation) argumentValues(
+	instruction *gen.InstructionInfo,
+) ([]*big.Int, latticeLevel) {
+	values := make([]*big.Int, len(instruction.Arguments))
+	level := constantLevel
+
+	for i, argument := range instruction.Arguments {
+		if _, ok := argument.(*gen.LabelArgumentInfo); ok {
+			continue
+		}
+
+		value := p.argumentValue(argument)
+		switch value.level {
+		case undefinedLevel:
+			level = undefinedLevel
+		case overdefinedLevel:
+			if level == constantLevel {
+				level = overdefinedLevel
+			}
+		case constantLevel:
+			values[i] = value.value
+		}
+	}
+
+	return values, level
+}
+
+func (p *constantPropagation) evaluatePhi(
+	instruction *gen.InstructionInfo,
+	phi ConstantPropagationPhiInstruction,
+) {
+	value := undefinedValue
+	block := instruction.BasicBlockInfo
+
+	for _, predecessor := range block.BackwardEdges {
+		if !p.executableEdges.Contains(controlFlowEdge{predecessor, block}) {
+			continue
+		}
+
+		argument := phi.IncomingArgument(instruction, predecessor)
+		if argument != nil {
+			value = value.meet(p.argumentValue(argument))
+		}
+	}
+
+	for _, register := range gen.TargetsToRegisters(instruction.Targets) {
+		p.updateRegister(register, value)
+	}
+}
+
+func (p *constantPropagation) evaluateFolding(
+	instruction *gen.InstructionInfo,
+	folding ConstantFoldingInstruction,
+) core.ResultList {
+	arguments, level := p.argumentValues(instruction)
+	switch level {
+	case undefinedLevel:
+		return core.ResultList{}
+	case overdefinedLevel:
+		p.overdefineTargets(instruction)
+		return core.ResultList{}
+	}
+
+	values, results := folding.Fold(instruction, arguments)
+	if !results.IsEmpty() {
+		return results
+	}
+
+	for i, target := range instruction.Targets {
+		value := overdefinedValue
+		if i < len(values) && values[i] != nil {
+			value = wrapToType(values[i], target.Register.Type)
+		}
+
+		p.updateRegister(target.Register, value)
+	}
+
+	return core.ResultList{}
+}
+
+func (p *constantPropagation) markEdgeExecutable(from, to *gen.BasicBlockInfo) {
+	if to != nil {
+		p.edgesWorklist.Push(controlFlowEdge{from, to})
+	}
+}
+
+// Marks the edges that may be taken after the last instruction in the block
+// as executable.
+func (p *constantPropagation) evaluateSteps(block *gen.BasicBlockInfo) core.ResultList {
+	if len(block.Instructions) == 0 {
+		p.markEdgeExecutable(block, block.NextBlock)
+		return core.ResultList{}
+	}
+
+	last := block.Instructions[len(block.Instructions)-1]
+	steps, results := last.Definition.PossibleNextSteps(last)
+	if !results.IsEmpty() {
+		return results
+	}
+
+	if branching, ok := last.Definition.(ConstantBranchingInstruction); ok {
+		arguments, level := p.argumentValues(last)
+		if level == undefinedLevel && p.undefinedBranches.Contains(block) {
+			level = overdefinedLevel
+		}
+
+		switch level {
+		case undefinedLevel:
+			return core.ResultList{}
+		case overdefinedLevel:
+			delete(p.foldedSteps, block)
+		case constantLevel:
+			steps, results = branching.FoldBranch(last, arguments)
+			if !results.IsEmpty() {
+				return results
+			}
+
+			p.foldedSteps[block] = steps
+		}
+	}
+
+	if steps.PossibleContinue {
+		p.markEdgeExecutable(block, block.NextBlock)
+	}
+
+	for _, label := range steps.PossibleBranches {
+		p.markEdgeExecutable(block, label.BasicBlock)
+	}
+
+	return core.ResultList{}
+}
+
+func (p *constantPropagation) evaluateInstruction(
+	instruction *gen.InstructionInfo,
+) core.ResultList {
+	block := instruction.BasicBlockInfo
+	if block == nil || !p.executableBlocks.Contains(block) {
+		return core.ResultList{}
+	}
+
+	switch definition := instruction.Definition.(type) {
+	case ConstantPropagationPhiInstruction:
+		p.evaluatePhi(instruction, definition)
+	case ConstantFoldingInstruction:
+		results := p.evaluateFolding(instruction, definition)
+		if !results.IsEmpty() {
+			return results
+		}
+	default:
+		p.overdefineTargets(instruction)
+	}
+
+	if instruction == block.Instructions[len(block.Instructions)-1] {
+		return p.evaluateSteps(block)
+	}
+
+	return core.ResultList{}
+}
+
+func (p *constantPropagation) visitEdge(edge controlFlowEdge) core.ResultList {
+	if p.executableEdges.Contains(edge) {
+		return core.ResultList{}
+	}
+
+	p.executableEdges.Add(edge)
+	block := edge.to
+
+	if p.executableBlocks.Contains(block) {
+		// The block was already evaluated, but the phi instructions in it may
+		// now receive a value from a new predecessor.
+		for _, instruction := range block.Instructions {
+			if _, ok := instruction.Definition.(ConstantPropagationPhiInstruction); ok {
+				results := p.evaluateInstruction(instruction)
+				if !results.IsEmpty() {
+					return results
+				}
+			}
+		}
+
+		return core.ResultList{}
+	}
+
+	p.executableBlocks.Add(block)
+	if len(block.Instructions) == 0 {
+		return p.evaluateSteps(block)
+	}
+
+	for _, instruction := range block.Instructions {
+		results := p.evaluateInstruction(instruction)
+		if !results.IsEmpty() {
+			return results
+		}
+	}
+
+	return core.ResultList{}
+}
+
+// Marks the branches of executable blocks whose arguments are still undefined
+// as overdefined, and evaluates their next steps. Such a branch is never
+// folded, so all of its possible next steps are considered executable.
+//
+// Returns true if some branch was marked.
+func (p *constantPropagation) overdefineUndefinedBranches() (bool, core.ResultList) {
+	marked := false
+	for block := p.EntryBlock; block != nil; block = block.NextBlock {
+		if !p.executableBlocks.Contains(block) || p.undefinedBranches.Contains(block) {
+			continue
+		}
+
+		if len(block.Instructions) == 0 {
+			continue
+		}
+
+		last := block.Instructions[len(block.Instructions)-1]
+		if _, ok := last.Definition.(ConstantBranchingInstruction); !ok {
+			continue
+		}
+
+		if _, level := p.argumentValues(last); level != undefinedLevel {
+			continue
+		}
+
+		p.undefinedBranches.Add(block)
+		marked = true
+
+		results := p.evaluateSteps(block)
+		if !results.IsEmpty() {
+			return false, results
+		}
+	}
+
+	return marked, core.ResultList{}
+}
+
+func (p *constantPropagation) analyze() core.ResultList {
+	for _, parameter := range p.Parameters {
+		p.values[parameter] = overdefinedValue
+	}
+
+	p.edgesWorklist.Push(controlFlowEdge{nil, p.EntryBlock})
+
+	for {
+		for len(p.edgesWorklist) > 0 || len(p.instructionsWorklist) > 0 {
+			for len(p.edgesWorklist) > 0 {
+				edge := p.edgesWorklist.Top()
+				p.edgesWorklist.Pop()
+
+				results := p.visitEdge(edge)
+				if !results.IsEmpty() {
+					return results
+				}
+			}
+
+			for len(p.instructionsWorklist) > 0 {
+				instruction := p.instructionsWorklist.Top()
+				p.instructionsWorklist.Pop()
+
+				results := p.evaluateInstruction(instruction)
+				if !results.IsEmpty() {
+					return results
+				}
+			}
+		}
+
+		// Once the analysis converges, branches whose arguments are still
+		// undefined are treated as overdefined, which may make more blocks
+		// executable.
+		marked, results := p.overdefineUndefinedBranches()
+		if !results.IsEmpty() {
+			return results
+		}
+
+		if !marked {
+			return core.ResultList{}
+		}
+	}
+}
+
+// MARK: Rewrite
+
+// Removes the instruction from the definitions and usages lists of its
+// registers, and replaces its arguments with the provided ones.
+func replaceArguments(instruction *gen.InstructionInfo, arguments ...gen.ArgumentInfo) {
+	for _, argument := range instruction.Arguments {
+		argument.OnDetach(instruction)
+	}
+
+	instruction.Arguments = []gen.ArgumentInfo{}
+	instruction.AppendArgument(arguments...)
+	for _, argument := range arguments {
+		argument.OnAttach(instruction)
+	}
+}
+
+func removeInstruction(instruction *gen.InstructionInfo) {
+	replaceArguments(instruction)
+	for _, target := range instruction.Targets {
+		target.Register.RemoveDefinition(instruction)
+	}
+
+	instruction.BasicBlockInfo.RemoveInstruction(instruction)
+}
+
+func filterEdges(
+	blocks []*gen.BasicBlockInfo,
+	keep func(*gen.BasicBlockInfo) bool,
+) []*gen.BasicBlockInfo {
+	return slices.DeleteFunc(blocks, func(block *gen.BasicBlockInfo) bool {
+		return !keep(block)
+	})
+}
+
+// Removes basic blocks that are never executed, and control flow edges that
+// are never taken.
+func (p *constantPropagation) removeUnreachableCode() {
+	for block := p.EntryBlock; block != nil; block = block.NextBlock {
+		for block.NextBlock != nil && !p.executableBlocks.Contains(block.NextBlock) {
+			unreachable := block.NextBlock
+			for _, instruction := range slices.Clone(unreachable.Instructions) {
+				removeInstruction(instruction)
+			}
+
+			block.NextBlock = unreachable.NextBlock
+		}
+	}
+
+	for block := p.EntryBlock; block != nil; block = block.NextBlock {
+		for _, instruction := range block.Instructions {
+			phi, ok := instruction.Definition.(ConstantPropagationPhiInstruction)
+			if !ok {
+				continue
+			}
+
+			for _, predecessor := range uniqueBlocks(block.BackwardEdges) {
+				if !p.executableEdges.Contains(controlFlowEdge{predecessor, block}) {
+					phi.RemoveIncomingArgument(instruction, predecessor)
+				}
+			}
+		}
+
+		block.ForwardEdges = filterEdges(block.ForwardEdges, func(to *gen.BasicBlockInfo) bool {
+			return p.executableEdges.Contains(controlFlowEdge{block, to})
+		})
+
+		block.BackwardEdges = filterEdges(block.BackwardEdges, func(from *gen.BasicBlockInfo) bool {
+			return p.executableEdges.Contains(controlFlowEdge{from, block})
+		})
+	}
+}
+
+// Replaces branching instructions whose next step is known with unconditional
+// jumps, or removes them if they always continue to the next instruction.
+func (p *constantPropagation) rewriteBranches() {
+	for block := p.EntryBlock; block != nil; block = block.NextBlock {
+		steps, ok := p.foldedSteps[block]
+		if !ok {
+			continue
+		}
+
+		last := block.Instructions[len(block.Instructions)-1]
+		if len(steps.PossibleBranches) == 1 && !steps.PossibleContinue && !steps.PossibleReturn {
+			replaceArguments(last, gen.NewLabelArgumentInfo(steps.PossibleBranches[0]))
+			last.SetInstruction(p.Scheme.NewJumpDefinition())
+		} else if len(steps.PossibleBranches) == 0 && steps.PossibleContinue {
+			removeInstruction(last)
+		}
+	}
+}
+
+func (p *constantPropagation) registerConstant(
+	register *gen.RegisterInfo,
+) (*gen.ImmediateInfo, bool) {
+	value := p.registerValue(register)
+	if value.level != constantLevel {
+		return nil, false
+	}
+
+	return &gen.ImmediateInfo{Type: register.Type, Value: value.value}, true
+}
+
+// Replaces register arguments with constant values with immediates, and
+// instructions that define a single constant register with moves.
+func (p *constantPropagation) rewriteConstants() {
+	for _, instruction := range p.CollectInstructions() {
+		for i, argument := range instruction.Arguments {
+			register, ok := argument.(*gen.RegisterArgumentInfo)
+			if !ok {
+				continue
+			}
+
+			immediate, ok := p.registerConstant(register.Register)
+			if !ok {
+				continue
+			}
+
+			substitution, ok := instruction.Definition.(ConstantSubstitutionInstruction)
+			if ok && !substitution.CanSubstituteArgument(instruction, i, immediate) {
+				continue
+			}
+
+			instruction.SubstituteArgument(i, immediate)
+		}
+
+		switch instruction.Definition.(type) {
+		case ConstantFoldingInstruction, ConstantPropagationPhiInstruction:
+		default:
+			continue
+		}
+
+		if len(instruction.Targets) != 1 {
+			continue
+		}
+
+		immediate, ok := p.registerConstant(instruction.Targets[0].Register)
+		if ok {
+			replaceArguments(instruction, immediate)
+			instruction.SetInstruction(p.Scheme.NewMoveDefinition())
+		}
+	}
+}
+
+func uniqueBlocks(blocks []*gen.BasicBlockInfo) []*gen.BasicBlockInfo {
+	unique := []*gen.BasicBlockInfo{}
+	for _, block := range blocks {
+		if !slices.Contains(unique, block) {
+			unique = append(unique, block)
+		}
+	}
+	return unique
+}
+
+// ConstantPropagation performs sparse conditional constant propagation on the
+// provided function: registers with constant values are replaced with
+// immediates, branches with known conditions are replaced with unconditional
+// jumps, and basic blocks that can never be executed are removed.
+func ConstantPropagation(
+	function *gen.FunctionInfo,
+	scheme ConstantPropagationScheme,
+) core.ResultList {
+	propagation := constantPropagation{
+		FunctionInfo:         function,
+		Scheme:               scheme,
+		values:               make(map[*gen.RegisterInfo]latticeValue),
+		executableBlocks:     set.New[*gen.BasicBlockInfo](),
+		executableEdges:      set.New[controlFlowEdge](),
+		foldedSteps:          make(map[*gen.BasicBlockInfo]gen.StepInfo),
+		undefinedBranches:    set.New[*gen.BasicBlockInfo](),
+		edgesWorklist:        stack.New[controlFlowEdge](),
+		instructionsWorklist: stack.New[*gen.InstructionInfo](),
+	}
+
+	results := propagation.analyze()
+	if !results.IsEmpty() {
+		return results
+	}
+
+	propagation.removeUnreachableCode()
+	propagation.rewriteBranches()
+	propagation.rewriteConstants()
+	return core.ResultList{}
+}
+
+func FileToConstantPropagation(
+	file *gen.FileInfo,
+	scheme ConstantPropagationScheme,
+) core.ResultList {
+	results := core.ResultList{}
+
+	for _, function := range file.Functions {
+		if function.IsDefined() {
+			curResults := ConstantPropagation(function, scheme)
+			results.Extend(&curResults)
+		}
+	}
+
+	return results
+}
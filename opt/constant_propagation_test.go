@@ -0,0 +1,17 @@
+package opt_test
+
+import (
+	"testing"
+
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/opt"
+	usmopt "alon.kr/x/usm/usm/opt"
+)
+
+func TestConstantPropagation(t *testing.T) {
+	scheme := usmopt.NewConstantPropagationScheme()
+	RunOptimizationTests(t, "constant_propagation", func(function *gen.FunctionInfo) core.ResultList {
+		return opt.ConstantPropagation(function, scheme)
+	})
+}
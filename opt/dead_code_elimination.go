@@ -168,6 +168,18 @@ func TransformFileToDeadCodeElimination(
 	return data, results
 }
 
+// Removes the instruction from the definitions and usages lists of its
+// registers, before it is removed from its basic block.
+func detachInstructionOperands(instruction *gen.InstructionInfo) {
+	for _, argument := range instruction.Arguments {
+		argument.OnDetach(instruction)
+	}
+
+	for _, target := range instruction.Targets {
+		target.Register.RemoveDefinition(instruction)
+	}
+}
+
 func DeadCodeElimination(function *gen.FunctionInfo) core.ResultList {
 	usefulInstructions, results := collectUsefulInstructions(function)
 	if !results.IsEmpty() {
@@ -180,6 +192,7 @@ func DeadCodeElimination(function *gen.FunctionInfo) core.ResultList {
 		for i := len(block.Instructions) - 1; i >= 0; i-- {
 			instruction := block.Instructions[i]
 			if !usefulInstructions.Contains(instruction) {
+				detachInstructionOperands(instruction)
 				ok := block.RemoveInstruction(instruction)
 				if !ok {
 					results.Append(core.Result{{
@@ -4,8 +4,36 @@ import (
 	"testing"
 
 	"alon.kr/x/usm/opt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDeadCodeElimination(t *testing.T) {
 	RunOptimizationTests(t, "dead_code_elimination", opt.DeadCodeElimination)
 }
+
+func TestDeadCodeEliminationDetachesRemovedInstructions(t *testing.T) {
+	source := `func $64 @f $64 %a {
+.entry
+	$64 %b = add %a $64 #1
+	$64 %c = %b
+	ret %a
+}
+`
+
+	function := generateFileInfo(t, source).GetFunction("@f")
+	require.NotNil(t, function)
+
+	results := opt.DeadCodeElimination(function)
+	require.True(t, results.IsEmpty())
+
+	a := function.Registers.GetRegister("%a")
+	b := function.Registers.GetRegister("%b")
+	c := function.Registers.GetRegister("%c")
+
+	// Only the return instruction is left, and it is the only usage of %a.
+	assert.Equal(t, function.EntryBlock.Instructions, a.Usages)
+	assert.Empty(t, b.Definitions)
+	assert.Empty(t, b.Usages)
+	assert.Empty(t, c.Definitions)
+}
@@ -0,0 +1,138 @@
+package opt
+
+import (
+	"slices"
+
+	"alon.kr/x/set"
+	"alon.kr/x/stack"
+	"alon.kr/x/usm/gen"
+)
+
+// FunctionLivenessInfo holds the result of a liveness analysis of a function.
+//
+// A register is live at some point of the function if there exists a path
+// from that point to a usage of the register, that does not pass through a
+// definition of the register.
+type FunctionLivenessInfo struct {
+	gen.FunctionControlFlowInfo
+
+	// The registers that are live at the entry of each basic block.
+	// Indexed by the basic block index in the control flow info.
+	LiveIn []set.Set[*gen.RegisterInfo]
+
+	// The registers that are live at the exit of each basic block.
+	// Indexed by the basic block index in the control flow info.
+	LiveOut []set.Set[*gen.RegisterInfo]
+}
+
+// NewFunctionLivenessInfo computes the liveness information of all registers
+// in the provided function.
+//
+// The analysis is performed separately for each register, and is based on the
+// register Definitions and Usages lists: from each usage that is not preceded
+// by a definition in its basic block, we traverse the control flow graph
+// backwards until reaching basic blocks that define the register.
+func NewFunctionLivenessInfo(function *gen.FunctionInfo) FunctionLivenessInfo {
+	cfInfo := gen.NewFunctionControlFlowInfo(function)
+	n := len(cfInfo.BasicBlocks)
+
+	info := FunctionLivenessInfo{
+		FunctionControlFlowInfo: cfInfo,
+		LiveIn:                  make([]set.Set[*gen.RegisterInfo], n),
+		LiveOut:                 make([]set.Set[*gen.RegisterInfo], n),
+	}
+
+	for i := 0; i < n; i++ {
+		info.LiveIn[i] = set.New[*gen.RegisterInfo]()
+		info.LiveOut[i] = set.New[*gen.RegisterInfo]()
+	}
+
+	for _, register := range function.Registers.GetAllRegisters() {
+		info.analyzeRegister(register)
+	}
+
+	return info
+}
+
+// Returns true if the register is defined in the provided basic block by an
+// instruction that appears before the provided instruction index.
+func isDefinedBefore(
+	register *gen.RegisterInfo,
+	block *gen.BasicBlockInfo,
+	index int,
+) bool {
+	for _, definition := range register.Definitions {
+		if definition.BasicBlockInfo != block {
+			continue
+		}
+
+		definitionIndex := slices.Index(block.Instructions, definition)
+		if definitionIndex != -1 && definitionIndex < index {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (i *FunctionLivenessInfo) analyzeRegister(register *gen.RegisterInfo) {
+	definingBlocks := set.New[*gen.BasicBlockInfo]()
+	for _, definition := range register.Definitions {
+		if definition.BasicBlockInfo != nil {
+			definingBlocks.Add(definition.BasicBlockInfo)
+		}
+	}
+
+	worklist := stack.New[*gen.BasicBlockInfo]()
+	for _, usage := range register.Usages {
+		block := usage.BasicBlockInfo
+		if block == nil {
+			continue
+		}
+
+		index := slices.Index(block.Instructions, usage)
+		if isDefinedBefore(register, block, index) {
+			continue
+		}
+
+		blockIndex := i.BasicBlocksToIndex[block]
+		if !i.LiveIn[blockIndex].Contains(register) {
+			i.LiveIn[blockIndex].Add(register)
+			worklist.Push(block)
+		}
+	}
+
+	for len(worklist) > 0 {
+		block := worklist.Top()
+		worklist.Pop()
+		for _, predecessor := range block.BackwardEdges {
+			predecessorIndex := i.BasicBlocksToIndex[predecessor]
+			i.LiveOut[predecessorIndex].Add(register)
+
+			if definingBlocks.Contains(predecessor) {
+				continue
+			}
+
+			if !i.LiveIn[predecessorIndex].Contains(register) {
+				i.LiveIn[predecessorIndex].Add(register)
+				worklist.Push(predecessor)
+			}
+		}
+	}
+}
+
+// Returns true if the register is live at the entry of the basic block.
+func (i *FunctionLivenessInfo) IsLiveIn(
+	block *gen.BasicBlockInfo,
+	register *gen.RegisterInfo,
+) bool {
+	return i.LiveIn[i.BasicBlocksToIndex[block]].Contains(register)
+}
+
+// Returns true if the register is live at the exit of the basic block.
+func (i *FunctionLivenessInfo) IsLiveOut(
+	block *gen.BasicBlockInfo,
+	register *gen.RegisterInfo,
+) bool {
+	return i.LiveOut[i.BasicBlocksToIndex[block]].Contains(register)
+}
@@ -8,8 +8,8 @@ import (
 
 	"alon.kr/x/usm/core"
 	"alon.kr/x/usm/gen"
-	"alon.kr/x/usm/lex"
-	"alon.kr/x/usm/parse"
+	"alon.kr/x/usm/internal/testutil"
+	"alon.kr/x/usm/opt"
 	usmmanagers "alon.kr/x/usm/usm/managers"
 	"github.com/stretchr/testify/assert"
 )
@@ -62,22 +62,7 @@ func findTestPaths(t *testing.T, optimizationName string) []string {
 // source code.
 func generateFileInfo(t *testing.T, source string) *gen.FileInfo {
 	t.Helper()
-
-	srcView := core.NewSourceView(source)
-
-	lexResult, err := lex.NewTokenizer().Tokenize(srcView)
-	assert.NoError(t, err)
-
-	tknView := parse.NewTokenView(lexResult)
-	fileNode, result := parse.NewFileParser().Parse(&tknView)
-	assert.Nil(t, result)
-
-	ctx := usmmanagers.NewGenerationContext()
-	generator := gen.NewFileGenerator()
-	info, results := generator.Generate(ctx, srcView.Ctx(), fileNode)
-	assert.True(t, results.IsEmpty(), "Failed to generate file info")
-
-	return info
+	return testutil.MustGenerateFile(t, usmmanagers.NewGenerationContext(), source)
 }
 
 // extractTestFunctions extracts the @input and @expected functions from the
@@ -116,10 +101,23 @@ func RunOptimizationTests(
 			file := generateFileInfo(t, source)
 			inputFunc, expectedFunc := extractTestFunctions(t, file)
 
+			results := opt.VerifyFunction(inputFunc, false)
+			assert.True(t, results.IsEmpty(), "Input function verification failed")
+
 			// Apply optimization to the input function
-			results := optimizationFunc(inputFunc)
+			results = optimizationFunc(inputFunc)
 			assert.True(t, results.IsEmpty(), "Optimization returned errors")
 
+			results = opt.VerifyFunction(inputFunc, false)
+			assert.True(t, results.IsEmpty(), "Optimized function verification failed")
+
+			// The optimized instructions must be valid, so the output can be
+			// read back.
+			for _, instruction := range inputFunc.CollectInstructions() {
+				results = instruction.Definition.Validate(instruction)
+				assert.True(t, results.IsEmpty(), "Invalid instruction: %s", instruction)
+			}
+
 			// Compare the optimized function with the expected function
 			inputFunc.Name = ExpectedFuncName
 			assert.Equal(
@@ -0,0 +1,13 @@
+package opt
+
+import "alon.kr/x/usm/gen"
+
+// PhiInstruction is a phi instruction, whose value depends on the basic block
+// from which the execution arrived.
+type PhiInstruction interface {
+	gen.InstructionDefinition
+
+	// Returns the argument that is forwarded by the phi instruction when
+	// arriving from the provided predecessor block, or nil if there is none.
+	IncomingArgument(info *gen.InstructionInfo, predecessor *gen.BasicBlockInfo) gen.ArgumentInfo
+}
@@ -98,10 +98,21 @@ func (s *ReachingDefinitionsSet) RenameDefinitionRegister(
 ) *gen.RegisterInfo {
 	baseIndex := s.FunctionSsaInfo.RegistersToIndex[base]
 	renamed := s.SsaConstructionScheme.NewRenamedRegister(base)
+	s.Registers.NewRegister(renamed)
 	s.updateReachingDefinition(baseIndex, renamed)
 	return renamed
 }
 
+// Marks the function parameters as the reaching definitions of themselves at
+// the entry of the function. Parameters are not renamed, since they are
+// defined by the function signature and not by an instruction.
+func (s *ReachingDefinitionsSet) defineParameters() {
+	for _, parameter := range s.Parameters {
+		baseIndex := s.FunctionSsaInfo.RegistersToIndex[parameter]
+		s.registerDefinitionStacks[baseIndex].Push(parameter)
+	}
+}
+
 func (s *ReachingDefinitionsSet) updateReachingDefinition(
 	baseIndex uint,
 	renamed *gen.RegisterInfo,
@@ -119,6 +130,7 @@ func (s *ReachingDefinitionsSet) popBlock() {
 	// so we do not check for the special case of an empty stack.
 	for s.registerDefinitionPushes.Top() != blockSeparator {
 		registerIndex := s.registerDefinitionPushes.Top()
+		s.registerDefinitionPushes.Pop()
 		s.registerDefinitionStacks[registerIndex].Pop()
 	}
 
@@ -168,5 +180,5 @@ type SsaConstructionScheme interface {
 	//
 	// The caller does not grantee the order of basic blocks in which the calls
 	// to this method are made.
-	RenameBasicBlock(*gen.BasicBlockInfo, ReachingDefinitionsSet) core.ResultList
+	RenameBasicBlock(*gen.BasicBlockInfo, *ReachingDefinitionsSet) core.ResultList
 }
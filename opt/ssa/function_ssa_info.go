@@ -2,6 +2,7 @@ package ssa
 
 import (
 	"fmt"
+	"slices"
 
 	"alon.kr/x/graph"
 	"alon.kr/x/list"
@@ -193,6 +194,12 @@ func (i *FunctionSsaInfo) InsertPhiInstructions() core.ResultList {
 func (i *FunctionSsaInfo) deleteBaseRegisters() core.ResultList {
 	results := core.ResultList{}
 	for _, register := range i.BaseRegisters {
+		// Parameters are not renamed, and are still used as the definitions
+		// of their values at the entry of the function.
+		if slices.Contains(i.Parameters, register) {
+			continue
+		}
+
 		curResults := i.Registers.DeleteRegister(register)
 		results.Extend(&curResults)
 	}
@@ -214,6 +221,7 @@ func (i *FunctionSsaInfo) commitPhiInstructions() core.ResultList {
 
 func (i *FunctionSsaInfo) RenameRegisters() core.ResultList {
 	reachingSet := NewReachingDefinitionsSet(i)
+	reachingSet.defineParameters()
 	n := uint(len(i.BasicBlocks))
 
 	for _, event := range i.DominatorJoinGraph.Dfs.Timeline {
@@ -233,7 +241,7 @@ func (i *FunctionSsaInfo) RenameRegisters() core.ResultList {
 			// the reaching definition set that we have built so far, and
 			// the implementation should use it to query what is the live
 			// definition of each register in the current basic block.
-			results := i.SsaConstructionScheme.RenameBasicBlock(basicBlock, reachingSet)
+			results := i.SsaConstructionScheme.RenameBasicBlock(basicBlock, &reachingSet)
 			if !results.IsEmpty() {
 				return results
 			}
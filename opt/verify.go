@@ -0,0 +1,548 @@
+package opt
+
+import (
+	"fmt"
+
+	"alon.kr/x/set"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/transform"
+)
+
+// The verifier checks the internal invariants of the intermediate
+// representation, which the generator establishes, and which every pass
+// should preserve:
+//
+//   - The BasicBlockInfo of each instruction, and the FunctionInfo of each
+//     basic block point to their owners, and the label of each basic block
+//     points back to it.
+//   - The Definitions and Usages lists of each register match the targets and
+//     register arguments of the instructions in the function.
+//   - The ForwardEdges of each basic block match the possible next steps of
+//     its last instruction, and the BackwardEdges are their reverse.
+//
+// In SSA mode, it also checks that each register is defined at most once,
+// that each definition dominates all of its uses, and that the labels of phi
+// instructions match the predecessors of their basic block.
+//
+// A failed verification is a bug in the compiler, so it is reported as an
+// internal error.
+
+type functionVerifier struct {
+	*gen.FunctionInfo
+
+	// The basic blocks of the function, in order.
+	blocks []*gen.BasicBlockInfo
+
+	// The index of each instruction in its basic block.
+	instructionIndices map[*gen.InstructionInfo]int
+
+	results core.ResultList
+}
+
+func (v *functionVerifier) report(
+	instruction *gen.InstructionInfo,
+	format string,
+	args ...any,
+) {
+	result := core.Result{
+		{
+			Type: core.InternalErrorResult,
+			Message: fmt.Sprintf(
+				"Verification of function \"%s\" failed: %s",
+				v.Name,
+				fmt.Sprintf(format, args...),
+			),
+		},
+	}
+
+	if instruction != nil {
+		result[0].Location = instruction.Declaration
+		if instruction.Declaration == nil && instruction.Definition != nil {
+			result = append(result, core.ResultDetails{
+				Type:    core.HintResult,
+				Message: fmt.Sprintf("In the instruction \"%s\"", instruction.String()),
+			})
+		}
+	}
+
+	v.results.Append(result)
+}
+
+// MARK: Ownership
+
+// Collects the basic blocks of the function, and checks that they, and their
+// instructions, point to their owners.
+func (v *functionVerifier) verifyOwnership() {
+	visited := set.New[*gen.BasicBlockInfo]()
+
+	for block := v.EntryBlock; block != nil; block = block.NextBlock {
+		if visited.Contains(block) {
+			v.report(nil, "the basic blocks form a cycle at \"%s\"", block.Label)
+			return
+		}
+
+		visited.Add(block)
+		v.blocks = append(v.blocks, block)
+
+		if block.FunctionInfo != v.FunctionInfo {
+			v.report(nil, "basic block \"%s\" does not point to its function", block.Label)
+		}
+
+		if block.Label == nil {
+			v.report(nil, "a basic block has no label")
+		} else if block.Label.BasicBlock != block {
+			v.report(nil, "label \"%s\" does not point to its basic block", block.Label)
+		}
+
+		for i, instruction := range block.Instructions {
+			v.instructionIndices[instruction] = i
+			if instruction.BasicBlockInfo != block {
+				v.report(
+					instruction,
+					"an instruction does not point to its basic block \"%s\"",
+					block.Label,
+				)
+			}
+		}
+	}
+}
+
+// MARK: Definitions & Usages
+
+// Returns the number of occurrences of each instruction in the slice.
+func countInstructions(instructions []*gen.InstructionInfo) map[*gen.InstructionInfo]int {
+	counts := make(map[*gen.InstructionInfo]int)
+	for _, instruction := range instructions {
+		counts[instruction]++
+	}
+	return counts
+}
+
+func equalCounts(a, b map[*gen.InstructionInfo]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for key, count := range a {
+		if b[key] != count {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Checks that the register is managed by the function register manager.
+func (v *functionVerifier) verifyRegisterManaged(
+	instruction *gen.InstructionInfo,
+	register *gen.RegisterInfo,
+) bool {
+	if register == nil {
+		v.report(instruction, "an instruction has a nil register operand")
+		return false
+	}
+
+	if v.Registers.GetRegister(register.Name) != register {
+		v.report(
+			instruction,
+			"register \"%s\" is not managed by the function",
+			register,
+		)
+		return false
+	}
+
+	return true
+}
+
+// Checks that the Definitions and Usages lists of all registers match the
+// operands of the instructions in the function.
+func (v *functionVerifier) verifyDefinitionsAndUsages() {
+	definitions := make(map[*gen.RegisterInfo][]*gen.InstructionInfo)
+	usages := make(map[*gen.RegisterInfo][]*gen.InstructionInfo)
+
+	for _, block := range v.blocks {
+		for _, instruction := range block.Instructions {
+			for _, target := range instruction.Targets {
+				if v.verifyRegisterManaged(instruction, target.Register) {
+					definitions[target.Register] = append(
+						definitions[target.Register],
+						instruction,
+					)
+				}
+			}
+
+			for _, register := range gen.ArgumentsToRegisters(instruction.Arguments) {
+				if v.verifyRegisterManaged(instruction, register) {
+					usages[register] = append(usages[register], instruction)
+				}
+			}
+		}
+	}
+
+	for _, register := range v.Registers.GetAllRegisters() {
+		expected := countInstructions(definitions[register])
+		if !equalCounts(expected, countInstructions(register.Definitions)) {
+			v.report(
+				nil,
+				"the definitions of register \"%s\" do not match the instructions that target it",
+				register,
+			)
+		}
+
+		expected = countInstructions(usages[register])
+		if !equalCounts(expected, countInstructions(register.Usages)) {
+			v.report(
+				nil,
+				"the usages of register \"%s\" do not match the instructions that use it",
+				register,
+			)
+		}
+	}
+}
+
+// MARK: Control Flow
+
+func equalBlockSets(a, b []*gen.BasicBlockInfo) bool {
+	setA, setB := set.FromSlice(a), set.FromSlice(b)
+
+	for _, block := range a {
+		if !setB.Contains(block) {
+			return false
+		}
+	}
+
+	for _, block := range b {
+		if !setA.Contains(block) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the basic blocks to which the execution may continue after the
+// provided block.
+func (v *functionVerifier) expectedForwardEdges(
+	block *gen.BasicBlockInfo,
+) []*gen.BasicBlockInfo {
+	if len(block.Instructions) == 0 {
+		// An empty basic block (for example, after dead code elimination)
+		// falls through to the next block.
+		if block.NextBlock == nil {
+			v.report(nil, "the last basic block \"%s\" is empty", block.Label)
+			return nil
+		}
+		return []*gen.BasicBlockInfo{block.NextBlock}
+	}
+
+	last := block.Instructions[len(block.Instructions)-1]
+	steps, results := last.Definition.PossibleNextSteps(last)
+	if !results.IsEmpty() {
+		v.results.Extend(&results)
+		return nil
+	}
+
+	edges := []*gen.BasicBlockInfo{}
+	if steps.PossibleContinue {
+		if block.NextBlock == nil {
+			v.report(last, "the last instruction of the function may continue")
+		} else {
+			edges = append(edges, block.NextBlock)
+		}
+	}
+
+	for _, label := range steps.PossibleBranches {
+		if label.BasicBlock == nil || label.BasicBlock.FunctionInfo != v.FunctionInfo {
+			v.report(last, "branch to label \"%s\", which is not in the function", label)
+			continue
+		}
+		edges = append(edges, label.BasicBlock)
+	}
+
+	return edges
+}
+
+// Checks that the forward and backward edges of the basic blocks match the
+// possible next steps of their instructions.
+func (v *functionVerifier) verifyControlFlow() {
+	predecessors := make(map[*gen.BasicBlockInfo][]*gen.BasicBlockInfo)
+	inFunction := set.FromSlice(v.blocks)
+
+	for _, block := range v.blocks {
+		// Only the last instruction of a basic block may branch.
+		for _, instruction := range block.Instructions[:max(0, len(block.Instructions)-1)] {
+			steps, results := instruction.Definition.PossibleNextSteps(instruction)
+			if !results.IsEmpty() {
+				v.results.Extend(&results)
+			} else if steps.IsBranchPossible() {
+				v.report(instruction, "a branching instruction is not the last in its basic block")
+			}
+		}
+
+		expected := v.expectedForwardEdges(block)
+		if !equalBlockSets(expected, block.ForwardEdges) {
+			v.report(
+				nil,
+				"the forward edges of basic block \"%s\" do not match its last instruction",
+				block.Label,
+			)
+		}
+
+		for _, successor := range block.ForwardEdges {
+			if !inFunction.Contains(successor) {
+				v.report(nil, "basic block \"%s\" has an edge out of the function", block.Label)
+				continue
+			}
+			predecessors[successor] = append(predecessors[successor], block)
+		}
+	}
+
+	for _, block := range v.blocks {
+		if !equalBlockSets(predecessors[block], block.BackwardEdges) {
+			v.report(
+				nil,
+				"the backward edges of basic block \"%s\" do not match the forward edges of its predecessors",
+				block.Label,
+			)
+		}
+	}
+}
+
+// MARK: SSA
+
+// Checks that each register is defined at most once, and that parameters are
+// not redefined.
+func (v *functionVerifier) verifySingleDefinitions() {
+	parameters := set.FromSlice(v.Parameters)
+
+	for _, register := range v.Registers.GetAllRegisters() {
+		count := len(register.Definitions)
+		if parameters.Contains(register) {
+			count++
+		}
+
+		if count > 1 {
+			v.report(nil, "register \"%s\" is defined more than once", register)
+		}
+	}
+}
+
+// The dominator tree of the function, represented by the entry and exit times
+// of each basic block in a DFS traversal of the tree.
+type dominatorTree struct {
+	entry map[*gen.BasicBlockInfo]int
+	exit  map[*gen.BasicBlockInfo]int
+}
+
+func newDominatorTree(function *gen.FunctionInfo) dominatorTree {
+	cfInfo := gen.NewFunctionControlFlowInfo(function)
+	dominatorJoinGraph := cfInfo.ControlFlowGraph.DominatorJoinGraph(0)
+	n := uint(len(cfInfo.BasicBlocks))
+
+	tree := dominatorTree{
+		entry: make(map[*gen.BasicBlockInfo]int),
+		exit:  make(map[*gen.BasicBlockInfo]int),
+	}
+
+	path := []*gen.BasicBlockInfo{}
+	for time, event := range dominatorJoinGraph.Dfs.Timeline {
+		if event < n {
+			block := cfInfo.BasicBlocks[event]
+			tree.entry[block] = time
+			path = append(path, block)
+		} else {
+			block := path[len(path)-1]
+			path = path[:len(path)-1]
+			tree.exit[block] = time
+		}
+	}
+
+	return tree
+}
+
+// Returns true if the block is reachable from the entry of the function.
+func (t dominatorTree) reachable(block *gen.BasicBlockInfo) bool {
+	_, ok := t.entry[block]
+	return ok
+}
+
+// Returns true if a dominates b. Both blocks should be reachable.
+func (t dominatorTree) dominates(a, b *gen.BasicBlockInfo) bool {
+	return t.entry[a] <= t.entry[b] && t.exit[b] <= t.exit[a]
+}
+
+// Checks that the definition of the register dominates the end of the
+// provided basic block, in which it is used.
+func (v *functionVerifier) verifyDominatesBlock(
+	tree dominatorTree,
+	instruction *gen.InstructionInfo,
+	register *gen.RegisterInfo,
+	block *gen.BasicBlockInfo,
+) {
+	if len(register.Definitions) != 1 {
+		// Parameters, and registers with multiple definitions (which are
+		// reported separately) are not checked.
+		return
+	}
+
+	definition := register.Definitions[0].BasicBlockInfo
+	if !tree.reachable(definition) || !tree.reachable(block) {
+		return
+	}
+
+	if !tree.dominates(definition, block) {
+		v.report(
+			instruction,
+			"the definition of register \"%s\" does not dominate its use",
+			register,
+		)
+	}
+}
+
+// Checks the phi instruction labels and incoming values, and returns true if
+// the instruction is a phi instruction.
+func (v *functionVerifier) verifyPhi(
+	tree dominatorTree,
+	instruction *gen.InstructionInfo,
+) bool {
+	phi, ok := instruction.Definition.(PhiInstruction)
+	if !ok {
+		return false
+	}
+
+	block := instruction.BasicBlockInfo
+	predecessors := set.FromSlice(block.BackwardEdges)
+	seen := set.New[*gen.BasicBlockInfo]()
+
+	for _, label := range gen.ArgumentsToLabels(instruction.Arguments) {
+		predecessor := label.BasicBlock
+		if !predecessors.Contains(predecessor) {
+			v.report(
+				instruction,
+				"phi label \"%s\" is not a predecessor of basic block \"%s\"",
+				label,
+				block.Label,
+			)
+			continue
+		}
+
+		if seen.Contains(predecessor) {
+			v.report(instruction, "phi label \"%s\" appears more than once", label)
+			continue
+		}
+		seen.Add(predecessor)
+
+		argument := phi.IncomingArgument(instruction, predecessor)
+		if register, ok := argument.(*gen.RegisterArgumentInfo); ok {
+			v.verifyDominatesBlock(tree, instruction, register.Register, predecessor)
+		}
+	}
+
+	return true
+}
+
+// Checks that the definition of each register dominates all of its uses, and
+// that phi instructions are consistent with the control flow graph.
+func (v *functionVerifier) verifyDominance() {
+	tree := newDominatorTree(v.FunctionInfo)
+
+	for _, block := range v.blocks {
+		phisEnded := false
+		for _, instruction := range block.Instructions {
+			if v.verifyPhi(tree, instruction) {
+				if phisEnded {
+					v.report(instruction, "a phi instruction is not at the start of its basic block")
+				}
+				continue
+			}
+			phisEnded = true
+
+			for _, register := range gen.ArgumentsToRegisters(instruction.Arguments) {
+				if len(register.Definitions) != 1 {
+					continue
+				}
+
+				definition := register.Definitions[0]
+				if definition.BasicBlockInfo == block {
+					if v.instructionIndices[definition] >= v.instructionIndices[instruction] {
+						v.report(
+							instruction,
+							"register \"%s\" is used before its definition",
+							register,
+						)
+					}
+					continue
+				}
+
+				v.verifyDominatesBlock(tree, instruction, register, block)
+			}
+		}
+	}
+}
+
+// MARK: API
+
+// VerifyFunction checks the internal invariants of the function, and returns
+// an internal error for each violation. In SSA mode, the invariants of the
+// static single assignment form are checked too.
+func VerifyFunction(function *gen.FunctionInfo, ssa bool) core.ResultList {
+	if !function.IsDefined() {
+		return core.ResultList{}
+	}
+
+	v := functionVerifier{
+		FunctionInfo:       function,
+		instructionIndices: make(map[*gen.InstructionInfo]int),
+	}
+
+	v.verifyOwnership()
+	if !v.results.IsEmpty() {
+		// The rest of the checks assume that the structure is sound.
+		return v.results
+	}
+
+	v.verifyDefinitionsAndUsages()
+	v.verifyControlFlow()
+	if ssa && v.results.IsEmpty() {
+		v.verifySingleDefinitions()
+		v.verifyDominance()
+	}
+
+	return v.results
+}
+
+// VerifyFile checks the internal invariants of all functions in the file.
+// See VerifyFunction.
+func VerifyFile(file *gen.FileInfo, ssa bool) core.ResultList {
+	results := core.ResultList{}
+
+	for _, function := range file.Functions {
+		if function.FileInfo != file {
+			results.Append(core.Result{{
+				Type: core.InternalErrorResult,
+				Message: fmt.Sprintf(
+					"Verification of function \"%s\" failed: the function does not point to its file",
+					function.Name,
+				),
+			}})
+		}
+
+		curResults := VerifyFunction(function, ssa)
+		results.Extend(&curResults)
+	}
+
+	return results
+}
+
+func TransformFileVerify(
+	data *transform.TargetData,
+) (*transform.TargetData, core.ResultList) {
+	return data, VerifyFile(data.Code, false)
+}
+
+func TransformFileVerifySsa(
+	data *transform.TargetData,
+) (*transform.TargetData, core.ResultList) {
+	return data, VerifyFile(data.Code, true)
+}
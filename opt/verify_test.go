@@ -0,0 +1,201 @@
+package opt_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/opt"
+	usmopt "alon.kr/x/usm/usm/opt"
+	usmssa "alon.kr/x/usm/usm/ssa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const verifyTestSource = `func $32 @f $32 %a {
+.entry
+	jz %a .else
+.then
+	$32 %x = add %a $32 #1
+	j .end
+.else
+	$32 %y = sub %a $32 #1
+.end
+	$32 %z = phi .then %x .else %y
+	ret %z
+}
+`
+
+func generateVerifyTestFunction(t *testing.T, source string) *gen.FunctionInfo {
+	t.Helper()
+
+	function := generateFileInfo(t, source).GetFunction("@f")
+	require.NotNil(t, function)
+	return function
+}
+
+func resultMessages(results core.ResultList) string {
+	messages := ""
+	for _, result := range results.ToSlice() {
+		messages += result[0].Message + "\n"
+	}
+	return messages
+}
+
+func assertVerificationFails(t *testing.T, function *gen.FunctionInfo, ssa bool) {
+	t.Helper()
+
+	results := opt.VerifyFunction(function, ssa)
+	require.False(t, results.IsEmpty(), "Verification should fail")
+
+	for _, result := range results.ToSlice() {
+		assert.Equal(t, core.InternalErrorResult, result[0].Type)
+	}
+}
+
+func TestVerifyGeneratedFunction(t *testing.T) {
+	function := generateVerifyTestFunction(t, verifyTestSource)
+
+	results := opt.VerifyFunction(function, false)
+	assert.True(t, results.IsEmpty())
+
+	results = opt.VerifyFunction(function, true)
+	assert.True(t, results.IsEmpty())
+}
+
+// Runs the optimization pipeline on the inputs of all optimization tests, and
+// verifies the representation between each two passes.
+func TestVerifyBetweenPasses(t *testing.T) {
+	scheme := usmopt.NewConstantPropagationScheme()
+	passes := []struct {
+		name string
+		pass func(*gen.FunctionInfo) core.ResultList
+		ssa  bool
+	}{
+		{"ssa", usmssa.FunctionToSsaForm, true},
+		{"dce", opt.DeadCodeElimination, true},
+		{"sccp", func(function *gen.FunctionInfo) core.ResultList {
+			return opt.ConstantPropagation(function, scheme)
+		}, true},
+		{"dce", opt.DeadCodeElimination, true},
+		{"out-of-ssa", usmssa.FunctionOutOfSsaForm, false},
+	}
+
+	testPaths, err := filepath.Glob(filepath.Join("testdata", "*", "*.usm"))
+	require.NoError(t, err)
+	require.NotEmpty(t, testPaths)
+
+	for _, testPath := range testPaths {
+		t.Run(testPath, func(t *testing.T) {
+			source := readSourceFile(t, testPath)
+			if strings.Contains(source, "phi") {
+				// SSA construction renames the arguments of existing phi
+				// instructions as uses at the start of their block, and not at
+				// the end of the matching predecessors.
+				t.Skip("SSA construction does not support existing phi instructions")
+			}
+
+			file := generateFileInfo(t, source)
+			function := file.GetFunction(InputFuncName)
+			require.NotNil(t, function)
+
+			results := opt.VerifyFunction(function, false)
+			require.True(t, results.IsEmpty(), "Verification failed after generation")
+
+			for _, pass := range passes {
+				results = pass.pass(function)
+				require.True(t, results.IsEmpty(), "Pass \"%s\" failed", pass.name)
+
+				results = opt.VerifyFunction(function, pass.ssa)
+				require.True(
+					t,
+					results.IsEmpty(),
+					"Verification failed after \"%s\":\n%s\n%s",
+					pass.name,
+					resultMessages(results),
+					function.String(),
+				)
+			}
+		})
+	}
+}
+
+func TestVerifyInstructionBasicBlock(t *testing.T) {
+	function := generateVerifyTestFunction(t, verifyTestSource)
+	function.EntryBlock.Instructions[0].BasicBlockInfo = function.EntryBlock.NextBlock
+	assertVerificationFails(t, function, false)
+}
+
+func TestVerifyUsages(t *testing.T) {
+	function := generateVerifyTestFunction(t, verifyTestSource)
+	register := function.Registers.GetRegister("%a")
+	register.Usages = register.Usages[1:]
+	assertVerificationFails(t, function, false)
+}
+
+func TestVerifyDefinitions(t *testing.T) {
+	function := generateVerifyTestFunction(t, verifyTestSource)
+	x := function.Registers.GetRegister("%x")
+	y := function.Registers.GetRegister("%y")
+	x.Definitions = append(x.Definitions, y.Definitions...)
+	assertVerificationFails(t, function, false)
+}
+
+func TestVerifyForwardEdges(t *testing.T) {
+	function := generateVerifyTestFunction(t, verifyTestSource)
+	entry := function.EntryBlock
+	entry.ForwardEdges = entry.ForwardEdges[:1]
+	assertVerificationFails(t, function, false)
+}
+
+func TestVerifyBackwardEdges(t *testing.T) {
+	function := generateVerifyTestFunction(t, verifyTestSource)
+	end := function.EntryBlock.NextBlock.NextBlock.NextBlock
+	end.BackwardEdges = append(end.BackwardEdges, function.EntryBlock)
+	assertVerificationFails(t, function, false)
+}
+
+func TestVerifySsaMultipleDefinitions(t *testing.T) {
+	source := `func $32 @f $32 %a {
+.entry
+	$32 %b = add %a $32 #1
+	$32 %b = add %b $32 #1
+	ret %b
+}
+`
+
+	function := generateVerifyTestFunction(t, source)
+	results := opt.VerifyFunction(function, false)
+	assert.True(t, results.IsEmpty())
+	assertVerificationFails(t, function, true)
+}
+
+func TestVerifySsaDominance(t *testing.T) {
+	source := `func $32 @f $32 %a {
+.entry
+	jz %a .end
+.then
+	$32 %b = add %a $32 #1
+.end
+	ret %b
+}
+`
+
+	function := generateVerifyTestFunction(t, source)
+	results := opt.VerifyFunction(function, false)
+	assert.True(t, results.IsEmpty())
+	assertVerificationFails(t, function, true)
+}
+
+func TestVerifySsaPhiLabels(t *testing.T) {
+	function := generateVerifyTestFunction(t, verifyTestSource)
+	end := function.EntryBlock.NextBlock.NextBlock.NextBlock
+	phi := end.Instructions[0]
+	phi.Arguments[0] = gen.NewLabelArgumentInfo(function.EntryBlock.Label)
+
+	results := opt.VerifyFunction(function, false)
+	assert.True(t, results.IsEmpty())
+	assertVerificationFails(t, function, true)
+}
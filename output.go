@@ -0,0 +1,76 @@
+package main
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"alon.kr/x/usm/transform"
+)
+
+// A special value of the output flag, which writes the output to stdout.
+const stdoutOutputFilepath = "-"
+
+var outputFilepath string
+
+// The permissions of output files that did not exist before.
+const defaultOutputFileMode fs.FileMode = 0o644
+
+// Returns the filepath that the output should be written to, which is the
+// output flag, if it is provided.
+//
+// Otherwise, the filepath is derived from the provided input filepath (without
+// its extension), unless the output target is the same as the input target,
+// in which case the output is written to stdout, and the input files are left
+// untouched.
+func resolveOutputFilepath(
+	cleanInputFilepath string,
+	inputTarget *transform.Target,
+	outputTarget *transform.Target,
+) string {
+	if outputFilepath != "" {
+		return outputFilepath
+	}
+
+	if outputTarget == inputTarget {
+		return stdoutOutputFilepath
+	}
+
+	return cleanInputFilepath + outputTarget.Extensions[0]
+}
+
+// Writes the output to the provided filepath atomically: the output is first
+// written to a temporary file in the same directory, which is then renamed
+// to the provided filepath. This way, if writing fails, an existing file is
+// left untouched, and a partially written file is never left behind.
+//
+// The permissions of an existing file are preserved.
+func writeFileAtomically(path string, write func(io.Writer) error) error {
+	mode := defaultOutputFileMode
+	if stat, err := os.Stat(path); err == nil {
+		mode = stat.Mode().Perm()
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	tempPath := temp.Name()
+	defer os.Remove(tempPath) // no-op if the file was renamed successfully.
+
+	err = write(temp)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tempPath, mode); err != nil {
+		return err
+	}
+
+	return os.Rename(tempPath, path)
+}
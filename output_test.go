@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"alon.kr/x/usm/transform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const outputTestSource = `func $64 @main $64 %a {
+.entry
+	$64 %b = add %a $64 #1
+	ret %b
+}
+`
+
+func dirEntryNames(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestWriteFileAtomically(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.usm")
+
+	err := writeFileAtomically(path, func(writer io.Writer) error {
+		_, err := io.WriteString(writer, "new")
+		return err
+	})
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(content))
+
+	stat, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, defaultOutputFileMode, stat.Mode().Perm())
+
+	assert.Equal(t, []string{"out.usm"}, dirEntryNames(t, dir))
+}
+
+func TestWriteFileAtomicallyFailureKeepsExistingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.usm")
+	require.NoError(t, os.WriteFile(path, []byte("old"), 0o644))
+
+	writeErr := errors.New("write failed")
+	err := writeFileAtomically(path, func(writer io.Writer) error {
+		io.WriteString(writer, "partial")
+		return writeErr
+	})
+	assert.ErrorIs(t, err, writeErr)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(content))
+
+	// The temporary file is removed.
+	assert.Equal(t, []string{"out.usm"}, dirEntryNames(t, dir))
+}
+
+func TestWriteFileAtomicallyPreservesPermissions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.sh")
+	require.NoError(t, os.WriteFile(path, []byte("old"), 0o600))
+	require.NoError(t, os.Chmod(path, 0o750))
+
+	err := writeFileAtomically(path, func(writer io.Writer) error {
+		_, err := io.WriteString(writer, "new")
+		return err
+	})
+	require.NoError(t, err)
+
+	stat, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0o750), stat.Mode().Perm())
+}
+
+func TestResolveOutputFilepath(t *testing.T) {
+	usm := &transform.Target{Names: []string{"usm"}, Extensions: []string{".usm"}}
+	gas := &transform.Target{Names: []string{"gas"}, Extensions: []string{".s"}}
+
+	assert.Equal(t, stdoutOutputFilepath, resolveOutputFilepath("dir/f", usm, usm))
+	assert.Equal(t, "dir/f.s", resolveOutputFilepath("dir/f", usm, gas))
+
+	outputFilepath = "out.usm"
+	t.Cleanup(func() { outputFilepath = "" })
+	assert.Equal(t, "out.usm", resolveOutputFilepath("dir/f", usm, usm))
+}
+
+// Redirects stdout to a file for the duration of the test, and returns a
+// function that returns everything that was written to it.
+func captureStdout(t *testing.T) func() string {
+	t.Helper()
+
+	file, err := os.CreateTemp(t.TempDir(), "stdout")
+	require.NoError(t, err)
+
+	stdout := os.Stdout
+	os.Stdout = file
+	t.Cleanup(func() {
+		os.Stdout = stdout
+		file.Close()
+	})
+
+	return func() string {
+		content, err := os.ReadFile(file.Name())
+		require.NoError(t, err)
+		return string(content)
+	}
+}
+
+func TestRunSameTargetWritesToStdout(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "f.usm")
+	require.NoError(t, os.WriteFile(path, []byte(outputTestSource), 0o644))
+
+	stdout := captureStdout(t)
+	Run(nil, []string{path, "dce"})
+
+	assert.Equal(t, outputTestSource, stdout())
+
+	// The input file is not rewritten, and no other file is created.
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, outputTestSource, string(content))
+	assert.Equal(t, []string{"f.usm"}, dirEntryNames(t, dir))
+}
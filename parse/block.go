@@ -48,7 +48,7 @@ type BlockParser[NodeT Node] struct {
 //
 // The node type may optionally implement commentAttachable; if it does,
 // leading comments are attached directly to each node. Otherwise they are
-// silently dropped (acceptable for blocks like type fields or immediates).
+// dropped, and Format refuses to format the source.
 func (p BlockParser[NodeT]) parseBlockNodes(v *TokenView) (nodes []NodeT, trailing []lex.Comment) {
 	for {
 		pending := v.consumeLeadingComments()
@@ -17,6 +17,23 @@ func (n FileNode) View() core.UnmanagedSourceView {
 	return core.NewFullUnmanagedSourceView()
 }
 
+// Merges the provided file nodes into a single node that holds all of their
+// declarations.
+//
+// The files should be parsed from views of the same source context (see
+// core.Sources), so the views of their nodes do not overlap.
+func MergeFileNodes(nodes ...FileNode) (merged FileNode) {
+	for _, node := range nodes {
+		merged.Functions = append(merged.Functions, node.Functions...)
+		merged.Types = append(merged.Types, node.Types...)
+		merged.Constants = append(merged.Constants, node.Constants...)
+		merged.Variables = append(merged.Variables, node.Variables...)
+		merged.TrailingComments = append(merged.TrailingComments, node.TrailingComments...)
+	}
+
+	return merged
+}
+
 func (n FileNode) countAllNodes() int {
 	return len(n.Functions) + len(n.Types) + len(n.Constants) + len(n.Variables)
 }
@@ -0,0 +1,85 @@
+package parse
+
+import (
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/lex"
+)
+
+// Returns the comments of the provided tokens, in source order.
+func collectComments(tokens []lex.Token) []lex.Comment {
+	comments := []lex.Comment{}
+	for _, token := range tokens {
+		if token.Type == lex.CommentToken {
+			comments = append(comments, lex.Comment{View: token.View})
+		}
+	}
+	return comments
+}
+
+// Tokenizes and parses the provided source, and returns its canonical form,
+// and the comments of the source.
+func formatOnce(view core.SourceView) (string, []lex.Comment, core.Result) {
+	tokens, err := lex.NewTokenizer().Tokenize(view)
+	if err != nil {
+		return "", nil, core.Result{{
+			Type:    core.ErrorResult,
+			Message: err.Error(),
+		}}
+	}
+
+	tknView := NewTokenView(tokens)
+	node, result := NewFileParser().Parse(&tknView)
+	if result != nil {
+		return "", nil, result
+	}
+
+	ctx := StringContext{SourceContext: view.Ctx()}
+	return node.String(&ctx), collectComments(tokens), nil
+}
+
+// Format returns the canonical form of the source code of a single file.
+//
+// Formatting never drops comments, and is idempotent: formatting the
+// canonical form of a source yields the same canonical form. If either of
+// these guarantees can't be met for the provided source, an error is
+// returned, and the source should be left as is.
+func Format(view core.SourceView) (string, core.Result) {
+	formatted, comments, result := formatOnce(view)
+	if result != nil {
+		return "", result
+	}
+
+	reformatted, formattedComments, result := formatOnce(core.NewSourceView(formatted))
+	if result != nil {
+		return "", core.Result{{
+			Type:    core.InternalErrorResult,
+			Message: "Formatted source can't be parsed: " + result[0].Message,
+		}}
+	}
+
+	ctx := view.Ctx()
+	formattedCtx := []rune(formatted)
+	for i, comment := range comments {
+		text := string(comment.View.Raw(ctx))
+		if i >= len(formattedComments) ||
+			string(formattedComments[i].View.Raw(formattedCtx)) != text {
+			return "", core.Result{{
+				Type:     core.ErrorResult,
+				Message:  "Comment can't be kept by the formatter",
+				Location: &comment.View,
+			}, {
+				Type:    core.HintResult,
+				Message: "Move the comment to its own line, before a declaration or an instruction",
+			}}
+		}
+	}
+
+	if reformatted != formatted {
+		return "", core.Result{{
+			Type:    core.InternalErrorResult,
+			Message: "Formatting is not idempotent",
+		}}
+	}
+
+	return formatted, nil
+}
@@ -0,0 +1,84 @@
+package parse_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/parse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatExamples(t *testing.T) {
+	paths := []string{}
+	err := filepath.WalkDir("../examples", func(path string, entry os.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() && filepath.Ext(path) == ".usm" {
+			paths = append(paths, path)
+		}
+		return err
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+
+			formatted, result := parse.Format(core.NewSourceView(string(data)))
+			require.Nil(t, result)
+
+			again, result := parse.Format(core.NewSourceView(formatted))
+			require.Nil(t, result)
+			assert.Equal(t, formatted, again)
+		})
+	}
+}
+
+func TestFormatKeepsComments(t *testing.T) {
+	src := "; top\nfunc   $32 @f { ; after brace\n  ret $32 #1 ; trailing\n ; before close\n}\n; end\n"
+	expected := "; top\nfunc $32 @f {\n\t; after brace\n\tret $32 #1 ; trailing\n\t; before close\n}\n\n; end\n"
+
+	formatted, result := parse.Format(core.NewSourceView(src))
+	require.Nil(t, result)
+	assert.Equal(t, expected, formatted)
+}
+
+func TestFormatKeepsTypeDeclarationComments(t *testing.T) {
+	src := "type $t { ; first\n ; field\n  .a $32 ; inline\n\t$8\n ; before close\n}\n"
+	expected := "type $t {\n\t; first\n\t; field\n\t.a $32 ; inline\n\t$8\n\t; before close\n}\n"
+
+	formatted, result := parse.Format(core.NewSourceView(src))
+	require.Nil(t, result)
+	assert.Equal(t, expected, formatted)
+}
+
+func TestFormatKeepsImmediateFieldComments(t *testing.T) {
+	src := "type $pair {\n\t.a $32\n\t.b $32\n}\n\n" +
+		"var @x $pair {\n .a #1 ; one\n ; before\n .b #2 ; two\n}\n\n" +
+		"var @y $32 ^2 { ; first\n\t#1\n}\n"
+	expected := "type $pair {\n\t.a $32\n\t.b $32\n}\n\n" +
+		"var @x $pair {\n\t.a #1 ; one\n\t; before\n\t.b #2 ; two\n}\n\n" +
+		"var @y $32 ^2 {\n\t; first\n\t#1\n}\n"
+
+	formatted, result := parse.Format(core.NewSourceView(src))
+	require.Nil(t, result)
+	assert.Equal(t, expected, formatted)
+}
+
+func TestFormatParseError(t *testing.T) {
+	_, result := parse.Format(core.NewSourceView("func"))
+	require.NotNil(t, result)
+	assert.Equal(t, core.ErrorResult, result[0].Type)
+}
+
+func TestFormatMoveInstruction(t *testing.T) {
+	src := "func @f {\n  $32 %a   =   $32 #1\n}"
+	expected := "func @f {\n\t$32 %a = $32 #1\n}\n"
+
+	formatted, result := parse.Format(core.NewSourceView(src))
+	require.Nil(t, result)
+	assert.Equal(t, expected, formatted)
+}
@@ -97,6 +97,15 @@ type ImmediateFieldNode struct {
 	// Field is nil if a label is not specified.
 	Label *LabelNode
 	Value ImmediateValueNode
+
+	// LeadingComments holds whole-line comments before this field.
+	LeadingComments []lex.Comment
+	// TrailingComment holds the inline comment on the same line, after the value.
+	TrailingComment *lex.Comment
+}
+
+func (n *ImmediateFieldNode) attachLeadingComments(c []lex.Comment) {
+	n.LeadingComments = c
 }
 
 func (n ImmediateFieldNode) View() core.UnmanagedSourceView {
@@ -114,11 +123,19 @@ func (n ImmediateFieldNode) stringLabel(ctx *StringContext) (s string) {
 	return
 }
 
+func (n ImmediateFieldNode) stringTrailingComment(ctx *StringContext) string {
+	if n.TrailingComment == nil {
+		return ""
+	}
+	return " " + string(n.TrailingComment.View.Raw(ctx.SourceContext))
+}
+
 func (n ImmediateFieldNode) String(ctx *StringContext) string {
 	prefix := strings.Repeat("\t", ctx.Indent)
 	label := n.stringLabel(ctx)
 	value := n.Value.String(ctx)
-	return prefix + label + value + "\n"
+	return ctx.renderComments(n.LeadingComments) +
+		prefix + label + value + n.stringTrailingComment(ctx) + "\n"
 }
 
 type ImmediateFieldParser struct {
@@ -144,6 +161,7 @@ func (p ImmediateFieldParser) Parse(v *TokenView) (node ImmediateFieldNode, err
 	}
 
 	node.Value = val
+	node.TrailingComment = v.consumeTrailingComment()
 	return
 }
 
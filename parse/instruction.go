@@ -77,10 +77,17 @@ func (n InstructionNode) stringTrailingComment(ctx *StringContext) string {
 }
 
 func (n InstructionNode) String(ctx *StringContext) string {
+	operator := string(n.Operator.Raw(ctx.SourceContext))
+	rest := n.stringArguments(ctx) + n.stringTrailingComment(ctx)
+	if operator == "" {
+		// The operator of a move instruction is empty, so the separating
+		// space before the arguments is not needed.
+		rest = strings.TrimPrefix(rest, " ")
+	}
+
 	return ctx.renderComments(n.LeadingComments) +
 		n.stringLabels(ctx) +
-		ctx.indent() + n.stringTargets(ctx) + string(n.Operator.Raw(ctx.SourceContext)) +
-		n.stringArguments(ctx) + n.stringTrailingComment(ctx) + "\n"
+		ctx.indent() + n.stringTargets(ctx) + operator + rest + "\n"
 }
 
 type InstructionParser struct {
@@ -9,11 +9,38 @@ import (
 
 type TypeDeclarationNode struct {
 	core.UnmanagedSourceView
-	Identifier      core.UnmanagedSourceView
-	Fields          BlockNode[TypeFieldNode]
+	Identifier core.UnmanagedSourceView
+	Fields     BlockNode[TypeFieldNode]
+
+	// The signature of a function type, or nil if the declaration declares a
+	// type with fields.
+	Signature       *TypeSignatureNode
 	LeadingComments []lex.Comment
 }
 
+// The signature of a function type, declared in the form
+// "type $name <returns> = func <parameters>". For example:
+//
+//	type $binop $32 = func $32 $32
+type TypeSignatureNode struct {
+	core.UnmanagedSourceView
+	Returns    []TypeNode
+	Parameters []TypeNode
+}
+
+func (n TypeSignatureNode) String(ctx *StringContext) (s string) {
+	for _, ret := range n.Returns {
+		s += ret.String(ctx) + " "
+	}
+
+	s += "= func"
+	for _, param := range n.Parameters {
+		s += " " + param.String(ctx)
+	}
+
+	return
+}
+
 func (n TypeDeclarationNode) View() core.UnmanagedSourceView {
 	return n.UnmanagedSourceView
 }
@@ -24,6 +51,10 @@ func (n *TypeDeclarationNode) attachLeadingComments(c []lex.Comment) {
 
 func (n TypeDeclarationNode) String(ctx *StringContext) string {
 	id := string(n.Identifier.Raw(ctx.SourceContext))
+	if n.Signature != nil {
+		return ctx.renderComments(n.LeadingComments) + "type " + id + " " + n.Signature.String(ctx)
+	}
+
 	fields := n.Fields.String(ctx)
 	return ctx.renderComments(n.LeadingComments) + "type " + id + " " + fields
 }
@@ -32,6 +63,7 @@ func (n TypeDeclarationNode) String(ctx *StringContext) string {
 
 type TypeDeclarationParser struct {
 	FieldsParser BlockParser[TypeFieldNode]
+	TypeParser   TypeParser
 }
 
 func NewTypeDeclarationParser() TypeDeclarationParser {
@@ -39,6 +71,7 @@ func NewTypeDeclarationParser() TypeDeclarationParser {
 		FieldsParser: BlockParser[TypeFieldNode]{
 			Parser: NewTypeFieldParser(),
 		},
+		TypeParser: NewTypeParser(),
 	}
 }
 
@@ -72,6 +105,37 @@ func (p TypeDeclarationParser) parseBlock(v *TokenView, node *TypeDeclarationNod
 	return
 }
 
+func (p TypeDeclarationParser) parseSignature(v *TokenView, node *TypeDeclarationNode) (err core.Result) {
+	signature := &TypeSignatureNode{}
+	signature.Returns = ParseMany(p.TypeParser, v)
+
+	eq, err := v.ConsumeToken(lex.EqualToken)
+	if err != nil {
+		return
+	}
+
+	kw, err := v.ConsumeToken(lex.FuncKeywordToken)
+	if err != nil {
+		return
+	}
+
+	signature.Parameters = ParseMany(p.TypeParser, v)
+
+	signature.Start = eq.View.Start
+	if len(signature.Returns) > 0 {
+		signature.Start = signature.Returns[0].View().Start
+	}
+
+	signature.End = kw.View.End
+	if len(signature.Parameters) > 0 {
+		signature.End = signature.Parameters[len(signature.Parameters)-1].View().End
+	}
+
+	node.Signature = signature
+	node.End = signature.End
+	return
+}
+
 func (p TypeDeclarationParser) Parse(v *TokenView) (node TypeDeclarationNode, err core.Result) {
 	err = p.parseTypeKeyword(v, &node)
 	if err != nil {
@@ -83,6 +147,13 @@ func (p TypeDeclarationParser) Parse(v *TokenView) (node TypeDeclarationNode, er
 		return
 	}
 
+	// A type declaration that does not continue with a block of fields is
+	// a function type declaration.
+	if _, peekErr := v.PeekToken(lex.LeftCurlyBraceToken); peekErr != nil {
+		err = p.parseSignature(v, &node)
+		return
+	}
+
 	err = p.parseBlock(v, &node)
 	return
 }
@@ -54,6 +54,22 @@ func TestTypeDeclarationLongOneLine(t *testing.T) {
 	testExpectedTypeDeclaration(t, src, expected)
 }
 
+func TestTypeDeclarationSignature(t *testing.T) {
+	src := "type $binop $32 = func $32 $32"
+	testExpectedTypeDeclaration(t, src, src)
+}
+
+func TestTypeDeclarationSignatureWithoutReturns(t *testing.T) {
+	src := "type $callback   =  func $8 * $64"
+	expected := "type $callback = func $8 * $64"
+	testExpectedTypeDeclaration(t, src, expected)
+}
+
+func TestTypeDeclarationSignatureWithoutParameters(t *testing.T) {
+	src := "type $thunk $64 $64 = func"
+	testExpectedTypeDeclaration(t, src, src)
+}
+
 // MARK: Helpers
 
 func testExpectedTypeDeclaration(t *testing.T, src, expected string) {
@@ -4,11 +4,21 @@ import (
 	"strings"
 
 	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/lex"
 )
 
 type TypeFieldNode struct {
 	Type   TypeNode
 	Labels []LabelNode
+
+	// LeadingComments holds whole-line comments before this field.
+	LeadingComments []lex.Comment
+	// TrailingComment holds the inline comment on the same line, after the type.
+	TrailingComment *lex.Comment
+}
+
+func (n *TypeFieldNode) attachLeadingComments(c []lex.Comment) {
+	n.LeadingComments = c
 }
 
 func (n TypeFieldNode) View() core.UnmanagedSourceView {
@@ -29,11 +39,19 @@ func (n TypeFieldNode) stringLabels(ctx *StringContext) (s string) {
 	return
 }
 
+func (n TypeFieldNode) stringTrailingComment(ctx *StringContext) string {
+	if n.TrailingComment == nil {
+		return ""
+	}
+	return " " + string(n.TrailingComment.View.Raw(ctx.SourceContext))
+}
+
 func (n TypeFieldNode) String(ctx *StringContext) (s string) {
 	prefix := strings.Repeat("\t", ctx.Indent)
 	labels := n.stringLabels(ctx)
 	typ := n.Type.String(ctx)
-	return prefix + labels + typ + "\n"
+	return ctx.renderComments(n.LeadingComments) +
+		prefix + labels + typ + n.stringTrailingComment(ctx) + "\n"
 }
 
 type TypeFieldParser struct {
@@ -50,5 +68,10 @@ func NewTypeFieldParser() Parser[TypeFieldNode] {
 func (p TypeFieldParser) Parse(v *TokenView) (node TypeFieldNode, err core.Result) {
 	node.Labels, _ = ParseManyIgnoreSeparators(p.LabelParser, v)
 	node.Type, err = p.TypeParser.Parse(v)
+	if err != nil {
+		return
+	}
+
+	node.TrailingComment = v.consumeTrailingComment()
 	return
 }
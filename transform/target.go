@@ -2,7 +2,9 @@ package transform
 
 import (
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
 	"alon.kr/x/faststringmap"
 	"alon.kr/x/list"
@@ -37,6 +39,10 @@ type Target struct {
 	// If nil, assumes that the target cannot be generated from a USM source.
 	GenerationContext *gen.GenerationContext
 
+	// True if the data of this target is a binary artifact, which is not
+	// human readable (for example, an object file).
+	Binary bool
+
 	Transformations TransformationCollection
 }
 
@@ -118,17 +124,120 @@ func (c *TargetCollection) Traverse(
 	return
 }
 
+// Finds a shortest chain of transformations that transforms the start target
+// to the end target. If there are multiple shortest chains, the one that uses
+// the transformations that are declared first is returned.
+//
+// Transformations that do not change the target (for example, optimizations)
+// are never a part of the returned chain.
+func (c *TargetCollection) Route(
+	start *Target,
+	end *Target,
+) (transformations []*Transformation, results core.ResultList) {
+	type step struct {
+		previous       *Target
+		transformation *Transformation
+	}
+
+	// Breadth first search, where each target is visited once, and the step
+	// that first reached it is recorded.
+	steps := map[*Target]step{start: {}}
+	queue := []*Target{start}
+
+	for len(queue) > 0 && queue[0] != end {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, transformation := range current.Transformations.Transformations {
+			next, ok := c.NameToTarget.LookupString(transformation.TargetName)
+			if !ok {
+				return nil, list.FromSingle(core.Result{
+					{
+						Type: core.InternalErrorResult,
+						Message: fmt.Sprintf(
+							"Target \"%s\" does not exist",
+							transformation.TargetName,
+						),
+					},
+				})
+			}
+
+			if _, visited := steps[next]; !visited {
+				steps[next] = step{previous: current, transformation: transformation}
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	if _, reached := steps[end]; !reached {
+		reachable := []string{}
+		for _, target := range c.Targets {
+			if _, ok := steps[target]; ok && target != start {
+				reachable = append(reachable, target.Names[0])
+			}
+		}
+
+		hint := "No other targets are reachable from it"
+		if len(reachable) > 0 {
+			hint = "Reachable targets: " + strings.Join(reachable, ", ")
+		}
+
+		return nil, list.FromSingle(core.Result{
+			{
+				Type: core.ErrorResult,
+				Message: fmt.Sprintf(
+					"Target \"%s\" is not reachable from target \"%s\"",
+					end.Names[0],
+					start.Names[0],
+				),
+			},
+			{
+				Type:    core.HintResult,
+				Message: hint,
+			},
+		})
+	}
+
+	for target := end; target != start; target = steps[target].previous {
+		transformations = append(transformations, steps[target].transformation)
+	}
+
+	slices.Reverse(transformations)
+	return transformations, core.ResultList{}
+}
+
+// Called after each transformation in a chain is applied, with the index of
+// the transformation in the chain, its output, and the time it took.
+type TransformationObserver func(
+	index int,
+	transformation *Transformation,
+	data *TargetData,
+	duration time.Duration,
+)
+
 func (c *TargetCollection) Transform(
 	data *TargetData,
 	transformationNames []string,
+) (end *TargetData, results core.ResultList) {
+	return c.TransformWithObserver(data, transformationNames, nil)
+}
+
+// Same as Transform, but calls the provided observer (if not nil) after each
+// transformation in the chain is applied successfully.
+func (c *TargetCollection) TransformWithObserver(
+	data *TargetData,
+	transformationNames []string,
+	observer TransformationObserver,
 ) (end *TargetData, results core.ResultList) {
 	transformations, _, results := c.Traverse(data.Target, transformationNames)
 	if !results.IsEmpty() {
 		return nil, results
 	}
 
-	for _, transformation := range transformations {
+	for i, transformation := range transformations {
+		start := time.Now()
 		data, results = transformation.Transform(data)
+		duration := time.Since(start)
 		if !results.IsEmpty() {
 			return nil, results
 		}
@@ -148,6 +257,10 @@ func (c *TargetCollection) Transform(
 		}
 
 		data.Target = target
+
+		if observer != nil {
+			observer(i, transformation, data, duration)
+		}
 	}
 
 	return data, core.ResultList{}
@@ -2,6 +2,7 @@ package transform
 
 import (
 	"bytes"
+	"encoding/hex"
 	"io"
 
 	"alon.kr/x/usm/gen"
@@ -28,12 +29,28 @@ func NewTargetData(target *Target, code *gen.FileInfo) *TargetData {
 	}
 }
 
+// Writes the target data to the provided writer.
+// The artifact buffer is not consumed, so the data can be written more than
+// once.
 func (d *TargetData) WriteTo(writer io.Writer) (int64, error) {
 	if d.Artifact != nil {
-		return d.Artifact.WriteTo(writer)
+		n, err := writer.Write(d.Artifact.Bytes())
+		return int64(n), err
 	}
 
 	str := d.Code.String()
 	n, err := writer.Write([]byte(str))
 	return int64(n), err
 }
+
+// Writes a hex dump of the target data to the provided writer, in the format
+// of `hexdump -C`.
+func (d *TargetData) WriteHexTo(writer io.Writer) (int64, error) {
+	buffer := bytes.Buffer{}
+	if _, err := d.WriteTo(&buffer); err != nil {
+		return 0, err
+	}
+
+	n, err := io.WriteString(writer, hex.Dump(buffer.Bytes()))
+	return int64(n), err
+}
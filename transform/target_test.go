@@ -0,0 +1,158 @@
+package transform_test
+
+import (
+	"testing"
+	"time"
+
+	"alon.kr/x/list"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/transform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func identityTransform(data *transform.TargetData) (*transform.TargetData, core.ResultList) {
+	return data, core.ResultList{}
+}
+
+func newTestTargets() *transform.TargetCollection {
+	return transform.NewTargetCollection(
+		&transform.Target{
+			Names: []string{"a"},
+			Transformations: *transform.NewTransformationCollection(
+				&transform.Transformation{Names: []string{"opt"}, TargetName: "a", Transform: identityTransform},
+				&transform.Transformation{Names: []string{"a-to-b"}, TargetName: "b", Transform: identityTransform},
+				&transform.Transformation{Names: []string{"a-to-c"}, TargetName: "c", Transform: identityTransform},
+			),
+		},
+		&transform.Target{
+			Names: []string{"b"},
+			Transformations: *transform.NewTransformationCollection(
+				&transform.Transformation{Names: []string{"b-to-d"}, TargetName: "d", Transform: identityTransform},
+			),
+		},
+		&transform.Target{
+			Names: []string{"c"},
+			Transformations: *transform.NewTransformationCollection(
+				&transform.Transformation{Names: []string{"c-to-d"}, TargetName: "d", Transform: identityTransform},
+			),
+		},
+		&transform.Target{Names: []string{"d"}},
+	)
+}
+
+func lookupTarget(t *testing.T, targets *transform.TargetCollection, name string) *transform.Target {
+	target, ok := targets.NameToTarget.LookupString(name)
+	require.True(t, ok)
+	return target
+}
+
+func TestRouteShortestChain(t *testing.T) {
+	targets := newTestTargets()
+	a, d := lookupTarget(t, targets, "a"), lookupTarget(t, targets, "d")
+
+	transformations, results := targets.Route(a, d)
+	require.True(t, results.IsEmpty())
+
+	names := []string{}
+	for _, transformation := range transformations {
+		names = append(names, transformation.Names[0])
+	}
+
+	// Both chains are the shortest, so the first declared one is chosen.
+	assert.Equal(t, []string{"a-to-b", "b-to-d"}, names)
+}
+
+func TestRouteToSameTarget(t *testing.T) {
+	targets := newTestTargets()
+	a := lookupTarget(t, targets, "a")
+
+	transformations, results := targets.Route(a, a)
+	require.True(t, results.IsEmpty())
+	assert.Empty(t, transformations)
+}
+
+func TestRouteUnreachableTarget(t *testing.T) {
+	targets := newTestTargets()
+	a, b := lookupTarget(t, targets, "a"), lookupTarget(t, targets, "b")
+
+	_, results := targets.Route(b, a)
+	require.False(t, results.IsEmpty())
+
+	result := results.Head.Value
+	assert.Equal(t, core.ErrorResult, result[0].Type)
+	assert.Equal(t, "Target \"a\" is not reachable from target \"b\"", result[0].Message)
+	assert.Equal(t, "Reachable targets: d", result[1].Message)
+}
+
+func TestTransformWithObserver(t *testing.T) {
+	targets := newTestTargets()
+	a := lookupTarget(t, targets, "a")
+
+	type observation struct {
+		index          int
+		transformation string
+		target         string
+		data           *transform.TargetData
+	}
+
+	observations := []observation{}
+	observer := func(
+		index int,
+		transformation *transform.Transformation,
+		data *transform.TargetData,
+		duration time.Duration,
+	) {
+		assert.GreaterOrEqual(t, duration, time.Duration(0))
+		observations = append(observations, observation{
+			index:          index,
+			transformation: transformation.Names[0],
+			target:         data.Target.Names[0],
+			data:           data,
+		})
+	}
+
+	data := transform.NewTargetData(a, nil)
+	end, results := targets.TransformWithObserver(
+		data,
+		[]string{"opt", "a-to-c", "c-to-d"},
+		observer,
+	)
+	require.True(t, results.IsEmpty())
+
+	assert.Equal(t, []observation{
+		{0, "opt", "a", data},
+		{1, "a-to-c", "c", data},
+		{2, "c-to-d", "d", data},
+	}, observations)
+	assert.Same(t, end, data)
+}
+
+func TestTransformWithObserverStopsOnError(t *testing.T) {
+	failing := func(*transform.TargetData) (*transform.TargetData, core.ResultList) {
+		return nil, list.FromSingle(core.Result{{
+			Type:    core.ErrorResult,
+			Message: "Transformation failed",
+		}})
+	}
+
+	targets := transform.NewTargetCollection(
+		&transform.Target{
+			Names: []string{"a"},
+			Transformations: *transform.NewTransformationCollection(
+				&transform.Transformation{Names: []string{"opt"}, TargetName: "a", Transform: identityTransform},
+				&transform.Transformation{Names: []string{"fail"}, TargetName: "a", Transform: failing},
+			),
+		},
+	)
+
+	indices := []int{}
+	observer := func(index int, _ *transform.Transformation, _ *transform.TargetData, _ time.Duration) {
+		indices = append(indices, index)
+	}
+
+	data := transform.NewTargetData(lookupTarget(t, targets, "a"), nil)
+	_, results := targets.TransformWithObserver(data, []string{"opt", "fail", "opt"}, observer)
+	assert.False(t, results.IsEmpty())
+	assert.Equal(t, []int{0}, indices)
+}
@@ -14,6 +14,13 @@ type Transformation struct {
 	// The name of the target of this transformation.
 	TargetName string
 
+	// The name of a transformation that reverts this transformation, and
+	// should be applied before the code is transformed to another target.
+	// For example, code in SSA form must be converted out of it before it is
+	// lowered to a machine specific target.
+	// Empty if the transformation does not need to be reverted.
+	Revert string
+
 	Transform DoTransform
 }
 
@@ -1,90 +1,42 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"math/big"
 	"os"
+	"path/filepath"
 	"strings"
 
-	aarch64managers "alon.kr/x/usm/aarch64/managers"
-	aarch64translation "alon.kr/x/usm/aarch64/translation"
+	"alon.kr/x/usm/compiler"
 	"alon.kr/x/usm/core"
 	"alon.kr/x/usm/gen"
-	"alon.kr/x/usm/lex"
-	"alon.kr/x/usm/opt"
+	"alon.kr/x/usm/interpreter"
+	"alon.kr/x/usm/lsp"
 	"alon.kr/x/usm/parse"
 	"alon.kr/x/usm/transform"
-	usmmanagers "alon.kr/x/usm/usm/managers"
-	usmssa "alon.kr/x/usm/usm/ssa"
 	"github.com/spf13/cobra"
 )
 
-var targets = transform.NewTargetCollection(
-	&transform.Target{
-		Names:             []string{"usm"},
-		Extensions:        []string{".usm"},
-		Description:       "A universal assembly language",
-		GenerationContext: usmmanagers.NewGenerationContext(),
-		Transformations: *transform.NewTransformationCollection(
-			&transform.Transformation{
-				Names:       []string{"dead-code-elimination", "dce"},
-				Description: "An optimization pass that eliminates unnecessary instructions",
-				TargetName:  "usm",
-				Transform:   opt.TransformFileToDeadCodeElimination,
-			},
-			&transform.Transformation{
-				Names:       []string{"static-single-assignment", "ssa"},
-				Description: "An optimization pass that converts the assembly to static single assignment form",
-				TargetName:  "usm",
-				Transform:   usmssa.TransformFileToSsaForm,
-			},
-			&transform.Transformation{
-				Names:       []string{"aarch64", "arm64"},
-				Description: "Converts the universal assembly to matching machine specific AArch64 assembly",
-				TargetName:  "aarch64",
-				// Transform: ,
-			},
-		),
-	},
-
-	&transform.Target{
-		Names:             []string{"aarch64", "arm64"},
-		Extensions:        []string{".aarch64.usm", ".arm64.usm"},
-		Description:       "AArch64 (ARM64, ARMv8) assembly",
-		GenerationContext: aarch64managers.NewGenerationContext(),
-		Transformations: *transform.NewTransformationCollection(
-			&transform.Transformation{
-				Names:      []string{"macho", "macho-obj", "macho-object"},
-				TargetName: "aarch64-macho-object",
-				Transform:  aarch64translation.ToMachoObject,
-			},
-		),
-	},
-
-	&transform.Target{
-		Names: []string{
-			"aarch64-macho-object",
-			"aarch64-macho-obj",
-			"aarch64-macho",
-		},
-		Extensions:  []string{".o"},
-		Description: "Mach-O object file containing aarch64 assembly",
-	},
-)
-
-var inputFilepath string
+var targets = compiler.NewDefaultTargets()
 
-func printResultAndExit(sourceView core.SourceView, result core.Result) {
-	stringer := core.NewResultStringer(sourceView.Ctx(), inputFilepath)
-	fmt.Fprint(os.Stderr, stringer.StringResult(result))
-	os.Exit(1)
-}
-
-func printResultsAndExit(sourceView core.SourceView, results core.ResultList) {
-	stringer := core.NewResultStringer(sourceView.Ctx(), inputFilepath)
-	for result := range results.Range() {
-		fmt.Fprint(os.Stderr, stringer.StringResult(result))
+// Splits the command line arguments into the input files and the rest of the
+// arguments.
+//
+// The first argument is always an input file. It is followed by any number of
+// additional input files, which are recognized by having a filename of a known
+// target.
+func splitInputFilepaths(args []string) (filepaths []string, rest []string) {
+	i := 1
+	for i < len(args) {
+		if target, _ := targets.FilenameToTarget(args[i]); target == nil {
+			break
+		}
+		i++
 	}
-	os.Exit(1)
+
+	return args[:i], args[i:]
 }
 
 func ValidArgsFunction(
@@ -105,7 +57,8 @@ func ValidArgsFunction(
 		return targets.TransformationNames(), cobra.ShellCompDirectiveNoFileComp
 	}
 
-	_, end, results := targets.Traverse(start, args[1:])
+	_, transformationNames := splitInputFilepaths(args)
+	_, end, results := targets.Traverse(start, transformationNames)
 	if !results.IsEmpty() {
 		// Invalid transformation chain: just suggest all transformations.
 		return targets.TransformationNames(), cobra.ShellCompDirectiveNoFileComp
@@ -122,99 +75,406 @@ func Args(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func Run(cmd *cobra.Command, args []string) {
-	inputFilepath = args[0]
+// Determines the target of the input files from their filenames.
+// All input files should be of the same target.
+// On failure, the error is printed and the program exits.
+func inputFilepathsToTarget(filepaths []string) (
+	inputTarget *transform.Target,
+	inputExt string,
+) {
+	for i, filepath := range filepaths {
+		target, ext := targets.FilenameToTarget(filepath)
+		if target == nil {
+			printErrorAndExit(
+				ExitUsageError,
+				"Target type can't be determined from filename: %v",
+				filepath,
+			)
+		}
 
-	inputTarget, inputExt := targets.FilenameToTarget(inputFilepath)
-	if inputTarget == nil {
-		fmt.Fprintf(
-			os.Stderr,
-			"Target type can't be determined from filename: %v\n",
-			inputFilepath,
-		)
-		os.Exit(1)
+		if i == 0 {
+			inputTarget, inputExt = target, ext
+		} else if target != inputTarget {
+			printErrorAndExit(
+				ExitUsageError,
+				"All input files should be of the same target type: %v is of type %v, but %v is of type %v",
+				filepaths[0],
+				inputTarget.Names[0],
+				filepath,
+				target.Names[0],
+			)
+		}
 	}
 
-	ctx := inputTarget.GenerationContext
-	if ctx == nil {
-		fmt.Fprintf(
-			os.Stderr,
-			"Target type isn't supported as input: %v\n",
+	return inputTarget, inputExt
+}
+
+// Returns the compilation options that are set by the command line flags.
+func newCompilerOptions() compiler.Options {
+	return compiler.Options{
+		Targets:      targets,
+		OutputTarget: outputTargetName,
+	}
+}
+
+// Reads the input files, and generates their linked internal representation.
+// On failure, the errors are printed and the program exits.
+func generateInput(filepaths []string, inputTarget *transform.Target) (
+	sources core.Sources,
+	info *gen.FileInfo,
+) {
+	if inputTarget.GenerationContext == nil {
+		printErrorAndExit(
+			ExitUsageError,
+			"Target type isn't supported as input: %v",
 			inputTarget.Names[0],
 		)
-		os.Exit(1)
 	}
 
-	file, err := os.Open(inputFilepath)
+	sources, err := core.ReadSources(filepaths)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error opening file: %v\n", err)
-		os.Exit(1)
+		printErrorAndExit(ExitIOError, "Can't read source: %v", err)
 	}
-	defer file.Close()
 
-	view, err := core.ReadSource(file)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading source: %v\n", err)
-		os.Exit(1)
+	options := newCompilerOptions()
+	info, results := options.Generate(sources, inputTarget)
+	if !results.IsEmpty() {
+		printResultsAndExit(sources, results, ExitInputError)
 	}
 
-	lexResult, err := lex.NewTokenizer().Tokenize(view)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error tokenizing: %v\n", err)
-		os.Exit(1)
+	return sources, info
+}
+
+var outputTargetName string
+var verbose bool
+
+// Returns the transformations of the provided chain, followed by a shortest
+// chain of transformations to the output target, if one is requested.
+// Exits if the chain is invalid, or if the output target is unreachable.
+func resolveTransformationChain(start *transform.Target, transformationNames []string) (
+	[]*transform.Transformation,
+	[]string,
+) {
+	options := newCompilerOptions()
+	transformations, transformationNames, results := options.ResolveChain(
+		start,
+		transformationNames,
+	)
+	if !results.IsEmpty() {
+		printResultsAndExit(core.Sources{}, results, ExitUsageError)
+	}
+
+	if verbose {
+		printTransformationChain(start, transformations)
 	}
 
-	tknView := parse.NewTokenView(lexResult)
-	node, result := parse.NewFileParser().Parse(&tknView)
-	if result != nil {
-		printResultAndExit(view, result)
+	return transformations, transformationNames
+}
+
+func printTransformationChain(start *transform.Target, transformations []*transform.Transformation) {
+	fmt.Fprintf(os.Stderr, "Transformation chain from \"%s\":\n", start.Names[0])
+	if len(transformations) == 0 {
+		fmt.Fprintln(os.Stderr, "  (empty)")
 	}
 
-	generator := gen.NewFileGenerator()
-	info, results := generator.Generate(ctx, view.Ctx(), node)
+	current := start
+	for _, transformation := range transformations {
+		next, _ := targets.NameToTarget.LookupString(transformation.TargetName)
+		fmt.Fprintf(
+			os.Stderr,
+			"  %s: %s -> %s\n",
+			transformation.Names[0],
+			current.Names[0],
+			next.Names[0],
+		)
+		current = next
+	}
+}
+
+func Run(cmd *cobra.Command, args []string) {
+	inputFilepaths, transformationNames := splitInputFilepaths(args)
+	inputTarget, inputExt := inputFilepathsToTarget(inputFilepaths)
+
+	transformations, transformationNames := resolveTransformationChain(
+		inputTarget,
+		transformationNames,
+	)
+
+	results := validateDumpAfter(transformations)
 	if !results.IsEmpty() {
-		printResultsAndExit(view, results)
+		printResultsAndExit(core.Sources{}, results, ExitUsageError)
 	}
 
+	sources, info := generateInput(inputFilepaths, inputTarget)
+
+	// The output and dump filepaths are derived from the first input file.
+	cleanInputFilepath, _ := strings.CutSuffix(inputFilepaths[0], inputExt)
+	options := newCompilerOptions()
+	options.Observer = newDumpObserver(filepath.Base(cleanInputFilepath))
+
 	data := transform.NewTargetData(inputTarget, info)
-	transformationNames := args[1:]
-	data, results = targets.Transform(data, transformationNames)
+	data, results = options.Transform(data, transformationNames)
 	if !results.IsEmpty() {
-		printResultsAndExit(view, results)
+		printResultsAndExit(sources, results, ExitInputError)
+	}
+
+	path := resolveOutputFilepath(cleanInputFilepath, inputTarget, data.Target)
+	if path == stdoutOutputFilepath {
+		if _, err := data.WriteTo(os.Stdout); err != nil {
+			printErrorAndExit(ExitIOError, "Can't write to output file: %v", err)
+		}
+	} else {
+		err := writeFileAtomically(path, func(writer io.Writer) error {
+			_, err := data.WriteTo(writer)
+			return err
+		})
+		if err != nil {
+			printErrorAndExit(ExitIOError, "Can't write to output file: %v", err)
+		}
 	}
 
-	// TODO: if the transformation chain is empty, use the same output filepath
-	// as the input filepath by default. In general, if the input target is the
-	// same as the output target, use the same filepath.
+	printSuccess(sources)
+}
+
+var entryFunctionName string
 
-	outputTarget := data.Target
-	cleanInputFilepath, _ := strings.CutSuffix(inputFilepath, inputExt)
-	outputFilepath := cleanInputFilepath + outputTarget.Extensions[0]
+func Interpret(cmd *cobra.Command, args []string) {
+	inputFilepaths, rest := splitInputFilepaths(args)
+	inputTarget, _ := inputFilepathsToTarget(inputFilepaths)
 
-	outputFile, err := os.Create(outputFilepath)
+	arguments := make([]*big.Int, len(rest))
+	for i, arg := range rest {
+		value, ok := new(big.Int).SetString(arg, 0)
+		if !ok {
+			printErrorAndExit(ExitUsageError, "Invalid integer argument: %v", arg)
+		}
+		arguments[i] = value
+	}
+
+	sources, info := generateInput(inputFilepaths, inputTarget)
+
+	functionName := entryFunctionName
+	if !strings.HasPrefix(functionName, "@") {
+		functionName = "@" + functionName
+	}
+
+	values, results := interpreter.NewInterpreter(info).Run(functionName, arguments)
+	if !results.IsEmpty() {
+		printResultsAndExit(sources, results, ExitInputError)
+	}
+
+	for _, value := range values {
+		fmt.Println(value)
+	}
+
+	printSuccess(sources)
+}
+
+var formatCheck bool
+var formatDiff bool
+
+// Formats the provided files in place. In check mode, the files that are not
+// formatted are listed instead, and in diff mode, the changes that formatting
+// would make are printed instead.
+func Format(cmd *cobra.Command, args []string) {
+	sources, err := core.ReadSources(args)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating output file: %v\n", err)
-		os.Exit(1)
+		printErrorAndExit(ExitIOError, "Can't read source: %v", err)
+	}
+
+	results := core.ResultList{}
+	unformatted := false
+
+	for _, file := range sources.Files {
+		formatted, result := parse.Format(sources.FileView(file))
+		if result != nil {
+			// Files that can't be formatted are left as is, and the rest of
+			// the files are still formatted.
+			file.ResolveEof(result)
+			results.Append(result)
+			continue
+		}
+
+		original := string(file.View.Raw(sources.Context))
+		if formatted == original {
+			continue
+		}
+
+		unformatted = true
+
+		if formatCheck {
+			fmt.Println(file.Filepath)
+		}
+
+		if formatDiff {
+			fmt.Print(core.UnifiedDiff(
+				file.Filepath+".orig",
+				file.Filepath,
+				original,
+				formatted,
+			))
+		}
+
+		if formatCheck || formatDiff {
+			continue
+		}
+
+		err := writeFileAtomically(file.Filepath, func(writer io.Writer) error {
+			_, err := io.WriteString(writer, formatted)
+			return err
+		})
+		if err != nil {
+			printErrorAndExit(ExitIOError, "Can't write to output file: %v", err)
+		}
+	}
+
+	if !results.IsEmpty() {
+		printResultsAndExit(sources, results, ExitInputError)
 	}
-	defer outputFile.Close()
 
-	if _, err := data.WriteTo(outputFile); err != nil {
-		fmt.Fprintf(os.Stderr, "Error writing to output file: %v\n", err)
-		os.Exit(1)
+	printSuccess(sources)
+	if formatCheck && unformatted {
+		os.Exit(ExitInputError)
+	}
+}
+
+func Lsp(cmd *cobra.Command, args []string) {
+	err := lsp.NewServer(targets).Serve(os.Stdin, os.Stdout)
+	if errors.Is(err, lsp.ErrExitWithoutShutdown) {
+		// The protocol requires exit code 1 in this case.
+		os.Exit(ExitInputError)
+	} else if err != nil {
+		printErrorAndExit(ExitIOError, "Language server communication failed: %v", err)
 	}
 }
 
 func main() {
 	rootCmd := &cobra.Command{
-		Use:               "usm <input_file> [transformation...]",
+		Use:               "usm <input_file...> [transformation...]",
 		Short:             "One Universal assembly language to rule them all.",
+		Long:              "One Universal assembly language to rule them all.\n\n" + exitCodesHelp,
 		ValidArgsFunction: ValidArgsFunction,
 		Args:              Args,
 		Run:               Run,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return validateDiagnosticsFormat()
+		},
 	}
 
+	rootCmd.PersistentFlags().StringVar(
+		&diagnosticsFormat,
+		"diagnostics-format",
+		textDiagnosticsFormat,
+		"the format of reported diagnostics: text, json or sarif",
+	)
+
+	rootCmd.Flags().StringVarP(
+		&outputFilepath,
+		"output",
+		"o",
+		"",
+		"the output file, or \"-\" for stdout (default derived from the first input file)",
+	)
+	rootCmd.Flags().StringVar(
+		&outputTargetName,
+		"to",
+		"",
+		"append a shortest chain of transformations to the provided target",
+	)
+	rootCmd.Flags().BoolVarP(
+		&verbose,
+		"verbose",
+		"v",
+		false,
+		"print the transformation chain",
+	)
+	rootCmd.RegisterFlagCompletionFunc(
+		"to",
+		func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+			names := []string{}
+			for _, target := range targets.Targets {
+				names = append(names, target.Names...)
+			}
+			return names, cobra.ShellCompDirectiveNoFileComp
+		},
+	)
+
+	rootCmd.Flags().StringSliceVar(
+		&dumpAfter,
+		"dump-after",
+		nil,
+		"dump the intermediate target data after the provided transformations, or after \"all\" of them",
+	)
+	rootCmd.Flags().StringVar(
+		&dumpDir,
+		"dump-dir",
+		"",
+		"the directory to write dumps to (default stderr)",
+	)
+	rootCmd.Flags().BoolVar(
+		&dumpHex,
+		"dump-hex",
+		false,
+		"dump binary artifacts as hex instead of skipping them",
+	)
+
+	runCmd := &cobra.Command{
+		Use:   "run [--function @name] <input_file...> [argument...]",
+		Short: "Interpret a function of the input file, and print the returned values.",
+		Long:  "Interpret a function of the input file, and print the returned values.\n\n" + exitCodesHelp,
+		Args:  Args,
+		Run:   Interpret,
+	}
+
+	// Flags must appear before the input file, so negative integer arguments
+	// are not parsed as flags.
+	runCmd.Flags().SetInterspersed(false)
+	runCmd.Flags().StringVarP(
+		&entryFunctionName,
+		"function",
+		"f",
+		"@main",
+		"the function to execute",
+	)
+
+	formatCmd := &cobra.Command{
+		Use:   "fmt [--check] [--diff] <input_file...>",
+		Short: "Rewrite the input files in the canonical format.",
+		Long: "Rewrite the input files in the canonical format.\n\n" +
+			"Formatting keeps all comments, and formatting a formatted file does not\n" +
+			"change it. Files that can't be formatted are reported and left as is.\n\n" +
+			"In check mode, a non-zero exit code indicates that some of the files\n" +
+			"are not formatted.\n\n" + exitCodesHelp,
+		Args: cobra.MinimumNArgs(1),
+		Run:  Format,
+	}
+
+	formatCmd.Flags().BoolVar(
+		&formatCheck,
+		"check",
+		false,
+		"list the files that are not formatted instead of rewriting them",
+	)
+	formatCmd.Flags().BoolVar(
+		&formatDiff,
+		"diff",
+		false,
+		"print the changes as a unified diff instead of rewriting the files",
+	)
+
+	lspCmd := &cobra.Command{
+		Use:   "lsp",
+		Short: "Start a language server, which communicates over stdin and stdout.",
+		Args:  cobra.NoArgs,
+		Run:   Lsp,
+	}
+
+	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(formatCmd)
+	rootCmd.AddCommand(lspCmd)
+
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(ExitUsageError)
 	}
 }
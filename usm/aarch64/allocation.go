@@ -0,0 +1,277 @@
+package usmaarch64
+
+import (
+	"fmt"
+	"slices"
+
+	"alon.kr/x/list"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/opt"
+	usmisa "alon.kr/x/usm/usm/isa"
+)
+
+// The maximal offset (in bytes) from the stack pointer that can be encoded in
+// a single 64 bit "ldr" or "str" instruction.
+const maxStackOffset = 8 * 4095
+
+// liveInterval is the range of (linear) instruction positions in which a usm
+// register holds a value that may be used later.
+type liveInterval struct {
+	Register *gen.RegisterInfo
+	Start    int
+	End      int
+
+	// True if the register holds a value across a call instruction, and thus
+	// must be allocated to a callee saved register (or spilled).
+	CrossesCall bool
+
+	// The AArch64 register that is assigned to the interval, or an empty
+	// string if the interval is spilled.
+	Allocated string
+}
+
+func (i *liveInterval) extend(position int) {
+	i.Start = min(i.Start, position)
+	i.End = max(i.End, position)
+}
+
+// MARK: Intervals
+
+// Computes the live interval of each register in the function.
+//
+// Instructions are numbered linearly, in the order of the function basic
+// blocks. Each instruction gets an even position, which leaves room to mark
+// registers that are live at the entry or exit of a basic block, without
+// them appearing to start or end exactly at the first or last instruction of
+// the block. The function parameters are defined at position 0.
+func computeLiveIntervals(function *gen.FunctionInfo) []*liveInterval {
+	liveness := opt.NewFunctionLivenessInfo(function)
+	registers := collectRegistersInOrder(function)
+
+	intervals := make([]*liveInterval, len(registers))
+	registerToInterval := make(map[*gen.RegisterInfo]*liveInterval, len(registers))
+	for i, register := range registers {
+		intervals[i] = &liveInterval{Register: register, Start: -1, End: -1}
+		registerToInterval[register] = intervals[i]
+	}
+
+	extend := func(register *gen.RegisterInfo, position int) {
+		interval := registerToInterval[register]
+		if interval.Start < 0 {
+			interval.Start, interval.End = position, position
+		} else {
+			interval.extend(position)
+		}
+	}
+
+	for _, parameter := range function.Parameters {
+		extend(parameter, 0)
+	}
+
+	calls := []int{}
+	position := 2
+	for _, block := range liveness.BasicBlocks {
+		if len(block.Instructions) == 0 {
+			continue
+		}
+
+		first := position
+		for _, instruction := range block.Instructions {
+			for _, register := range gen.ArgumentsToRegisters(instruction.Arguments) {
+				extend(register, position)
+			}
+
+			for _, register := range gen.TargetsToRegisters(instruction.Targets) {
+				extend(register, position)
+			}
+
+			if _, ok := instruction.Definition.(usmisa.Call); ok {
+				calls = append(calls, position)
+			}
+
+			position += 2
+		}
+		last := position - 2
+
+		for _, register := range registers {
+			if liveness.IsLiveIn(block, register) {
+				extend(register, first-1)
+			}
+
+			if liveness.IsLiveOut(block, register) {
+				extend(register, last+1)
+			}
+		}
+	}
+
+	for _, interval := range intervals {
+		for _, call := range calls {
+			if interval.Start < call && call < interval.End {
+				interval.CrossesCall = true
+				break
+			}
+		}
+	}
+
+	slices.SortStableFunc(intervals, func(a, b *liveInterval) int {
+		return a.Start - b.Start
+	})
+
+	return intervals
+}
+
+// MARK: Linear Scan
+
+// linearScan holds the state of the linear scan register allocation
+// algorithm.
+type linearScan struct {
+	// The intervals that are currently assigned to a register, ordered by
+	// their end position.
+	active []*liveInterval
+
+	// The intervals that are assigned to a stack slot, in the order in which
+	// they were spilled.
+	spilled []*liveInterval
+
+	// The registers that are currently not assigned to any active interval.
+	free map[string]bool
+}
+
+func newLinearScan() *linearScan {
+	free := make(map[string]bool)
+	for _, name := range temporaryRegisterNames {
+		free[name] = true
+	}
+
+	for _, name := range calleeSavedRegisterNames {
+		free[name] = true
+	}
+
+	return &linearScan{free: free}
+}
+
+// Removes the intervals that end before the provided position from the active
+// list, and frees their registers.
+func (s *linearScan) expire(position int) {
+	active := s.active[:0]
+	for _, interval := range s.active {
+		if interval.End < position {
+			s.free[interval.Allocated] = true
+		} else {
+			active = append(active, interval)
+		}
+	}
+	s.active = active
+}
+
+// Returns the names of the registers that may be assigned to the provided
+// interval, in the order of preference.
+func allowedRegisterNames(interval *liveInterval) []string {
+	if interval.CrossesCall {
+		return calleeSavedRegisterNames
+	}
+
+	return slices.Concat(temporaryRegisterNames, calleeSavedRegisterNames)
+}
+
+func (s *linearScan) activate(interval *liveInterval, register string) {
+	interval.Allocated = register
+	s.free[register] = false
+
+	index, _ := slices.BinarySearchFunc(s.active, interval, func(a, b *liveInterval) int {
+		return a.End - b.End
+	})
+	s.active = slices.Insert(s.active, index, interval)
+}
+
+func (s *linearScan) allocate(interval *liveInterval) {
+	s.expire(interval.Start)
+
+	allowed := allowedRegisterNames(interval)
+	for _, name := range allowed {
+		if s.free[name] {
+			s.activate(interval, name)
+			return
+		}
+	}
+
+	// No register is available: we spill the interval that ends last, among
+	// the current interval and active intervals with a register that the
+	// current interval can use.
+	for i := len(s.active) - 1; i >= 0; i-- {
+		candidate := s.active[i]
+		if candidate.End <= interval.End {
+			break
+		}
+
+		if slices.Contains(allowed, candidate.Allocated) {
+			register := candidate.Allocated
+			candidate.Allocated = ""
+			s.active = slices.Delete(s.active, i, i+1)
+			s.spilled = append(s.spilled, candidate)
+			s.activate(interval, register)
+			return
+		}
+	}
+
+	s.spilled = append(s.spilled, interval)
+}
+
+// AllocateRegisters assigns each usm register of the function an AArch64
+// register, or a stack slot, using the linear scan register allocation
+// algorithm.
+//
+// Registers that hold a value across a call instruction are assigned callee
+// saved registers, and other registers prefer the temporary (caller saved)
+// registers. The argument registers, the scratch registers, the platform
+// register (x18), the frame pointer, the link register and the stack pointer
+// are never assigned. When there are not enough registers, the register whose
+// live interval ends last is spilled to the stack, for its entire lifetime.
+func AllocateRegisters(
+	function *gen.FunctionInfo,
+) (RegisterAllocation, core.ResultList) {
+	scan := newLinearScan()
+	intervals := computeLiveIntervals(function)
+	for _, interval := range intervals {
+		scan.allocate(interval)
+	}
+
+	allocation := RegisterAllocation{
+		Registers:  make(map[*gen.RegisterInfo]string, len(intervals)),
+		StackSlots: make(map[*gen.RegisterInfo]uint64, len(scan.spilled)),
+	}
+
+	used := make(map[string]bool)
+	for _, interval := range intervals {
+		if interval.Allocated != "" {
+			allocation.Registers[interval.Register] = interval.Allocated
+			used[interval.Allocated] = true
+		}
+	}
+
+	for _, name := range calleeSavedRegisterNames {
+		if used[name] {
+			allocation.CalleeSaved = append(allocation.CalleeSaved, name)
+		}
+	}
+
+	for i, interval := range scan.spilled {
+		allocation.StackSlots[interval.Register] = uint64(i)
+	}
+
+	if allocation.frameSize() > maxStackOffset {
+		return RegisterAllocation{}, list.FromSingle(core.Result{
+			{
+				Type: core.ErrorResult,
+				Message: fmt.Sprintf(
+					"Function requires %d stack slots, which exceeds the supported frame size",
+					len(scan.spilled),
+				),
+				Location: function.Declaration,
+			},
+		})
+	}
+
+	return allocation, core.ResultList{}
+}
@@ -0,0 +1,129 @@
+package usmaarch64_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"alon.kr/x/usm/gen"
+	usmaarch64 "alon.kr/x/usm/usm/aarch64"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func allocateFunction(
+	t *testing.T,
+	src string,
+	name string,
+) (*gen.FunctionInfo, usmaarch64.RegisterAllocation) {
+	t.Helper()
+
+	function := generateFileInfo(t, src).GetFunction(name)
+	require.NotNil(t, function)
+
+	allocation, results := usmaarch64.AllocateRegisters(function)
+	require.True(t, results.IsEmpty())
+
+	return function, allocation
+}
+
+func TestAllocationReusesRegisters(t *testing.T) {
+	src := `func $64 @f $64 %a {
+	$64 %b = add %a $64 #1
+	$64 %c = add %b $64 #2
+	$64 %d = add %c $64 #3
+	ret %d
+}`
+
+	function, allocation := allocateFunction(t, src, "@f")
+	registers := function.Registers
+
+	assert.Empty(t, allocation.CalleeSaved)
+	assert.Empty(t, allocation.StackSlots)
+	assert.Equal(t, "%x8", allocation.Registers[registers.GetRegister("%a")])
+	assert.Equal(t, "%x9", allocation.Registers[registers.GetRegister("%b")])
+	assert.Equal(t, "%x8", allocation.Registers[registers.GetRegister("%c")])
+	assert.Equal(t, "%x9", allocation.Registers[registers.GetRegister("%d")])
+}
+
+func TestAllocationAcrossCalls(t *testing.T) {
+	src := `func $64 @g $64 %x
+
+func $64 @f $64 %a {
+	$64 %b = add %a $64 #1
+	$64 %r = call @g %a
+	$64 %s = add %r %b
+	ret %s
+}`
+
+	function, allocation := allocateFunction(t, src, "@f")
+	registers := function.Registers
+
+	// %b is live across the call, and thus must be held in a callee saved
+	// register. %a is last used as a call argument, and does not.
+	assert.Equal(t, "%x19", allocation.Registers[registers.GetRegister("%b")])
+	assert.Equal(t, "%x8", allocation.Registers[registers.GetRegister("%a")])
+	assert.Equal(t, []string{"%x19"}, allocation.CalleeSaved)
+	assert.Empty(t, allocation.StackSlots)
+}
+
+func TestAllocationLoopLiveness(t *testing.T) {
+	src := `func $64 @f $64 %n {
+	$64 %sum = add %n $64 #0
+.loop
+	$64 %tmp = add %sum $64 #1
+	%sum = add %tmp %n
+	%n = sub %n $64 #1
+	jnz %n .loop
+	ret %sum
+}`
+
+	function, allocation := allocateFunction(t, src, "@f")
+	registers := function.Registers
+
+	// %sum is live throughout the loop, and thus can't share a register with
+	// %tmp, although %sum is not used after the definition of %tmp in the
+	// linear order of the instructions.
+	sum := allocation.Registers[registers.GetRegister("%sum")]
+	tmp := allocation.Registers[registers.GetRegister("%tmp")]
+	n := allocation.Registers[registers.GetRegister("%n")]
+	assert.NotEqual(t, sum, tmp)
+	assert.NotEqual(t, n, tmp)
+	assert.NotEqual(t, n, sum)
+}
+
+// Returns a function that holds more values simultaneously than the number of
+// available registers.
+func highPressureSource(values int) string {
+	lines := []string{"func $64 @f $64 %a {"}
+	for i := 0; i < values; i++ {
+		lines = append(lines, fmt.Sprintf("\t$64 %%v%d = add %%a $64 #%d", i, i))
+	}
+
+	lines = append(lines, "\t$64 %s = add %a %v0")
+	for i := 1; i < values; i++ {
+		lines = append(lines, fmt.Sprintf("\t%%s = add %%s %%v%d", i))
+	}
+
+	lines = append(lines, "\tret %s", "}")
+	return strings.Join(lines, "\n")
+}
+
+func TestAllocationSpills(t *testing.T) {
+	src := highPressureSource(24)
+	function, allocation := allocateFunction(t, src, "@f")
+
+	assert.NotEmpty(t, allocation.StackSlots)
+	for _, register := range function.Registers.GetAllRegisters() {
+		_, allocated := allocation.Registers[register]
+		_, spilled := allocation.StackSlots[register]
+		assert.True(t, allocated != spilled, "register %s", register.Name)
+	}
+
+	file, results := usmaarch64.FileToAarch64(generateFileInfo(t, src))
+	require.True(t, results.IsEmpty())
+
+	body := functionBody(file.GetFunction("@f"))
+	assert.Contains(t, body, "str %x16 %sp $64 #")
+	assert.Contains(t, body, "$64 %x16 = ldr %sp $64 #")
+}
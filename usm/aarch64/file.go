@@ -0,0 +1,142 @@
+package usmaarch64
+
+import (
+	aarch64managers "alon.kr/x/usm/aarch64/managers"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/transform"
+)
+
+// fileLowering holds the state of the lowering of a single usm file into an
+// AArch64 file.
+type fileLowering struct {
+	// The generation context of the AArch64 file. The lowered functions,
+	// registers, labels and types are all created using the managers of this
+	// context.
+	*gen.FileGenerationContext
+
+	// The (usm) source file that is being lowered.
+	Source *gen.FileInfo
+
+	// The lowered AArch64 file.
+	File *gen.FileInfo
+
+	// Maps each usm function to its lowered AArch64 counterpart.
+	Functions map[*gen.FunctionInfo]*gen.FunctionInfo
+}
+
+func newFileLowering(source *gen.FileInfo) *fileLowering {
+	ctx := aarch64managers.NewGenerationContext()
+	return &fileLowering{
+		FileGenerationContext: ctx.NewFileGenerationContext(nil),
+		Source:                source,
+		File:                  gen.NewFileInfo(),
+		Functions:             make(map[*gen.FunctionInfo]*gen.FunctionInfo),
+	}
+}
+
+// Creates the AArch64 function signatures (without a body) of all of the
+// functions in the source file.
+//
+// This is done before lowering the function bodies, so function calls can
+// refer to the lowered functions.
+func (l *fileLowering) declareFunctions() core.ResultList {
+	results := core.ResultList{}
+
+	for _, source := range l.Source.Functions {
+		function, curResults := l.declareFunction(source)
+		results.Extend(&curResults)
+
+		if curResults.IsEmpty() {
+			l.Functions[source] = function
+			l.File.AppendFunction(function)
+			curResults = l.Globals.NewGlobal(gen.NewFunctionGlobalInfo(function))
+			results.Extend(&curResults)
+		}
+	}
+
+	return results
+}
+
+// Copies the declared types, global variables and constants of the source
+// file to the lowered file.
+//
+// The types and data globals hold no target specific information, so the
+// same infos are shared between the source and the lowered files.
+func (l *fileLowering) declareTypesAndDataGlobals() core.ResultList {
+	results := core.ResultList{}
+
+	for _, typ := range l.Source.Types {
+		l.File.AppendType(typ)
+	}
+
+	for _, variable := range l.Source.Variables {
+		l.File.AppendVariable(variable)
+		curResults := l.Globals.NewGlobal(variable)
+		results.Extend(&curResults)
+	}
+
+	for _, constant := range l.Source.Constants {
+		l.File.AppendConstant(constant)
+		curResults := l.Globals.NewGlobal(constant)
+		results.Extend(&curResults)
+	}
+
+	return results
+}
+
+func (l *fileLowering) defineFunctions() core.ResultList {
+	results := core.ResultList{}
+
+	for source, function := range l.Functions {
+		if source.IsDefined() {
+			curResults := l.defineFunction(source, function)
+			results.Extend(&curResults)
+		}
+	}
+
+	return results
+}
+
+// FileToAarch64 lowers the provided usm file into an equivalent AArch64 file,
+// bound to the AArch64 generation context.
+//
+// The source file is expected to be in its non-SSA form (without "phi"
+// instructions).
+func FileToAarch64(file *gen.FileInfo) (*gen.FileInfo, core.ResultList) {
+	lowering := newFileLowering(file)
+
+	results := lowering.declareTypesAndDataGlobals()
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	results = lowering.declareFunctions()
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	results = lowering.defineFunctions()
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	results = lowering.File.Validate()
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return lowering.File, core.ResultList{}
+}
+
+func TransformFileToAarch64(
+	data *transform.TargetData,
+) (*transform.TargetData, core.ResultList) {
+	file, results := FileToAarch64(data.Code)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	data.Code = file
+	return data, core.ResultList{}
+}
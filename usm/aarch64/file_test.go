@@ -0,0 +1,351 @@
+package usmaarch64_test
+
+import (
+	"strings"
+	"testing"
+
+	aarch64codegen "alon.kr/x/usm/aarch64/codegen"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/internal/testutil"
+	usmaarch64 "alon.kr/x/usm/usm/aarch64"
+	usmmanagers "alon.kr/x/usm/usm/managers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateFileInfo(t *testing.T, source string) *gen.FileInfo {
+	t.Helper()
+	return testutil.MustGenerateFile(t, usmmanagers.NewGenerationContext(), source)
+}
+
+// Returns the string representation of all instructions in the function body,
+// excluding the function prologue.
+func functionBody(function *gen.FunctionInfo) string {
+	lines := []string{}
+	for block := function.EntryBlock.NextBlock; block != nil; block = block.NextBlock {
+		for _, instruction := range block.Instructions {
+			lines = append(lines, instruction.String())
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// The epilogue of functions that do not use callee saved registers and stack
+// slots.
+const epilogue = `$64 %x29 = ldr %sp $64 #0
+$64 %x30 = ldr %sp $64 #8
+$64 %sp = add %sp $12 #16
+ret`
+
+func TestArithmeticLowering(t *testing.T) {
+	src := `func $64 @f $64 %a {
+	$64 %b = add %a $64 #5
+	%b = sub %b $64 #-7
+	%b = mul %b $64 #0x12345
+	%b = xor %b %a
+	ret %b
+}`
+
+	file, results := usmaarch64.FileToAarch64(generateFileInfo(t, src))
+	require.True(t, results.IsEmpty())
+
+	function := file.GetFunction("@f")
+	require.NotNil(t, function)
+
+	expected := `$64 %x9 = add %x8 $12 #5
+$64 %x9 = add %x9 $12 #7
+$64 %x17 = movz $16 #9029
+$64 %x17 = movk $16 #1 $8 #16
+$64 %x9 = mul %x9 %x17
+$64 %x9 = eor %x9 %x8
+$64 %x0 = mov %x9
+` + epilogue
+
+	assert.Equal(t, expected, functionBody(function))
+}
+
+func TestConditionalJumpLowering(t *testing.T) {
+	src := `func @f $32 %a {
+.loop
+	$32 %b = %a
+	jn %b .loop
+	ret
+}`
+
+	file, results := usmaarch64.FileToAarch64(generateFileInfo(t, src))
+	require.True(t, results.IsEmpty())
+
+	expected := `$64 %x9 = mov %x8
+$64 %x16 = lsl %x9 $6 #32
+$64 %xzr = subs %x16 $12 #0
+b.lt .loop
+` + epilogue
+
+	assert.Equal(t, expected, functionBody(file.GetFunction("@f")))
+}
+
+func TestSwitchLowering(t *testing.T) {
+	t.Run("compare chain", func(t *testing.T) {
+		src := `func @f $8 %a {
+.entry
+	switch %a .entry $8 #-1 .a $8 #100 .b
+.a
+	ret
+.b
+	ret
+}`
+
+		file, results := usmaarch64.FileToAarch64(generateFileInfo(t, src))
+		require.True(t, results.IsEmpty())
+
+		body := functionBody(file.GetFunction("@f"))
+		expected := `$64 %x16 = lsl %x8 $6 #56
+$64 %x16 = lsr %x16 $6 #56
+$64 %xzr = subs %x16 $12 #100
+b.eq .b
+$64 %xzr = subs %x16 $12 #255
+b.eq .a
+b .entry
+`
+		assert.True(t, strings.HasPrefix(body, expected), body)
+	})
+
+	t.Run("jump table", func(t *testing.T) {
+		src := `func @f $64 %a {
+.entry
+	switch %a .default $64 #1 .a $64 #2 .b $64 #3 .a $64 #5 .b
+.a
+	ret
+.b
+	ret
+.default
+	ret
+}`
+
+		file, results := usmaarch64.FileToAarch64(generateFileInfo(t, src))
+		require.True(t, results.IsEmpty())
+
+		operators := []string{}
+		words := []string{}
+		function := file.GetFunction("@f")
+		for block := function.EntryBlock.NextBlock; block != nil; block = block.NextBlock {
+			for _, instruction := range block.Instructions {
+				operator := instruction.Definition.Operator(instruction)
+				if operator == "word" {
+					words = append(words, instruction.Arguments[0].String())
+				}
+
+				operators = append(operators, operator)
+			}
+		}
+
+		assert.Equal(t, []string{
+			"sub", "subs", "b.hi", "adr", "ldrsw", "add", "br",
+			"word", "word", "word", "word", "word",
+		}, operators[:12])
+		assert.Equal(t, []string{".a", ".b", ".a", ".default", ".b"}, words)
+	})
+}
+
+func TestShiftLowering(t *testing.T) {
+	src := `func $32 @f $32 %a $8 %n {
+	$32 %b = shl %a %n
+	%b = lshr %b $8 #3
+	%b = ashr %b %n
+	$64 %c = sext %b
+	$16 %d = trunc %c
+	$32 %e = zext %d
+	ret %e
+}`
+
+	file, results := usmaarch64.FileToAarch64(generateFileInfo(t, src))
+	require.True(t, results.IsEmpty())
+
+	expected := `$64 %x10 = lslv %x8 %x9
+$64 %x16 = lsl %x10 $6 #32
+$64 %x16 = lsr %x16 $6 #32
+$64 %x10 = lsr %x16 $6 #3
+$64 %x16 = lsl %x10 $6 #32
+$64 %x16 = asr %x16 $6 #32
+$64 %x10 = asrv %x16 %x9
+$64 %x8 = lsl %x10 $6 #32
+$64 %x8 = asr %x8 $6 #32
+$64 %x9 = mov %x8
+$64 %x8 = lsl %x9 $6 #48
+$64 %x8 = lsr %x8 $6 #48
+$64 %x0 = mov %x8
+` + epilogue
+
+	assert.Equal(t, expected, functionBody(file.GetFunction("@f")))
+}
+
+func TestDivisionAndComparisonLowering(t *testing.T) {
+	src := `func $64 $1 @f $64 %a $64 %b {
+	$64 %r = srem %a %b
+	$1 %c = ult %r %b
+	ret %r %c
+}`
+
+	file, results := usmaarch64.FileToAarch64(generateFileInfo(t, src))
+	require.True(t, results.IsEmpty())
+
+	expected := `$64 %x30 = sdiv %x8 %x9
+$64 %x10 = msub %x30 %x9 %x8
+$64 %xzr = subs %x10 %x9
+$64 %x8 = cset.cc
+$64 %x0 = mov %x10
+$64 %x1 = mov %x8
+` + epilogue
+
+	assert.Equal(t, expected, functionBody(file.GetFunction("@f")))
+}
+
+func TestCallLowering(t *testing.T) {
+	src := `func $64 @callee $64 %x $64 %y
+
+func $64 @caller {
+	$64 %r = call @callee $64 #1 $64 #-1
+	ret %r
+}`
+
+	file, results := usmaarch64.FileToAarch64(generateFileInfo(t, src))
+	require.True(t, results.IsEmpty())
+
+	callee := file.GetFunction("@callee")
+	require.NotNil(t, callee)
+	assert.False(t, callee.IsDefined())
+	assert.Len(t, callee.Parameters, 2)
+
+	expected := `$64 %x0 = movz $16 #1
+$64 %x1 = movz $16 #65535
+$64 %x1 = movk $16 #65535 $8 #16
+$64 %x1 = movk $16 #65535 $8 #32
+$64 %x1 = movk $16 #65535 $8 #48
+bl @callee
+$64 %x8 = mov %x0
+$64 %x0 = mov %x8
+` + epilogue
+
+	assert.Equal(t, expected, functionBody(file.GetFunction("@caller")))
+}
+
+func TestIndirectCallLowering(t *testing.T) {
+	src := `type $binop $64 = func $64 $64
+
+func $64 @add $64 %a $64 %b {
+	$64 %r = add %a %b
+	ret %r
+}
+
+func $64 @caller $64 %x {
+	$binop * %p = fptr @add
+	$64 %r = call %p %x $64 #1
+	ret %r
+}`
+
+	file, results := usmaarch64.FileToAarch64(generateFileInfo(t, src))
+	require.True(t, results.IsEmpty())
+
+	expected := `$64 %x9 = adrp @add
+$64 %x9 = add %x9 @add
+$64 %x0 = mov %x8
+$64 %x1 = movz $16 #1
+blr %x9
+$64 %x10 = mov %x0
+$64 %x0 = mov %x10
+` + epilogue
+
+	assert.Equal(t, expected, functionBody(file.GetFunction("@caller")))
+}
+
+func TestExternalFunctionPointerLowering(t *testing.T) {
+	src := `type $thunk = func
+
+func @external
+
+func @f {
+	$thunk * %p = fptr @external
+	call %p
+	ret
+}`
+
+	file, results := usmaarch64.FileToAarch64(generateFileInfo(t, src))
+	require.True(t, results.IsEmpty())
+
+	body := functionBody(file.GetFunction("@f"))
+	assert.Contains(t, body, "$64 %x8 = adrp @external\n$64 %x8 = add %x8 @external\n")
+}
+
+func TestPhiLoweringFails(t *testing.T) {
+	src := `func $64 @f $64 %a {
+.entry
+	jz %a .end
+.end
+	$64 %b = phi .entry %a
+	ret %b
+}`
+
+	_, results := usmaarch64.FileToAarch64(generateFileInfo(t, src))
+	assert.False(t, results.IsEmpty())
+}
+
+func TestDataGlobalsLowering(t *testing.T) {
+	src := `type $node {
+	.value $16
+	.next $node *
+}
+
+var @head $node {
+	.value #-1
+	#8
+}
+`
+
+	file := generateFileInfo(t, src)
+	lowered, results := usmaarch64.FileToAarch64(file)
+	require.True(t, results.IsEmpty())
+
+	ctx := aarch64codegen.NewFileCodegenContext(lowered)
+	section, results := aarch64codegen.NewDataSection(ctx.OrderedVariables)
+	require.True(t, results.IsEmpty())
+
+	assert.Equal(t, []byte{
+		0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // .value, and padding
+		0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // .next
+	}, section.Data)
+}
+
+func TestDataGlobalAddressLowering(t *testing.T) {
+	src := `var @counter $64 #7
+const @limit $32 #3
+
+func $64 * @f {
+	$64 * %p = @counter
+	ret %p
+}
+
+func $32 * @g {
+	ret @limit
+}`
+
+	file, results := usmaarch64.FileToAarch64(generateFileInfo(t, src))
+	require.True(t, results.IsEmpty())
+
+	f := file.GetFunction("@f")
+	require.NotNil(t, f)
+
+	expected := `$64 %x8 = adrp @counter
+$64 %x8 = add %x8 @counter
+$64 %x0 = mov %x8
+` + epilogue
+	assert.Equal(t, expected, functionBody(f))
+
+	g := file.GetFunction("@g")
+	require.NotNil(t, g)
+
+	expected = `$64 %x0 = adrp @limit
+$64 %x0 = add %x0 @limit
+` + epilogue
+	assert.Equal(t, expected, functionBody(g))
+}
@@ -0,0 +1,405 @@
+package usmaarch64
+
+import (
+	"fmt"
+	"math/big"
+
+	"alon.kr/x/list"
+	aarch64isa "alon.kr/x/usm/aarch64/isa"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+)
+
+// The maximal amount that the stack pointer can be adjusted by with a single
+// instruction, while keeping it aligned to 16 bytes.
+const maxStackPointerAdjustment = 4080
+
+// functionLowering holds the state of the lowering of a single usm function
+// into an AArch64 function.
+type functionLowering struct {
+	*gen.FunctionGenerationContext
+
+	file *fileLowering
+
+	// The (usm) source function that is being lowered.
+	Source *gen.FunctionInfo
+
+	// The lowered AArch64 function.
+	Function *gen.FunctionInfo
+
+	// The location of each of the source function registers.
+	Allocation RegisterAllocation
+
+	// The size of the stack frame of the function, in bytes.
+	FrameSize uint64
+
+	// Maps each basic block in the source function to the basic block in the
+	// lowered function in which its instructions are lowered into.
+	BasicBlocks map[*gen.BasicBlockInfo]*gen.BasicBlockInfo
+
+	// The basic block to which new instructions are currently emitted.
+	block *gen.BasicBlockInfo
+
+	// The source instruction that is currently being lowered.
+	instruction *gen.InstructionInfo
+}
+
+// MARK: Types
+
+// Returns the size (in bits) of the value that a register of the provided type
+// holds, or nil if the type can't be stored in a single AArch64 register.
+func (l *fileLowering) typeSize(typ gen.ReferencedTypeInfo) *big.Int {
+	if len(typ.Descriptors) > 0 {
+		outer := typ.Descriptors[len(typ.Descriptors)-1]
+		if outer.Type == gen.PointerTypeDescriptor {
+			return l.PointerSize
+		}
+
+		return nil
+	}
+
+	size := typ.Base.Size
+	if size == nil || size.Sign() <= 0 || size.Cmp(l.PointerSize) > 0 {
+		return nil
+	}
+
+	return size
+}
+
+func (l *fileLowering) assertSupportedType(
+	typ gen.ReferencedTypeInfo,
+	location *core.UnmanagedSourceView,
+) core.ResultList {
+	if l.typeSize(typ) == nil {
+		return list.FromSingle(core.Result{
+			{
+				Type: core.ErrorResult,
+				Message: fmt.Sprintf(
+					"Type \"%s\" is not supported in AArch64",
+					typ.String(),
+				),
+				Location: location,
+			},
+			{
+				Type:    core.HintResult,
+				Message: "Only integer and pointer types of up to 64 bits are supported",
+			},
+		})
+	}
+
+	return core.ResultList{}
+}
+
+// MARK: Declaration
+
+func (l *fileLowering) declareFunction(
+	source *gen.FunctionInfo,
+) (*gen.FunctionInfo, core.ResultList) {
+	results := core.ResultList{}
+
+	if len(source.Parameters) > maxRegisterArguments {
+		results.Append(core.Result{
+			{
+				Type: core.ErrorResult,
+				Message: fmt.Sprintf(
+					"Functions with more than %d parameters are not supported in AArch64",
+					maxRegisterArguments,
+				),
+				Location: source.Declaration,
+			},
+		})
+	}
+
+	if len(source.Targets) > maxRegisterArguments {
+		results.Append(core.Result{
+			{
+				Type: core.ErrorResult,
+				Message: fmt.Sprintf(
+					"Functions with more than %d targets are not supported in AArch64",
+					maxRegisterArguments,
+				),
+				Location: source.Declaration,
+			},
+		})
+	}
+
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	for _, parameter := range source.Parameters {
+		curResults := l.assertSupportedType(parameter.Type, &parameter.Declaration)
+		results.Extend(&curResults)
+	}
+
+	for _, target := range source.Targets {
+		curResults := l.assertSupportedType(target, target.Declaration)
+		results.Extend(&curResults)
+	}
+
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	ctx := l.NewFunctionGenerationContext()
+	type64 := gen.ReferencedTypeInfo{Base: l.Types.GetType("$64")}
+
+	parameters := make([]*gen.RegisterInfo, len(source.Parameters))
+	for i := range source.Parameters {
+		parameters[i] = ctx.Registers.GetRegister(argumentRegisterNames[i])
+	}
+
+	targets := make([]gen.ReferencedTypeInfo, len(source.Targets))
+	for i := range source.Targets {
+		targets[i] = type64
+	}
+
+	function := &gen.FunctionInfo{
+		Name:        source.Name,
+		Declaration: source.Declaration,
+		Registers:   ctx.Registers,
+		Labels:      ctx.Labels,
+		Parameters:  parameters,
+		Targets:     targets,
+	}
+
+	return function, core.ResultList{}
+}
+
+// MARK: Definition
+
+func (l *fileLowering) newFunctionLowering(
+	source *gen.FunctionInfo,
+	function *gen.FunctionInfo,
+	allocation RegisterAllocation,
+) *functionLowering {
+	ctx := &gen.FunctionGenerationContext{
+		FileGenerationContext: l.FileGenerationContext,
+		Registers:             function.Registers,
+		Labels:                function.Labels,
+	}
+
+	return &functionLowering{
+		FunctionGenerationContext: ctx,
+		file:                      l,
+		Source:                    source,
+		Function:                  function,
+		Allocation:                allocation,
+		FrameSize:                 allocation.frameSize(),
+		BasicBlocks:               make(map[*gen.BasicBlockInfo]*gen.BasicBlockInfo),
+	}
+}
+
+func (l *fileLowering) defineFunction(
+	source *gen.FunctionInfo,
+	function *gen.FunctionInfo,
+) core.ResultList {
+	allocation, results := AllocateRegisters(source)
+	if !results.IsEmpty() {
+		return results
+	}
+
+	lowering := l.newFunctionLowering(source, function, allocation)
+
+	results = lowering.assertSupportedRegisterTypes()
+	if !results.IsEmpty() {
+		return results
+	}
+
+	results = lowering.createBasicBlocks()
+	if !results.IsEmpty() {
+		return results
+	}
+
+	results = lowering.lowerBasicBlocks()
+	if !results.IsEmpty() {
+		return results
+	}
+
+	return lowering.linkBasicBlocks()
+}
+
+func (l *functionLowering) assertSupportedRegisterTypes() core.ResultList {
+	results := core.ResultList{}
+
+	for _, register := range collectRegistersInOrder(l.Source) {
+		curResults := l.file.assertSupportedType(register.Type, &register.Declaration)
+		results.Extend(&curResults)
+	}
+
+	return results
+}
+
+// Creates an (empty) basic block in the lowered function for each basic block
+// in the source function, with the same labels, and an additional entry basic
+// block for the function prologue.
+func (l *functionLowering) createBasicBlocks() core.ResultList {
+	results := core.ResultList{}
+	sourceBlocks := l.Source.CollectBasicBlocks()
+	blocks := make([]*gen.BasicBlockInfo, 0, len(sourceBlocks))
+
+	for _, sourceBlock := range sourceBlocks {
+		label := &gen.LabelInfo{
+			Name:        sourceBlock.Label.Name,
+			Declaration: sourceBlock.Label.Declaration,
+		}
+
+		curResults := l.Labels.NewLabel(label)
+		results.Extend(&curResults)
+
+		block := gen.NewEmptyBasicBlockInfo(l.Function)
+		block.SetLabel(label)
+		l.BasicBlocks[sourceBlock] = block
+		blocks = append(blocks, block)
+	}
+
+	// The prologue is placed in a dedicated basic block, since the first
+	// source basic block may be a target of a branch, and we don't want to
+	// execute the prologue more than once.
+	prologueLabel := l.Labels.GenerateLabel()
+	curResults := l.Labels.NewLabel(prologueLabel)
+	results.Extend(&curResults)
+
+	if !results.IsEmpty() {
+		return results
+	}
+
+	prologue := gen.NewEmptyBasicBlockInfo(l.Function)
+	prologue.SetLabel(prologueLabel)
+	l.Function.EntryBlock = prologue
+
+	previous := prologue
+	for _, block := range blocks {
+		previous.AppendBasicBlock(block)
+		previous = block
+	}
+
+	return core.ResultList{}
+}
+
+func (l *functionLowering) lowerBasicBlocks() core.ResultList {
+	l.block = l.Function.EntryBlock
+	l.lowerPrologue()
+
+	for _, sourceBlock := range l.Source.CollectBasicBlocks() {
+		l.block = l.BasicBlocks[sourceBlock]
+		for _, instruction := range sourceBlock.Instructions {
+			l.instruction = instruction
+			results := l.lowerInstruction(instruction)
+			if !results.IsEmpty() {
+				return results
+			}
+		}
+	}
+
+	l.instruction = nil
+	return core.ResultList{}
+}
+
+// Fills the forward and backward edges of the lowered basic blocks, according
+// to the last instruction in each basic block.
+func (l *functionLowering) linkBasicBlocks() core.ResultList {
+	for block := l.Function.EntryBlock; block != nil; block = block.NextBlock {
+		steps := gen.StepInfo{PossibleContinue: true}
+		if len(block.Instructions) > 0 {
+			last := block.Instructions[len(block.Instructions)-1]
+			var results core.ResultList
+			steps, results = last.Definition.PossibleNextSteps(last)
+			if !results.IsEmpty() {
+				return results
+			}
+		}
+
+		if steps.PossibleContinue {
+			if block.NextBlock == nil {
+				return list.FromSingle(core.Result{
+					{
+						Type:     core.InternalErrorResult,
+						Message:  "Lowered function does not end with a terminating instruction",
+						Location: l.Source.Declaration,
+					},
+				})
+			}
+
+			block.AppendForwardEdge(block.NextBlock)
+		}
+
+		for _, label := range steps.PossibleBranches {
+			block.AppendForwardEdge(label.BasicBlock)
+		}
+	}
+
+	return core.ResultList{}
+}
+
+// MARK: Frame
+
+// Emits instructions that subtract the provided amount (in bytes) from the
+// stack pointer.
+func (l *functionLowering) decreaseStackPointer(amount uint64) {
+	sp := l.register(spRegisterName)
+	scratch := l.register(scratchRegisterNames[0])
+
+	for amount > 0 {
+		chunk := min(amount, maxStackPointerAdjustment)
+
+		// The "sub" (immediate) instruction can't target the stack pointer
+		// directly in usm AArch64, so we compute the new value into a scratch
+		// register first.
+		l.emit(aarch64isa.NewSub(), []*gen.RegisterInfo{scratch}, l.registerArgument(sp), l.immediate(12, chunk))
+		l.emit(aarch64isa.NewAdd(), []*gen.RegisterInfo{sp}, l.registerArgument(scratch), l.immediate(12, 0))
+		amount -= chunk
+	}
+}
+
+// Emits instructions that add the provided amount (in bytes) to the stack
+// pointer.
+func (l *functionLowering) increaseStackPointer(amount uint64) {
+	sp := l.register(spRegisterName)
+
+	for amount > 0 {
+		chunk := min(amount, maxStackPointerAdjustment)
+		l.emit(aarch64isa.NewAdd(), []*gen.RegisterInfo{sp}, l.registerArgument(sp), l.immediate(12, chunk))
+		amount -= chunk
+	}
+}
+
+// Returns the names of the registers that are saved in the stack frame,
+// ordered by their location in the frame.
+func (l *functionLowering) savedRegisterNames() []string {
+	names := []string{framePointerRegisterName, linkRegisterName}
+	return append(names, l.Allocation.CalleeSaved...)
+}
+
+func (l *functionLowering) lowerPrologue() {
+	sp := l.register(spRegisterName)
+	l.decreaseStackPointer(l.FrameSize)
+
+	for i, name := range l.savedRegisterNames() {
+		offset := l.immediate(64, uint64(8*i))
+		l.emit(aarch64isa.NewStr(), nil, l.registerArgument(l.register(name)), l.registerArgument(sp), offset)
+	}
+
+	framePointer := l.register(framePointerRegisterName)
+	l.emit(aarch64isa.NewAdd(), []*gen.RegisterInfo{framePointer}, l.registerArgument(sp), l.immediate(12, 0))
+
+	for i, parameter := range l.Source.Parameters {
+		argument := l.register(argumentRegisterNames[i])
+		if _, spilled := l.Allocation.StackSlots[parameter]; spilled {
+			l.storeSpilled(parameter, argument)
+		} else {
+			l.moveRegister(l.allocatedRegister(parameter), argument)
+		}
+	}
+}
+
+func (l *functionLowering) lowerEpilogue() {
+	sp := l.register(spRegisterName)
+
+	for i, name := range l.savedRegisterNames() {
+		offset := l.immediate(64, uint64(8*i))
+		l.emit(aarch64isa.NewLdr(), []*gen.RegisterInfo{l.register(name)}, l.registerArgument(sp), offset)
+	}
+
+	l.increaseStackPointer(l.FrameSize)
+}
@@ -0,0 +1,796 @@
+package usmaarch64
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+
+	"alon.kr/x/aarch64codegen/immediates"
+	"alon.kr/x/list"
+	aarch64isa "alon.kr/x/usm/aarch64/isa"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	usmisa "alon.kr/x/usm/usm/isa"
+)
+
+// Lowers a single usm instruction into (possibly multiple) AArch64
+// instructions, which are appended to the current basic block.
+func (l *functionLowering) lowerInstruction(
+	info *gen.InstructionInfo,
+) core.ResultList {
+	switch info.Definition.(type) {
+	// Arithmetic
+	case usmisa.Move:
+		return l.lowerMove(info)
+	case usmisa.Add:
+		return l.lowerAddOrSub(info, false)
+	case usmisa.Sub:
+		return l.lowerAddOrSub(info, true)
+	case usmisa.Mul:
+		return l.lowerBinaryRegisters(info, aarch64isa.NewMul())
+	case usmisa.Udiv:
+		return l.lowerDivision(info, false, false)
+	case usmisa.Sdiv:
+		return l.lowerDivision(info, true, false)
+	case usmisa.Urem:
+		return l.lowerDivision(info, false, true)
+	case usmisa.Srem:
+		return l.lowerDivision(info, true, true)
+
+	// Bitwise Operations
+	case usmisa.And:
+		return l.lowerBinaryRegisters(info, aarch64isa.NewAnd())
+	case usmisa.Or:
+		return l.lowerBinaryRegisters(info, aarch64isa.NewOrr())
+	case usmisa.Xor:
+		return l.lowerBinaryRegisters(info, aarch64isa.NewEor())
+	case usmisa.Shl:
+		return l.lowerShift(info, aarch64isa.NewLsl(), aarch64isa.NewLslv(), false, false)
+	case usmisa.Lshr:
+		return l.lowerShift(info, aarch64isa.NewLsr(), aarch64isa.NewLsrv(), true, false)
+	case usmisa.Ashr:
+		return l.lowerShift(info, aarch64isa.NewAsr(), aarch64isa.NewAsrv(), true, true)
+
+	// Width Conversions
+	case usmisa.Zext:
+		return l.lowerExtend(info, false)
+	case usmisa.Sext:
+		return l.lowerExtend(info, true)
+	case usmisa.Trunc:
+		// The upper bits of narrow values are never assumed to be zero (or
+		// sign) extended, so truncation does not modify the value.
+		return l.lowerMove(info)
+
+	// Comparisons
+	case usmisa.Eq:
+		return l.lowerComparison(info, immediates.ConditionEq, false)
+	case usmisa.Ne:
+		return l.lowerComparison(info, immediates.ConditionNe, false)
+	case usmisa.Ult:
+		return l.lowerComparison(info, immediates.ConditionCc, false)
+	case usmisa.Ule:
+		return l.lowerComparison(info, immediates.ConditionLs, false)
+	case usmisa.Ugt:
+		return l.lowerComparison(info, immediates.ConditionHi, false)
+	case usmisa.Uge:
+		return l.lowerComparison(info, immediates.ConditionCs, false)
+	case usmisa.Slt:
+		return l.lowerComparison(info, immediates.ConditionLt, true)
+	case usmisa.Sle:
+		return l.lowerComparison(info, immediates.ConditionLe, true)
+	case usmisa.Sgt:
+		return l.lowerComparison(info, immediates.ConditionGt, true)
+	case usmisa.Sge:
+		return l.lowerComparison(info, immediates.ConditionGe, true)
+
+	// Functions
+	case usmisa.Call:
+		return l.lowerCall(info)
+	case usmisa.Ret:
+		return l.lowerRet(info)
+	case usmisa.Fptr:
+		return l.lowerFptr(info)
+
+	// Control Flow
+	case usmisa.J:
+		return l.lowerJump(info)
+	case usmisa.Jz:
+		return l.lowerConditionalJump(info, immediates.ConditionEq)
+	case usmisa.Jnz:
+		return l.lowerConditionalJump(info, immediates.ConditionNe)
+	case usmisa.Jp:
+		return l.lowerConditionalJump(info, immediates.ConditionGt)
+	case usmisa.Jnp:
+		return l.lowerConditionalJump(info, immediates.ConditionLe)
+	case usmisa.Jn:
+		return l.lowerConditionalJump(info, immediates.ConditionLt)
+	case usmisa.Jnn:
+		return l.lowerConditionalJump(info, immediates.ConditionGe)
+	case usmisa.Switch:
+		return l.lowerSwitch(info)
+
+	// Static Single Assignment (SSA)
+	case usmisa.Phi:
+		return list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "\"phi\" instructions can't be lowered to AArch64",
+				Location: info.Declaration,
+			},
+			{
+				Type:    core.HintResult,
+				Message: "Convert the function out of static single assignment form first",
+			},
+		})
+
+	default:
+		return list.FromSingle(core.Result{
+			{
+				Type: core.ErrorResult,
+				Message: fmt.Sprintf(
+					"Instruction \"%s\" is not supported in AArch64",
+					info.Definition.Operator(info),
+				),
+				Location: info.Declaration,
+			},
+		})
+	}
+}
+
+// MARK: Arithmetic
+
+func (l *functionLowering) lowerMove(info *gen.InstructionInfo) core.ResultList {
+	dst := l.targetRegister(info.Targets[0])
+	results := l.moveArgument(dst, info.Arguments[0])
+	if !results.IsEmpty() {
+		return results
+	}
+
+	l.storeTarget(info.Targets[0])
+	return core.ResultList{}
+}
+
+// Lowers binary instructions, by loading both arguments to registers, and
+// using the register variant of the provided AArch64 instruction.
+func (l *functionLowering) lowerBinaryRegisters(
+	info *gen.InstructionInfo,
+	definition gen.InstructionDefinition,
+) core.ResultList {
+	left, results := l.argumentToRegister(info.Arguments[0], l.register(scratchRegisterNames[0]))
+	if !results.IsEmpty() {
+		return results
+	}
+
+	right, results := l.argumentToRegister(info.Arguments[1], l.register(scratchRegisterNames[1]))
+	if !results.IsEmpty() {
+		return results
+	}
+
+	dst := l.targetRegister(info.Targets[0])
+	l.emit(definition, []*gen.RegisterInfo{dst}, l.registerArgument(left), l.registerArgument(right))
+	l.storeTarget(info.Targets[0])
+	return core.ResultList{}
+}
+
+// Returns the unsigned 12 bit immediate that can be used in an "add" or "sub"
+// instruction instead of the provided argument, and whether the operation
+// should be negated (an "add" should be replaced with a "sub", or vice versa).
+//
+// If the argument can't be encoded as such immediate, ok is false.
+func immediate12Operand(argument gen.ArgumentInfo) (value uint64, negate bool, ok bool) {
+	immediate, isImmediate := argument.(*gen.ImmediateInfo)
+	if !isImmediate {
+		return 0, false, false
+	}
+
+	abs := new(big.Int).Abs(immediate.Value)
+	if abs.BitLen() > 12 {
+		return 0, false, false
+	}
+
+	return abs.Uint64(), immediate.Value.Sign() < 0, true
+}
+
+func (l *functionLowering) lowerAddOrSub(
+	info *gen.InstructionInfo,
+	isSub bool,
+) core.ResultList {
+	value, negate, ok := immediate12Operand(info.Arguments[1])
+	if !ok {
+		definition := aarch64isa.NewAdd()
+		if isSub {
+			definition = aarch64isa.NewSub()
+		}
+
+		return l.lowerBinaryRegisters(info, definition)
+	}
+
+	left, results := l.argumentToRegister(info.Arguments[0], l.register(scratchRegisterNames[0]))
+	if !results.IsEmpty() {
+		return results
+	}
+
+	definition := aarch64isa.NewAdd()
+	if isSub != negate {
+		definition = aarch64isa.NewSub()
+	}
+
+	dst := l.targetRegister(info.Targets[0])
+	l.emit(definition, []*gen.RegisterInfo{dst}, l.registerArgument(left), l.immediate(12, value))
+	l.storeTarget(info.Targets[0])
+	return core.ResultList{}
+}
+
+// Returns an AArch64 register that holds the value of the provided usm
+// argument, which is the provided amount of bits wide. Values that are
+// narrower than the register are zero or sign extended to the full width of
+// the register, in the provided scratch register.
+func (l *functionLowering) extendedArgumentToRegister(
+	argument gen.ArgumentInfo,
+	scratch *gen.RegisterInfo,
+	size *big.Int,
+	signed bool,
+) (*gen.RegisterInfo, core.ResultList) {
+	register, results := l.argumentToRegister(argument, scratch)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	if size.Cmp(l.PointerSize) < 0 {
+		l.extendRegister(scratch, register, size, signed)
+		return scratch, core.ResultList{}
+	}
+
+	return register, core.ResultList{}
+}
+
+// Loads both arguments of a binary instruction into (extended) registers.
+func (l *functionLowering) extendedArgumentsToRegisters(
+	info *gen.InstructionInfo,
+	signed bool,
+) (left, right *gen.RegisterInfo, results core.ResultList) {
+	size, results := l.argumentSize(info.Arguments[0])
+	if !results.IsEmpty() {
+		return nil, nil, results
+	}
+
+	left, results = l.extendedArgumentToRegister(info.Arguments[0], l.register(scratchRegisterNames[0]), size, signed)
+	if !results.IsEmpty() {
+		return nil, nil, results
+	}
+
+	right, results = l.extendedArgumentToRegister(info.Arguments[1], l.register(scratchRegisterNames[1]), size, signed)
+	if !results.IsEmpty() {
+		return nil, nil, results
+	}
+
+	return left, right, core.ResultList{}
+}
+
+// Lowers a division or a remainder instruction. The remainder is computed
+// from the quotient, as the dividend minus the quotient times the divisor.
+func (l *functionLowering) lowerDivision(
+	info *gen.InstructionInfo,
+	signed bool,
+	remainder bool,
+) core.ResultList {
+	left, right, results := l.extendedArgumentsToRegisters(info, signed)
+	if !results.IsEmpty() {
+		return results
+	}
+
+	definition := aarch64isa.NewUdiv()
+	if signed {
+		definition = aarch64isa.NewSdiv()
+	}
+
+	dst := l.targetRegister(info.Targets[0])
+	if !remainder {
+		l.emit(definition, []*gen.RegisterInfo{dst}, l.registerArgument(left), l.registerArgument(right))
+		l.storeTarget(info.Targets[0])
+		return core.ResultList{}
+	}
+
+	// Both scratch registers may hold the arguments, so the quotient is stored
+	// in the link register, which is saved by the function prologue and
+	// restored by the epilogue.
+	quotient := l.register(linkRegisterName)
+	l.emit(definition, []*gen.RegisterInfo{quotient}, l.registerArgument(left), l.registerArgument(right))
+	l.emit(
+		aarch64isa.NewMsub(),
+		[]*gen.RegisterInfo{dst},
+		l.registerArgument(quotient),
+		l.registerArgument(right),
+		l.registerArgument(left),
+	)
+
+	l.storeTarget(info.Targets[0])
+	return core.ResultList{}
+}
+
+// MARK: Comparisons
+
+// Lowers a comparison instruction, which sets its target to one if the
+// provided condition holds after comparing the arguments, and to zero
+// otherwise.
+func (l *functionLowering) lowerComparison(
+	info *gen.InstructionInfo,
+	condition immediates.Condition,
+	signed bool,
+) core.ResultList {
+	left, right, results := l.extendedArgumentsToRegisters(info, signed)
+	if !results.IsEmpty() {
+		return results
+	}
+
+	xzr := l.register(xzrRegisterName)
+	l.emit(aarch64isa.NewSubs(), []*gen.RegisterInfo{xzr}, l.registerArgument(left), l.registerArgument(right))
+
+	dst := l.targetRegister(info.Targets[0])
+	l.emit(aarch64isa.NewCset(condition), []*gen.RegisterInfo{dst})
+	l.storeTarget(info.Targets[0])
+	return core.ResultList{}
+}
+
+// MARK: Shifts
+
+// Emits instructions that set the provided register to the value of the
+// source register, which is the provided amount of bits wide, zero or sign
+// extended to the full width of the register.
+//
+// The value is shifted to the upper bits of the register, and then shifted
+// back, which discards the upper bits of the source register.
+func (l *functionLowering) extendRegister(
+	dst, src *gen.RegisterInfo,
+	size *big.Int,
+	signed bool,
+) {
+	if size.Cmp(l.PointerSize) >= 0 {
+		l.moveRegister(dst, src)
+		return
+	}
+
+	shift := l.PointerSize.Uint64() - size.Uint64()
+	l.emit(aarch64isa.NewLsl(), []*gen.RegisterInfo{dst}, l.registerArgument(src), l.immediate(6, shift))
+
+	right := aarch64isa.NewLsr()
+	if signed {
+		right = aarch64isa.NewAsr()
+	}
+
+	l.emit(right, []*gen.RegisterInfo{dst}, l.registerArgument(dst), l.immediate(6, shift))
+}
+
+func (l *functionLowering) lowerExtend(
+	info *gen.InstructionInfo,
+	signed bool,
+) core.ResultList {
+	size, results := l.argumentSize(info.Arguments[0])
+	if !results.IsEmpty() {
+		return results
+	}
+
+	src, results := l.argumentToRegister(info.Arguments[0], l.register(scratchRegisterNames[0]))
+	if !results.IsEmpty() {
+		return results
+	}
+
+	dst := l.targetRegister(info.Targets[0])
+	l.extendRegister(dst, src, size, signed)
+	l.storeTarget(info.Targets[0])
+	return core.ResultList{}
+}
+
+// Returns the shift amount that can be used in an immediate shift instruction
+// instead of the provided argument. If the argument can't be encoded as such
+// immediate, ok is false.
+func shiftAmountOperand(argument gen.ArgumentInfo) (value uint64, ok bool) {
+	immediate, isImmediate := argument.(*gen.ImmediateInfo)
+	if !isImmediate || immediate.Value.Sign() < 0 || immediate.Value.BitLen() > 6 {
+		return 0, false
+	}
+
+	return immediate.Value.Uint64(), true
+}
+
+// Lowers a shift instruction, using the provided immediate variant of the
+// shift if the amount is an immediate, and the register variant otherwise.
+//
+// If extend is true, the shifted value is first zero or sign extended to the
+// full width of the register, so the shifted upper bits are well defined.
+func (l *functionLowering) lowerShift(
+	info *gen.InstructionInfo,
+	immediateDefinition gen.InstructionDefinition,
+	registerDefinition gen.InstructionDefinition,
+	extend bool,
+	signed bool,
+) core.ResultList {
+	size, results := l.argumentSize(info.Arguments[0])
+	if !results.IsEmpty() {
+		return results
+	}
+
+	scratch := l.register(scratchRegisterNames[0])
+	value, results := l.argumentToRegister(info.Arguments[0], scratch)
+	if !results.IsEmpty() {
+		return results
+	}
+
+	if extend && size.Cmp(l.PointerSize) < 0 {
+		l.extendRegister(scratch, value, size, signed)
+		value = scratch
+	}
+
+	dst := l.targetRegister(info.Targets[0])
+	if amount, ok := shiftAmountOperand(info.Arguments[1]); ok {
+		l.emit(immediateDefinition, []*gen.RegisterInfo{dst}, l.registerArgument(value), l.immediate(6, amount))
+		l.storeTarget(info.Targets[0])
+		return core.ResultList{}
+	}
+
+	amountSize, results := l.argumentSize(info.Arguments[1])
+	if !results.IsEmpty() {
+		return results
+	}
+
+	amountScratch := l.register(scratchRegisterNames[1])
+	amount, results := l.argumentToRegister(info.Arguments[1], amountScratch)
+	if !results.IsEmpty() {
+		return results
+	}
+
+	// The register variants of the shifts use the lower 6 bits of the amount,
+	// which may contain arbitrary data if the amount is narrower.
+	if amountSize.Cmp(big.NewInt(6)) < 0 {
+		l.extendRegister(amountScratch, amount, amountSize, false)
+		amount = amountScratch
+	}
+
+	l.emit(registerDefinition, []*gen.RegisterInfo{dst}, l.registerArgument(value), l.registerArgument(amount))
+	l.storeTarget(info.Targets[0])
+	return core.ResultList{}
+}
+
+// MARK: Functions
+
+func (l *functionLowering) lowerCall(info *gen.InstructionInfo) core.ResultList {
+	indirect := info.Definition.(usmisa.Call).IsIndirect(info)
+
+	var calleeArgument gen.ArgumentInfo
+	if !indirect {
+		global := info.Arguments[0].(*gen.GlobalArgumentInfo)
+		callee := l.file.File.GetFunction(global.Name())
+		if callee == nil {
+			return list.FromSingle(core.Result{
+				{
+					Type:     core.ErrorResult,
+					Message:  "Call global function does not exist, or is not a function",
+					Location: global.Declaration(),
+				},
+			})
+		}
+
+		calleeArgument = &gen.GlobalArgumentInfo{GlobalInfo: l.Globals.GetGlobal(callee.Name)}
+	}
+
+	for i, argument := range info.Arguments[1:] {
+		results := l.moveArgument(l.register(argumentRegisterNames[i]), argument)
+		if !results.IsEmpty() {
+			return results
+		}
+	}
+
+	if indirect {
+		// Function pointers are never allocated to argument registers, so
+		// the pointer is not overwritten by the arguments.
+		pointer, results := l.argumentToRegister(info.Arguments[0], l.register(scratchRegisterNames[0]))
+		if !results.IsEmpty() {
+			return results
+		}
+
+		l.emit(aarch64isa.NewBlr(), nil, l.registerArgument(pointer))
+	} else {
+		l.emit(aarch64isa.NewBl(), nil, calleeArgument)
+	}
+
+	for i, target := range info.Targets {
+		l.moveRegister(l.targetRegister(target), l.register(argumentRegisterNames[i]))
+		l.storeTarget(target)
+	}
+
+	return core.ResultList{}
+}
+
+// Lowers a function pointer instruction, which computes the address of a
+// function. The address is resolved by the linker, so the function may be
+// defined in another file.
+func (l *functionLowering) lowerFptr(info *gen.InstructionInfo) core.ResultList {
+	global := info.Arguments[0].(*gen.GlobalArgumentInfo)
+	dst := l.targetRegister(info.Targets[0])
+	l.moveGlobalAddress(dst, global.Name())
+	l.storeTarget(info.Targets[0])
+	return core.ResultList{}
+}
+
+func (l *functionLowering) lowerRet(info *gen.InstructionInfo) core.ResultList {
+	for i, argument := range info.Arguments {
+		results := l.moveArgument(l.register(argumentRegisterNames[i]), argument)
+		if !results.IsEmpty() {
+			return results
+		}
+	}
+
+	l.lowerEpilogue()
+	l.emit(aarch64isa.NewRet(), nil)
+	return core.ResultList{}
+}
+
+// MARK: Control Flow
+
+func (l *functionLowering) lowerJump(info *gen.InstructionInfo) core.ResultList {
+	label, results := gen.ArgumentToLabel(info.Arguments[0])
+	if !results.IsEmpty() {
+		return results
+	}
+
+	l.emit(aarch64isa.NewBranch(), nil, l.labelArgument(label))
+	return core.ResultList{}
+}
+
+// Lowers a conditional jump, which compares the provided value with zero,
+// and branches to the label if the provided condition holds.
+func (l *functionLowering) lowerConditionalJump(
+	info *gen.InstructionInfo,
+	condition immediates.Condition,
+) core.ResultList {
+	label, results := gen.ArgumentToLabel(info.Arguments[1])
+	if !results.IsEmpty() {
+		return results
+	}
+
+	size, results := l.argumentSize(info.Arguments[0])
+	if !results.IsEmpty() {
+		return results
+	}
+
+	scratch := l.register(scratchRegisterNames[0])
+	value, results := l.argumentToRegister(info.Arguments[0], scratch)
+	if !results.IsEmpty() {
+		return results
+	}
+
+	// Values that are narrower than the register may contain arbitrary data
+	// in the upper bits of the register. We shift the value to the upper bits
+	// of the register, which keeps both the zero and the sign properties of
+	// the value, and discards the irrelevant bits.
+	if size.Cmp(l.PointerSize) < 0 {
+		shift := l.PointerSize.Uint64() - size.Uint64()
+		l.emit(aarch64isa.NewLsl(), []*gen.RegisterInfo{scratch}, l.registerArgument(value), l.immediate(6, shift))
+		value = scratch
+	}
+
+	xzr := l.register(xzrRegisterName)
+	l.emit(aarch64isa.NewSubs(), []*gen.RegisterInfo{xzr}, l.registerArgument(value), l.immediate(12, 0))
+	l.emit(aarch64isa.NewBcond(condition), nil, l.labelArgument(label))
+	return core.ResultList{}
+}
+
+// A switch is lowered into a jump table if it has at least this many cases.
+const minJumpTableCases = 4
+
+// A switch is lowered into a jump table only if at least 2 of each 5 entries
+// in the table are case values (and not gaps that jump to the default label).
+const (
+	jumpTableDensityNumerator   = 2
+	jumpTableDensityDenominator = 5
+)
+
+// switchCase is a single case of a switch instruction, with the case value
+// as an unsigned integer, and the (source) label that the case jumps to.
+type switchCase struct {
+	Value uint64
+	Label *gen.LabelInfo
+}
+
+// Returns the cases of the provided switch instruction, sorted by value. The
+// case values are converted to their unsigned form in the provided size.
+func switchCases(info *gen.InstructionInfo, size *big.Int) []switchCase {
+	mask := new(big.Int).Lsh(big.NewInt(1), uint(size.Uint64()))
+	mask.Sub(mask, big.NewInt(1))
+
+	definition := info.Definition.(usmisa.Switch)
+
+	cases := []switchCase{}
+	for _, sourceCase := range definition.Cases(info) {
+		value := new(big.Int).And(sourceCase.Value.Value, mask)
+		cases = append(cases, switchCase{Value: value.Uint64(), Label: sourceCase.Label})
+	}
+
+	slices.SortFunc(cases, func(a, b switchCase) int {
+		switch {
+		case a.Value < b.Value:
+			return -1
+		case a.Value > b.Value:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	return cases
+}
+
+// Returns true if the provided (sorted) cases are dense enough to be lowered
+// into a jump table.
+func useJumpTable(cases []switchCase) bool {
+	if len(cases) < minJumpTableCases {
+		return false
+	}
+
+	// The table has (span + 1) entries. The first check also ensures that
+	// the table size computation does not overflow.
+	span := cases[len(cases)-1].Value - cases[0].Value
+	limit := uint64(len(cases)) * jumpTableDensityDenominator
+	if span >= limit {
+		return false
+	}
+
+	return (span+1)*jumpTableDensityNumerator <= limit
+}
+
+// Returns an argument that holds the provided value, which is a 12 bit
+// immediate if possible, and otherwise the provided scratch register, into
+// which the value is moved.
+func (l *functionLowering) immediate12OrRegister(
+	value uint64,
+	scratch *gen.RegisterInfo,
+) gen.ArgumentInfo {
+	if value < 1<<12 {
+		return l.immediate(12, value)
+	}
+
+	l.moveImmediate(scratch, new(big.Int).SetUint64(value))
+	return l.registerArgument(scratch)
+}
+
+// Appends a new empty basic block after the current basic block, and sets it
+// as the basic block to which new instructions are emitted.
+func (l *functionLowering) startBasicBlock() core.ResultList {
+	label := l.Labels.GenerateLabel()
+	results := l.Labels.NewLabel(label)
+	if !results.IsEmpty() {
+		return results
+	}
+
+	block := gen.NewEmptyBasicBlockInfo(l.Function)
+	block.SetLabel(label)
+	l.block.AppendBasicBlock(block)
+	l.block = block
+	return core.ResultList{}
+}
+
+// Lowers a switch instruction into a jump table if its cases are dense, and
+// into a chain of comparisons otherwise.
+func (l *functionLowering) lowerSwitch(info *gen.InstructionInfo) core.ResultList {
+	size, results := l.argumentSize(info.Arguments[0])
+	if !results.IsEmpty() {
+		return results
+	}
+
+	scratch := l.register(scratchRegisterNames[0])
+	value, results := l.extendedArgumentToRegister(info.Arguments[0], scratch, size, false)
+	if !results.IsEmpty() {
+		return results
+	}
+
+	cases := switchCases(info, size)
+	if useJumpTable(cases) {
+		return l.lowerJumpTable(info, value, cases)
+	}
+
+	return l.lowerCompareChain(info, value, cases)
+}
+
+// Compares the value with each of the cases in order, and branches to the
+// label of the first matching case, or to the default label if no case
+// matches. Each conditional branch ends a basic block.
+func (l *functionLowering) lowerCompareChain(
+	info *gen.InstructionInfo,
+	value *gen.RegisterInfo,
+	cases []switchCase,
+) core.ResultList {
+	xzr := l.register(xzrRegisterName)
+	scratch := l.register(scratchRegisterNames[1])
+
+	for _, switchCase := range cases {
+		operand := l.immediate12OrRegister(switchCase.Value, scratch)
+		l.emit(aarch64isa.NewSubs(), []*gen.RegisterInfo{xzr}, l.registerArgument(value), operand)
+		l.emit(aarch64isa.NewBcond(immediates.ConditionEq), nil, l.labelArgument(switchCase.Label))
+
+		results := l.startBasicBlock()
+		if !results.IsEmpty() {
+			return results
+		}
+	}
+
+	definition := info.Definition.(usmisa.Switch)
+	l.emit(aarch64isa.NewBranch(), nil, l.labelArgument(definition.Default(info)))
+	return core.ResultList{}
+}
+
+// Lowers the switch into a bounds check, followed by an indirect branch
+// through a table of 32 bit offsets, which is placed right after the branch.
+// Entries of values that do not match any case point to the default label.
+func (l *functionLowering) lowerJumpTable(
+	info *gen.InstructionInfo,
+	value *gen.RegisterInfo,
+	cases []switchCase,
+) core.ResultList {
+	xzr := l.register(xzrRegisterName)
+	index := l.register(scratchRegisterNames[0])
+	base := l.register(scratchRegisterNames[1])
+	definition := info.Definition.(usmisa.Switch)
+	defaultLabel := definition.Default(info)
+
+	// The value is rebased so the table starts at the minimal case value.
+	// Values below the minimum wrap around to large unsigned values, and are
+	// handled by the same (unsigned) bounds check as values above the maximum.
+	minimum := cases[0].Value
+	if minimum != 0 {
+		operand := l.immediate12OrRegister(minimum, base)
+		l.emit(aarch64isa.NewSub(), []*gen.RegisterInfo{index}, l.registerArgument(value), operand)
+		value = index
+	}
+
+	span := cases[len(cases)-1].Value - minimum + 1
+	operand := l.immediate12OrRegister(span-1, base)
+	l.emit(aarch64isa.NewSubs(), []*gen.RegisterInfo{xzr}, l.registerArgument(value), operand)
+	l.emit(aarch64isa.NewBcond(immediates.ConditionHi), nil, l.labelArgument(defaultLabel))
+
+	results := l.startBasicBlock()
+	if !results.IsEmpty() {
+		return results
+	}
+
+	tableLabel := l.Labels.GenerateLabel()
+	results = l.Labels.NewLabel(tableLabel)
+	if !results.IsEmpty() {
+		return results
+	}
+
+	l.emit(aarch64isa.NewAdr(), []*gen.RegisterInfo{base}, gen.NewLabelArgumentInfo(tableLabel))
+	l.emit(aarch64isa.NewLdrsw(), []*gen.RegisterInfo{index}, l.registerArgument(base), l.registerArgument(value))
+	l.emit(aarch64isa.NewAdd(), []*gen.RegisterInfo{base}, l.registerArgument(base), l.registerArgument(index))
+
+	steps, results := definition.PossibleNextSteps(info)
+	if !results.IsEmpty() {
+		return results
+	}
+
+	branchArguments := []gen.ArgumentInfo{l.registerArgument(base)}
+	for _, label := range steps.PossibleBranches {
+		branchArguments = append(branchArguments, l.labelArgument(label))
+	}
+
+	l.emit(aarch64isa.NewBr(), nil, branchArguments...)
+
+	table := gen.NewEmptyBasicBlockInfo(l.Function)
+	table.SetLabel(tableLabel)
+	l.block.AppendBasicBlock(table)
+	l.block = table
+
+	targets := make([]*gen.LabelInfo, span)
+	for i := range targets {
+		targets[i] = defaultLabel
+	}
+
+	for _, switchCase := range cases {
+		targets[switchCase.Value-minimum] = switchCase.Label
+	}
+
+	for _, target := range targets {
+		l.emit(aarch64isa.NewWord(), nil, l.labelArgument(target), gen.NewLabelArgumentInfo(tableLabel))
+	}
+
+	return core.ResultList{}
+}
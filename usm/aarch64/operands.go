@@ -0,0 +1,278 @@
+package usmaarch64
+
+import (
+	"fmt"
+	"math/big"
+
+	"alon.kr/x/list"
+	aarch64isa "alon.kr/x/usm/aarch64/isa"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+)
+
+// MARK: Emit
+
+// Appends a new instruction to the basic block that is currently being lowered.
+// The declaration of the new instruction is the declaration of the source
+// instruction that is currently being lowered.
+func (l *functionLowering) emit(
+	definition gen.InstructionDefinition,
+	targets []*gen.RegisterInfo,
+	arguments ...gen.ArgumentInfo,
+) {
+	var declaration *core.UnmanagedSourceView
+	if l.instruction != nil {
+		declaration = l.instruction.Declaration
+	}
+
+	info := gen.NewEmptyInstructionInfo(declaration)
+	info.Definition = definition
+
+	for _, register := range targets {
+		target := gen.NewTargetInfo(register)
+		info.AppendTarget(&target)
+	}
+
+	for _, argument := range arguments {
+		info.AppendArgument(argument)
+		argument.OnAttach(info)
+	}
+
+	l.block.AppendInstruction(info)
+}
+
+// MARK: Arguments
+
+// Returns the AArch64 register with the provided name.
+func (l *functionLowering) register(name string) *gen.RegisterInfo {
+	return l.Registers.GetRegister(name)
+}
+
+func (l *functionLowering) registerArgument(
+	register *gen.RegisterInfo,
+) gen.ArgumentInfo {
+	return gen.NewRegisterArgumentInfo(register)
+}
+
+// Returns a new AArch64 immediate argument, of the "$<bits>" integer type.
+func (l *functionLowering) immediate(bits int, value uint64) gen.ArgumentInfo {
+	return &gen.ImmediateInfo{
+		Type:  gen.ReferencedTypeInfo{Base: l.Types.GetType(fmt.Sprintf("$%d", bits))},
+		Value: new(big.Int).SetUint64(value),
+	}
+}
+
+func (l *functionLowering) labelArgument(label *gen.LabelInfo) gen.ArgumentInfo {
+	block := l.BasicBlocks[label.BasicBlock]
+	return gen.NewLabelArgumentInfo(block.Label)
+}
+
+// MARK: Registers
+
+// Returns the AArch64 register that is allocated to the provided usm register.
+// Should not be called with spilled registers.
+func (l *functionLowering) allocatedRegister(
+	register *gen.RegisterInfo,
+) *gen.RegisterInfo {
+	return l.register(l.Allocation.Registers[register])
+}
+
+// Returns a new argument that references the stack slot of the provided
+// spilled usm register, as an offset from the stack pointer.
+func (l *functionLowering) stackSlotOffset(register *gen.RegisterInfo) gen.ArgumentInfo {
+	slot := l.Allocation.StackSlots[register]
+	return l.immediate(64, l.Allocation.stackSlotOffset(int(slot)))
+}
+
+// Emits an instruction that loads the value of the provided spilled usm
+// register from its stack slot into the provided AArch64 register.
+func (l *functionLowering) loadSpilled(dst *gen.RegisterInfo, register *gen.RegisterInfo) {
+	sp := l.register(spRegisterName)
+	l.emit(aarch64isa.NewLdr(), []*gen.RegisterInfo{dst}, l.registerArgument(sp), l.stackSlotOffset(register))
+}
+
+// Emits an instruction that stores the value of the provided AArch64 register
+// into the stack slot of the provided spilled usm register.
+func (l *functionLowering) storeSpilled(register *gen.RegisterInfo, src *gen.RegisterInfo) {
+	sp := l.register(spRegisterName)
+	l.emit(aarch64isa.NewStr(), nil, l.registerArgument(src), l.registerArgument(sp), l.stackSlotOffset(register))
+}
+
+// Returns the AArch64 register which should be defined as the provided usm
+// instruction target.
+//
+// If the target register is spilled, the first scratch register is returned,
+// and storeTarget should be called after the value is defined.
+func (l *functionLowering) targetRegister(target *gen.TargetInfo) *gen.RegisterInfo {
+	if _, spilled := l.Allocation.StackSlots[target.Register]; spilled {
+		return l.register(scratchRegisterNames[0])
+	}
+
+	return l.allocatedRegister(target.Register)
+}
+
+// Emits an instruction that stores the value of the provided usm target into
+// its stack slot, if the target register is spilled.
+func (l *functionLowering) storeTarget(target *gen.TargetInfo) {
+	if _, spilled := l.Allocation.StackSlots[target.Register]; spilled {
+		l.storeSpilled(target.Register, l.targetRegister(target))
+	}
+}
+
+// Returns an AArch64 register that holds the value of the provided usm
+// argument.
+//
+// If the argument is not already stored in a register (for example, an
+// immediate or a spilled register), instructions that materialize it into the
+// provided scratch register are emitted.
+func (l *functionLowering) argumentToRegister(
+	argument gen.ArgumentInfo,
+	scratch *gen.RegisterInfo,
+) (*gen.RegisterInfo, core.ResultList) {
+	switch typedArgument := argument.(type) {
+	case *gen.RegisterArgumentInfo:
+		register := typedArgument.Register
+		if _, spilled := l.Allocation.StackSlots[register]; spilled {
+			l.loadSpilled(scratch, register)
+			return scratch, core.ResultList{}
+		}
+
+		return l.allocatedRegister(register), core.ResultList{}
+
+	case *gen.ImmediateInfo:
+		l.moveImmediate(scratch, typedArgument.Value)
+		return scratch, core.ResultList{}
+
+	case *gen.GlobalArgumentInfo:
+		results := l.moveDataGlobalAddress(scratch, typedArgument)
+		if !results.IsEmpty() {
+			return nil, results
+		}
+
+		return scratch, core.ResultList{}
+
+	default:
+		return nil, list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Expected a register, an immediate or a data global argument",
+				Location: argument.Declaration(),
+			},
+		})
+	}
+}
+
+// Returns the size (in bits) of the value of the provided argument.
+func (l *functionLowering) argumentSize(
+	argument gen.ArgumentInfo,
+) (*big.Int, core.ResultList) {
+	typ, results := gen.ArgumentToType(argument)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	results = l.file.assertSupportedType(typ, argument.Declaration())
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return l.file.typeSize(typ), core.ResultList{}
+}
+
+// MARK: Moves
+
+func (l *functionLowering) moveRegister(dst, src *gen.RegisterInfo) {
+	if dst != src {
+		l.emit(aarch64isa.NewMov(), []*gen.RegisterInfo{dst}, l.registerArgument(src))
+	}
+}
+
+// Emits instructions that set the value of the provided register to the
+// provided immediate value, using a "movz" instruction followed by (up to
+// three) "movk" instructions.
+//
+// Negative values are represented in their 64 bit two's complement form.
+func (l *functionLowering) moveImmediate(dst *gen.RegisterInfo, value *big.Int) {
+	mask := new(big.Int).SetUint64(^uint64(0))
+	bits := new(big.Int).And(value, mask).Uint64()
+
+	emitted := false
+	for i := 0; i < 4; i++ {
+		chunk := (bits >> (16 * i)) & 0xffff
+		if chunk == 0 {
+			continue
+		}
+
+		arguments := []gen.ArgumentInfo{l.immediate(16, chunk)}
+		if i > 0 {
+			arguments = append(arguments, l.immediate(8, uint64(16*i)))
+		}
+
+		if emitted {
+			l.emit(aarch64isa.NewMovk(), []*gen.RegisterInfo{dst}, arguments...)
+		} else {
+			l.emit(aarch64isa.NewMovz(), []*gen.RegisterInfo{dst}, arguments...)
+			emitted = true
+		}
+	}
+
+	if !emitted {
+		l.emit(aarch64isa.NewMovz(), []*gen.RegisterInfo{dst}, l.immediate(16, 0))
+	}
+}
+
+// Emits instructions that set the value of the provided register to the
+// address of the provided global: an "adrp" instruction, which computes the
+// address of the page of the global, followed by an "add" instruction, which
+// adds the offset of the global in its page.
+func (l *functionLowering) moveGlobalAddress(dst *gen.RegisterInfo, name string) {
+	global := l.Globals.GetGlobal(name)
+	l.emit(
+		aarch64isa.NewAdrp(),
+		[]*gen.RegisterInfo{dst},
+		&gen.GlobalArgumentInfo{GlobalInfo: global},
+	)
+	l.emit(
+		aarch64isa.NewAdd(),
+		[]*gen.RegisterInfo{dst},
+		l.registerArgument(dst),
+		&gen.GlobalArgumentInfo{GlobalInfo: global},
+	)
+}
+
+// Emits instructions that set the value of the provided register to the
+// address of the provided global variable or constant (see moveGlobalAddress).
+func (l *functionLowering) moveDataGlobalAddress(
+	dst *gen.RegisterInfo,
+	argument *gen.GlobalArgumentInfo,
+) core.ResultList {
+	switch argument.GlobalInfo.(type) {
+	case *gen.VariableGlobalInfo, *gen.ConstantGlobalInfo:
+	default:
+		return list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Only global variables and constants can be used as values in AArch64",
+				Location: argument.Declaration(),
+			},
+		})
+	}
+
+	l.moveGlobalAddress(dst, argument.Name())
+	return core.ResultList{}
+}
+
+// Emits instructions that set the value of the provided register to the value
+// of the provided usm argument.
+func (l *functionLowering) moveArgument(
+	dst *gen.RegisterInfo,
+	argument gen.ArgumentInfo,
+) core.ResultList {
+	src, results := l.argumentToRegister(argument, dst)
+	if !results.IsEmpty() {
+		return results
+	}
+
+	l.moveRegister(dst, src)
+	return core.ResultList{}
+}
@@ -0,0 +1,98 @@
+package usmaarch64
+
+import (
+	"alon.kr/x/usm/gen"
+)
+
+// The maximal number of arguments and return values that are passed in
+// registers, according to the AArch64 procedure call standard (AAPCS64).
+const maxRegisterArguments = 8
+
+// Registers x16 and x17 (IP0 and IP1) are intra-procedure-call scratch
+// registers. The lowering uses them to materialize immediates and other
+// temporary values, and they are never assigned to usm registers.
+var scratchRegisterNames = [2]string{"%x16", "%x17"}
+
+var argumentRegisterNames = [maxRegisterArguments]string{
+	"%x0", "%x1", "%x2", "%x3", "%x4", "%x5", "%x6", "%x7",
+}
+
+// Temporary registers (x8-x15), which are not preserved across calls.
+var temporaryRegisterNames = []string{
+	"%x8", "%x9", "%x10", "%x11", "%x12", "%x13", "%x14", "%x15",
+}
+
+// Callee saved registers (x19-x28), which hold their value across calls.
+var calleeSavedRegisterNames = []string{
+	"%x19", "%x20", "%x21", "%x22", "%x23",
+	"%x24", "%x25", "%x26", "%x27", "%x28",
+}
+
+const (
+	spRegisterName           = "%sp"
+	xzrRegisterName          = "%xzr"
+	framePointerRegisterName = "%x29"
+	linkRegisterName         = "%x30"
+)
+
+// RegisterAllocation describes the location of each usm register of a
+// function in the lowered AArch64 function.
+type RegisterAllocation struct {
+	// Maps each usm register to the name of the AArch64 register that holds
+	// its value.
+	Registers map[*gen.RegisterInfo]string
+
+	// The callee saved registers that are used by the allocation, and should
+	// be saved and restored by the function prologue and epilogue.
+	CalleeSaved []string
+
+	// Maps each usm register that is spilled to the stack to the index of its
+	// stack slot. Spilled registers do not appear in Registers.
+	StackSlots map[*gen.RegisterInfo]uint64
+}
+
+// Returns the offset (in bytes) of the provided stack slot from the stack
+// pointer. Stack slots are located in the frame after the saved registers.
+func (a *RegisterAllocation) stackSlotOffset(slot int) uint64 {
+	savedRegisters := 2 + len(a.CalleeSaved)
+	return uint64(8 * (savedRegisters + slot))
+}
+
+// Returns the size of the stack frame that is required by the allocation, in
+// bytes. The frame contains the frame pointer and link register, the callee
+// saved registers and the stack slots, and is aligned to 16 bytes.
+func (a *RegisterAllocation) frameSize() uint64 {
+	size := a.stackSlotOffset(len(a.StackSlots))
+	return (size + 15) &^ 15
+}
+
+// Returns the usm registers of the function, ordered by their first
+// appearance: first the function parameters, and then the registers in the
+// order that they appear in the function instructions.
+func collectRegistersInOrder(function *gen.FunctionInfo) []*gen.RegisterInfo {
+	seen := make(map[*gen.RegisterInfo]struct{})
+	registers := []*gen.RegisterInfo{}
+
+	add := func(register *gen.RegisterInfo) {
+		if _, ok := seen[register]; !ok {
+			seen[register] = struct{}{}
+			registers = append(registers, register)
+		}
+	}
+
+	for _, parameter := range function.Parameters {
+		add(parameter)
+	}
+
+	for _, instruction := range function.CollectInstructions() {
+		for _, register := range gen.ArgumentsToRegisters(instruction.Arguments) {
+			add(register)
+		}
+
+		for _, register := range gen.TargetsToRegisters(instruction.Targets) {
+			add(register)
+		}
+	}
+
+	return registers
+}
@@ -0,0 +1,73 @@
+package usmisa
+
+import (
+	"fmt"
+
+	"alon.kr/x/list"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/opt"
+)
+
+// The alloca instruction reserves a slot on the stack of the function, and
+// defines its target as a pointer to the slot. The type of the slot is the
+// type that the target points to:
+//
+//	$32 * %p = alloca
+type Alloca struct {
+	// Control Flow
+	gen.NonBranchingInstruction
+
+	// Dead Code Elimination
+	opt.NonCriticalInstruction
+	opt.UsesArgumentsInstruction
+	opt.DefinesTargetsInstruction
+}
+
+func NewAlloca() gen.InstructionDefinition {
+	return Alloca{}
+}
+
+func (Alloca) Operator(*gen.InstructionInfo) string {
+	return "alloca"
+}
+
+func (Alloca) Validate(info *gen.InstructionInfo) core.ResultList {
+	results := core.ResultList{}
+
+	curResults := gen.AssertTargetsExactly(info, 1)
+	results.Extend(&curResults)
+
+	curResults = gen.AssertArgumentsExactly(info, 0)
+	results.Extend(&curResults)
+
+	if !results.IsEmpty() {
+		return results
+	}
+
+	targetType := info.Targets[0].Register.Type
+	if !targetType.IsPointer() {
+		return list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Expected a pointer target",
+				Location: info.Targets[0].Declaration,
+			},
+			{
+				Type:     core.HintResult,
+				Message:  fmt.Sprintf("Target type is \"%s\"", targetType),
+				Location: targetType.Declaration,
+			},
+		})
+	}
+
+	return core.ResultList{}
+}
+
+func (Alloca) Defines(info *gen.InstructionInfo) []*gen.RegisterInfo {
+	return gen.TargetsToRegisters(info.Targets)
+}
+
+func (Alloca) Uses(info *gen.InstructionInfo) []*gen.RegisterInfo {
+	return gen.ArgumentsToRegisters(info.Arguments)
+}
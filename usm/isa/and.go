@@ -1,7 +1,11 @@
 package usmisa
 
 import (
+	"math/big"
+
+	"alon.kr/x/usm/core"
 	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
 )
 
 type And struct {
@@ -16,3 +20,17 @@ func NewAnd() gen.InstructionDefinition {
 func (And) Operator(*gen.InstructionInfo) string {
 	return "and"
 }
+
+func (And) Fold(
+	info *gen.InstructionInfo,
+	arguments []*big.Int,
+) ([]*big.Int, core.ResultList) {
+	return []*big.Int{new(big.Int).And(arguments[0], arguments[1])}, core.ResultList{}
+}
+
+func (i And) Execute(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	return executeFolding(i, info, frame)
+}
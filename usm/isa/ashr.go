@@ -0,0 +1,45 @@
+package usmisa
+
+import (
+	"math/big"
+
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
+)
+
+// Arithmetically shifts the value right, filling the upper bits with copies
+// of the sign bit.
+type Ashr struct {
+	Shift
+}
+
+func NewAshr() gen.InstructionDefinition {
+	return Ashr{}
+}
+
+func (Ashr) Operator(*gen.InstructionInfo) string {
+	return "ashr"
+}
+
+// Values are represented in their signed form, and shifting a negative big
+// integer right rounds towards negative infinity, which matches the
+// arithmetic shift.
+func (i Ashr) Fold(
+	info *gen.InstructionInfo,
+	arguments []*big.Int,
+) ([]*big.Int, core.ResultList) {
+	_, amount, results := i.operands(info, arguments)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return []*big.Int{new(big.Int).Rsh(arguments[0], amount)}, core.ResultList{}
+}
+
+func (i Ashr) Execute(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	return executeFolding(i, info, frame)
+}
@@ -1,13 +1,24 @@
 package usmisa
 
 import (
+	"fmt"
+
 	"alon.kr/x/list"
 	"alon.kr/x/usm/core"
 	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
 	"alon.kr/x/usm/opt"
 )
 
-// The call instruction
+// The call instruction calls a function, and assigns the values that the
+// function returns to the targets of the instruction.
+//
+// The first argument is either a global function (a direct call), or a value
+// of a pointer to function type (an indirect call), which is validated
+// against the signature of the function type:
+//
+//	$32 %r = call @add %a %b
+//	$32 %r = call %p %a %b
 type Call struct {
 	// Control Flow
 	gen.NonBranchingInstruction
@@ -26,23 +37,46 @@ func (Call) Operator(*gen.InstructionInfo) string {
 	return "call"
 }
 
-func (Call) Validate(info *gen.InstructionInfo) core.ResultList {
+// Returns true if the call is an indirect call, through a function pointer.
+func (Call) IsIndirect(info *gen.InstructionInfo) bool {
+	_, ok := info.Arguments[0].(*gen.GlobalArgumentInfo)
+	return !ok
+}
+
+func (Call) validateIndirect(info *gen.InstructionInfo) core.ResultList {
+	signature, typ, results := argumentSignature(info.Arguments[0])
+	if !results.IsEmpty() {
+		return results
+	}
+
+	hint := core.ResultDetails{
+		Type:     core.HintResult,
+		Message:  fmt.Sprintf("Type \"%s\" declared here", typ.Base),
+		Location: typ.Base.Declaration,
+	}
+
+	return assertCallMatchesSignature(info, signature.Returns, signature.Parameters, hint)
+}
+
+// Validates that the arguments and targets of the call match the types of the
+// parameters and return values of the called function, or of the function
+// type of the called pointer.
+func (i Call) Validate(info *gen.InstructionInfo) core.ResultList {
 	results := gen.AssertAtLeastArguments(info, 1)
 	if !results.IsEmpty() {
 		return results
 	}
 
-	funcArg, ok := info.Arguments[0].(*gen.GlobalArgumentInfo)
-	if !ok {
-		return list.FromSingle(core.Result{
-			{
-				Type:     core.ErrorResult,
-				Message:  "Call instruction requires a global function argument as the first argument",
-				Location: funcArg.Declaration(),
-			},
-		})
+	if i.IsIndirect(info) {
+		return i.validateIndirect(info)
 	}
 
+	return i.validateDirect(info)
+}
+
+func (Call) validateDirect(info *gen.InstructionInfo) core.ResultList {
+	funcArg := info.Arguments[0].(*gen.GlobalArgumentInfo)
+
 	funcInfo := info.FileInfo.GetFunction(funcArg.Name())
 	if funcInfo == nil {
 		return list.FromSingle(core.Result{
@@ -54,18 +88,14 @@ func (Call) Validate(info *gen.InstructionInfo) core.ResultList {
 		})
 	}
 
-	// TODO: this should also check types.
-	curResults := gen.AssertArgumentsExactly(info, len(funcInfo.Parameters)+1)
-	results.Extend(&curResults)
-
-	curResults = gen.AssertTargetsExactly(info, len(funcInfo.Targets))
-	results.Extend(&curResults)
-
-	if !results.IsEmpty() {
-		return results
+	hint := core.ResultDetails{
+		Type:     core.HintResult,
+		Message:  fmt.Sprintf("Function \"%s\" declared here", funcInfo.Name),
+		Location: funcInfo.Declaration,
 	}
 
-	return core.ResultList{}
+	parameters := functionParameterTypes(funcInfo)
+	return assertCallMatchesSignature(info, funcInfo.Targets, parameters, hint)
 }
 
 func (Call) Defines(info *gen.InstructionInfo) []*gen.RegisterInfo {
@@ -75,3 +105,56 @@ func (Call) Defines(info *gen.InstructionInfo) []*gen.RegisterInfo {
 func (Call) Uses(info *gen.InstructionInfo) []*gen.RegisterInfo {
 	return gen.ArgumentsToRegisters(info.Arguments)
 }
+
+// Returns the function that is called by the instruction, in the provided
+// frame.
+func (i Call) callee(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (*gen.FunctionInfo, core.ResultList) {
+	if !i.IsIndirect(info) {
+		funcArg := info.Arguments[0].(*gen.GlobalArgumentInfo)
+		return info.FileInfo.GetFunction(funcArg.Name()), core.ResultList{}
+	}
+
+	address, results := frame.Value(info.Arguments[0])
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	function := frame.FunctionAt(address)
+	if function == nil {
+		return nil, list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Call through an invalid function pointer",
+				Location: info.Arguments[0].Declaration(),
+			},
+		})
+	}
+
+	return function, core.ResultList{}
+}
+
+func (i Call) Execute(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	function, results := i.callee(info, frame)
+	if !results.IsEmpty() {
+		return interpreter.Step{}, results
+	}
+
+	arguments, results := frame.Values(info.Arguments[1:])
+	if !results.IsEmpty() {
+		return interpreter.Step{}, results
+	}
+
+	values, results := frame.Call(function, arguments)
+	if !results.IsEmpty() {
+		return interpreter.Step{}, results
+	}
+
+	frame.SetTargets(info.Targets, values)
+	return interpreter.ContinueStep(), core.ResultList{}
+}
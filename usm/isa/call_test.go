@@ -0,0 +1,126 @@
+package usmisa_test
+
+import (
+	"testing"
+
+	"alon.kr/x/usm/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const functionTypeSource = `type $binop $32 = func $32 $32
+
+func $32 @add $32 %a $32 %b {
+	$32 %r = add %a %b
+	ret %r
+}
+
+func $8 @narrow $8 %a $32 %b {
+	ret %a
+}
+`
+
+func TestFunctionPointer(t *testing.T) {
+	src := functionTypeSource + `
+func $32 @f $32 %x {
+	$binop * %p = fptr @add
+	$32 %r = call %p %x $32 #1
+	ret %r
+}
+`
+	_, results := generateFile(t, src)
+	assert.True(t, results.IsEmpty())
+}
+
+func TestFunctionPointerSignatureMismatch(t *testing.T) {
+	src := functionTypeSource + `
+func @f {
+	$binop * %p = fptr @narrow
+	ret
+}
+`
+	_, results := generateFile(t, src)
+	require.False(t, results.IsEmpty())
+
+	details := results.Head.Value
+	require.Len(t, details, 3)
+	assert.Equal(t, `Function "@narrow" does not match the signature of type "$binop"`, details[0].Message)
+}
+
+func TestFunctionPointerRequiresFunctionType(t *testing.T) {
+	_, results := generateFile(t, functionTypeSource+"func @f {\n\t$32 * %p = fptr @add\n\tret\n}\n")
+	assert.False(t, results.IsEmpty())
+}
+
+func TestIndirectCallSignatureMismatch(t *testing.T) {
+	src := functionTypeSource + `
+func @f $binop * %p $8 %x {
+	$32 %r = call %p %x $32 #1
+	ret
+}
+`
+	_, results := generateFile(t, src)
+	require.False(t, results.IsEmpty())
+
+	details := results.Head.Value
+	require.Len(t, details, 2)
+	assert.Equal(t, `Argument type "$8" does not match the parameter type "$32"`, details[0].Message)
+	assert.Equal(t, `Type "$binop" declared here`, details[1].Message)
+
+	_, results = generateFile(t, functionTypeSource+"func @f $binop * %p {\n\tcall %p $32 #1 $32 #2\n\tret\n}\n")
+	assert.False(t, results.IsEmpty())
+
+	_, results = generateFile(t, functionTypeSource+"func @f $32 %p {\n\t$32 %r = call %p $32 #1 $32 #2\n\tret\n}\n")
+	assert.False(t, results.IsEmpty())
+}
+
+func TestDirectCallArgumentTypeMismatch(t *testing.T) {
+	src := `func $32 @external $32 %a $8 * %p
+
+func @f $8 %x {
+	$32 %r = call @external %x %x
+	ret
+}
+`
+	_, results := generateFile(t, src)
+	require.False(t, results.IsEmpty())
+
+	ctx := core.NewSourceView(src).Ctx()
+	details := results.Head.Value
+	require.Len(t, details, 2)
+	assert.Equal(t, `Argument type "$8" does not match the parameter type "$32"`, details[0].Message)
+	assert.Equal(t, "%x", string(details[0].Location.Raw(ctx)))
+	assert.Equal(t, `Function "@external" declared here`, details[1].Message)
+	assert.Contains(t, string(details[1].Location.Raw(ctx)), "@external $32 %a")
+
+	// Both arguments are reported.
+	assert.Equal(t, 2, results.Len())
+}
+
+func TestDirectCallTargetTypeMismatch(t *testing.T) {
+	src := functionTypeSource + `
+func @f {
+	$8 %r = call @add $32 #1 $32 #2
+	ret
+}
+`
+	_, results := generateFile(t, src)
+	require.False(t, results.IsEmpty())
+
+	details := results.Head.Value
+	assert.Equal(t, `Target type "$8" does not match the return type "$32"`, details[0].Message)
+}
+
+func TestDirectCallArgumentCount(t *testing.T) {
+	_, results := generateFile(t, functionTypeSource+"func @f {\n\t$32 %r = call @add $32 #1\n\tret\n}\n")
+	require.False(t, results.IsEmpty())
+	assert.Equal(t, "Expected 2 call arguments, but got 1", results.Head.Value[0].Message)
+
+	_, results = generateFile(t, functionTypeSource+"func @f {\n\t$32 %r = call @add $32 #1 $32 #2\n\tret\n}\n")
+	assert.True(t, results.IsEmpty())
+}
+
+func TestCallNonFunctionArgument(t *testing.T) {
+	_, results := generateFile(t, "func @f {\n\tcall $32 #1\n\tret\n}\n")
+	assert.False(t, results.IsEmpty())
+}
@@ -0,0 +1,142 @@
+package usmisa
+
+import (
+	"fmt"
+	"math/big"
+
+	"alon.kr/x/list"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/opt"
+)
+
+// Comparison implements the shared functionality of comparison instructions,
+// which compare two integer arguments of the same type, and define a "$1"
+// target which is one if the comparison holds, and zero otherwise:
+//
+//	$1 %c = slt %a %b
+//
+// Comparisons whose name starts with "u" interpret the arguments as unsigned
+// integers, and comparisons whose name starts with "s" interpret them as
+// signed integers.
+type Comparison struct {
+	// Control Flow
+	gen.NonBranchingInstruction
+
+	// Dead Code Elimination
+	opt.NonCriticalInstruction
+	opt.UsesArgumentsInstruction
+	opt.DefinesTargetsInstruction
+}
+
+func (Comparison) Validate(info *gen.InstructionInfo) core.ResultList {
+	results := core.ResultList{}
+
+	curResults := gen.AssertTargetsExactly(info, 1)
+	results.Extend(&curResults)
+
+	curResults = gen.AssertArgumentsExactly(info, 2)
+	results.Extend(&curResults)
+
+	if !results.IsEmpty() {
+		return results
+	}
+
+	_, curResults = argumentIntegerSize(info.Arguments[0])
+	results.Extend(&curResults)
+
+	_, curResults = argumentIntegerSize(info.Arguments[1])
+	results.Extend(&curResults)
+
+	targetType := gen.TargetToType(info.Targets[0])
+	targetSize := targetType.IntegerSize()
+	if targetSize == nil || targetSize.Cmp(big.NewInt(1)) != 0 {
+		results.Append(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Expected a \"$1\" target",
+				Location: info.Targets[0].Declaration,
+			},
+			{
+				Type:     core.HintResult,
+				Message:  fmt.Sprintf("Target type is \"%s\"", targetType),
+				Location: targetType.Declaration,
+			},
+		})
+	}
+
+	if !results.IsEmpty() {
+		return results
+	}
+
+	leftType, results := gen.ArgumentToType(info.Arguments[0])
+	if !results.IsEmpty() {
+		return results
+	}
+
+	rightType, results := gen.ArgumentToType(info.Arguments[1])
+	if !results.IsEmpty() {
+		return results
+	}
+
+	if !leftType.Equal(rightType) {
+		return list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Compared arguments are of different types",
+				Location: info.Declaration,
+			},
+			{
+				Type:     core.HintResult,
+				Message:  fmt.Sprintf("Left argument type is \"%s\"", leftType),
+				Location: leftType.Declaration,
+			},
+			{
+				Type:     core.HintResult,
+				Message:  fmt.Sprintf("Right argument type is \"%s\"", rightType),
+				Location: rightType.Declaration,
+			},
+		})
+	}
+
+	return core.ResultList{}
+}
+
+func (Comparison) Defines(info *gen.InstructionInfo) []*gen.RegisterInfo {
+	return gen.TargetsToRegisters(info.Targets)
+}
+
+func (Comparison) Uses(info *gen.InstructionInfo) []*gen.RegisterInfo {
+	return gen.ArgumentsToRegisters(info.Arguments)
+}
+
+// Compares the argument values, and returns -1, 0 or +1 if the left argument
+// is smaller, equal or greater than the right argument, respectively.
+func (Comparison) compare(
+	info *gen.InstructionInfo,
+	arguments []*big.Int,
+	signed bool,
+) (int, core.ResultList) {
+	if signed {
+		return arguments[0].Cmp(arguments[1]), core.ResultList{}
+	}
+
+	size, results := argumentIntegerSize(info.Arguments[0])
+	if !results.IsEmpty() {
+		return 0, results
+	}
+
+	left := unsignedValue(arguments[0], size)
+	right := unsignedValue(arguments[1], size)
+	return left.Cmp(right), core.ResultList{}
+}
+
+// Returns the value of a comparison target, provided whether the comparison
+// holds.
+func comparisonValue(holds bool) []*big.Int {
+	if holds {
+		return []*big.Int{big.NewInt(1)}
+	}
+
+	return []*big.Int{big.NewInt(0)}
+}
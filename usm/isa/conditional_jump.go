@@ -43,3 +43,13 @@ func (ConditionalJump) Validate(info *gen.InstructionInfo) core.ResultList {
 
 	return core.ResultList{}
 }
+
+// Returns the next step of the conditional jump, provided whether the condition
+// of the jump holds.
+func (ConditionalJump) foldBranch(info *gen.InstructionInfo, taken bool) gen.StepInfo {
+	if taken {
+		return gen.StepInfo{PossibleBranches: gen.ArgumentsToLabels(info.Arguments)}
+	}
+
+	return gen.StepInfo{PossibleContinue: true}
+}
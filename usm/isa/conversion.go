@@ -0,0 +1,138 @@
+package usmisa
+
+import (
+	"fmt"
+	"math/big"
+
+	"alon.kr/x/list"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/opt"
+)
+
+// Returns the bit width of the type of the provided argument, or an error if
+// the argument is not an integer.
+func argumentIntegerSize(argument gen.ArgumentInfo) (*big.Int, core.ResultList) {
+	typ, results := gen.ArgumentToType(argument)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	size := typ.IntegerSize()
+	if size == nil {
+		return nil, list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Expected an integer argument",
+				Location: argument.Declaration(),
+			},
+			{
+				Type:     core.HintResult,
+				Message:  fmt.Sprintf("Argument type is \"%s\"", typ),
+				Location: typ.Declaration,
+			},
+		})
+	}
+
+	return size, core.ResultList{}
+}
+
+// WidthConversion implements the shared functionality of instructions that
+// convert a single integer argument to an integer target of a different
+// width.
+type WidthConversion struct {
+	// Control Flow
+	gen.NonBranchingInstruction
+
+	// Dead Code Elimination
+	opt.NonCriticalInstruction
+	opt.UsesArgumentsInstruction
+	opt.DefinesTargetsInstruction
+
+	// True if the target should be strictly wider than the argument, and false
+	// if it should be strictly narrower.
+	Extends bool
+}
+
+// Validates that there is exactly one integer argument and one integer target,
+// and that the target is wider (or narrower) than the argument.
+func (c WidthConversion) Validate(info *gen.InstructionInfo) core.ResultList {
+	results := core.ResultList{}
+
+	curResults := gen.AssertTargetsExactly(info, 1)
+	results.Extend(&curResults)
+
+	curResults = gen.AssertArgumentsExactly(info, 1)
+	results.Extend(&curResults)
+
+	if !results.IsEmpty() {
+		return results
+	}
+
+	argumentSize, results := argumentIntegerSize(info.Arguments[0])
+	if !results.IsEmpty() {
+		return results
+	}
+
+	targetType := gen.TargetToType(info.Targets[0])
+	targetSize := targetType.IntegerSize()
+	if targetSize == nil {
+		return list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Expected an integer target",
+				Location: info.Targets[0].Declaration,
+			},
+			{
+				Type:     core.HintResult,
+				Message:  fmt.Sprintf("Target type is \"%s\"", targetType),
+				Location: targetType.Declaration,
+			},
+		})
+	}
+
+	cmp := targetSize.Cmp(argumentSize)
+	if c.Extends && cmp <= 0 {
+		return list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Target must be wider than the argument",
+				Location: info.Declaration,
+			},
+			{
+				Type: core.HintResult,
+				Message: fmt.Sprintf(
+					"Argument is %s bits wide, and target is %s bits wide",
+					argumentSize, targetSize,
+				),
+			},
+		})
+	}
+
+	if !c.Extends && cmp >= 0 {
+		return list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Target must be narrower than the argument",
+				Location: info.Declaration,
+			},
+			{
+				Type: core.HintResult,
+				Message: fmt.Sprintf(
+					"Argument is %s bits wide, and target is %s bits wide",
+					argumentSize, targetSize,
+				),
+			},
+		})
+	}
+
+	return core.ResultList{}
+}
+
+func (WidthConversion) Defines(info *gen.InstructionInfo) []*gen.RegisterInfo {
+	return gen.TargetsToRegisters(info.Targets)
+}
+
+func (WidthConversion) Uses(info *gen.InstructionInfo) []*gen.RegisterInfo {
+	return gen.ArgumentsToRegisters(info.Arguments)
+}
@@ -0,0 +1,81 @@
+package usmisa
+
+import (
+	"math/big"
+
+	"alon.kr/x/list"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
+	"alon.kr/x/usm/opt"
+)
+
+// Division implements the shared functionality of the division and remainder
+// instructions, which are binary calculations whose right argument (the
+// divisor) must not be zero.
+type Division struct {
+	// Inherits most of the functionality from BinaryCalculation
+	BinaryCalculation
+}
+
+// Validates the instruction as a binary calculation, and that the divisor is
+// not a constant zero.
+func (i Division) Validate(info *gen.InstructionInfo) core.ResultList {
+	results := i.BinaryCalculation.Validate(info)
+	if !results.IsEmpty() {
+		return results
+	}
+
+	divisor, ok := info.Arguments[1].(*gen.ImmediateInfo)
+	if ok && divisor.Value.Sign() == 0 {
+		return list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Division by zero",
+				Location: divisor.Declaration(),
+			},
+		})
+	}
+
+	return core.ResultList{}
+}
+
+// A constant zero divisor is rejected by Validate, so constant propagation
+// keeps the register divisor in that case.
+func (Division) CanSubstituteArgument(
+	info *gen.InstructionInfo,
+	index int,
+	immediate *gen.ImmediateInfo,
+) bool {
+	return index != 1 || immediate.Value.Sign() != 0
+}
+
+// Returns the width of the arguments, in bits.
+func (Division) size(info *gen.InstructionInfo) (*big.Int, core.ResultList) {
+	return argumentIntegerSize(info.Arguments[0])
+}
+
+// Executes the division instruction, or returns an error if the divisor is
+// zero. A division by zero can't be folded, so its target is overdefined.
+func executeDivision(
+	definition opt.ConstantFoldingInstruction,
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	divisor, results := frame.Value(info.Arguments[1])
+	if !results.IsEmpty() {
+		return interpreter.Step{}, results
+	}
+
+	if divisor.Sign() == 0 {
+		return interpreter.Step{}, list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Division by zero",
+				Location: info.Declaration,
+			},
+		})
+	}
+
+	return executeFolding(definition, info, frame)
+}
@@ -0,0 +1,37 @@
+package usmisa_test
+
+import (
+	"testing"
+
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/internal/testutil"
+	usmmanagers "alon.kr/x/usm/usm/managers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateFile(t *testing.T, source string) (*gen.FileInfo, core.ResultList) {
+	t.Helper()
+	return testutil.GenerateFile(t, usmmanagers.NewGenerationContext(), source)
+}
+
+func TestDivisionByConstantZero(t *testing.T) {
+	for _, operator := range []string{"udiv", "sdiv", "urem", "srem"} {
+		src := "func $32 @f $32 %a {\n\t$32 %b = " + operator + " %a $32 #0\n\tret %b\n}\n"
+		_, results := generateFile(t, src)
+		require.False(t, results.IsEmpty(), operator)
+		assert.Equal(t, "Division by zero", results.Head.Value[0].Message)
+	}
+
+	_, results := generateFile(t, "func $32 @f $32 %a {\n\t$32 %b = udiv %a $32 #3\n\tret %b\n}\n")
+	assert.True(t, results.IsEmpty())
+}
+
+func TestComparisonRequiresBitTarget(t *testing.T) {
+	_, results := generateFile(t, "func @f $32 %a {\n\t$32 %c = slt %a %a\n\tret\n}\n")
+	assert.False(t, results.IsEmpty())
+
+	_, results = generateFile(t, "func @f $32 %a $8 %b {\n\t$1 %c = slt %a %b\n\tret\n}\n")
+	assert.False(t, results.IsEmpty())
+}
@@ -0,0 +1,41 @@
+package usmisa
+
+import (
+	"math/big"
+
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
+)
+
+// Compares whether the arguments are equal.
+type Eq struct {
+	Comparison
+}
+
+func NewEq() gen.InstructionDefinition {
+	return Eq{}
+}
+
+func (Eq) Operator(*gen.InstructionInfo) string {
+	return "eq"
+}
+
+func (i Eq) Fold(
+	info *gen.InstructionInfo,
+	arguments []*big.Int,
+) ([]*big.Int, core.ResultList) {
+	cmp, results := i.compare(info, arguments, false)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return comparisonValue(cmp == 0), core.ResultList{}
+}
+
+func (i Eq) Execute(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	return executeFolding(i, info, frame)
+}
@@ -0,0 +1,81 @@
+package usmisa
+
+import (
+	"math/big"
+
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
+	"alon.kr/x/usm/opt"
+)
+
+// Returns the current values of the instruction arguments, in the form that
+// is expected by the Fold and FoldBranch methods: values of label arguments
+// are nil.
+func argumentValues(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) ([]*big.Int, core.ResultList) {
+	values := make([]*big.Int, len(info.Arguments))
+	results := core.ResultList{}
+
+	for i, argument := range info.Arguments {
+		if _, ok := argument.(*gen.LabelArgumentInfo); ok {
+			continue
+		}
+
+		value, curResults := frame.Value(argument)
+		results.Extend(&curResults)
+		values[i] = value
+	}
+
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return values, core.ResultList{}
+}
+
+// Executes an instruction whose targets are computed by its Fold method.
+func executeFolding(
+	definition opt.ConstantFoldingInstruction,
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	arguments, results := argumentValues(info, frame)
+	if !results.IsEmpty() {
+		return interpreter.Step{}, results
+	}
+
+	values, results := definition.Fold(info, arguments)
+	if !results.IsEmpty() {
+		return interpreter.Step{}, results
+	}
+
+	frame.SetTargets(info.Targets, values)
+	return interpreter.ContinueStep(), core.ResultList{}
+}
+
+// Executes a branching instruction whose next step is determined by its
+// FoldBranch method.
+func executeBranchFolding(
+	definition opt.ConstantBranchingInstruction,
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	arguments, results := argumentValues(info, frame)
+	if !results.IsEmpty() {
+		return interpreter.Step{}, results
+	}
+
+	step, results := definition.FoldBranch(info, arguments)
+	if !results.IsEmpty() {
+		return interpreter.Step{}, results
+	}
+
+	if step.IsBranchPossible() {
+		return interpreter.BranchStep(step.PossibleBranches[0]), core.ResultList{}
+	}
+
+	return interpreter.ContinueStep(), core.ResultList{}
+}
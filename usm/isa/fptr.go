@@ -0,0 +1,116 @@
+package usmisa
+
+import (
+	"fmt"
+	"math/big"
+
+	"alon.kr/x/list"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
+	"alon.kr/x/usm/opt"
+)
+
+// The fptr instruction materializes the address of a function as a value of a
+// pointer to function type. The signature of the function should match the
+// signature of the function type:
+//
+//	type $binop $32 = func $32 $32
+//	...
+//	$binop * %p = fptr @add
+//
+// The pointer can later be called by an indirect "call" instruction.
+type Fptr struct {
+	// Control Flow
+	gen.NonBranchingInstruction
+
+	// Dead Code Elimination
+	opt.NonCriticalInstruction
+	opt.UsesArgumentsInstruction
+	opt.DefinesTargetsInstruction
+}
+
+func NewFptr() gen.InstructionDefinition {
+	return Fptr{}
+}
+
+func (Fptr) Operator(*gen.InstructionInfo) string {
+	return "fptr"
+}
+
+func (Fptr) Validate(info *gen.InstructionInfo) core.ResultList {
+	results := gen.AssertTargetsExactly(info, 1)
+
+	curResults := gen.AssertArgumentsExactly(info, 1)
+	results.Extend(&curResults)
+
+	if !results.IsEmpty() {
+		return results
+	}
+
+	function, results := argumentFunction(info, info.Arguments[0])
+	if !results.IsEmpty() {
+		return results
+	}
+
+	target := info.Targets[0]
+	typ := target.Register.Type
+	signature := typ.FunctionSignature()
+	if signature == nil {
+		location := target.Declaration
+		if location == nil {
+			location = info.Declaration
+		}
+
+		return list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Expected a pointer to a function type target",
+				Location: location,
+			},
+			{
+				Type:     core.HintResult,
+				Message:  fmt.Sprintf("Target type is \"%s\"", typ),
+				Location: typ.Declaration,
+			},
+		})
+	}
+
+	parameters := functionParameterTypes(function)
+	if !typesEqual(signature.Parameters, parameters) || !typesEqual(signature.Returns, function.Targets) {
+		return list.FromSingle(core.Result{
+			{
+				Type: core.ErrorResult,
+				Message: fmt.Sprintf(
+					"Function \"%s\" does not match the signature of type \"%s\"",
+					function.Name, typ.Base,
+				),
+				Location: info.Arguments[0].Declaration(),
+			},
+			{
+				Type:     core.HintResult,
+				Message:  "Function declared here",
+				Location: function.Declaration,
+			},
+			{
+				Type:     core.HintResult,
+				Message:  fmt.Sprintf("Type \"%s\" declared here", typ.Base),
+				Location: typ.Base.Declaration,
+			},
+		})
+	}
+
+	return core.ResultList{}
+}
+
+func (Fptr) Execute(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	global := info.Arguments[0].(*gen.GlobalArgumentInfo)
+	function := info.FileInfo.GetFunction(global.Name())
+
+	address := frame.FunctionAddress(function)
+	frame.SetTargets(info.Targets, []*big.Int{address})
+	return interpreter.ContinueStep(), core.ResultList{}
+}
@@ -0,0 +1,207 @@
+package usmisa
+
+import (
+	"fmt"
+	"math/big"
+
+	"alon.kr/x/list"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/opt"
+)
+
+// The gep ("get element pointer") instruction computes the address of an
+// element inside the value that its first (pointer) argument points to.
+//
+// If the pointer points to a repeated type, the second argument is the index
+// of the element in the repeated type, which can be any integer:
+//
+//	$32 * %q = gep %p %i   ; %p is of type "$32 ^4 *"
+//
+// If the pointer points to a declared type, the second argument must be an
+// immediate which is the index of the field in the declared type:
+//
+//	$8 * %q = gep %p $64 #1   ; %p is a pointer to a declared type
+type Gep struct {
+	// Control Flow
+	gen.NonBranchingInstruction
+
+	// Dead Code Elimination
+	opt.NonCriticalInstruction
+	opt.UsesArgumentsInstruction
+	opt.DefinesTargetsInstruction
+}
+
+func NewGep() gen.InstructionDefinition {
+	return Gep{}
+}
+
+func (Gep) Operator(*gen.InstructionInfo) string {
+	return "gep"
+}
+
+// Returns an error if the provided immediate index is not in the range
+// [0, amount). The hint describes the amount of elements in the indexed type.
+func (Gep) assertIndexInRange(
+	index *gen.ImmediateInfo,
+	amount *big.Int,
+	typ gen.ReferencedTypeInfo,
+	hint core.ResultDetails,
+) core.ResultList {
+	if index.Value.Sign() >= 0 && index.Value.Cmp(amount) < 0 {
+		return core.ResultList{}
+	}
+
+	return list.FromSingle(core.Result{
+		{
+			Type:     core.ErrorResult,
+			Message:  fmt.Sprintf("Index out of range of type \"%s\"", typ),
+			Location: index.Declaration(),
+		},
+		hint,
+	})
+}
+
+// Returns the type of the element of a repeated type that is selected by the
+// index argument.
+func (i Gep) repeatedElementType(
+	info *gen.InstructionInfo,
+	typ gen.ReferencedTypeInfo,
+) (gen.ReferencedTypeInfo, core.ResultList) {
+	argument := info.Arguments[1]
+	indexType, results := gen.ArgumentToType(argument)
+	if !results.IsEmpty() {
+		return gen.ReferencedTypeInfo{}, results
+	}
+
+	if !indexType.IsPure() || indexType.Base.Fields != nil {
+		return gen.ReferencedTypeInfo{}, list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Expected an integer index",
+				Location: argument.Declaration(),
+			},
+			{
+				Type:     core.HintResult,
+				Message:  fmt.Sprintf("Index type is \"%s\"", indexType),
+				Location: indexType.Declaration,
+			},
+		})
+	}
+
+	if immediate, ok := argument.(*gen.ImmediateInfo); ok {
+		amount := typ.Descriptors[len(typ.Descriptors)-1].Amount
+		hint := core.ResultDetails{
+			Type:     core.HintResult,
+			Message:  fmt.Sprintf("Type \"%s\" holds %s elements", typ, amount),
+			Location: typ.Declaration,
+		}
+
+		results := i.assertIndexInRange(immediate, amount, typ, hint)
+		if !results.IsEmpty() {
+			return gen.ReferencedTypeInfo{}, results
+		}
+	}
+
+	return typ.RepeatedElement(), core.ResultList{}
+}
+
+// Returns the type of the field of a declared type that is selected by the
+// index argument.
+func (i Gep) fieldType(
+	info *gen.InstructionInfo,
+	typ gen.ReferencedTypeInfo,
+) (gen.ReferencedTypeInfo, core.ResultList) {
+	argument := info.Arguments[1]
+	immediate, ok := argument.(*gen.ImmediateInfo)
+	if !ok {
+		return gen.ReferencedTypeInfo{}, list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Expected an immediate field index",
+				Location: argument.Declaration(),
+			},
+			{
+				Type: core.HintResult,
+				Message: fmt.Sprintf(
+					"Fields of type \"%s\" can only be selected by a constant index",
+					typ,
+				),
+			},
+		})
+	}
+
+	fields := typ.Base.Fields
+	amount := big.NewInt(int64(len(fields)))
+	hint := core.ResultDetails{
+		Type:     core.HintResult,
+		Message:  fmt.Sprintf("Type \"%s\" has %d fields", typ, len(fields)),
+		Location: typ.Base.Declaration,
+	}
+
+	results := i.assertIndexInRange(immediate, amount, typ, hint)
+	if !results.IsEmpty() {
+		return gen.ReferencedTypeInfo{}, results
+	}
+
+	return fields[immediate.Value.Int64()].Type, core.ResultList{}
+}
+
+func (i Gep) Validate(info *gen.InstructionInfo) core.ResultList {
+	results := core.ResultList{}
+
+	curResults := gen.AssertTargetsExactly(info, 1)
+	results.Extend(&curResults)
+
+	curResults = gen.AssertArgumentsExactly(info, 2)
+	results.Extend(&curResults)
+
+	if !results.IsEmpty() {
+		return results
+	}
+
+	pointeeType, results := argumentPointee(info, 0)
+	if !results.IsEmpty() {
+		return results
+	}
+
+	var elementType gen.ReferencedTypeInfo
+	if pointeeType.IsRepeated() {
+		elementType, results = i.repeatedElementType(info, pointeeType)
+	} else if pointeeType.IsPure() && pointeeType.Base.Fields != nil {
+		elementType, results = i.fieldType(info, pointeeType)
+	} else {
+		return list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Expected a pointer to a repeated or a declared type",
+				Location: info.Arguments[0].Declaration(),
+			},
+			{
+				Type:     core.HintResult,
+				Message:  fmt.Sprintf("Argument points to type \"%s\"", pointeeType),
+				Location: pointeeType.Declaration,
+			},
+		})
+	}
+
+	if !results.IsEmpty() {
+		return results
+	}
+
+	targetType := info.Targets[0].Register.Type
+	return assertTypesMatch(
+		info,
+		"Target type does not match the type of the selected element pointer",
+		elementType.PointerTo(),
+		targetType,
+	)
+}
+
+func (Gep) Defines(info *gen.InstructionInfo) []*gen.RegisterInfo {
+	return gen.TargetsToRegisters(info.Targets)
+}
+
+func (Gep) Uses(info *gen.InstructionInfo) []*gen.RegisterInfo {
+	return gen.ArgumentsToRegisters(info.Arguments)
+}
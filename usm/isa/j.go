@@ -3,6 +3,7 @@ package usmisa
 import (
 	"alon.kr/x/usm/core"
 	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
 	"alon.kr/x/usm/opt"
 )
 
@@ -47,3 +48,15 @@ func (i J) Validate(info *gen.InstructionInfo) core.ResultList {
 
 	return core.ResultList{}
 }
+
+func (J) Execute(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	label, results := gen.ArgumentToLabel(info.Arguments[0])
+	if !results.IsEmpty() {
+		return interpreter.Step{}, results
+	}
+
+	return interpreter.BranchStep(label), core.ResultList{}
+}
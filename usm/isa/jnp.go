@@ -1,7 +1,11 @@
 package usmisa
 
 import (
+	"math/big"
+
+	"alon.kr/x/usm/core"
 	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
 )
 
 // Jump to label if the value is not positive (negative or zero).
@@ -17,3 +21,17 @@ func NewJnp() gen.InstructionDefinition {
 func (Jnp) Operator(*gen.InstructionInfo) string {
 	return "jnp"
 }
+
+func (i Jnp) FoldBranch(
+	info *gen.InstructionInfo,
+	arguments []*big.Int,
+) (gen.StepInfo, core.ResultList) {
+	return i.foldBranch(info, arguments[0].Sign() <= 0), core.ResultList{}
+}
+
+func (i Jnp) Execute(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	return executeBranchFolding(i, info, frame)
+}
@@ -1,7 +1,11 @@
 package usmisa
 
 import (
+	"math/big"
+
+	"alon.kr/x/usm/core"
 	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
 )
 
 // Jump to label if the value is positive.
@@ -17,3 +21,17 @@ func NewJp() gen.InstructionDefinition {
 func (Jp) Operator(*gen.InstructionInfo) string {
 	return "jp"
 }
+
+func (i Jp) FoldBranch(
+	info *gen.InstructionInfo,
+	arguments []*big.Int,
+) (gen.StepInfo, core.ResultList) {
+	return i.foldBranch(info, arguments[0].Sign() > 0), core.ResultList{}
+}
+
+func (i Jp) Execute(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	return executeBranchFolding(i, info, frame)
+}
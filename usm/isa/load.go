@@ -0,0 +1,63 @@
+package usmisa
+
+import (
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/opt"
+)
+
+// The load instruction reads the value that its pointer argument points to:
+//
+//	$32 %v = load %p
+type Load struct {
+	// Control Flow
+	gen.NonBranchingInstruction
+
+	// Dead Code Elimination
+	opt.NonCriticalInstruction
+	opt.UsesArgumentsInstruction
+	opt.DefinesTargetsInstruction
+}
+
+func NewLoad() gen.InstructionDefinition {
+	return Load{}
+}
+
+func (Load) Operator(*gen.InstructionInfo) string {
+	return "load"
+}
+
+func (Load) Validate(info *gen.InstructionInfo) core.ResultList {
+	results := core.ResultList{}
+
+	curResults := gen.AssertTargetsExactly(info, 1)
+	results.Extend(&curResults)
+
+	curResults = gen.AssertArgumentsExactly(info, 1)
+	results.Extend(&curResults)
+
+	if !results.IsEmpty() {
+		return results
+	}
+
+	pointeeType, results := argumentPointee(info, 0)
+	if !results.IsEmpty() {
+		return results
+	}
+
+	targetType := info.Targets[0].Register.Type
+	return assertTypesMatch(
+		info,
+		"Target type does not match the type that the argument points to",
+		pointeeType,
+		targetType,
+	)
+}
+
+func (Load) Defines(info *gen.InstructionInfo) []*gen.RegisterInfo {
+	return gen.TargetsToRegisters(info.Targets)
+}
+
+func (Load) Uses(info *gen.InstructionInfo) []*gen.RegisterInfo {
+	return gen.ArgumentsToRegisters(info.Arguments)
+}
@@ -0,0 +1,42 @@
+package usmisa
+
+import (
+	"math/big"
+
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
+)
+
+// Logically shifts the value right, filling the upper bits with zeros.
+type Lshr struct {
+	Shift
+}
+
+func NewLshr() gen.InstructionDefinition {
+	return Lshr{}
+}
+
+func (Lshr) Operator(*gen.InstructionInfo) string {
+	return "lshr"
+}
+
+func (i Lshr) Fold(
+	info *gen.InstructionInfo,
+	arguments []*big.Int,
+) ([]*big.Int, core.ResultList) {
+	size, amount, results := i.operands(info, arguments)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	value := unsignedValue(arguments[0], size)
+	return []*big.Int{value.Rsh(value, amount)}, core.ResultList{}
+}
+
+func (i Lshr) Execute(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	return executeFolding(i, info, frame)
+}
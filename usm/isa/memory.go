@@ -0,0 +1,69 @@
+package usmisa
+
+import (
+	"fmt"
+
+	"alon.kr/x/list"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+)
+
+// Returns the type of the value that the pointer argument at the provided
+// index points to, or an error if the argument is not a pointer.
+func argumentPointee(
+	info *gen.InstructionInfo,
+	index int,
+) (gen.ReferencedTypeInfo, core.ResultList) {
+	argument := info.Arguments[index]
+	typ, results := gen.ArgumentToType(argument)
+	if !results.IsEmpty() {
+		return gen.ReferencedTypeInfo{}, results
+	}
+
+	if !typ.IsPointer() {
+		return gen.ReferencedTypeInfo{}, list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Expected a pointer argument",
+				Location: argument.Declaration(),
+			},
+			{
+				Type:     core.HintResult,
+				Message:  fmt.Sprintf("Argument type is \"%s\"", typ),
+				Location: typ.Declaration,
+			},
+		})
+	}
+
+	return typ.Pointee(), core.ResultList{}
+}
+
+// Returns an error if the provided types are not equal.
+func assertTypesMatch(
+	info *gen.InstructionInfo,
+	message string,
+	expected gen.ReferencedTypeInfo,
+	actual gen.ReferencedTypeInfo,
+) core.ResultList {
+	if expected.Equal(actual) {
+		return core.ResultList{}
+	}
+
+	return list.FromSingle(core.Result{
+		{
+			Type:     core.ErrorResult,
+			Message:  message,
+			Location: info.Declaration,
+		},
+		{
+			Type:     core.HintResult,
+			Message:  fmt.Sprintf("Expected type \"%s\"", expected),
+			Location: expected.Declaration,
+		},
+		{
+			Type:     core.HintResult,
+			Message:  fmt.Sprintf("Actual type is \"%s\"", actual),
+			Location: actual.Declaration,
+		},
+	})
+}
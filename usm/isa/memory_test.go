@@ -0,0 +1,134 @@
+package usmisa_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const memoryTestTypes = `type $pair {
+	.first $32
+	.second $8
+}
+
+`
+
+// Generates the provided function, with the memoryTestTypes declared, and
+// asserts that generation fails with the provided error message.
+func assertMemoryError(t *testing.T, function string, message string) {
+	t.Helper()
+
+	_, results := generateFile(t, memoryTestTypes+function)
+	require.False(t, results.IsEmpty())
+	assert.Equal(t, message, results.Head.Value[0].Message)
+}
+
+func assertMemoryValid(t *testing.T, function string) {
+	t.Helper()
+
+	_, results := generateFile(t, memoryTestTypes+function)
+	assert.True(t, results.IsEmpty())
+}
+
+func TestAlloca(t *testing.T) {
+	assertMemoryValid(t, "func @f {\n\t$32 * %p = alloca\n\tret\n}\n")
+
+	assertMemoryError(
+		t,
+		"func @f {\n\t$32 %p = alloca\n\tret\n}\n",
+		"Expected a pointer target",
+	)
+
+	_, results := generateFile(t, "func @f $32 %a {\n\t$32 * %p = alloca %a\n\tret\n}\n")
+	assert.False(t, results.IsEmpty())
+}
+
+func TestLoad(t *testing.T) {
+	assertMemoryValid(t, "func @f $32 * %p {\n\t$32 %v = load %p\n\tret\n}\n")
+
+	assertMemoryError(
+		t,
+		"func @f $32 %p {\n\t$32 %v = load %p\n\tret\n}\n",
+		"Expected a pointer argument",
+	)
+
+	assertMemoryError(
+		t,
+		"func @f $32 * %p {\n\t$8 %v = load %p\n\tret\n}\n",
+		"Target type does not match the type that the argument points to",
+	)
+}
+
+func TestStore(t *testing.T) {
+	assertMemoryValid(t, "func @f $32 * %p $32 %v {\n\tstore %p %v\n\tret\n}\n")
+
+	assertMemoryError(
+		t,
+		"func @f $32 %p $32 %v {\n\tstore %p %v\n\tret\n}\n",
+		"Expected a pointer argument",
+	)
+
+	assertMemoryError(
+		t,
+		"func @f $32 * %p $8 %v {\n\tstore %p %v\n\tret\n}\n",
+		"Stored value type does not match the type that the argument points to",
+	)
+}
+
+func TestGepRepeated(t *testing.T) {
+	assertMemoryValid(t, "func @f $32 ^4 * %p $64 %i {\n\t$32 * %q = gep %p %i\n\tret\n}\n")
+	assertMemoryValid(t, "func @f $32 ^4 * %p {\n\t$32 * %q = gep %p $64 #3\n\tret\n}\n")
+
+	for _, index := range []string{"#4", "#-1"} {
+		assertMemoryError(
+			t,
+			"func @f $32 ^4 * %p {\n\t$32 * %q = gep %p $64 "+index+"\n\tret\n}\n",
+			"Index out of range of type \"$32 ^4\"",
+		)
+	}
+
+	assertMemoryError(
+		t,
+		"func @f $32 ^4 * %p {\n\t$8 * %q = gep %p $64 #0\n\tret\n}\n",
+		"Target type does not match the type of the selected element pointer",
+	)
+}
+
+func TestGepFields(t *testing.T) {
+	assertMemoryValid(t, "func @f $pair * %p {\n\t$8 * %q = gep %p $64 #1\n\tret\n}\n")
+
+	for _, index := range []string{"#2", "#-1"} {
+		assertMemoryError(
+			t,
+			"func @f $pair * %p {\n\t$8 * %q = gep %p $64 "+index+"\n\tret\n}\n",
+			"Index out of range of type \"$pair\"",
+		)
+	}
+
+	assertMemoryError(
+		t,
+		"func @f $pair * %p $64 %i {\n\t$8 * %q = gep %p %i\n\tret\n}\n",
+		"Expected an immediate field index",
+	)
+
+	assertMemoryError(
+		t,
+		"func @f $pair * %p {\n\t$32 * %q = gep %p $64 #1\n\tret\n}\n",
+		"Target type does not match the type of the selected element pointer",
+	)
+}
+
+func TestGepRequiresIndexableType(t *testing.T) {
+	assertMemoryError(
+		t,
+		"func @f $32 * %p {\n\t$32 * %q = gep %p $64 #0\n\tret\n}\n",
+		"Expected a pointer to a repeated or a declared type",
+	)
+
+	assertMemoryError(
+		t,
+		"func @f $32 %p {\n\t$32 * %q = gep %p $64 #0\n\tret\n}\n",
+		"Expected a pointer argument",
+	)
+}
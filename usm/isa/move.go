@@ -2,10 +2,12 @@ package usmisa
 
 import (
 	"fmt"
+	"math/big"
 
 	"alon.kr/x/list"
 	"alon.kr/x/usm/core"
 	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
 	"alon.kr/x/usm/opt"
 )
 
@@ -79,3 +81,17 @@ func (Move) Defines(info *gen.InstructionInfo) []*gen.RegisterInfo {
 func (Move) Uses(info *gen.InstructionInfo) []*gen.RegisterInfo {
 	return gen.ArgumentsToRegisters(info.Arguments)
 }
+
+func (Move) Fold(
+	info *gen.InstructionInfo,
+	arguments []*big.Int,
+) ([]*big.Int, core.ResultList) {
+	return []*big.Int{arguments[0]}, core.ResultList{}
+}
+
+func (i Move) Execute(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	return executeFolding(i, info, frame)
+}
@@ -0,0 +1,41 @@
+package usmisa
+
+import (
+	"math/big"
+
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
+)
+
+// Compares whether the arguments are not equal.
+type Ne struct {
+	Comparison
+}
+
+func NewNe() gen.InstructionDefinition {
+	return Ne{}
+}
+
+func (Ne) Operator(*gen.InstructionInfo) string {
+	return "ne"
+}
+
+func (i Ne) Fold(
+	info *gen.InstructionInfo,
+	arguments []*big.Int,
+) ([]*big.Int, core.ResultList) {
+	cmp, results := i.compare(info, arguments, false)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return comparisonValue(cmp != 0), core.ResultList{}
+}
+
+func (i Ne) Execute(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	return executeFolding(i, info, frame)
+}
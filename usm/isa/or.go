@@ -1,7 +1,11 @@
 package usmisa
 
 import (
+	"math/big"
+
+	"alon.kr/x/usm/core"
 	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
 )
 
 type Or struct {
@@ -16,3 +20,17 @@ func NewOr() gen.InstructionDefinition {
 func (Or) Operator(*gen.InstructionInfo) string {
 	return "or"
 }
+
+func (Or) Fold(
+	info *gen.InstructionInfo,
+	arguments []*big.Int,
+) ([]*big.Int, core.ResultList) {
+	return []*big.Int{new(big.Int).Or(arguments[0], arguments[1])}, core.ResultList{}
+}
+
+func (i Or) Execute(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	return executeFolding(i, info, frame)
+}
@@ -1,10 +1,14 @@
 package usmisa
 
 import (
+	"math/big"
+	"slices"
+
 	"alon.kr/x/list"
 	"alon.kr/x/set"
 	"alon.kr/x/usm/core"
 	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
 	"alon.kr/x/usm/opt"
 )
 
@@ -113,5 +117,75 @@ func (Phi) AddForwardingRegister(
 	labelArg := gen.NewLabelArgumentInfo(block.Label)
 	regArg := gen.NewRegisterArgumentInfo(register)
 	instruction.AppendArgument(labelArg, regArg)
+	regArg.OnAttach(instruction)
 	return core.ResultList{}
 }
+
+// Returns the index of the label argument that matches the provided block,
+// or -1 if there is no such argument.
+func (Phi) incomingIndex(
+	instruction *gen.InstructionInfo,
+	block *gen.BasicBlockInfo,
+) int {
+	for i := 0; i+1 < len(instruction.Arguments); i += 2 {
+		label, ok := instruction.Arguments[i].(*gen.LabelArgumentInfo)
+		if ok && label.Label.BasicBlock == block {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (i Phi) IncomingArgument(
+	instruction *gen.InstructionInfo,
+	block *gen.BasicBlockInfo,
+) gen.ArgumentInfo {
+	index := i.incomingIndex(instruction, block)
+	if index == -1 {
+		return nil
+	}
+
+	return instruction.Arguments[index+1]
+}
+
+func (i Phi) RemoveIncomingArgument(
+	instruction *gen.InstructionInfo,
+	block *gen.BasicBlockInfo,
+) {
+	index := i.incomingIndex(instruction, block)
+	if index == -1 {
+		return
+	}
+
+	instruction.Arguments[index+1].OnDetach(instruction)
+	instruction.Arguments = slices.Delete(instruction.Arguments, index, index+2)
+}
+
+// Assigns the target the value that is forwarded from the basic block from
+// which the execution arrived. All phi instructions of a basic block are
+// executed in parallel, so the value is read as it was at the entry of the
+// block.
+func (i Phi) Execute(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	argument := i.IncomingArgument(info, frame.PreviousBlock)
+	if argument == nil {
+		return interpreter.Step{}, list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Phi instruction has no value for the block from which the execution arrived",
+				Location: info.Declaration,
+			},
+		})
+	}
+
+	value, results := frame.EntryValue(argument)
+	if !results.IsEmpty() {
+		return interpreter.Step{}, results
+	}
+
+	frame.SetTargets(info.Targets, []*big.Int{value})
+	return interpreter.ContinueStep(), core.ResultList{}
+}
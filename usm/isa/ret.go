@@ -5,6 +5,7 @@ import (
 
 	"alon.kr/x/usm/core"
 	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
 	"alon.kr/x/usm/opt"
 )
 
@@ -83,3 +84,15 @@ func (Ret) Validate(info *gen.InstructionInfo) core.ResultList {
 
 	return core.ResultList{}
 }
+
+func (Ret) Execute(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	values, results := frame.Values(info.Arguments)
+	if !results.IsEmpty() {
+		return interpreter.Step{}, results
+	}
+
+	return interpreter.ReturnStep(values), core.ResultList{}
+}
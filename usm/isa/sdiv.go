@@ -0,0 +1,40 @@
+package usmisa
+
+import (
+	"math/big"
+
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
+)
+
+// Divides the signed arguments, rounding the quotient towards zero.
+type Sdiv struct {
+	Division
+}
+
+func NewSdiv() gen.InstructionDefinition {
+	return Sdiv{}
+}
+
+func (Sdiv) Operator(*gen.InstructionInfo) string {
+	return "sdiv"
+}
+
+func (i Sdiv) Fold(
+	info *gen.InstructionInfo,
+	arguments []*big.Int,
+) ([]*big.Int, core.ResultList) {
+	if arguments[1].Sign() == 0 {
+		return []*big.Int{nil}, core.ResultList{}
+	}
+
+	return []*big.Int{new(big.Int).Quo(arguments[0], arguments[1])}, core.ResultList{}
+}
+
+func (i Sdiv) Execute(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	return executeDivision(i, info, frame)
+}
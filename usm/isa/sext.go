@@ -0,0 +1,39 @@
+package usmisa
+
+import (
+	"math/big"
+
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
+)
+
+// Sign extends the argument to the wider target type: the argument is
+// interpreted as a signed (two's complement) integer.
+type Sext struct {
+	WidthConversion
+}
+
+func NewSext() gen.InstructionDefinition {
+	return Sext{WidthConversion{Extends: true}}
+}
+
+func (Sext) Operator(*gen.InstructionInfo) string {
+	return "sext"
+}
+
+// Values are always represented in their signed form, so sign extension
+// keeps the value as is.
+func (Sext) Fold(
+	info *gen.InstructionInfo,
+	arguments []*big.Int,
+) ([]*big.Int, core.ResultList) {
+	return []*big.Int{arguments[0]}, core.ResultList{}
+}
+
+func (i Sext) Execute(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	return executeFolding(i, info, frame)
+}
@@ -0,0 +1,42 @@
+package usmisa
+
+import (
+	"math/big"
+
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
+)
+
+// Compares whether the left argument is greater than or equal to the right
+// argument, as signed integers.
+type Sge struct {
+	Comparison
+}
+
+func NewSge() gen.InstructionDefinition {
+	return Sge{}
+}
+
+func (Sge) Operator(*gen.InstructionInfo) string {
+	return "sge"
+}
+
+func (i Sge) Fold(
+	info *gen.InstructionInfo,
+	arguments []*big.Int,
+) ([]*big.Int, core.ResultList) {
+	cmp, results := i.compare(info, arguments, true)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return comparisonValue(cmp >= 0), core.ResultList{}
+}
+
+func (i Sge) Execute(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	return executeFolding(i, info, frame)
+}
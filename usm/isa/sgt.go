@@ -0,0 +1,42 @@
+package usmisa
+
+import (
+	"math/big"
+
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
+)
+
+// Compares whether the left argument is greater than the right argument, as
+// signed integers.
+type Sgt struct {
+	Comparison
+}
+
+func NewSgt() gen.InstructionDefinition {
+	return Sgt{}
+}
+
+func (Sgt) Operator(*gen.InstructionInfo) string {
+	return "sgt"
+}
+
+func (i Sgt) Fold(
+	info *gen.InstructionInfo,
+	arguments []*big.Int,
+) ([]*big.Int, core.ResultList) {
+	cmp, results := i.compare(info, arguments, true)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return comparisonValue(cmp > 0), core.ResultList{}
+}
+
+func (i Sgt) Execute(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	return executeFolding(i, info, frame)
+}
@@ -0,0 +1,103 @@
+package usmisa
+
+import (
+	"math/big"
+
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/opt"
+)
+
+// Returns the provided value, which is interpreted as an integer of the
+// provided width (in bits), in its unsigned form.
+func unsignedValue(value *big.Int, size *big.Int) *big.Int {
+	modulo := new(big.Int).Lsh(big.NewInt(1), uint(size.Uint64()))
+	return new(big.Int).Mod(value, modulo)
+}
+
+// Shift implements the shared functionality of shift instructions, which
+// shift their first argument by the amount in their second argument:
+//
+//	$32 %r = shl %value $8 #3
+//
+// The shifted value and the target should be of the same integer type, and
+// the amount can be of any integer type. The amount is interpreted as an
+// unsigned integer. The result of shifting by an amount which is not smaller
+// than the width of the shifted value is unspecified.
+type Shift struct {
+	// Control Flow
+	gen.NonBranchingInstruction
+
+	// Dead Code Elimination
+	opt.NonCriticalInstruction
+	opt.UsesArgumentsInstruction
+	opt.DefinesTargetsInstruction
+}
+
+func (Shift) Validate(info *gen.InstructionInfo) core.ResultList {
+	results := core.ResultList{}
+
+	curResults := gen.AssertTargetsExactly(info, 1)
+	results.Extend(&curResults)
+
+	curResults = gen.AssertArgumentsExactly(info, 2)
+	results.Extend(&curResults)
+
+	if !results.IsEmpty() {
+		return results
+	}
+
+	_, curResults = argumentIntegerSize(info.Arguments[0])
+	results.Extend(&curResults)
+
+	_, curResults = argumentIntegerSize(info.Arguments[1])
+	results.Extend(&curResults)
+
+	if !results.IsEmpty() {
+		return results
+	}
+
+	valueType, results := gen.ArgumentToType(info.Arguments[0])
+	if !results.IsEmpty() {
+		return results
+	}
+
+	return assertTypesMatch(
+		info,
+		"Target type does not match the type of the shifted argument",
+		valueType,
+		gen.TargetToType(info.Targets[0]),
+	)
+}
+
+func (Shift) Defines(info *gen.InstructionInfo) []*gen.RegisterInfo {
+	return gen.TargetsToRegisters(info.Targets)
+}
+
+func (Shift) Uses(info *gen.InstructionInfo) []*gen.RegisterInfo {
+	return gen.ArgumentsToRegisters(info.Arguments)
+}
+
+// Returns the width of the shifted value, and the unsigned shift amount,
+// which is clamped to the width of the shifted value.
+func (Shift) operands(
+	info *gen.InstructionInfo,
+	arguments []*big.Int,
+) (size *big.Int, amount uint, results core.ResultList) {
+	size, results = argumentIntegerSize(info.Arguments[0])
+	if !results.IsEmpty() {
+		return nil, 0, results
+	}
+
+	amountSize, results := argumentIntegerSize(info.Arguments[1])
+	if !results.IsEmpty() {
+		return nil, 0, results
+	}
+
+	unsignedAmount := unsignedValue(arguments[1], amountSize)
+	if unsignedAmount.Cmp(size) > 0 {
+		unsignedAmount = size
+	}
+
+	return size, uint(unsignedAmount.Uint64()), core.ResultList{}
+}
@@ -0,0 +1,41 @@
+package usmisa
+
+import (
+	"math/big"
+
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
+)
+
+// Shifts the value left, filling the lower bits with zeros.
+type Shl struct {
+	Shift
+}
+
+func NewShl() gen.InstructionDefinition {
+	return Shl{}
+}
+
+func (Shl) Operator(*gen.InstructionInfo) string {
+	return "shl"
+}
+
+func (i Shl) Fold(
+	info *gen.InstructionInfo,
+	arguments []*big.Int,
+) ([]*big.Int, core.ResultList) {
+	_, amount, results := i.operands(info, arguments)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return []*big.Int{new(big.Int).Lsh(arguments[0], amount)}, core.ResultList{}
+}
+
+func (i Shl) Execute(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	return executeFolding(i, info, frame)
+}
@@ -0,0 +1,183 @@
+package usmisa
+
+import (
+	"fmt"
+
+	"alon.kr/x/list"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+)
+
+// Returns the function that the provided global argument refers to.
+func argumentFunction(
+	info *gen.InstructionInfo,
+	argument gen.ArgumentInfo,
+) (*gen.FunctionInfo, core.ResultList) {
+	global, ok := argument.(*gen.GlobalArgumentInfo)
+	if !ok {
+		return nil, list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Expected a global function argument",
+				Location: argument.Declaration(),
+			},
+		})
+	}
+
+	function := info.FileInfo.GetFunction(global.Name())
+	if function == nil {
+		return nil, list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Global does not exist, or is not a function",
+				Location: argument.Declaration(),
+			},
+		})
+	}
+
+	return function, core.ResultList{}
+}
+
+// Returns the types of the parameters of the provided function, in order.
+func functionParameterTypes(function *gen.FunctionInfo) []gen.ReferencedTypeInfo {
+	types := make([]gen.ReferencedTypeInfo, 0, len(function.Parameters))
+	for _, parameter := range function.Parameters {
+		types = append(types, parameter.Type)
+	}
+
+	return types
+}
+
+func typesEqual(a, b []gen.ReferencedTypeInfo) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the signature of the function type that the provided argument
+// points to, or an error if the argument is not a pointer to a function type.
+func argumentSignature(
+	argument gen.ArgumentInfo,
+) (*gen.TypeSignatureInfo, gen.ReferencedTypeInfo, core.ResultList) {
+	typ, results := gen.ArgumentToType(argument)
+	if !results.IsEmpty() {
+		return nil, typ, results
+	}
+
+	signature := typ.FunctionSignature()
+	if signature == nil {
+		return nil, typ, list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Expected a pointer to a function type",
+				Location: argument.Declaration(),
+			},
+			{
+				Type:     core.HintResult,
+				Message:  fmt.Sprintf("Argument type is \"%s\"", typ),
+				Location: typ.Declaration,
+			},
+		})
+	}
+
+	return signature, typ, core.ResultList{}
+}
+
+// Validates that the arguments (excluding the first, called argument) and the
+// targets of a call instruction match the provided parameter and return
+// types. The hint is attached to every error, and should point to the
+// declaration of the called signature.
+func assertCallMatchesSignature(
+	info *gen.InstructionInfo,
+	returns []gen.ReferencedTypeInfo,
+	parameters []gen.ReferencedTypeInfo,
+	hint core.ResultDetails,
+) core.ResultList {
+	results := core.ResultList{}
+
+	if arguments := len(info.Arguments) - 1; arguments != len(parameters) {
+		results.Append(core.Result{
+			{
+				Type: core.ErrorResult,
+				Message: fmt.Sprintf(
+					"Expected %d call arguments, but got %d",
+					len(parameters), arguments,
+				),
+				Location: info.Declaration,
+			},
+			hint,
+		})
+	}
+
+	if len(info.Targets) != len(returns) {
+		results.Append(core.Result{
+			{
+				Type: core.ErrorResult,
+				Message: fmt.Sprintf(
+					"Expected %d call targets, but got %d",
+					len(returns), len(info.Targets),
+				),
+				Location: info.Declaration,
+			},
+			hint,
+		})
+	}
+
+	if !results.IsEmpty() {
+		return results
+	}
+
+	for i, argument := range info.Arguments[1:] {
+		typ, curResults := gen.ArgumentToType(argument)
+		if !curResults.IsEmpty() {
+			results.Extend(&curResults)
+			continue
+		}
+
+		if !typ.Equal(parameters[i]) {
+			results.Append(core.Result{
+				{
+					Type: core.ErrorResult,
+					Message: fmt.Sprintf(
+						"Argument type \"%s\" does not match the parameter type \"%s\"",
+						typ, parameters[i],
+					),
+					Location: argument.Declaration(),
+				},
+				hint,
+			})
+		}
+	}
+
+	for i, target := range info.Targets {
+		typ := target.Register.Type
+		if !typ.Equal(returns[i]) {
+			location := target.Declaration
+			if location == nil {
+				location = info.Declaration
+			}
+
+			results.Append(core.Result{
+				{
+					Type: core.ErrorResult,
+					Message: fmt.Sprintf(
+						"Target type \"%s\" does not match the return type \"%s\"",
+						typ, returns[i],
+					),
+					Location: location,
+				},
+				hint,
+			})
+		}
+	}
+
+	return results
+}
@@ -0,0 +1,41 @@
+package usmisa
+
+import (
+	"math/big"
+
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
+)
+
+// Computes the remainder of the division of the signed arguments, rounding
+// the quotient towards zero. The remainder has the sign of the dividend.
+type Srem struct {
+	Division
+}
+
+func NewSrem() gen.InstructionDefinition {
+	return Srem{}
+}
+
+func (Srem) Operator(*gen.InstructionInfo) string {
+	return "srem"
+}
+
+func (i Srem) Fold(
+	info *gen.InstructionInfo,
+	arguments []*big.Int,
+) ([]*big.Int, core.ResultList) {
+	if arguments[1].Sign() == 0 {
+		return []*big.Int{nil}, core.ResultList{}
+	}
+
+	return []*big.Int{new(big.Int).Rem(arguments[0], arguments[1])}, core.ResultList{}
+}
+
+func (i Srem) Execute(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	return executeDivision(i, info, frame)
+}
@@ -0,0 +1,67 @@
+package usmisa
+
+import (
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/opt"
+)
+
+// The store instruction writes its second argument to the memory that its
+// first (pointer) argument points to:
+//
+//	store %p %v
+//
+// Since the effect of a store is not visible in the registers of the function,
+// stores are always considered critical.
+type Store struct {
+	// Control Flow
+	gen.NonBranchingInstruction
+
+	// Dead Code Elimination
+	opt.CriticalInstruction
+	opt.UsesArgumentsInstruction
+	opt.DefinesNothingInstruction
+}
+
+func NewStore() gen.InstructionDefinition {
+	return Store{}
+}
+
+func (Store) Operator(*gen.InstructionInfo) string {
+	return "store"
+}
+
+func (Store) Validate(info *gen.InstructionInfo) core.ResultList {
+	results := core.ResultList{}
+
+	curResults := gen.AssertTargetsExactly(info, 0)
+	results.Extend(&curResults)
+
+	curResults = gen.AssertArgumentsExactly(info, 2)
+	results.Extend(&curResults)
+
+	if !results.IsEmpty() {
+		return results
+	}
+
+	pointeeType, results := argumentPointee(info, 0)
+	if !results.IsEmpty() {
+		return results
+	}
+
+	valueType, results := gen.ArgumentToType(info.Arguments[1])
+	if !results.IsEmpty() {
+		return results
+	}
+
+	return assertTypesMatch(
+		info,
+		"Stored value type does not match the type that the argument points to",
+		pointeeType,
+		valueType,
+	)
+}
+
+func (Store) Uses(info *gen.InstructionInfo) []*gen.RegisterInfo {
+	return gen.ArgumentsToRegisters(info.Arguments)
+}
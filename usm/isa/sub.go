@@ -1,7 +1,11 @@
 package usmisa
 
 import (
+	"math/big"
+
+	"alon.kr/x/usm/core"
 	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
 )
 
 type Sub struct {
@@ -16,3 +20,17 @@ func NewSub() gen.InstructionDefinition {
 func (Sub) Operator(*gen.InstructionInfo) string {
 	return "sub"
 }
+
+func (Sub) Fold(
+	info *gen.InstructionInfo,
+	arguments []*big.Int,
+) ([]*big.Int, core.ResultList) {
+	return []*big.Int{new(big.Int).Sub(arguments[0], arguments[1])}, core.ResultList{}
+}
+
+func (i Sub) Execute(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	return executeFolding(i, info, frame)
+}
@@ -0,0 +1,220 @@
+package usmisa
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+
+	"alon.kr/x/list"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
+	"alon.kr/x/usm/opt"
+)
+
+// The switch instruction jumps to one of multiple labels, according to the
+// value of its first argument. The first argument is followed by the default
+// label, and then by pairs of case values and labels:
+//
+//	switch %v .default $32 #0 .zero $32 #1 .one
+//
+// If the value matches one of the case values, the execution continues at
+// the label of the case, and otherwise at the default label.
+type Switch struct {
+	// Dead Code Elimination
+	opt.CriticalInstruction
+	opt.UsesArgumentsInstruction
+	opt.DefinesNothingInstruction
+}
+
+// SwitchCase is a single case of a switch instruction.
+type SwitchCase struct {
+	Value *gen.ImmediateInfo
+	Label *gen.LabelInfo
+}
+
+func NewSwitch() gen.InstructionDefinition {
+	return Switch{}
+}
+
+func (Switch) Operator(*gen.InstructionInfo) string {
+	return "switch"
+}
+
+// Returns all of the labels that the switch may jump to, where each label
+// appears only once.
+func (Switch) PossibleNextSteps(info *gen.InstructionInfo) (gen.StepInfo, core.ResultList) {
+	labels := []*gen.LabelInfo{}
+	for _, label := range gen.ArgumentsToLabels(info.Arguments) {
+		if !slices.Contains(labels, label) {
+			labels = append(labels, label)
+		}
+	}
+
+	return gen.StepInfo{PossibleBranches: labels}, core.ResultList{}
+}
+
+// Returns the default label of the switch. Assumes that the instruction is
+// valid.
+func (Switch) Default(info *gen.InstructionInfo) *gen.LabelInfo {
+	return info.Arguments[1].(*gen.LabelArgumentInfo).Label
+}
+
+// Returns the cases of the switch, in order. Assumes that the instruction is
+// valid.
+func (Switch) Cases(info *gen.InstructionInfo) []SwitchCase {
+	cases := make([]SwitchCase, 0, (len(info.Arguments)-2)/2)
+	for i := 2; i+1 < len(info.Arguments); i += 2 {
+		cases = append(cases, SwitchCase{
+			Value: info.Arguments[i].(*gen.ImmediateInfo),
+			Label: info.Arguments[i+1].(*gen.LabelArgumentInfo).Label,
+		})
+	}
+
+	return cases
+}
+
+func (Switch) validateArgumentsStructure(info *gen.InstructionInfo) core.ResultList {
+	results := gen.AssertTargetsExactly(info, 0)
+
+	curResults := gen.AssertAtLeastArguments(info, 2)
+	results.Extend(&curResults)
+
+	if !results.IsEmpty() {
+		return results
+	}
+
+	if len(info.Arguments)%2 != 0 {
+		return list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "A \"switch\" instruction must have a value, a default label, and pairs of case values and labels",
+				Location: info.Declaration,
+			},
+		})
+	}
+
+	return core.ResultList{}
+}
+
+// Validates that the case value at the provided argument index is an
+// immediate of the provided type.
+func (Switch) validateCaseValue(
+	info *gen.InstructionInfo,
+	index int,
+	valueType gen.ReferencedTypeInfo,
+) core.ResultList {
+	argument := info.Arguments[index]
+	immediate, ok := argument.(*gen.ImmediateInfo)
+	if !ok {
+		return list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Expected an immediate case value",
+				Location: argument.Declaration(),
+			},
+		})
+	}
+
+	if !immediate.Type.Equal(valueType) {
+		return list.FromSingle(core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Case value type does not match the switch value type",
+				Location: argument.Declaration(),
+			},
+			{
+				Type:     core.HintResult,
+				Message:  fmt.Sprintf("Switch value type is \"%s\"", valueType),
+				Location: valueType.Declaration,
+			},
+		})
+	}
+
+	return core.ResultList{}
+}
+
+func (i Switch) Validate(info *gen.InstructionInfo) core.ResultList {
+	results := i.validateArgumentsStructure(info)
+	if !results.IsEmpty() {
+		return results
+	}
+
+	size, results := argumentIntegerSize(info.Arguments[0])
+	if !results.IsEmpty() {
+		return results
+	}
+
+	valueType, results := gen.ArgumentToType(info.Arguments[0])
+	if !results.IsEmpty() {
+		return results
+	}
+
+	_, curResults := gen.ArgumentToLabel(info.Arguments[1])
+	results.Extend(&curResults)
+
+	for index := 2; index < len(info.Arguments); index += 2 {
+		curResults = i.validateCaseValue(info, index, valueType)
+		results.Extend(&curResults)
+
+		_, curResults = gen.ArgumentToLabel(info.Arguments[index+1])
+		results.Extend(&curResults)
+	}
+
+	if !results.IsEmpty() {
+		return results
+	}
+
+	// Case values are compared in their unsigned form, since different
+	// immediates may represent the same value of the type (for example,
+	// "$8 #255" and "$8 #-1").
+	previous := make(map[string]*gen.ImmediateInfo)
+	for _, switchCase := range i.Cases(info) {
+		key := unsignedValue(switchCase.Value.Value, size).String()
+		if other, ok := previous[key]; ok {
+			results.Append(core.Result{
+				{
+					Type:     core.ErrorResult,
+					Message:  "Duplicate case value",
+					Location: switchCase.Value.Declaration(),
+				},
+				{
+					Type:     core.HintResult,
+					Message:  "Previous case here",
+					Location: other.Declaration(),
+				},
+			})
+			continue
+		}
+
+		previous[key] = switchCase.Value
+	}
+
+	return results
+}
+
+func (i Switch) FoldBranch(
+	info *gen.InstructionInfo,
+	arguments []*big.Int,
+) (gen.StepInfo, core.ResultList) {
+	size, results := argumentIntegerSize(info.Arguments[0])
+	if !results.IsEmpty() {
+		return gen.StepInfo{}, results
+	}
+
+	value := unsignedValue(arguments[0], size)
+	for _, switchCase := range i.Cases(info) {
+		if unsignedValue(switchCase.Value.Value, size).Cmp(value) == 0 {
+			return gen.StepInfo{PossibleBranches: []*gen.LabelInfo{switchCase.Label}}, core.ResultList{}
+		}
+	}
+
+	return gen.StepInfo{PossibleBranches: []*gen.LabelInfo{i.Default(info)}}, core.ResultList{}
+}
+
+func (i Switch) Execute(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	return executeBranchFolding(i, info, frame)
+}
@@ -0,0 +1,35 @@
+package usmisa_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSwitchDuplicateCaseValue(t *testing.T) {
+	src := `func @f $8 %v {
+.entry
+	switch %v .entry $8 #1 .a $8 #-1 .a $8 #255 .entry
+.a
+	ret
+}
+`
+	_, results := generateFile(t, src)
+	require.False(t, results.IsEmpty())
+
+	result := results.Head.Value
+	assert.Equal(t, "Duplicate case value", result[0].Message)
+	assert.Equal(t, "Previous case here", result[1].Message)
+}
+
+func TestSwitchCaseValueType(t *testing.T) {
+	_, results := generateFile(t, "func @f $8 %v {\n.entry\n\tswitch %v .entry $32 #1 .entry\n}\n")
+	assert.False(t, results.IsEmpty())
+
+	_, results = generateFile(t, "func @f $8 %v {\n.entry\n\tswitch %v .entry $8 #1\n}\n")
+	assert.False(t, results.IsEmpty())
+
+	_, results = generateFile(t, "func @f $8 %v {\n.entry\n\tswitch %v .entry\n}\n")
+	assert.True(t, results.IsEmpty())
+}
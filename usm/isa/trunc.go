@@ -0,0 +1,39 @@
+package usmisa
+
+import (
+	"math/big"
+
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
+)
+
+// Truncates the argument to the narrower target type, by discarding its upper
+// bits.
+type Trunc struct {
+	WidthConversion
+}
+
+func NewTrunc() gen.InstructionDefinition {
+	return Trunc{WidthConversion{Extends: false}}
+}
+
+func (Trunc) Operator(*gen.InstructionInfo) string {
+	return "trunc"
+}
+
+// The value is wrapped around to the width of the target when it is assigned,
+// which discards the upper bits.
+func (Trunc) Fold(
+	info *gen.InstructionInfo,
+	arguments []*big.Int,
+) ([]*big.Int, core.ResultList) {
+	return []*big.Int{arguments[0]}, core.ResultList{}
+}
+
+func (i Trunc) Execute(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	return executeFolding(i, info, frame)
+}
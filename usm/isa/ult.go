@@ -0,0 +1,42 @@
+package usmisa
+
+import (
+	"math/big"
+
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
+)
+
+// Compares whether the left argument is smaller than the right argument, as
+// unsigned integers.
+type Ult struct {
+	Comparison
+}
+
+func NewUlt() gen.InstructionDefinition {
+	return Ult{}
+}
+
+func (Ult) Operator(*gen.InstructionInfo) string {
+	return "ult"
+}
+
+func (i Ult) Fold(
+	info *gen.InstructionInfo,
+	arguments []*big.Int,
+) ([]*big.Int, core.ResultList) {
+	cmp, results := i.compare(info, arguments, false)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return comparisonValue(cmp < 0), core.ResultList{}
+}
+
+func (i Ult) Execute(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	return executeFolding(i, info, frame)
+}
@@ -0,0 +1,47 @@
+package usmisa
+
+import (
+	"math/big"
+
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
+)
+
+// Computes the remainder of the division of the unsigned arguments.
+type Urem struct {
+	Division
+}
+
+func NewUrem() gen.InstructionDefinition {
+	return Urem{}
+}
+
+func (Urem) Operator(*gen.InstructionInfo) string {
+	return "urem"
+}
+
+func (i Urem) Fold(
+	info *gen.InstructionInfo,
+	arguments []*big.Int,
+) ([]*big.Int, core.ResultList) {
+	if arguments[1].Sign() == 0 {
+		return []*big.Int{nil}, core.ResultList{}
+	}
+
+	size, results := i.size(info)
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	left := unsignedValue(arguments[0], size)
+	right := unsignedValue(arguments[1], size)
+	return []*big.Int{left.Rem(left, right)}, core.ResultList{}
+}
+
+func (i Urem) Execute(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	return executeDivision(i, info, frame)
+}
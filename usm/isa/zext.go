@@ -0,0 +1,42 @@
+package usmisa
+
+import (
+	"math/big"
+
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/interpreter"
+)
+
+// Zero extends the argument to the wider target type: the argument is
+// interpreted as an unsigned integer.
+type Zext struct {
+	WidthConversion
+}
+
+func NewZext() gen.InstructionDefinition {
+	return Zext{WidthConversion{Extends: true}}
+}
+
+func (Zext) Operator(*gen.InstructionInfo) string {
+	return "zext"
+}
+
+func (Zext) Fold(
+	info *gen.InstructionInfo,
+	arguments []*big.Int,
+) ([]*big.Int, core.ResultList) {
+	size, results := argumentIntegerSize(info.Arguments[0])
+	if !results.IsEmpty() {
+		return nil, results
+	}
+
+	return []*big.Int{unsignedValue(arguments[0], size)}, core.ResultList{}
+}
+
+func (i Zext) Execute(
+	info *gen.InstructionInfo,
+	frame *interpreter.Frame,
+) (interpreter.Step, core.ResultList) {
+	return executeFolding(i, info, frame)
+}
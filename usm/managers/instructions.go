@@ -14,15 +14,46 @@ func NewInstructionManager() gen.InstructionManager {
 			{Key: "add", Value: usmisa.NewAdd()},
 			{Key: "sub", Value: usmisa.NewSub()},
 			{Key: "mul", Value: usmisa.NewMul()},
+			{Key: "udiv", Value: usmisa.NewUdiv()}, // unsigned division
+			{Key: "sdiv", Value: usmisa.NewSdiv()}, // signed division
+			{Key: "urem", Value: usmisa.NewUrem()}, // unsigned remainder
+			{Key: "srem", Value: usmisa.NewSrem()}, // signed remainder
 
 			// Bitwise Operations
 			{Key: "and", Value: usmisa.NewAnd()},
 			{Key: "or", Value: usmisa.NewOr()},
 			{Key: "xor", Value: usmisa.NewXor()},
+			{Key: "shl", Value: usmisa.NewShl()},   // shift left
+			{Key: "lshr", Value: usmisa.NewLshr()}, // logical shift right
+			{Key: "ashr", Value: usmisa.NewAshr()}, // arithmetic shift right
+
+			// Width Conversions
+			{Key: "zext", Value: usmisa.NewZext()},   // zero extend
+			{Key: "sext", Value: usmisa.NewSext()},   // sign extend
+			{Key: "trunc", Value: usmisa.NewTrunc()}, // truncate
+
+			// Memory
+			{Key: "alloca", Value: usmisa.NewAlloca()},
+			{Key: "load", Value: usmisa.NewLoad()},
+			{Key: "store", Value: usmisa.NewStore()},
+			{Key: "gep", Value: usmisa.NewGep()},
+
+			// Comparisons
+			{Key: "eq", Value: usmisa.NewEq()},   // equal
+			{Key: "ne", Value: usmisa.NewNe()},   // not equal
+			{Key: "ult", Value: usmisa.NewUlt()}, // unsigned less than
+			{Key: "ule", Value: usmisa.NewUle()}, // unsigned less than or equal
+			{Key: "ugt", Value: usmisa.NewUgt()}, // unsigned greater than
+			{Key: "uge", Value: usmisa.NewUge()}, // unsigned greater than or equal
+			{Key: "slt", Value: usmisa.NewSlt()}, // signed less than
+			{Key: "sle", Value: usmisa.NewSle()}, // signed less than or equal
+			{Key: "sgt", Value: usmisa.NewSgt()}, // signed greater than
+			{Key: "sge", Value: usmisa.NewSge()}, // signed greater than or equal
 
 			// Functions
 			{Key: "ret", Value: usmisa.NewRet()},
 			{Key: "call", Value: usmisa.NewCall()},
+			{Key: "fptr", Value: usmisa.NewFptr()}, // function pointer
 
 			// Control Flow
 			{Key: "j", Value: usmisa.NewJump()},  // Unconditional jump
@@ -30,8 +61,9 @@ func NewInstructionManager() gen.InstructionManager {
 			{Key: "jnz", Value: usmisa.NewJnz()}, // jump if not zero
 			{Key: "jp", Value: usmisa.NewJp()},   // jump if positive
 			{Key: "jnp", Value: usmisa.NewJnp()}, // jump if not positive
-			{Key: "jn", Value: usmisa.NewJp()},   // jump if negative
-			{Key: "jnn", Value: usmisa.NewJnp()}, // jump if not negative
+			{Key: "jn", Value: usmisa.NewJn()},   // jump if negative
+			{Key: "jnn", Value: usmisa.NewJnn()}, // jump if not negative
+			{Key: "switch", Value: usmisa.NewSwitch()},
 
 			// Static Single Assignment (SSA)
 			{Key: "phi", Value: usmisa.NewPhi()},
@@ -44,13 +44,21 @@ func (m *TypeMap) GetType(name string) *gen.NamedTypeInfo {
 	return (*m)[name]
 }
 
-func (m *TypeMap) NewType(*gen.NamedTypeInfo) core.Result {
-	return core.Result{
-		{
-			Type:    core.ErrorResult,
-			Message: "Type declaration not supported yet in usm isa",
-		},
+// Registers a new declared type. Declared types can't shadow base types, and
+// thus their names can't be of the form "$<n>".
+func (m *TypeMap) NewType(typ *gen.NamedTypeInfo) core.Result {
+	if m.toBaseTypeSize(typ.Name) != nil {
+		return core.Result{
+			{
+				Type:     core.ErrorResult,
+				Message:  "Type name is reserved for a builtin integer type",
+				Location: typ.Declaration,
+			},
+		}
 	}
+
+	(*m)[typ.Name] = typ
+	return nil
 }
 
 func NewTypeManager(*gen.GenerationContext) gen.TypeManager {
@@ -0,0 +1,32 @@
+package usmopt
+
+import (
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/opt"
+	"alon.kr/x/usm/transform"
+	usmisa "alon.kr/x/usm/usm/isa"
+)
+
+// ConstantPropagationScheme provides the usm instructions that are used to
+// rewrite functions in the constant propagation optimization.
+type ConstantPropagationScheme struct{}
+
+func NewConstantPropagationScheme() opt.ConstantPropagationScheme {
+	return ConstantPropagationScheme{}
+}
+
+func (ConstantPropagationScheme) NewMoveDefinition() gen.InstructionDefinition {
+	return usmisa.NewMove()
+}
+
+func (ConstantPropagationScheme) NewJumpDefinition() gen.InstructionDefinition {
+	return usmisa.NewJump()
+}
+
+func TransformFileToConstantPropagation(
+	data *transform.TargetData,
+) (*transform.TargetData, core.ResultList) {
+	results := opt.FileToConstantPropagation(data.Code, NewConstantPropagationScheme())
+	return data, results
+}
@@ -47,7 +47,7 @@ func (s *ConstructionScheme) NewRenamedRegister(
 func (s *ConstructionScheme) renameArgument(
 	instruction *gen.InstructionInfo,
 	argument gen.ArgumentInfo,
-	reachingSet ssa.ReachingDefinitionsSet,
+	reachingSet *ssa.ReachingDefinitionsSet,
 ) core.ResultList {
 	if argument, ok := argument.(*gen.RegisterArgumentInfo); ok {
 		baseRegister := argument.Register
@@ -61,7 +61,7 @@ func (s *ConstructionScheme) renameArgument(
 func (s *ConstructionScheme) renameTarget(
 	instruction *gen.InstructionInfo,
 	target *gen.TargetInfo,
-	reachingSet ssa.ReachingDefinitionsSet,
+	reachingSet *ssa.ReachingDefinitionsSet,
 ) core.ResultList {
 	baseRegister := target.Register
 	renamedRegister := reachingSet.RenameDefinitionRegister(baseRegister)
@@ -71,7 +71,7 @@ func (s *ConstructionScheme) renameTarget(
 
 func (s *ConstructionScheme) renameInstruction(
 	instruction *gen.InstructionInfo,
-	reachingSet ssa.ReachingDefinitionsSet,
+	reachingSet *ssa.ReachingDefinitionsSet,
 ) core.ResultList {
 	// First, we rename the arguments.
 	for _, argument := range instruction.Arguments {
@@ -94,7 +94,7 @@ func (s *ConstructionScheme) renameInstruction(
 
 func (s *ConstructionScheme) RenameBasicBlock(
 	block *gen.BasicBlockInfo,
-	reachingSet ssa.ReachingDefinitionsSet,
+	reachingSet *ssa.ReachingDefinitionsSet,
 ) core.ResultList {
 	for _, instruction := range block.Instructions {
 		results := s.renameInstruction(instruction, reachingSet)
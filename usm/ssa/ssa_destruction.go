@@ -0,0 +1,632 @@
+package usmssa
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+
+	"alon.kr/x/set"
+	"alon.kr/x/usm/core"
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/opt"
+	"alon.kr/x/usm/transform"
+	usmisa "alon.kr/x/usm/usm/isa"
+)
+
+// A single copy of a parallel copy, which assigns the value of the argument
+// to the target register.
+type parallelCopy struct {
+	target *gen.RegisterInfo
+	value  gen.ArgumentInfo
+}
+
+// A basic block that was inserted in the middle of a critical control flow
+// edge, to hold the copies that are executed only on that edge.
+type splitEdge struct {
+	from  *gen.BasicBlockInfo
+	to    *gen.BasicBlockInfo
+	block *gen.BasicBlockInfo
+
+	// True if the "from" block continues to the split block by falling
+	// through, and not by branching to it.
+	isFallthrough bool
+}
+
+type ssaDestruction struct {
+	*gen.FunctionInfo
+
+	// The blocks that were inserted to split critical edges.
+	splits []splitEdge
+
+	// Labels of the inserted blocks, that are not yet registered in the
+	// function label manager.
+	pendingLabels set.Set[string]
+}
+
+// MARK: Instructions
+
+func newMoveInstruction(
+	target *gen.RegisterInfo,
+	value gen.ArgumentInfo,
+) *gen.InstructionInfo {
+	info := gen.NewEmptyInstructionInfo(nil)
+	info.SetInstruction(usmisa.NewMove())
+
+	targetInfo := gen.NewTargetInfo(target)
+	info.AppendTarget(&targetInfo)
+
+	info.AppendArgument(value)
+	value.OnAttach(info)
+	return info
+}
+
+func newJumpInstruction(label *gen.LabelInfo) *gen.InstructionInfo {
+	info := gen.NewEmptyInstructionInfo(nil)
+	info.SetInstruction(usmisa.NewJump())
+	info.AppendArgument(gen.NewLabelArgumentInfo(label))
+	return info
+}
+
+// Removes the instruction from its basic block, and removes it from the
+// definitions and usages lists of its registers.
+func detachInstruction(instruction *gen.InstructionInfo) {
+	for _, argument := range instruction.Arguments {
+		argument.OnDetach(instruction)
+	}
+
+	for _, target := range instruction.Targets {
+		target.Register.RemoveDefinition(instruction)
+	}
+
+	instruction.BasicBlockInfo.RemoveInstruction(instruction)
+}
+
+// Returns a new argument that references the same value as the provided one,
+// which can be attached to a different instruction.
+func copyArgument(argument gen.ArgumentInfo) gen.ArgumentInfo {
+	if register, ok := argument.(*gen.RegisterArgumentInfo); ok {
+		return gen.NewRegisterArgumentInfo(register.Register)
+	}
+
+	return argument
+}
+
+func argumentRegister(argument gen.ArgumentInfo) *gen.RegisterInfo {
+	if register, ok := argument.(*gen.RegisterArgumentInfo); ok {
+		return register.Register
+	}
+
+	return nil
+}
+
+func isBranchingInstruction(instruction *gen.InstructionInfo) bool {
+	steps, results := instruction.Definition.PossibleNextSteps(instruction)
+	if !results.IsEmpty() {
+		return true
+	}
+
+	return steps.IsBranchPossible() || !steps.PossibleContinue
+}
+
+// Returns true if the execution may continue from the end of the provided
+// basic block to the next basic block, without branching.
+func mayContinue(block *gen.BasicBlockInfo) bool {
+	if len(block.Instructions) == 0 {
+		return true
+	}
+
+	last := block.Instructions[len(block.Instructions)-1]
+	steps, results := last.Definition.PossibleNextSteps(last)
+	return results.IsEmpty() && steps.PossibleContinue
+}
+
+func uniqueBlocks(blocks []*gen.BasicBlockInfo) []*gen.BasicBlockInfo {
+	unique := []*gen.BasicBlockInfo{}
+	for _, block := range blocks {
+		if !slices.Contains(unique, block) {
+			unique = append(unique, block)
+		}
+	}
+	return unique
+}
+
+func replaceBlock(blocks []*gen.BasicBlockInfo, oldBlock, newBlock *gen.BasicBlockInfo) {
+	for i, block := range blocks {
+		if block == oldBlock {
+			blocks[i] = newBlock
+		}
+	}
+}
+
+// Replaces all label arguments of the instruction that reference the old label
+// with the new label.
+func replaceLabel(instruction *gen.InstructionInfo, oldLabel, newLabel *gen.LabelInfo) {
+	for i, argument := range instruction.Arguments {
+		if label, ok := argument.(*gen.LabelArgumentInfo); ok && label.Label == oldLabel {
+			instruction.SubstituteArgument(i, gen.NewLabelArgumentInfo(newLabel))
+		}
+	}
+}
+
+// MARK: Edges
+
+func (d *ssaDestruction) generateLabel() *gen.LabelInfo {
+	label := d.Labels.GenerateLabel()
+	for d.pendingLabels.Contains(label.Name) {
+		label = d.Labels.GenerateLabel()
+	}
+
+	d.pendingLabels.Add(label.Name)
+	return label
+}
+
+// Inserts a new empty basic block in the middle of the provided edge, and
+// returns it.
+//
+// If the edge is a fall through edge, the new block is placed between the two
+// blocks. Otherwise, the branch is redirected to the new block, which is
+// placed at the end of the function and jumps to the original target.
+//
+// An edge to the next block is not a fall through edge if the source block
+// never continues, for example when it ends with a switch that explicitly
+// jumps to the next block.
+func (d *ssaDestruction) splitEdge(from, to *gen.BasicBlockInfo) *gen.BasicBlockInfo {
+	block := gen.NewEmptyBasicBlockInfo(d.FunctionInfo)
+	block.SetLabel(d.generateLabel())
+
+	split := splitEdge{from: from, to: to, block: block}
+	if from.NextBlock == to && mayContinue(from) {
+		split.isFallthrough = true
+		from.AppendBasicBlock(block)
+	} else {
+		last := from
+		for last.NextBlock != nil {
+			last = last.NextBlock
+		}
+
+		last.AppendBasicBlock(block)
+		replaceLabel(from.Instructions[len(from.Instructions)-1], to.Label, block.Label)
+		block.AppendInstruction(newJumpInstruction(to.Label))
+	}
+
+	replaceBlock(from.ForwardEdges, to, block)
+	replaceBlock(to.BackwardEdges, from, block)
+	block.ForwardEdges = []*gen.BasicBlockInfo{to}
+	block.BackwardEdges = []*gen.BasicBlockInfo{from}
+
+	d.splits = append(d.splits, split)
+	return block
+}
+
+// Removes the split block from the function, and restores the original edge.
+func (d *ssaDestruction) unsplitEdge(split splitEdge) {
+	for block := d.EntryBlock; block != nil; block = block.NextBlock {
+		if block.NextBlock == split.block {
+			block.NextBlock = split.block.NextBlock
+			break
+		}
+	}
+
+	if !split.isFallthrough {
+		replaceLabel(split.from.Instructions[len(split.from.Instructions)-1], split.block.Label, split.to.Label)
+	}
+
+	replaceBlock(split.from.ForwardEdges, split.block, split.to)
+	replaceBlock(split.to.BackwardEdges, split.block, split.from)
+}
+
+// Removes split blocks that do not contain any copies, and registers the
+// labels of the remaining split blocks.
+func (d *ssaDestruction) commitSplitEdges() core.ResultList {
+	results := core.ResultList{}
+
+	for _, split := range d.splits {
+		copies := len(split.block.Instructions)
+		if !split.isFallthrough {
+			copies--
+		}
+
+		if copies == 0 {
+			d.unsplitEdge(split)
+		} else {
+			curResults := d.Labels.NewLabel(split.block.Label)
+			results.Extend(&curResults)
+		}
+	}
+
+	return results
+}
+
+// Returns the basic block and the index in it, in which copies that should be
+// executed when moving from the provided block to its provided successor
+// should be inserted.
+func (d *ssaDestruction) copiesInsertionPoint(
+	from, to *gen.BasicBlockInfo,
+) (*gen.BasicBlockInfo, int) {
+	if len(uniqueBlocks(from.ForwardEdges)) == 1 {
+		index := len(from.Instructions)
+		if index > 0 && isBranchingInstruction(from.Instructions[index-1]) {
+			index--
+		}
+
+		return from, index
+	}
+
+	if len(uniqueBlocks(to.BackwardEdges)) == 1 {
+		return to, 0
+	}
+
+	return d.splitEdge(from, to), 0
+}
+
+// MARK: Copies
+
+func (d *ssaDestruction) newTemporaryRegister(
+	register *gen.RegisterInfo,
+) *gen.RegisterInfo {
+	name := register.Name + "_tmp"
+	for i := 1; d.Registers.GetRegister(name) != nil; i++ {
+		name = register.Name + "_tmp" + strconv.Itoa(i)
+	}
+
+	temporary := gen.NewRegisterInfo(name, register.Type)
+	d.Registers.NewRegister(temporary)
+	return temporary
+}
+
+// Converts the provided parallel copies into a sequence of move instructions
+// with the same semantics.
+//
+// A copy can be emitted once its target is not used as the value of any other
+// pending copy. If no such copy exists, the remaining copies form cycles
+// (for example, a swap of two registers), and the cycle is broken by saving
+// the value of one of the targets in a new temporary register.
+func (d *ssaDestruction) sequenceCopies(
+	copies []parallelCopy,
+) []*gen.InstructionInfo {
+	pending := []parallelCopy{}
+	for _, current := range copies {
+		if argumentRegister(current.value) != current.target {
+			pending = append(pending, current)
+		}
+	}
+
+	isUsed := func(register *gen.RegisterInfo) bool {
+		for _, current := range pending {
+			if argumentRegister(current.value) == register {
+				return true
+			}
+		}
+		return false
+	}
+
+	instructions := []*gen.InstructionInfo{}
+	for len(pending) > 0 {
+		index := slices.IndexFunc(pending, func(current parallelCopy) bool {
+			return !isUsed(current.target)
+		})
+
+		if index != -1 {
+			current := pending[index]
+			pending = slices.Delete(pending, index, index+1)
+			instructions = append(instructions, newMoveInstruction(current.target, copyArgument(current.value)))
+			continue
+		}
+
+		saved := pending[0].target
+		temporary := d.newTemporaryRegister(saved)
+		instructions = append(instructions, newMoveInstruction(temporary, gen.NewRegisterArgumentInfo(saved)))
+
+		for i := range pending {
+			if argumentRegister(pending[i].value) == saved {
+				pending[i].value = gen.NewRegisterArgumentInfo(temporary)
+			}
+		}
+	}
+
+	return instructions
+}
+
+// Returns the parallel copies that replace the provided phi instructions,
+// when moving into their basic block from the provided predecessor.
+func phiCopies(
+	phis []*gen.InstructionInfo,
+	predecessor *gen.BasicBlockInfo,
+) []parallelCopy {
+	copies := []parallelCopy{}
+	for _, phi := range phis {
+		value := usmisa.Phi{}.IncomingArgument(phi, predecessor)
+		if value != nil {
+			copies = append(copies, parallelCopy{
+				target: phi.Targets[0].Register,
+				value:  value,
+			})
+		}
+	}
+
+	return copies
+}
+
+func (d *ssaDestruction) eliminateBlockPhiInstructions(block *gen.BasicBlockInfo) {
+	phis := []*gen.InstructionInfo{}
+	for _, instruction := range block.Instructions {
+		if _, ok := instruction.Definition.(usmisa.Phi); ok {
+			phis = append(phis, instruction)
+		}
+	}
+
+	if len(phis) == 0 {
+		return
+	}
+
+	copiesPerPredecessor := [][]parallelCopy{}
+	predecessors := uniqueBlocks(block.BackwardEdges)
+	for _, predecessor := range predecessors {
+		copiesPerPredecessor = append(copiesPerPredecessor, phiCopies(phis, predecessor))
+	}
+
+	for _, phi := range phis {
+		detachInstruction(phi)
+	}
+
+	for i, predecessor := range predecessors {
+		instructions := d.sequenceCopies(copiesPerPredecessor[i])
+		if len(instructions) == 0 {
+			continue
+		}
+
+		insertionBlock, index := d.copiesInsertionPoint(predecessor, block)
+		for j, instruction := range instructions {
+			insertionBlock.InsertInstruction(index+j, instruction)
+		}
+	}
+}
+
+// MARK: Renaming
+
+// Matches names of registers that were renamed in the SSA construction.
+var renamedRegisterPattern = regexp.MustCompile(`^(.*)_[0-9]+$`)
+
+// Returns the name of the register before it was renamed in the SSA
+// construction, as generated by ConstructionScheme.NewRenamedRegister.
+func baseRegisterName(register *gen.RegisterInfo) string {
+	if match := renamedRegisterPattern.FindStringSubmatch(register.Name); match != nil {
+		return match[1]
+	}
+
+	return register.Name
+}
+
+type registerPair struct {
+	first, second *gen.RegisterInfo
+}
+
+// Returns all pairs of registers that hold values that are live at the same
+// time, and thus can't be merged into a single register.
+//
+// A register defined by a move instruction does not interfere with the moved
+// register, since they hold the same value.
+func computeInterference(function *gen.FunctionInfo) set.Set[registerPair] {
+	liveness := opt.NewFunctionLivenessInfo(function)
+	interference := set.New[registerPair]()
+
+	interfere := func(first, second *gen.RegisterInfo) {
+		if first != second {
+			interference.Add(registerPair{first, second})
+			interference.Add(registerPair{second, first})
+		}
+	}
+
+	for i, block := range liveness.BasicBlocks {
+		live := set.New[*gen.RegisterInfo]()
+		for register := range liveness.LiveOut[i] {
+			live.Add(register)
+		}
+
+		for j := len(block.Instructions) - 1; j >= 0; j-- {
+			instruction := block.Instructions[j]
+
+			var moved *gen.RegisterInfo
+			if _, ok := instruction.Definition.(usmisa.Move); ok {
+				moved = argumentRegister(instruction.Arguments[0])
+			}
+
+			for _, target := range gen.TargetsToRegisters(instruction.Targets) {
+				for register := range live {
+					if register != moved {
+						interfere(target, register)
+					}
+				}
+			}
+
+			for _, target := range gen.TargetsToRegisters(instruction.Targets) {
+				live.Remove(target)
+			}
+
+			for _, register := range gen.ArgumentsToRegisters(instruction.Arguments) {
+				live.Add(register)
+			}
+		}
+	}
+
+	// Parameters are defined together at the entry of the function.
+	if len(liveness.BasicBlocks) > 0 {
+		for _, parameter := range function.Parameters {
+			for register := range liveness.LiveIn[0] {
+				interfere(parameter, register)
+			}
+
+			for _, other := range function.Parameters {
+				interfere(parameter, other)
+			}
+		}
+	}
+
+	return interference
+}
+
+// Replaces all definitions and usages of the provided register with the
+// representative register.
+func (d *ssaDestruction) mergeRegister(register, representative *gen.RegisterInfo) {
+	for _, instruction := range slices.Clone(register.Definitions) {
+		for _, target := range instruction.Targets {
+			if target.Register == register {
+				instruction.SwitchTarget(target, representative)
+			}
+		}
+	}
+
+	for _, instruction := range uniqueInstructions(register.Usages) {
+		for _, argument := range instruction.Arguments {
+			if argument, ok := argument.(*gen.RegisterArgumentInfo); ok && argument.Register == register {
+				argument.SwitchRegister(instruction, representative)
+			}
+		}
+	}
+
+	for i, parameter := range d.Parameters {
+		if parameter == register {
+			d.Parameters[i] = representative
+		}
+	}
+
+	d.Registers.DeleteRegister(register)
+}
+
+func uniqueInstructions(instructions []*gen.InstructionInfo) []*gen.InstructionInfo {
+	unique := []*gen.InstructionInfo{}
+	for _, instruction := range instructions {
+		if !slices.Contains(unique, instruction) {
+			unique = append(unique, instruction)
+		}
+	}
+	return unique
+}
+
+func (d *ssaDestruction) renameRegister(register *gen.RegisterInfo, name string) {
+	d.Registers.DeleteRegister(register)
+	register.Name = name
+	d.Registers.NewRegister(register)
+}
+
+// Returns the numeric suffix of a renamed register, which is used to order
+// registers with the same base name. The base register itself comes first.
+func renamedRegisterIndex(register *gen.RegisterInfo) int {
+	base := baseRegisterName(register)
+	if base == register.Name {
+		return -1
+	}
+
+	index, _ := strconv.Atoi(register.Name[len(base)+1:])
+	return index
+}
+
+// Reverses the renaming of registers that was done in the SSA construction,
+// by merging registers that originate from the same base register back into
+// a single register, when their values are never live at the same time.
+func (d *ssaDestruction) restoreRegisterNames() {
+	groups := make(map[string][]*gen.RegisterInfo)
+	for _, register := range d.Registers.GetAllRegisters() {
+		base := baseRegisterName(register)
+		groups[base] = append(groups[base], register)
+	}
+
+	bases := make([]string, 0, len(groups))
+	for base := range groups {
+		bases = append(bases, base)
+	}
+	slices.Sort(bases)
+
+	interference := computeInterference(d.FunctionInfo)
+
+	for _, base := range bases {
+		members := groups[base]
+		if len(members) == 1 && members[0].Name == base {
+			continue
+		}
+
+		slices.SortFunc(members, func(a, b *gen.RegisterInfo) int {
+			return renamedRegisterIndex(a) - renamedRegisterIndex(b)
+		})
+
+		representative := members[0]
+		merged := []*gen.RegisterInfo{representative}
+		for _, member := range members[1:] {
+			canMerge := member.Type.Equal(representative.Type)
+			for _, other := range merged {
+				if interference.Contains(registerPair{member, other}) {
+					canMerge = false
+					break
+				}
+			}
+
+			if canMerge {
+				merged = append(merged, member)
+			}
+		}
+
+		for _, member := range merged[1:] {
+			d.mergeRegister(member, representative)
+		}
+
+		if representative.Name != base {
+			d.renameRegister(representative, base)
+		}
+	}
+}
+
+// Removes move instructions that move a register into itself.
+func (d *ssaDestruction) removeRedundantMoves() {
+	for _, instruction := range d.CollectInstructions() {
+		if _, ok := instruction.Definition.(usmisa.Move); !ok {
+			continue
+		}
+
+		if argumentRegister(instruction.Arguments[0]) == instruction.Targets[0].Register {
+			detachInstruction(instruction)
+		}
+	}
+}
+
+// MARK: Destruction
+
+// FunctionOutOfSsaForm converts a function in static single assignment form
+// back into a regular function, by replacing phi instructions with move
+// instructions in the predecessor blocks.
+//
+// Critical edges are split when needed, and registers that were renamed by
+// the SSA construction are merged back into their original name when possible.
+func FunctionOutOfSsaForm(function *gen.FunctionInfo) core.ResultList {
+	destruction := ssaDestruction{
+		FunctionInfo:  function,
+		pendingLabels: set.New[string](),
+	}
+
+	for _, block := range function.CollectBasicBlocks() {
+		destruction.eliminateBlockPhiInstructions(block)
+	}
+
+	destruction.restoreRegisterNames()
+	destruction.removeRedundantMoves()
+	return destruction.commitSplitEdges()
+}
+
+func FileOutOfSsaForm(file *gen.FileInfo) core.ResultList {
+	results := core.ResultList{}
+
+	for _, function := range file.Functions {
+		if function.IsDefined() {
+			curResults := FunctionOutOfSsaForm(function)
+			results.Extend(&curResults)
+		}
+	}
+
+	return results
+}
+
+func TransformFileOutOfSsaForm(
+	data *transform.TargetData,
+) (*transform.TargetData, core.ResultList) {
+	results := FileOutOfSsaForm(data.Code)
+	return data, results
+}
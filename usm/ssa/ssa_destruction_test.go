@@ -0,0 +1,179 @@
+package usmssa_test
+
+import (
+	"testing"
+
+	"alon.kr/x/usm/gen"
+	"alon.kr/x/usm/internal/testutil"
+	usmmanagers "alon.kr/x/usm/usm/managers"
+	usmssa "alon.kr/x/usm/usm/ssa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateFunction(t *testing.T, source string) *gen.FunctionInfo {
+	t.Helper()
+
+	info := testutil.MustGenerateFile(t, usmmanagers.NewGenerationContext(), source)
+	function := info.GetFunction("@f")
+	require.NotNil(t, function)
+	return function
+}
+
+func blockInstructions(block *gen.BasicBlockInfo) []string {
+	instructions := []string{}
+	for _, instruction := range block.Instructions {
+		instructions = append(instructions, instruction.String())
+	}
+	return instructions
+}
+
+func TestSsaRoundTrip(t *testing.T) {
+	sources := []string{
+		`func $32 @f $32 %n {
+.entry
+	$32 %sum = $32 #0
+.loop
+	$32 %sum = add %sum %n
+	$32 %n = sub %n $32 #1
+	jnz %n .loop
+.exit
+	ret %sum
+}
+`,
+		`func $32 @f $32 %a {
+.entry
+	jz %a .else
+.then
+	$32 %x = $32 #1
+	j .end
+.else
+	$32 %x = $32 #2
+.end
+	ret %x
+}
+`,
+		`func $32 @f $32 %a $32 %b $32 %n {
+.entry
+	jz %n .end
+.loop
+	$32 %t = %a
+	$32 %a = %b
+	$32 %b = %t
+	$32 %n = sub %n $32 #1
+	jnz %n .loop
+.end
+	ret %a
+}
+`,
+	}
+
+	for _, source := range sources {
+		function := generateFunction(t, source)
+
+		results := usmssa.FunctionToSsaForm(function)
+		require.True(t, results.IsEmpty())
+
+		results = usmssa.FunctionOutOfSsaForm(function)
+		require.True(t, results.IsEmpty())
+
+		assert.Equal(t, source, function.String())
+
+		results = function.Validate()
+		assert.True(t, results.IsEmpty())
+	}
+}
+
+func TestOutOfSsaSwap(t *testing.T) {
+	source := `func $32 @f $32 %a $32 %b $32 %n {
+.entry
+	jz %n .end
+.loop
+	$32 %x = phi .entry %a .loop %y
+	$32 %y = phi .entry %b .loop %x
+	$32 %i = phi .entry %n .loop %j
+	$32 %j = sub %i $32 #1
+	jnz %j .loop
+.end
+	ret %x
+}`
+
+	function := generateFunction(t, source)
+	results := usmssa.FunctionOutOfSsaForm(function)
+	require.True(t, results.IsEmpty())
+
+	blocks := function.CollectBasicBlocks()
+	require.Len(t, blocks, 5)
+
+	// The edges from the entry block and from the loop block to the loop block
+	// are critical, and thus both are split.
+	assert.Equal(t, []string{
+		"$32 %x = %a",
+		"$32 %y = %b",
+		"$32 %i = %n",
+	}, blockInstructions(blocks[1]))
+
+	loopBack := blocks[4]
+	assert.Equal(t, []string{
+		"$32 %j = sub %i $32 #1",
+		"jnz %j " + loopBack.Label.Name,
+	}, blockInstructions(blocks[2]))
+
+	assert.Equal(t, []string{
+		"$32 %i = %j",
+		"$32 %x_tmp = %x",
+		"$32 %x = %y",
+		"$32 %y = %x_tmp",
+		"j .loop",
+	}, blockInstructions(loopBack))
+
+	assert.Equal(t, []*gen.BasicBlockInfo{blocks[2]}, loopBack.BackwardEdges)
+	assert.Equal(t, []*gen.BasicBlockInfo{blocks[2]}, loopBack.ForwardEdges)
+
+	results = function.Validate()
+	assert.True(t, results.IsEmpty())
+}
+
+func TestOutOfSsaExplicitJumpToNextBlock(t *testing.T) {
+	source := `func $32 @f $32 %v {
+.entry
+	$32 %a = $32 #1
+	switch %v .next $32 #0 .other
+.next
+	$32 %x = phi .entry %a .other %b
+	ret %x
+.other
+	$32 %b = $32 #2
+	j .next
+}`
+
+	function := generateFunction(t, source)
+	results := usmssa.FunctionOutOfSsaForm(function)
+	require.True(t, results.IsEmpty())
+
+	blocks := function.CollectBasicBlocks()
+	require.Len(t, blocks, 4)
+
+	// The edge from the entry block to the next block is critical, but the
+	// switch never falls through, so the copies can't be placed between the
+	// two blocks. Instead, the switch is redirected to a new block.
+	split := blocks[3]
+	assert.Equal(t, []string{
+		"$32 %a = $32 #1",
+		"switch %v " + split.Label.Name + " $32 #0 .other",
+	}, blockInstructions(blocks[0]))
+
+	assert.Equal(t, []string{
+		"$32 %x = %a",
+		"j .next",
+	}, blockInstructions(split))
+
+	assert.Equal(t, []string{
+		"$32 %b = $32 #2",
+		"$32 %x = %b",
+		"j .next",
+	}, blockInstructions(blocks[2]))
+
+	results = function.Validate()
+	assert.True(t, results.IsEmpty())
+}